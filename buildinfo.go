@@ -0,0 +1,74 @@
+package harelog
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Payload keys of the build information added by WithBuildInfo.
+const (
+	buildVersionKey  = "version"
+	buildRevisionKey = "revision"
+)
+
+// Payload keys of the service identity added by WithServiceContext.
+// The version key is shared with WithBuildInfo.
+const (
+	serviceNameKey = "service"
+	serviceEnvKey  = "env"
+)
+
+// buildInfo returns the build information fields of the running binary.
+// The build information does not change, so it is read only once.
+var buildInfo = sync.OnceValue(func() map[string]string {
+	return buildInfoFields(debug.ReadBuildInfo())
+})
+
+// buildInfoFields returns the main module version and the VCS revision as payload
+// fields. Values that are not embedded in the binary, such as when it was built
+// without module support or outside of a VCS checkout, are omitted.
+func buildInfoFields(info *debug.BuildInfo, ok bool) map[string]string {
+	fields := make(map[string]string, 2)
+
+	if !ok || info == nil {
+		return fields
+	}
+
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		fields[buildVersionKey] = v
+	}
+
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && s.Value != "" {
+			fields[buildRevisionKey] = s.Value
+		}
+	}
+
+	return fields
+}
+
+// applyBuildInfo adds the build information fields to the payload, or removes
+// them if enabled is false. Fields with the same keys set to other values are kept.
+func (l *Logger) applyBuildInfo(enabled bool) {
+	for k, v := range buildInfo() {
+		if enabled {
+			l.payload[k] = v
+		} else if s, ok := l.payload[k].(string); ok && s == v {
+			delete(l.payload, k)
+		}
+	}
+}
+
+// applyServiceContext adds the service identity fields to the payload.
+// Empty values are not added.
+func (l *Logger) applyServiceContext(service, version, env string) {
+	for _, f := range [...]struct{ key, value string }{
+		{serviceNameKey, service},
+		{buildVersionKey, version},
+		{serviceEnvKey, env},
+	} {
+		if f.value != "" {
+			l.payload[f.key] = f.value
+		}
+	}
+}
@@ -0,0 +1,665 @@
+package harelog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/taknb2nch/harelog"
+)
+
+// appLogger is an example of a library-provided wrapper around harelog.
+type appLogger struct {
+	l *harelog.Logger
+}
+
+// Info is the wrapper's own logging function; the reported source location
+// should be the caller of Info, not Info itself.
+func (a *appLogger) Info(msg string, kvs ...interface{}) {
+	a.l.Infow(msg, kvs...)
+}
+
+// Infof delegates through the ...f method, which internally calls the ...fCtx variant.
+func (a *appLogger) Infof(format string, v ...interface{}) {
+	a.l.Infof(format, v...)
+}
+
+// TestWithCallerSkip verifies that WithCallerSkip attributes the source location
+// to the caller of a wrapper function.
+func TestWithCallerSkip(t *testing.T) {
+	t.Parallel()
+
+	sourceLine := func(t *testing.T, buf *bytes.Buffer) (string, int) {
+		t.Helper()
+
+		var entry struct {
+			SourceLocation *harelog.SourceLocation `json:"logging.googleapis.com/sourceLocation"`
+		}
+
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("failed to unmarshal log output: %v", err)
+		}
+
+		if entry.SourceLocation == nil {
+			t.Fatal("expected sourceLocation to be present")
+		}
+
+		return entry.SourceLocation.Function, entry.SourceLocation.Line
+	}
+
+	t.Run("Without skip reports the wrapper", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		a := &appLogger{l: harelog.New(
+			harelog.WithOutput(&buf),
+			harelog.WithAutoSource(harelog.SourceLocationModeAlways),
+		)}
+
+		a.Info("hello")
+
+		function, _ := sourceLine(t, &buf)
+		if !strings.HasSuffix(function, "(*appLogger).Info") {
+			t.Errorf("expected wrapper function, got %q", function)
+		}
+	})
+
+	t.Run("With skip reports the wrapper's caller", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		a := &appLogger{l: harelog.New(
+			harelog.WithOutput(&buf),
+			harelog.WithAutoSource(harelog.SourceLocationModeAlways),
+			harelog.WithCallerSkip(1),
+		)}
+
+		_, _, wantLine, _ := runtime.Caller(0)
+		a.Info("hello")
+
+		function, line := sourceLine(t, &buf)
+		if !strings.Contains(function, "TestWithCallerSkip") {
+			t.Errorf("expected test function, got %q", function)
+		}
+		if line != wantLine+1 {
+			t.Errorf("unexpected line: got %d, want %d", line, wantLine+1)
+		}
+
+		buf.Reset()
+
+		_, _, wantLine, _ = runtime.Caller(0)
+		a.Infof("hello %s", "world")
+
+		_, line = sourceLine(t, &buf)
+		if line != wantLine+1 {
+			t.Errorf("unexpected line via ...f delegation: got %d, want %d", line, wantLine+1)
+		}
+	})
+}
+
+// TestStackTrace verifies that captured stack traces never contain harelog's own
+// frames and that the filter options are applied.
+func TestStackTrace(t *testing.T) {
+	t.Parallel()
+
+	stackTrace := func(t *testing.T, buf *bytes.Buffer) string {
+		t.Helper()
+
+		var entry map[string]interface{}
+
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("failed to unmarshal log output: %v", err)
+		}
+
+		s, _ := entry["stack_trace"].(string)
+
+		return s
+	}
+
+	t.Run("Harelog frames are omitted", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		a := &appLogger{l: harelog.New(
+			harelog.WithOutput(&buf),
+			harelog.WithStackTrace(harelog.LogLevelInfo),
+		)}
+
+		a.Info("hello")
+
+		st := stackTrace(t, &buf)
+		if !strings.Contains(st, "TestStackTrace") {
+			t.Errorf("expected the test function in the stack trace, got:\n%s", st)
+		}
+		if strings.Contains(st, "github.com/taknb2nch/harelog.") {
+			t.Errorf("expected no harelog frames in the stack trace, got:\n%s", st)
+		}
+	})
+
+	t.Run("Below the configured level", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		l := harelog.New(
+			harelog.WithOutput(&buf),
+			harelog.WithStackTrace(harelog.LogLevelError),
+		)
+
+		l.Warnf("warn")
+
+		if st := stackTrace(t, &buf); st != "" {
+			t.Errorf("expected no stack trace, got:\n%s", st)
+		}
+	})
+
+	t.Run("Minified and filtered", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		l := harelog.New(
+			harelog.WithOutput(&buf),
+			harelog.WithStackTrace(harelog.LogLevelError),
+			harelog.WithMinifiedStacktrace(),
+			harelog.WithStackTraceFilter(func(frame runtime.Frame) bool {
+				return !strings.HasPrefix(frame.Function, "testing.")
+			}),
+		)
+
+		l.Errorf("boom")
+
+		st := stackTrace(t, &buf)
+		if !strings.Contains(st, "TestStackTrace") {
+			t.Errorf("expected the test function in the stack trace, got:\n%s", st)
+		}
+		if strings.Contains(st, "testing.") || strings.Contains(st, "runtime.goexit") {
+			t.Errorf("expected filtered frames to be omitted, got:\n%s", st)
+		}
+	})
+}
+
+// grpcLoggerV2 mirrors gRPC's grpclog.LoggerV2 interface, which harelog.GRPCLogger
+// implements without importing gRPC.
+type grpcLoggerV2 interface {
+	Info(args ...interface{})
+	Infoln(args ...interface{})
+	Infof(format string, args ...interface{})
+	Warning(args ...interface{})
+	Warningln(args ...interface{})
+	Warningf(format string, args ...interface{})
+	Error(args ...interface{})
+	Errorln(args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatal(args ...interface{})
+	Fatalln(args ...interface{})
+	Fatalf(format string, args ...interface{})
+	V(l int) bool
+}
+
+var _ grpcLoggerV2 = (*harelog.GRPCLogger)(nil)
+
+// TestGRPCLogger verifies the level mapping of the gRPC adapter and that the
+// source location points at the adapter's caller.
+func TestGRPCLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	l := harelog.New(
+		harelog.WithOutput(&buf),
+		harelog.WithAutoSource(harelog.SourceLocationModeAlways),
+	)
+
+	var g grpcLoggerV2 = harelog.NewGRPCLogger(l, 2)
+
+	tests := []struct {
+		log      func()
+		severity string
+		message  string
+	}{
+		{func() { g.Info("info", 1) }, "INFO", "info 1"},
+		{func() { g.Warningln("warn", 2) }, "WARN", "warn 2"},
+		{func() { g.Errorf("error %d", 3) }, "ERROR", "error 3"},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+
+		tt.log()
+
+		var entry struct {
+			Severity       string                  `json:"severity"`
+			Message        string                  `json:"message"`
+			SourceLocation *harelog.SourceLocation `json:"logging.googleapis.com/sourceLocation"`
+		}
+
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("failed to unmarshal log output: %v", err)
+		}
+
+		if entry.Severity != tt.severity || strings.TrimSpace(entry.Message) != tt.message {
+			t.Errorf("got %s %q, want %s %q", entry.Severity, entry.Message, tt.severity, tt.message)
+		}
+		if entry.SourceLocation == nil || !strings.Contains(entry.SourceLocation.Function, "TestGRPCLogger") {
+			t.Errorf("expected the source location to point at the caller, got %+v", entry.SourceLocation)
+		}
+	}
+
+	if !g.V(2) || g.V(3) {
+		t.Error("expected V to report levels up to the verbosity")
+	}
+}
+
+// logFromFunction logs from a plain function call site.
+func logFromFunction(l *harelog.Logger) {
+	l.Infof("from function")
+}
+
+// logFromMethod logs from a method call site.
+func (a *appLogger) logFromMethod() {
+	a.l.Infof("from method")
+}
+
+// TestWithSourceFunctionMode verifies how the function name of the source location
+// is reported for plain-function and method call sites.
+func TestWithSourceFunctionMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		mode harelog.SourceFunctionMode
+		log  func(l *harelog.Logger)
+		want string
+	}{
+		{"Full Function", harelog.SourceFunctionModeFull, logFromFunction, "github.com/taknb2nch/harelog_test.logFromFunction"},
+		{"Full Method", harelog.SourceFunctionModeFull, func(l *harelog.Logger) { (&appLogger{l: l}).logFromMethod() }, "github.com/taknb2nch/harelog_test.(*appLogger).logFromMethod"},
+		{"Short Function", harelog.SourceFunctionModeShort, logFromFunction, "harelog_test.logFromFunction"},
+		{"Short Method", harelog.SourceFunctionModeShort, func(l *harelog.Logger) { (&appLogger{l: l}).logFromMethod() }, "harelog_test.(*appLogger).logFromMethod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			tt.log(harelog.New(
+				harelog.WithOutput(&buf),
+				harelog.WithAutoSource(harelog.SourceLocationModeAlways),
+				harelog.WithSourceFunctionMode(tt.mode),
+			))
+
+			var entry struct {
+				SourceLocation *harelog.SourceLocation `json:"logging.googleapis.com/sourceLocation"`
+			}
+
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("failed to unmarshal log output: %v", err)
+			}
+
+			if entry.SourceLocation == nil || entry.SourceLocation.Function != tt.want {
+				t.Errorf("unexpected source location: %+v, want function %q", entry.SourceLocation, tt.want)
+			}
+		})
+	}
+
+	t.Run("Invalid Mode Panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for an invalid mode")
+			}
+		}()
+
+		harelog.WithSourceFunctionMode(harelog.SourceFunctionMode(99))
+	})
+}
+
+// panicConfig is a struct panic value.
+type panicConfig struct {
+	Name  string
+	Retry int
+}
+
+// recoverPanicEntry panics with v and returns the PanicEntry built from the recovered value.
+func recoverPanicEntry(v interface{}) (entry *harelog.LogEntry) {
+	defer func() {
+		entry = harelog.PanicEntry(recover())
+	}()
+
+	panickingFunction(v)
+
+	return nil
+}
+
+// panickingFunction is the expected panic site of the tests.
+func panickingFunction(v interface{}) {
+	panic(v)
+}
+
+// TestPanicEntry verifies the structured form of recovered panic values.
+func TestPanicEntry(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name      string
+		value     interface{}
+		wantValue string
+		wantType  string
+	}{
+		{"error", errors.New("connection reset"), "connection reset", "*errors.errorString"},
+		{"string", "test panic", "test panic", "string"},
+		{"struct", panicConfig{Name: "db", Retry: 3}, "{Name:db Retry:3}", "harelog_test.panicConfig"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			e := recoverPanicEntry(tt.value)
+
+			if e.Severity != harelog.LogLevelError {
+				t.Errorf("expected severity ERROR, got %s", e.Severity)
+			}
+			if got := e.Payload["panic.value"]; got != tt.wantValue {
+				t.Errorf("panic.value = %q, want %q", got, tt.wantValue)
+			}
+			if got := e.Payload["panic.type"]; got != tt.wantType {
+				t.Errorf("panic.type = %q, want %q", got, tt.wantType)
+			}
+			if st, _ := e.Payload["stack_trace"].(string); !strings.Contains(st, "harelog_test.panickingFunction") {
+				t.Errorf("expected the panicking function in the stack trace, got:\n%s", st)
+			}
+			if e.SourceLocation == nil || !strings.HasSuffix(e.SourceLocation.Function, ".panickingFunction") {
+				t.Errorf("expected the source location at the panic site, got %+v", e.SourceLocation)
+			}
+		})
+	}
+}
+
+// TestRecoverAndLog verifies that RecoverAndLog stops the panic and logs it.
+func TestRecoverAndLog(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	l := harelog.New(harelog.WithOutput(&buf), harelog.WithAutoSource(harelog.SourceLocationModeAlways))
+
+	func() {
+		defer l.RecoverAndLog()
+
+		panickingFunction(errors.New("boom"))
+	}()
+
+	var entry struct {
+		Severity       string                  `json:"severity"`
+		Message        string                  `json:"message"`
+		PanicValue     string                  `json:"panic.value"`
+		PanicType      string                  `json:"panic.type"`
+		StackTrace     string                  `json:"stack_trace"`
+		SourceLocation *harelog.SourceLocation `json:"logging.googleapis.com/sourceLocation"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to unmarshal log output %q: %v", buf.String(), err)
+	}
+
+	if entry.Severity != "ERROR" || entry.PanicValue != "boom" || entry.PanicType != "*errors.errorString" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if !strings.Contains(entry.StackTrace, "harelog_test.panickingFunction") {
+		t.Errorf("expected the panicking function in the stack trace, got:\n%s", entry.StackTrace)
+	}
+	if entry.SourceLocation == nil || !strings.HasSuffix(entry.SourceLocation.Function, ".panickingFunction") {
+		t.Errorf("expected the source location at the panic site, got %+v", entry.SourceLocation)
+	}
+}
+
+// TestPackageLevelOverrides verifies that WithPackageLevelOverrides applies the level
+// set with SetPackageLevel for the package of the caller.
+func TestPackageLevelOverrides(t *testing.T) {
+	t.Cleanup(harelog.ResetPackageLevels)
+
+	var buf bytes.Buffer
+
+	logger := harelog.New(harelog.WithOutput(&buf), harelog.WithPackageLevelOverrides(true))
+
+	// The calls of this test are made from the harelog_test package; with a caller
+	// skip of 1, the caller of the test function, in the testing package, is used.
+	thisPackage := "github.com/taknb2nch/harelog_test"
+	testingCaller := logger.WithCallerSkip(1)
+
+	harelog.SetPackageLevel("github.com/taknb2nch/other", harelog.LogLevelDebug)
+
+	if logger.IsDebugEnabled() {
+		t.Error("expected the level of another package not to apply")
+	}
+
+	harelog.SetPackageLevel(thisPackage, harelog.LogLevelDebug)
+
+	if !logger.IsDebugEnabled() || testingCaller.IsDebugEnabled() {
+		t.Error("expected Debug to be enabled for this package only")
+	}
+
+	logger.Debugf("debug from this package")
+
+	if !strings.Contains(buf.String(), "debug from this package") {
+		t.Errorf("expected the Debug entry to be written, got %q", buf.String())
+	}
+
+	harelog.SetPackageLevel("testing", harelog.LogLevelError)
+
+	if testingCaller.IsWarnEnabled() || !testingCaller.IsErrorEnabled() {
+		t.Error("expected the level of the testing package to replace the logger's level")
+	}
+
+	if logger.WithPackageLevelOverrides(false).IsDebugEnabled() {
+		t.Error("expected the package levels not to apply without WithPackageLevelOverrides")
+	}
+
+	harelog.ResetPackageLevels()
+
+	if logger.IsDebugEnabled() || !logger.IsInfoEnabled() {
+		t.Error("expected the logger's level after ResetPackageLevels")
+	}
+}
+
+// TestWithSourceSnippet verifies that WithSourceSnippet adds the source line of the
+// log call, and nothing without a source location.
+func TestWithSourceSnippet(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := harelog.New(
+		harelog.WithOutput(&buf),
+		harelog.WithAutoSource(harelog.SourceLocationModeAlways),
+		harelog.WithSourceSnippet(true),
+	)
+
+	logger.Infow("snippet test", "id", 42) // the line read back
+
+	var entry struct {
+		SourceSnippet string `json:"sourceSnippet"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to unmarshal log output: %v", err)
+	}
+
+	if want := `logger.Infow("snippet test", "id", 42) // the line read back`; entry.SourceSnippet != want {
+		t.Errorf("expected snippet %q, got %q", want, entry.SourceSnippet)
+	}
+
+	buf.Reset()
+	logger.WithAutoSource(harelog.SourceLocationModeNever).Infof("no source")
+
+	if strings.Contains(buf.String(), "sourceSnippet") {
+		t.Errorf("expected no snippet without a source location, got %s", buf.String())
+	}
+}
+
+// stackError mimics an error of github.com/pkg/errors, whose StackTrace method
+// returns a slice of uintptr-based frames of a package-local type.
+type stackError struct {
+	msg   string
+	stack []stackFrame
+}
+
+type stackFrame uintptr
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) StackTrace() []stackFrame { return e.stack }
+
+// newStackError returns a stackError recording the stack of its caller.
+func newStackError(msg string) error {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+
+	stack := make([]stackFrame, n)
+	for i, pc := range pcs[:n] {
+		stack[i] = stackFrame(pc)
+	}
+
+	return &stackError{msg: msg, stack: stack}
+}
+
+// failingOperation returns an error recorded with its stack.
+func failingOperation() error {
+	return newStackError("operation failed")
+}
+
+// TestWithErrorStackExtraction verifies that WithErrorStackExtraction logs the stack
+// recorded by the logged error, also when it is wrapped.
+func TestWithErrorStackExtraction(t *testing.T) {
+	t.Parallel()
+
+	err := fmt.Errorf("handling request: %w", failingOperation())
+
+	testCases := []struct {
+		name      string
+		logger    func(out *bytes.Buffer) *harelog.Logger
+		log       func(l *harelog.Logger)
+		wantStack bool
+	}{
+		{
+			name: "enabled",
+			logger: func(out *bytes.Buffer) *harelog.Logger {
+				return harelog.New(harelog.WithOutput(out), harelog.WithErrorStackExtraction(true))
+			},
+			log:       func(l *harelog.Logger) { l.Errorw("request failed", "error", err) },
+			wantStack: true,
+		},
+		{
+			name: "enabled with a typed field",
+			logger: func(out *bytes.Buffer) *harelog.Logger {
+				return harelog.New(harelog.WithOutput(out)).WithErrorStackExtraction(true)
+			},
+			log:       func(l *harelog.Logger) { l.Errorfields("request failed", harelog.Err(err)) },
+			wantStack: true,
+		},
+		{
+			name: "disabled",
+			logger: func(out *bytes.Buffer) *harelog.Logger {
+				return harelog.New(harelog.WithOutput(out))
+			},
+			log: func(l *harelog.Logger) { l.Errorw("request failed", "error", err) },
+		},
+		{
+			name: "error without a stack",
+			logger: func(out *bytes.Buffer) *harelog.Logger {
+				return harelog.New(harelog.WithOutput(out), harelog.WithErrorStackExtraction(true))
+			},
+			log: func(l *harelog.Logger) { l.Errorw("request failed", "error", errors.New("plain")) },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			tc.log(tc.logger(&buf))
+
+			var entry struct {
+				Error      string `json:"error"`
+				StackTrace string `json:"stack_trace"`
+			}
+
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("failed to unmarshal log output: %v", err)
+			}
+
+			if !tc.wantStack {
+				if entry.StackTrace != "" {
+					t.Errorf("expected no stack trace, got %q", entry.StackTrace)
+				}
+
+				return
+			}
+
+			if entry.Error != "handling request: operation failed" {
+				t.Errorf("expected the error message, got %q", entry.Error)
+			}
+
+			for _, want := range []string{"harelog_test.failingOperation()", "caller_test.go:"} {
+				if !strings.Contains(entry.StackTrace, want) {
+					t.Errorf("expected stack trace to contain %q, got %q", want, entry.StackTrace)
+				}
+			}
+
+			if strings.Contains(entry.StackTrace, "harelog_test.newStackError") {
+				t.Errorf("expected the stack to start at the caller of newStackError, got %q", entry.StackTrace)
+			}
+		})
+	}
+}
+
+// TestWithErrorStackExtraction_Join verifies that WithErrorStackExtraction finds the
+// stack of an error joined with others, by errors.Join or by several %w verbs.
+func TestWithErrorStackExtraction_Join(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		err  error
+	}{
+		{"errors.Join", errors.Join(errors.New("timeout"), failingOperation())},
+		{"several %w", fmt.Errorf("handling request: %w, %w", errors.New("timeout"), failingOperation())},
+		{"wrapped join", fmt.Errorf("handling request: %w", errors.Join(errors.New("timeout"), failingOperation()))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			logger := harelog.New(harelog.WithOutput(&buf), harelog.WithErrorStackExtraction(true))
+			logger.Errorw("request failed", "error", tc.err)
+
+			var entry struct {
+				StackTrace string `json:"stack_trace"`
+			}
+
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("failed to unmarshal log output: %v", err)
+			}
+
+			if !strings.Contains(entry.StackTrace, "harelog_test.failingOperation()") {
+				t.Errorf("expected the stack of the joined error, got %q", entry.StackTrace)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package harelog
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// concurrentWriterShardSize is the number of records buffered per shard.
+const concurrentWriterShardSize = 256
+
+// concurrentWriterClosed is set in the sequence counter when the writer is closed,
+// so that the number of records to drain and the closing are read in one atomic step.
+const concurrentWriterClosed = 1 << 63
+
+// pendingRecord is a formatted record waiting to be written.
+type pendingRecord struct {
+	seq    uint64
+	out    io.Writer
+	record []byte
+}
+
+// concurrentWriter writes the records of a logger from a single goroutine (see
+// WithConcurrentWriter). Callers take a sequence number and enqueue the record to
+// the shard it selects, instead of waiting for a lock held during formatting and I/O.
+// The writer restores the sequence order, so records are written in the order their
+// sequence numbers were taken.
+type concurrentWriter struct {
+	seq    atomic.Uint64
+	shards []chan pendingRecord
+
+	mu        sync.Mutex // serializes writes after close with the draining writer
+	final     uint64     // number of records to drain; set before closing is closed
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{} // closed when the writer goroutine exits
+}
+
+// newConcurrentWriter creates a concurrentWriter with the given number of shards
+// and starts its writer goroutine.
+func newConcurrentWriter(shards int) *concurrentWriter {
+	w := &concurrentWriter{
+		shards:  make([]chan pendingRecord, shards),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	for i := range w.shards {
+		w.shards[i] = make(chan pendingRecord, concurrentWriterShardSize)
+	}
+
+	go w.run()
+
+	return w
+}
+
+// write enqueues a record to be written to out. It blocks only when the shard is full.
+// After close, the record is written synchronously.
+func (w *concurrentWriter) write(out io.Writer, record []byte) {
+	seq := w.seq.Add(1) - 1
+
+	if seq&concurrentWriterClosed != 0 {
+		w.mu.Lock()
+		out.Write(record)
+		w.mu.Unlock()
+
+		return
+	}
+
+	w.shards[seq%uint64(len(w.shards))] <- pendingRecord{seq: seq, out: out, record: record}
+}
+
+// run writes the records in sequence order until the writer is closed and drained.
+func (w *concurrentWriter) run() {
+	defer close(w.done)
+
+	n := uint64(len(w.shards))
+	closing := w.closing
+	final := uint64(concurrentWriterClosed)
+
+	// Records of one shard may arrive out of order when a caller is preempted
+	// between taking its sequence number and enqueuing; they wait here.
+	pending := make(map[uint64]pendingRecord)
+
+	for next := uint64(0); next < final; {
+		r, ok := pending[next]
+		if ok {
+			delete(pending, next)
+		} else {
+			select {
+			case r = <-w.shards[next%n]:
+				if r.seq != next {
+					pending[r.seq] = r
+
+					continue
+				}
+			case <-closing:
+				final = w.final
+				closing = nil
+
+				continue
+			}
+		}
+
+		w.mu.Lock()
+		r.out.Write(r.record)
+		w.mu.Unlock()
+
+		next++
+	}
+}
+
+// close stops the writer after the records enqueued so far are written.
+// It is safe to call multiple times.
+func (w *concurrentWriter) close() {
+	w.closeOnce.Do(func() {
+		w.final = w.seq.Or(concurrentWriterClosed)
+		close(w.closing)
+	})
+
+	<-w.done
+}
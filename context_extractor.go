@@ -0,0 +1,49 @@
+package harelog
+
+import "context"
+
+// ContextExtractor extracts log fields from a context.Context.
+// It returns key-value pairs in the same form as the ...w methods.
+//
+// Fields from extractors have the lowest precedence: they are overridden by
+// the logger's own fields (added via With) and by the fields of the log call.
+// Special keys such as "httpRequest" are handled as in the ...w methods;
+// the trace fields extracted via WithTraceContextKey are not affected.
+//
+// Extractors run when the entry is created, so the extracted fields, like the trace
+// fields, are part of the entry that the redactor and the hooks receive.
+type ContextExtractor func(ctx context.Context) []interface{}
+
+// BaggageExtractor returns a ContextExtractor that adds the members returned by
+// the members function as log fields, with each key prefixed by prefix (e.g. "baggage.").
+//
+// It is intended for OpenTelemetry baggage, while keeping harelog free of the
+// OpenTelemetry dependency:
+//
+//	harelog.BaggageExtractor("baggage.", func(ctx context.Context) map[string]string {
+//		m := make(map[string]string)
+//		for _, member := range baggage.FromContext(ctx).Members() {
+//			m[member.Key()] = member.Value()
+//		}
+//		return m
+//	})
+func BaggageExtractor(prefix string, members func(ctx context.Context) map[string]string) ContextExtractor {
+	if members == nil {
+		panic("harelog: nil members function provided to BaggageExtractor")
+	}
+
+	return func(ctx context.Context) []interface{} {
+		m := members(ctx)
+		if len(m) == 0 {
+			return nil
+		}
+
+		kvs := make([]interface{}, 0, len(m)*2)
+
+		for k, v := range m {
+			kvs = append(kvs, prefix+k, v)
+		}
+
+		return kvs
+	}
+}
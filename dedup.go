@@ -0,0 +1,172 @@
+package harelog
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+// DedupKey defines what WithDedup compares to detect identical consecutive entries.
+type DedupKey int
+
+const (
+	// DedupKeyOutput compares the formatted output, ignoring the timestamp.
+	DedupKeyOutput DedupKey = iota
+	// DedupKeyMessage compares only the severity, the message and the payload fields,
+	// so entries differing in, e.g., labels or trace are still collapsed.
+	DedupKeyMessage
+)
+
+// dedupRepeatedKey is the payload key holding the count of a dedup summary entry.
+const dedupRepeatedKey = "repeated"
+
+// validateDedupKey panics if the key is unknown.
+func validateDedupKey(key DedupKey) {
+	switch key {
+	case DedupKeyOutput, DedupKeyMessage:
+	default:
+		panic(fmt.Sprintf("harelog: invalid DedupKey provided: %d", key))
+	}
+}
+
+// deduper collapses identical consecutive records within a window.
+// It is shared by a logger and its clones, like the hook worker, and serializes
+// their writes while dedup is enabled.
+type deduper struct {
+	mu     sync.Mutex
+	window time.Duration
+
+	open   bool // a window is open for the last written record
+	key    string
+	count  int // records suppressed in the current window
+	level  LogLevel
+	logger *Logger // the logger that writes the summary
+	timer  *time.Timer
+	gen    uint64 // invalidates the timers of closed windows
+}
+
+// newDeduper creates a deduper with the given window.
+func newDeduper(window time.Duration) *deduper {
+	return &deduper{window: window}
+}
+
+// write writes the record of an entry with the given key and level to l's output,
+// unless it repeats the last record within the window.
+func (d *deduper) write(l *Logger, key string, level LogLevel, record []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.open && key == d.key {
+		d.count++
+
+		return
+	}
+
+	d.flushLocked()
+
+	d.open = true
+	d.key = key
+	d.level = level
+	d.logger = l
+
+	gen := d.gen
+	d.timer = time.AfterFunc(d.window, func() {
+		d.expire(gen)
+	})
+
+	l.writeRecord(level, record)
+}
+
+// expire closes the window started in generation gen, if it is still open.
+func (d *deduper) expire(gen uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if gen == d.gen {
+		d.flushLocked()
+	}
+}
+
+// flush closes the current window, writing the summary of suppressed records.
+func (d *deduper) flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.flushLocked()
+}
+
+// flushLocked is flush with d.mu held.
+func (d *deduper) flushLocked() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+
+	if d.open && d.count > 0 {
+		d.logger.writeDedupSummary(d.level, d.count)
+	}
+
+	d.open = false
+	d.key = ""
+	d.count = 0
+	d.logger = nil
+	d.gen++
+}
+
+// dedupKeyOf returns the key WithDedup compares for the entry.
+func (l *Logger) dedupKeyOf(e *LogEntry) string {
+	if l.dedupKey == DedupKeyMessage {
+		return fmt.Sprintf("%s\x00%s\x00%v", e.Severity, e.Message, e.Payload)
+	}
+
+	// Formatters may mask fields in place, so the key is formatted from a copy.
+	entry := *e
+	entry.Time = time.Time{}
+	entry.Labels = maps.Clone(e.Labels)
+	entry.Payload = maps.Clone(e.Payload)
+
+	out, err := l.formatter.Format(&entry)
+	if err != nil {
+		// The entry itself fails to format, so it is never written anyway.
+		return ""
+	}
+
+	return string(out)
+}
+
+// writeDedupSummary writes the entry reporting that the last record was repeated count times.
+func (l *Logger) writeDedupSummary(level LogLevel, count int) {
+	e := &LogEntry{
+		Severity: level,
+		Message:  fmt.Sprintf("last message repeated %d times", count),
+		Time:     time.Now(),
+		Payload:  map[string]interface{}{dedupRepeatedKey: count},
+	}
+
+	l.setTimeFormat(e)
+
+	out, err := l.formatter.Format(e)
+	if err != nil {
+		l.formatFailed(err, e)
+
+		return
+	}
+
+	l.writeRecord(level, l.record(level, out))
+}
+
+// undeduped returns the logger to dispatch a Fatal entry with. Fatal entries are
+// never suppressed: the pending summary is written first and the entry bypasses dedup.
+func (l *Logger) undeduped() *Logger {
+	if l.dedup == nil {
+		return l
+	}
+
+	l.dedup.flush()
+
+	newLogger := l.Clone()
+	newLogger.dedup = nil
+
+	return newLogger
+}
@@ -0,0 +1,305 @@
+package harelog
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	json "github.com/goccy/go-json"
+)
+
+// DiffValue holds two states of a value, such as a record before and after an
+// update, and is logged as the set of fields that changed between them.
+//
+// The JSON formatter writes it as an object keyed by field, e.g.
+// {"name":{"old":"alice","new":"bob"}}, while the text-based formatters write it
+// in a compact form, e.g. "name: alice -> bob; age: 3 -> 4".
+//
+// The changes are computed with reflection when the entry is formatted, so a
+// DiffValue passed at a disabled level costs only its construction.
+type DiffValue struct {
+	Before any
+	After  any
+}
+
+// Change is a field that differs between the two states of a DiffValue.
+// Old or New is nil if the field is absent from that state.
+type Change struct {
+	Field string
+	Old   any
+	New   any
+}
+
+// Diff returns a DiffValue recording the changes from before to after:
+//
+//	logger.Infow("user updated", "changes", harelog.Diff(oldUser, newUser))
+//
+// Structs (and pointers to them) are compared field by field and maps key by
+// key, descending into nested structs and maps with dotted field names such as
+// "address.city". Struct fields are named after their `json` tags, in declaration
+// order; fields tagged `log:"-"` are ignored and the values of fields tagged
+// `log:"mask"` are written as "[MASKED]". Map keys are sorted. Types implementing
+// json.Marshaler or encoding.TextMarshaler, such as time.Time, and all other
+// values are compared as a whole with reflect.DeepEqual.
+// A nil before or after records the creation or deletion of the value, and a
+// struct or map that refers back to itself is compared only once.
+func Diff(before, after any) DiffValue {
+	return DiffValue{Before: before, After: after}
+}
+
+// Changes returns the changed fields. A change of the whole value, when it is
+// neither a struct nor a map, has an empty Field.
+func (d DiffValue) Changes() []Change {
+	var changes []Change
+
+	diffValues("", reflect.ValueOf(d.Before), reflect.ValueOf(d.After), false, make(map[diffVisit]bool), &changes)
+
+	return changes
+}
+
+// String returns the compact form, e.g. "name: alice -> bob; age: 3 -> 4".
+func (d DiffValue) String() string {
+	var b strings.Builder
+
+	for i, c := range d.Changes() {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+
+		if c.Field != "" {
+			b.WriteString(c.Field)
+			b.WriteString(": ")
+		}
+
+		fmt.Fprint(&b, c.Old)
+		b.WriteString(" -> ")
+		fmt.Fprint(&b, c.New)
+	}
+
+	return b.String()
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d DiffValue) MarshalJSON() ([]byte, error) {
+	b := []byte{'{'}
+
+	for i, c := range d.Changes() {
+		v, err := json.Marshal(struct {
+			Old any `json:"old"`
+			New any `json:"new"`
+		}{c.Old, c.New})
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := json.Marshal(c.Field)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		b = append(b, k...)
+		b = append(b, ':')
+		b = append(b, v...)
+	}
+
+	return append(b, '}'), nil
+}
+
+// diffVisit is a pair of structs or maps being compared by diffValues, identified
+// by their addresses, which are zero for values that are absent or not addressable.
+type diffVisit struct {
+	before, after uintptr
+	typ           reflect.Type
+}
+
+// diffValues appends the changes between before and after under the field name
+// path to changes. An invalid value stands for an absent or nil one.
+//
+// visiting holds the pairs of structs and maps enclosing the current one, as in
+// reflect.DeepEqual, so that a cyclic value is not descended into again: the
+// changes within it are those already found at its first visit.
+func diffValues(path string, before, after reflect.Value, masked bool, visiting map[diffVisit]bool, changes *[]Change) {
+	before, after = indirectValue(before), indirectValue(after)
+
+	if !masked {
+		t := diffableType(before, after)
+		if t != nil {
+			v := diffVisit{valueAddr(before), valueAddr(after), t}
+			if v.before != 0 || v.after != 0 {
+				if visiting[v] {
+					return
+				}
+
+				visiting[v] = true
+				defer delete(visiting, v)
+			}
+		}
+
+		switch {
+		case t == nil:
+		case t.Kind() == reflect.Struct:
+			diffStructs(path, t, before, after, visiting, changes)
+
+			return
+		case t.Kind() == reflect.Map:
+			diffMaps(path, before, after, visiting, changes)
+
+			return
+		}
+	}
+
+	oldValue, newValue := valueInterface(before), valueInterface(after)
+	if reflect.DeepEqual(oldValue, newValue) {
+		return
+	}
+
+	if masked {
+		oldValue, newValue = maskedValueString, maskedValueString
+	}
+
+	*changes = append(*changes, Change{Field: path, Old: oldValue, New: newValue})
+}
+
+// diffStructs appends the changes between the fields of two structs of type t.
+func diffStructs(path string, t reflect.Type, before, after reflect.Value, visiting map[diffVisit]bool, changes *[]Change) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		jsonTag := sf.Tag.Get("json")
+		logTag := sf.Tag.Get(structTagKey)
+		if !sf.IsExported() || jsonTag == "-" || logTag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(jsonTag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+
+		diffValues(joinFieldPath(path, name), structField(before, i), structField(after, i), logTag == "mask", visiting, changes)
+	}
+}
+
+// diffMaps appends the changes between the entries of two maps, in key order.
+func diffMaps(path string, before, after reflect.Value, visiting map[diffVisit]bool, changes *[]Change) {
+	keys := make(map[string]reflect.Value)
+
+	for _, m := range []reflect.Value{before, after} {
+		if !m.IsValid() {
+			continue
+		}
+
+		for _, k := range m.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+	}
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		diffValues(joinFieldPath(path, name), mapIndex(before, keys[name]), mapIndex(after, keys[name]), false, visiting, changes)
+	}
+}
+
+// diffableType returns the type of the valid values among before and after if it is
+// a struct or map type compared field by field, or nil.
+func diffableType(before, after reflect.Value) reflect.Type {
+	var t reflect.Type
+
+	switch {
+	case before.IsValid() && after.IsValid():
+		if before.Type() != after.Type() {
+			return nil
+		}
+
+		t = before.Type()
+	case before.IsValid():
+		t = before.Type()
+	case after.IsValid():
+		t = after.Type()
+	default:
+		return nil
+	}
+
+	if (t.Kind() != reflect.Struct && t.Kind() != reflect.Map) ||
+		t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return nil
+	}
+
+	return t
+}
+
+// indirectValue dereferences pointers and interfaces, returning an invalid value for nil.
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+
+		v = v.Elem()
+	}
+
+	return v
+}
+
+// valueAddr returns the address of the struct or map v, or zero if v is invalid or
+// a struct that is not addressable.
+func valueAddr(v reflect.Value) uintptr {
+	switch {
+	case !v.IsValid():
+		return 0
+	case v.Kind() == reflect.Map:
+		return uintptr(v.UnsafePointer())
+	case v.CanAddr():
+		return v.UnsafeAddr()
+	default:
+		return 0
+	}
+}
+
+// structField returns the ith field of the struct v, or an invalid value if v is invalid.
+func structField(v reflect.Value, i int) reflect.Value {
+	if !v.IsValid() {
+		return reflect.Value{}
+	}
+
+	return v.Field(i)
+}
+
+// mapIndex returns the entry of the map v for key, or an invalid value if v is invalid
+// or has no such entry.
+func mapIndex(v, key reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return reflect.Value{}
+	}
+
+	return v.MapIndex(key)
+}
+
+// valueInterface returns the value held by v, or nil if v is invalid.
+func valueInterface(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+
+	return v.Interface()
+}
+
+// joinFieldPath appends name to the dotted field path.
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
@@ -0,0 +1,166 @@
+package harelog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var entryBuilderPool = sync.Pool{
+	New: func() any {
+		return &EntryBuilder{
+			kvs: make([]interface{}, 0, 16),
+		}
+	},
+}
+
+// EntryBuilder provides a fluent API for building a single log entry with typed fields.
+// It is obtained via (*Logger).Entry() and is dispatched by calling Msg or Msgf.
+//
+// An EntryBuilder must not be used after Msg or Msgf has been called,
+// as it is returned to an internal pool for reuse.
+type EntryBuilder struct {
+	logger *Logger
+	ctx    context.Context
+	level  LogLevel
+	kvs    []interface{}
+}
+
+// Entry returns a new EntryBuilder bound to the logger.
+// The default level of the entry is Info; use Level to change it.
+//
+//	logger.Entry().Level(harelog.LogLevelWarn).Str("user", "u-1").Int("retries", 3).Msg("retrying")
+func (l *Logger) Entry() *EntryBuilder {
+	b := entryBuilderPool.Get().(*EntryBuilder)
+
+	b.logger = l
+	b.ctx = l.boundContext()
+	b.level = LogLevelInfo
+
+	return b
+}
+
+// Level sets the severity of the entry.
+// It panics if the level is not a valid log level.
+func (b *EntryBuilder) Level(level LogLevel) *EntryBuilder {
+	if _, ok := levelMap[level]; !ok || level == LogLevelOff || level == LogLevelAll {
+		panic(fmt.Sprintf("harelog: invalid log level provided to (*EntryBuilder).Level: %q", level))
+	}
+
+	b.level = level
+
+	return b
+}
+
+// Ctx sets the context.Context from which trace information is extracted.
+func (b *EntryBuilder) Ctx(ctx context.Context) *EntryBuilder {
+	if ctx != nil {
+		b.ctx = ctx
+	}
+
+	return b
+}
+
+// Str adds a string field to the entry.
+func (b *EntryBuilder) Str(key, value string) *EntryBuilder {
+	b.kvs = append(b.kvs, key, value)
+
+	return b
+}
+
+// Int adds an int field to the entry.
+func (b *EntryBuilder) Int(key string, value int) *EntryBuilder {
+	b.kvs = append(b.kvs, key, value)
+
+	return b
+}
+
+// Int64 adds an int64 field to the entry.
+func (b *EntryBuilder) Int64(key string, value int64) *EntryBuilder {
+	b.kvs = append(b.kvs, key, value)
+
+	return b
+}
+
+// Float64 adds a float64 field to the entry.
+func (b *EntryBuilder) Float64(key string, value float64) *EntryBuilder {
+	b.kvs = append(b.kvs, key, value)
+
+	return b
+}
+
+// Bool adds a bool field to the entry.
+func (b *EntryBuilder) Bool(key string, value bool) *EntryBuilder {
+	b.kvs = append(b.kvs, key, value)
+
+	return b
+}
+
+// Dur adds a time.Duration field to the entry.
+func (b *EntryBuilder) Dur(key string, value time.Duration) *EntryBuilder {
+	b.kvs = append(b.kvs, key, value)
+
+	return b
+}
+
+// Time adds a time.Time field to the entry.
+func (b *EntryBuilder) Time(key string, value time.Time) *EntryBuilder {
+	b.kvs = append(b.kvs, key, value)
+
+	return b
+}
+
+// Metric adds a metric field to the entry, keyed by name. See MetricValue.
+func (b *EntryBuilder) Metric(name string, value float64, unit string) *EntryBuilder {
+	b.kvs = append(b.kvs, Metric(name, value, unit))
+
+	return b
+}
+
+// Err adds the error under the special "error" key. A nil error is ignored.
+func (b *EntryBuilder) Err(err error) *EntryBuilder {
+	if err != nil {
+		b.kvs = append(b.kvs, "error", err)
+	}
+
+	return b
+}
+
+// Any adds a field of arbitrary type to the entry.
+// Special keys such as "httpRequest" and "sourceLocation" are handled as in the ...w methods.
+func (b *EntryBuilder) Any(key string, value interface{}) *EntryBuilder {
+	b.kvs = append(b.kvs, key, value)
+
+	return b
+}
+
+// Msg dispatches the entry with the given message and releases the builder.
+func (b *EntryBuilder) Msg(msg string) {
+	if b.logger.isLevelEnabledCtx(b.ctx, b.level) {
+		b.logger.dispatch(b.ctx, b.level, msg, b.kvs...)
+	}
+
+	b.release()
+}
+
+// Msgf dispatches the entry with a formatted message and releases the builder.
+func (b *EntryBuilder) Msgf(format string, v ...interface{}) {
+	if b.logger.isLevelEnabledCtx(b.ctx, b.level) {
+		b.logger.dispatchf(b.ctx, b.level, format, v, b.kvs...)
+	}
+
+	b.release()
+}
+
+// release resets the builder and returns it to the pool.
+func (b *EntryBuilder) release() {
+	clear(b.kvs)
+
+	b.kvs = b.kvs[:0]
+	b.logger = nil
+	b.ctx = nil
+	b.level = ""
+
+	entryBuilderPool.Put(b)
+}
@@ -0,0 +1,64 @@
+package harelog
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// ErrorClassifier chooses the level at which LogError logs an error.
+type ErrorClassifier func(err error) LogLevel
+
+// SeverityError is implemented by errors that know the level they should be logged at.
+// The default classifier uses it when found in the error chain.
+type SeverityError interface {
+	error
+	Severity() LogLevel
+}
+
+// defaultErrorClassifier returns the level of the first SeverityError in the chain,
+// or LogLevelError otherwise.
+func defaultErrorClassifier(err error) LogLevel {
+	var se SeverityError
+	if errors.As(err, &se) {
+		return se.Severity()
+	}
+
+	return LogLevelError
+}
+
+// LogError logs a message with the error under the "error" key, at a level chosen
+// by the logger's ErrorClassifier (see WithErrorClassifier). This lets a helper log
+// retryable errors at Warn and others at Error without branching on the level.
+// If the classifier returns a level that cannot be logged at, LogLevelError is used.
+//
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) LogError(ctx context.Context, err error, msg string, kvs ...interface{}) {
+	classify := l.errorClassifier
+	if classify == nil {
+		classify = defaultErrorClassifier
+	}
+
+	level := classify(err)
+	if _, ok := levelMap[level]; !ok || level == LogLevelOff || level == LogLevelAll {
+		level = LogLevelError
+	}
+
+	if !l.isLevelEnabledCtx(ctx, level) {
+		return
+	}
+
+	l.dispatch(ctx, level, msg, append(slices.Clip(kvs), "error", err)...)
+}
+
+// LogError logs a message with the error using the default logger,
+// at a level chosen by its ErrorClassifier.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func LogError(ctx context.Context, err error, msg string, kvs ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.LogError(ctx, err, msg, kvs...)
+}
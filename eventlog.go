@@ -0,0 +1,34 @@
+package harelog
+
+// eventLogEventID is the event ID of the events written by NewEventLogWriter.
+const eventLogEventID = 1
+
+// eventLogType is the type of a Windows Event Log event.
+type eventLogType int
+
+const (
+	eventLogInformation eventLogType = iota
+	eventLogWarning
+	eventLogError
+)
+
+// eventLogTypes maps each LogLevel to the type of its Windows Event Log event
+// (see NewEventLogWriter):
+//
+//	CRITICAL -> Error
+//	ERROR    -> Error
+//	WARN     -> Warning
+//	INFO     -> Information
+//	DEBUG    -> Information
+//	TRACE    -> Information
+var eventLogTypes = map[LogLevel]eventLogType{
+	LogLevelCritical: eventLogError,
+	LogLevelError:    eventLogError,
+	LogLevelWarn:     eventLogWarning,
+}
+
+// eventLogTypeOf returns the Event Log type for level. Levels without a type of
+// their own, such as those of entries printed outside of a log call, use Information.
+func eventLogTypeOf(level LogLevel) eventLogType {
+	return eventLogTypes[level]
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package harelog
+
+import (
+	"errors"
+	"io"
+)
+
+// NewEventLogWriter returns a writer that reports each record written to it as an
+// event of the Windows Event Log. On this platform, it returns an error.
+func NewEventLogWriter(source string) (io.WriteCloser, error) {
+	return nil, errors.New("harelog: the Windows Event Log is not supported on this platform")
+}
@@ -0,0 +1,88 @@
+//go:build windows
+
+package harelog
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventLogWriter is the io.WriteCloser returned by NewEventLogWriter.
+type eventLogWriter struct {
+	mu     sync.Mutex
+	log    *eventlog.Log
+	closed bool
+}
+
+// NewEventLogWriter returns a writer that reports each record written to it as an
+// event of the Windows Event Log under the given source, e.g. the service name.
+// It is intended as a logger output, set with WithOutput; the event type then follows
+// the level of the entry: Error for CRITICAL and ERROR, Warning for WARN and
+// Information for the others. Records written to it outside of a logger, or through
+// WithOutputs, are reported as Information. Logger.Close closes it.
+//
+// The source must be registered first, which requires administrator rights and is
+// usually done by the installer of the service, e.g. with eventlog.InstallAsEventCreate
+// of golang.org/x/sys/windows/svc/eventlog.
+//
+// On other platforms, it returns an error.
+func NewEventLogWriter(source string) (io.WriteCloser, error) {
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventLogWriter{log: l}, nil
+}
+
+// Write implements io.Writer. The record is reported as an Information event.
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	return w.writeLevel(LogLevelInfo, p)
+}
+
+// writeLevel reports the record as an event of the type of level.
+func (w *eventLogWriter) writeLevel(level LogLevel, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errors.New("harelog: write to closed event log writer")
+	}
+
+	msg := strings.TrimRight(string(p), "\r\n")
+
+	var err error
+
+	switch eventLogTypeOf(level) {
+	case eventLogError:
+		err = w.log.Error(eventLogEventID, msg)
+	case eventLogWarning:
+		err = w.log.Warning(eventLogEventID, msg)
+	default:
+		err = w.log.Info(eventLogEventID, msg)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close implements io.Closer. Calling Close more than once is a no-op.
+func (w *eventLogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+
+	return w.log.Close()
+}
@@ -0,0 +1,416 @@
+package harelog
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	json "github.com/goccy/go-json"
+)
+
+// fieldType identifies which member of a Field holds its value.
+type fieldType uint8
+
+const (
+	fieldTypeAny fieldType = iota
+	fieldTypeString
+	fieldTypeInt
+	fieldTypeInt64
+	fieldTypeFloat64
+	fieldTypeBool
+	fieldTypeDuration
+)
+
+// Field is a strongly typed key-value pair for the ...fields logging methods
+// (e.g. Infofields). Unlike the ...w methods, which take alternating keys and
+// values as interface{}, a Field carries its key and typed value together, so
+// it needs no argument pairing or key type checks when applied to the entry.
+//
+// The value of a Field created by String, Int, Int64, Float64, Bool or Duration is not
+// boxed into interface{}: the JSON formatter writes it directly when the entry has no
+// other payload fields, so the ...fields methods allocate less than the ...w methods.
+// It is boxed into the entry's payload like a key-value pair when the payload is needed
+// as a map, such as for the hooks, a redactor or the other formatters.
+//
+// Fields are created with constructors such as String, Int and Any.
+// The zero value is not a valid Field.
+type Field struct {
+	key   string
+	typ   fieldType
+	num   int64
+	str   string
+	iface interface{}
+}
+
+// String returns a Field with a string value.
+func String(key, value string) Field {
+	return Field{key: key, typ: fieldTypeString, str: value}
+}
+
+// Int returns a Field with an int value.
+func Int(key string, value int) Field {
+	return Field{key: key, typ: fieldTypeInt, num: int64(value)}
+}
+
+// Int64 returns a Field with an int64 value.
+func Int64(key string, value int64) Field {
+	return Field{key: key, typ: fieldTypeInt64, num: value}
+}
+
+// Float64 returns a Field with a float64 value.
+func Float64(key string, value float64) Field {
+	return Field{key: key, typ: fieldTypeFloat64, num: int64(math.Float64bits(value))}
+}
+
+// Bool returns a Field with a bool value.
+func Bool(key string, value bool) Field {
+	var num int64
+	if value {
+		num = 1
+	}
+
+	return Field{key: key, typ: fieldTypeBool, num: num}
+}
+
+// Duration returns a Field with a time.Duration value.
+func Duration(key string, value time.Duration) Field {
+	return Field{key: key, typ: fieldTypeDuration, num: int64(value)}
+}
+
+// Err returns a Field holding err under the "error" key.
+func Err(err error) Field {
+	return Field{key: "error", typ: fieldTypeAny, iface: err}
+}
+
+// Any returns a Field with an arbitrary value. It is handled exactly like a value
+// passed to the ...w methods, including the special keys such as "httpRequest".
+func Any(key string, value interface{}) Field {
+	return Field{key: key, typ: fieldTypeAny, iface: value}
+}
+
+// Key returns the key of the field.
+func (f Field) Key() string {
+	return f.key
+}
+
+// Value returns the value of the field, with the type given to its constructor.
+func (f Field) Value() interface{} {
+	switch f.typ {
+	case fieldTypeString:
+		return f.str
+	case fieldTypeInt:
+		return int(f.num)
+	case fieldTypeInt64:
+		return f.num
+	case fieldTypeFloat64:
+		return math.Float64frombits(uint64(f.num))
+	case fieldTypeBool:
+		return f.num == 1
+	case fieldTypeDuration:
+		return time.Duration(f.num)
+	default:
+		return f.iface
+	}
+}
+
+// appendJSON appends the field to b as a member of a JSON object, written as
+// json.Marshal writes it in a payload, or with the masked value if masked is true.
+func (f Field) appendJSON(b []byte, masked bool) []byte {
+	b = appendJSONString(b, f.key)
+	b = append(b, ':')
+
+	if masked {
+		return appendJSONString(b, maskedValueString)
+	}
+
+	switch f.typ {
+	case fieldTypeString:
+		return appendJSONString(b, f.str)
+	case fieldTypeInt, fieldTypeInt64, fieldTypeDuration:
+		return strconv.AppendInt(b, f.num, 10)
+	case fieldTypeBool:
+		return strconv.AppendBool(b, f.num == 1)
+	case fieldTypeFloat64:
+		// Other values are written in exponent form, or cannot be marshaled.
+		if v := math.Float64frombits(uint64(f.num)); v == 0 || (math.Abs(v) >= 1e-6 && math.Abs(v) < 1e21) {
+			return strconv.AppendFloat(b, v, 'f', -1, 64)
+		}
+	}
+
+	out, err := json.Marshal(f.Value())
+	if err != nil {
+		// As marshalPayload writes a value that cannot be marshaled.
+		return appendJSONString(b, "<unmarshalable: "+err.Error()+">")
+	}
+
+	return append(b, out...)
+}
+
+// appendJSONString appends s to b as a JSON string. Strings of printable ASCII
+// characters that json.Marshal does not escape are appended directly.
+func appendJSONString(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			out, _ := json.Marshal(s)
+
+			return append(b, out...)
+		}
+	}
+
+	b = append(b, '"')
+	b = append(b, s...)
+
+	return append(b, '"')
+}
+
+// applyFields applies typed fields to a log entry. If unboxed is true, the fields
+// other than those of Any and Err are kept in the entry as they are, to be written
+// by the formatter or boxed into the payload by boxFields.
+func (e *LogEntry) applyFields(fields []Field, unboxed bool) {
+	for _, f := range fields {
+		if f.typ == fieldTypeAny {
+			// A field applied later takes precedence, as it does in the payload.
+			if len(e.fields) > 0 {
+				e.fields = slices.DeleteFunc(e.fields, func(u Field) bool { return u.key == f.key })
+			}
+
+			e.applyKV(f.key, f.iface)
+
+			continue
+		}
+
+		if unboxed {
+			delete(e.Payload, f.key)
+			e.fields = append(e.fields, f)
+
+			continue
+		}
+
+		// Typed fields never hold one of the special values, so they skip applyKV.
+		e.setField(f.key, f.Value())
+	}
+}
+
+// boxFields stores the unboxed fields of the entry in its payload.
+func (e *LogEntry) boxFields() {
+	for _, f := range e.fields {
+		e.setField(f.key, f.Value())
+	}
+
+	clear(e.fields)
+	e.fields = e.fields[:0]
+}
+
+// keepsFieldsUnboxed reports whether the entries of the logger can keep typed fields
+// unboxed until the formatter writes them: the logger formats them as JSON and has
+// nothing else that reads or changes the payload before.
+// The hooks receive copies of the entries with the fields boxed (see defensiveCopy).
+func (l *Logger) keepsFieldsUnboxed() bool {
+	_, ok := l.formatter.(*jsonFormatter)
+
+	return ok && l.maxFields == 0 && l.maxFieldValueLength == 0 && l.maxEntrySize == 0 &&
+		l.redactor == nil && l.keyNormalizer == nil && l.dedup == nil && len(l.onceFields) == 0
+}
+
+// dispatchFields creates an entry with the given typed fields and dispatches it.
+// The fields take the same precedence as the key-value pairs of the ...w methods.
+func (l *Logger) dispatchFields(ctx context.Context, level LogLevel, msg string, fields []Field) {
+	e := l.createEntry(ctx, level, msg)
+	e.applyFields(fields, l.keepsFieldsUnboxed())
+
+	l.dispatchEntry(e)
+}
+
+// TracefieldsCtx logs a message at the Trace level with typed fields.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) TracefieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelTrace) {
+		return
+	}
+
+	l.dispatchFields(ctx, LogLevelTrace, msg, fields)
+}
+
+// DebugfieldsCtx logs a message at the Debug level with typed fields.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) DebugfieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelDebug) {
+		return
+	}
+
+	l.dispatchFields(ctx, LogLevelDebug, msg, fields)
+}
+
+// InfofieldsCtx logs a message at the Info level with typed fields.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) InfofieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelInfo) {
+		return
+	}
+
+	l.dispatchFields(ctx, LogLevelInfo, msg, fields)
+}
+
+// WarnfieldsCtx logs a message at the Warn level with typed fields.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) WarnfieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelWarn) {
+		return
+	}
+
+	l.dispatchFields(ctx, LogLevelWarn, msg, fields)
+}
+
+// ErrorfieldsCtx logs a message at the Error level with typed fields.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) ErrorfieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelError) {
+		return
+	}
+
+	l.dispatchFields(ctx, LogLevelError, msg, fields)
+}
+
+// CriticalfieldsCtx logs a message at the Critical level with typed fields.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) CriticalfieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelCritical) {
+		return
+	}
+
+	l.dispatchFields(ctx, LogLevelCritical, msg, fields)
+}
+
+// Tracefields logs a message at the Trace level with typed fields.
+func (l *Logger) Tracefields(msg string, fields ...Field) {
+	l.TracefieldsCtx(l.boundContext(), msg, fields...)
+}
+
+// Debugfields logs a message at the Debug level with typed fields.
+func (l *Logger) Debugfields(msg string, fields ...Field) {
+	l.DebugfieldsCtx(l.boundContext(), msg, fields...)
+}
+
+// Infofields logs a message at the Info level with typed fields.
+func (l *Logger) Infofields(msg string, fields ...Field) {
+	l.InfofieldsCtx(l.boundContext(), msg, fields...)
+}
+
+// Warnfields logs a message at the Warn level with typed fields.
+func (l *Logger) Warnfields(msg string, fields ...Field) {
+	l.WarnfieldsCtx(l.boundContext(), msg, fields...)
+}
+
+// Errorfields logs a message at the Error level with typed fields.
+func (l *Logger) Errorfields(msg string, fields ...Field) {
+	l.ErrorfieldsCtx(l.boundContext(), msg, fields...)
+}
+
+// Criticalfields logs a message at the Critical level with typed fields.
+func (l *Logger) Criticalfields(msg string, fields ...Field) {
+	l.CriticalfieldsCtx(l.boundContext(), msg, fields...)
+}
+
+// TracefieldsCtx logs a message at the Trace level with typed fields using the default logger.
+func TracefieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.TracefieldsCtx(ctx, msg, fields...)
+}
+
+// DebugfieldsCtx logs a message at the Debug level with typed fields using the default logger.
+func DebugfieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.DebugfieldsCtx(ctx, msg, fields...)
+}
+
+// InfofieldsCtx logs a message at the Info level with typed fields using the default logger.
+func InfofieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.InfofieldsCtx(ctx, msg, fields...)
+}
+
+// WarnfieldsCtx logs a message at the Warn level with typed fields using the default logger.
+func WarnfieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.WarnfieldsCtx(ctx, msg, fields...)
+}
+
+// ErrorfieldsCtx logs a message at the Error level with typed fields using the default logger.
+func ErrorfieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.ErrorfieldsCtx(ctx, msg, fields...)
+}
+
+// CriticalfieldsCtx logs a message at the Critical level with typed fields using the default logger.
+func CriticalfieldsCtx(ctx context.Context, msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.CriticalfieldsCtx(ctx, msg, fields...)
+}
+
+// Tracefields logs a message at the Trace level with typed fields using the default logger.
+func Tracefields(msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Tracefields(msg, fields...)
+}
+
+// Debugfields logs a message at the Debug level with typed fields using the default logger.
+func Debugfields(msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Debugfields(msg, fields...)
+}
+
+// Infofields logs a message at the Info level with typed fields using the default logger.
+func Infofields(msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Infofields(msg, fields...)
+}
+
+// Warnfields logs a message at the Warn level with typed fields using the default logger.
+func Warnfields(msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Warnfields(msg, fields...)
+}
+
+// Errorfields logs a message at the Error level with typed fields using the default logger.
+func Errorfields(msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Errorfields(msg, fields...)
+}
+
+// Criticalfields logs a message at the Critical level with typed fields using the default logger.
+func Criticalfields(msg string, fields ...Field) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Criticalfields(msg, fields...)
+}
@@ -0,0 +1,48 @@
+package harelog
+
+import "strings"
+
+// FieldError describes a validation error of a single input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors is a list of validation errors, logged as a structured list rather
+// than a concatenated string.
+//
+// The JSON formatter writes it as an array of objects, e.g.
+// [{"field":"email","message":"required"}], while the text-based formatters write
+// a compact form, e.g. "email: required; age: must be positive".
+//
+// FieldErrors implements error, so it can also be returned as an error. When logged
+// under the special "error" key, it is written as its Error string like any other error;
+// use another key, such as "errors", to keep the structure.
+type FieldErrors []FieldError
+
+// Add appends a field error and returns the updated list.
+func (fe FieldErrors) Add(field, message string) FieldErrors {
+	return append(fe, FieldError{Field: field, Message: message})
+}
+
+// String returns the compact form, e.g. "email: required; age: must be positive".
+func (fe FieldErrors) String() string {
+	var b strings.Builder
+
+	for i, e := range fe {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+
+		b.WriteString(e.Field)
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	}
+
+	return b.String()
+}
+
+// Error implements the error interface.
+func (fe FieldErrors) Error() string {
+	return fe.String()
+}
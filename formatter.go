@@ -2,13 +2,16 @@ package harelog
 
 import (
 	"bytes"
+	"encoding"
 	"fmt"
+	"maps"
 	"os"
+	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/fatih/color"
 	json "github.com/goccy/go-json"
@@ -31,6 +34,7 @@ var levelColorMap = map[LogLevel]*color.Color{
 	LogLevelWarn:     color.New(color.FgYellow),
 	LogLevelInfo:     color.New(color.FgGreen),
 	LogLevelDebug:    color.New(color.FgCyan),
+	LogLevelTrace:    color.New(color.FgHiBlack),
 }
 
 var jsonEntryPool = sync.Pool{
@@ -47,8 +51,9 @@ type jsonEntry struct {
 	TraceSampled   *bool           `json:"logging.googleapis.com/trace_sampled,omitempty"`
 	HTTPRequest    *HTTPRequest    `json:"httpRequest,omitempty"`
 	SourceLocation *SourceLocation `json:"logging.googleapis.com/sourceLocation,omitempty"`
+	Caller         string          `json:"caller,omitempty"`
 
-	Time   time.Time         `json:"timestamp,omitempty"`
+	Time   jsonTime          `json:"timestamp,omitempty"`
 	Labels map[string]string `json:"labels,omitempty"`
 
 	CorrelationID string `json:"correlationId,omitempty"`
@@ -63,7 +68,8 @@ func (e *jsonEntry) Clear() {
 	e.TraceSampled = nil
 	e.HTTPRequest = nil
 	e.SourceLocation = nil
-	e.Time = time.Time{}
+	e.Caller = ""
+	e.Time = jsonTime{}
 	// e.Labels = nil // Set to nil, as it's a reference
 	e.CorrelationID = ""
 
@@ -97,6 +103,99 @@ func (jsonOptions) WithMaskingKeysIgnoreCase(keys ...string) JSONFormatterOption
 	}
 }
 
+// WithMaskingKeysFromEnv adds the keys listed in the environment variable envVar
+// for masking in JSONFormatter, ignoring case, like WithMaskingKeysIgnoreCase.
+// The variable is read when the formatter is created and holds a comma-separated
+// list, e.g. "password, token,api_key"; spaces around the keys are trimmed.
+// The keys are merged with those set by the other masking options.
+func (jsonOptions) WithMaskingKeysFromEnv(envVar string) JSONFormatterOption {
+	return func(f *jsonFormatter) {
+		f.addInsensitiveFromEnv(envVar)
+	}
+}
+
+// WithMaskingQueryParams sets the URL query parameters whose values are masked
+// in the HTTPRequest's RequestURL in JSONFormatter. Parameter names are matched case-insensitively.
+func (jsonOptions) WithMaskingQueryParams(keys ...string) JSONFormatterOption {
+	return func(f *jsonFormatter) {
+		f.addQueryParams(keys...)
+	}
+}
+
+// WithStructTagMasking enables masking of struct fields by their `log` tag in JSONFormatter.
+// Fields tagged `log:"-"` are omitted and fields tagged `log:"mask"` are written as
+// "[MASKED]", at any depth of the payload values, including nested structs, pointers,
+// slices and maps. Field names and omitempty follow the `json` tags.
+// Types implementing json.Marshaler or encoding.TextMarshaler are written as usual.
+//
+// Struct tag masking uses reflection, so it is disabled by default. Whether a type
+// holds any log tags is cached, so values without them are cheap to check.
+func (jsonOptions) WithStructTagMasking(enabled bool) JSONFormatterOption {
+	return func(f *jsonFormatter) {
+		f.structTagMasking = enabled
+	}
+}
+
+// WithMaskingJSONPaths sets paths to values nested in the payload that are masked in
+// JSONFormatter, for payload values that are whole objects, where the masking keys
+// only match the top-level keys. A path starts with a payload key and follows object
+// keys separated by dots and array indexes in brackets:
+//
+//	user.ssn          the "ssn" key of the "user" payload field
+//	cards[*].number   the "number" key of every element of the "cards" array
+//	cards[0].number   the "number" key of its first element
+//	*.password        the "password" key of any payload field
+//
+// Objects are followed through maps with string keys, structs, by the names of their
+// `json` tags, and values implementing json.Marshaler or encoding.TextMarshaler, whose
+// JSON form is walked. Keys containing dots or brackets cannot be matched.
+//
+// Only the payload values a path leads into are copied, so that the caller's values
+// are never modified; the cost is paid by the entries holding a matching field.
+// It panics if a path is malformed.
+func (jsonOptions) WithMaskingJSONPaths(paths ...string) JSONFormatterOption {
+	parsed := make([][]jsonPathSegment, len(paths))
+
+	for i, path := range paths {
+		parsed[i] = parseJSONPath(path)
+	}
+
+	return func(f *jsonFormatter) {
+		f.jsonPaths = append(f.jsonPaths, parsed...)
+	}
+}
+
+// WithTimeEncoding sets how the timestamp is encoded in JSONFormatter.
+// The default is TimeRFC3339. Epoch encodings are emitted as JSON numbers.
+func (jsonOptions) WithTimeEncoding(enc TimeEncoding) JSONFormatterOption {
+	validateTimeEncoding(enc)
+
+	return func(f *jsonFormatter) {
+		f.timeEncoding = enc
+	}
+}
+
+// WithTypeField adds a constant field, such as "_type":"applog", as the first field of
+// every entry in JSONFormatter. Downstream processors of multi-schema log streams can
+// route entries by it. Unlike a field added with WithFields, it is guaranteed to be
+// present and first; a payload field with the same key is left out of the output, but
+// not removed from the entry. It is absent by default. It panics if key is empty.
+func (jsonOptions) WithTypeField(key, value string) JSONFormatterOption {
+	if key == "" {
+		panic("harelog: empty key provided to JSON.WithTypeField")
+	}
+
+	k, _ := json.Marshal(key)
+	v, _ := json.Marshal(value)
+
+	field := append(append(k, ':'), v...)
+
+	return func(f *jsonFormatter) {
+		f.typeKey = key
+		f.typeField = field
+	}
+}
+
 // NewJSONFormatter creates a new JSONFormatter.
 func (jsonOptions) NewFormatter(opts ...JSONFormatterOption) *jsonFormatter {
 	formatter := &jsonFormatter{}
@@ -111,6 +210,13 @@ func (jsonOptions) NewFormatter(opts ...JSONFormatterOption) *jsonFormatter {
 // jsonFormatter formats log entries as JSON.
 type jsonFormatter struct {
 	maskingCore
+	timeEncoding     TimeEncoding
+	fieldNames       *jsonFieldNames
+	callerField      bool
+	structTagMasking bool
+	jsonPaths        [][]jsonPathSegment
+	typeKey          string
+	typeField        []byte // the encoded `"key":"value"` of WithTypeField, or nil
 }
 
 // Deprecated: Use harelog.JSON.NewFormatter instead.
@@ -120,6 +226,40 @@ func NewJSONFormatter() *jsonFormatter {
 
 // Format converts a logEntry to JSON format.
 func (f *jsonFormatter) Format(e *LogEntry) ([]byte, error) {
+	if f.typeField == nil {
+		return f.format(e)
+	}
+
+	e.boxFields()
+
+	if _, ok := e.Payload[f.typeKey]; ok {
+		// A payload field with the type key is left out of a copy of the entry,
+		// so that the entry of the caller keeps it.
+		c := *e
+		c.Payload = maps.Clone(e.Payload)
+		delete(c.Payload, f.typeKey)
+
+		e = &c
+	}
+
+	out, err := f.format(e)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, len(out)+len(f.typeField)+1)
+	b = append(b, '{')
+	b = append(b, f.typeField...)
+
+	if len(out) > 2 {
+		b = append(b, ',')
+	}
+
+	return append(b, out[1:]...), nil
+}
+
+// format converts a logEntry to JSON format, without the type field.
+func (f *jsonFormatter) format(e *LogEntry) ([]byte, error) {
 	head := jsonEntryPool.Get().(*jsonEntry)
 
 	defer func() {
@@ -127,26 +267,46 @@ func (f *jsonFormatter) Format(e *LogEntry) ([]byte, error) {
 		jsonEntryPool.Put(head)
 	}()
 
+	// Unboxed typed fields are written directly only when they are the whole payload;
+	// otherwise they are stored in the payload, to be written in key order with it.
+	if len(e.fields) > 0 && (len(e.Payload) > 0 || f.fieldNames != nil || len(f.jsonPaths) > 0) {
+		e.boxFields()
+	}
+
 	for k := range e.Labels {
 		if f.isMasking(k) {
 			e.Labels[k] = maskedValueString
 		}
 	}
 
-	for k := range e.Payload {
+	for k, v := range e.Payload {
 		if f.isMasking(k) {
 			e.Payload[k] = maskedValueString
+		} else if f.structTagMasking {
+			e.Payload[k] = maskStructTags(v)
 		}
 	}
 
+	if len(f.jsonPaths) > 0 {
+		f.maskJSONPaths(e.Payload)
+	}
+
+	if f.fieldNames != nil {
+		return f.formatWithFieldNames(e)
+	}
+
 	head.Message = e.Message
 	head.Severity = e.Severity
 	head.Trace = e.Trace
 	head.SpanID = e.SpanID
 	head.TraceSampled = e.TraceSampled
-	head.HTTPRequest = e.HTTPRequest
-	head.SourceLocation = e.SourceLocation
-	head.Time = e.Time
+	head.HTTPRequest = f.maskHTTPRequest(e.HTTPRequest)
+	if f.callerField && e.SourceLocation != nil {
+		head.Caller = e.SourceLocation.caller()
+	} else {
+		head.SourceLocation = e.SourceLocation
+	}
+	head.Time = jsonTime{Time: e.Time, encoding: f.timeEncoding, encoder: e.timeEncoder}
 	head.Labels = e.Labels
 	head.CorrelationID = e.CorrelationID
 
@@ -155,11 +315,15 @@ func (f *jsonFormatter) Format(e *LogEntry) ([]byte, error) {
 		return nil, err
 	}
 
+	if len(e.fields) > 0 {
+		return f.appendFields(headerBytes, e.fields), nil
+	}
+
 	if len(e.Payload) == 0 {
 		return headerBytes, nil
 	}
 
-	payloadBytes, err := json.Marshal(e.Payload)
+	payloadBytes, err := marshalPayload(e.Payload)
 	if err != nil {
 		return nil, err
 	}
@@ -175,14 +339,121 @@ func (f *jsonFormatter) Format(e *LogEntry) ([]byte, error) {
 	return out, nil
 }
 
+// appendFields appends unboxed typed fields to the JSON object out, in key order like
+// the payload, keeping the last of several fields with the same key.
+func (f *jsonFormatter) appendFields(out []byte, fields []Field) []byte {
+	slices.SortStableFunc(fields, func(a, b Field) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	out = out[:len(out)-1]
+	sep := len(out) > 1
+
+	for i, field := range fields {
+		if i+1 < len(fields) && fields[i+1].key == field.key {
+			continue
+		}
+
+		if sep {
+			out = append(out, ',')
+		}
+
+		out = field.appendJSON(out, f.isMasking(field.key))
+		sep = true
+	}
+
+	return append(out, '}')
+}
+
+// marshalPayload marshals the payload. Errors are written as described by jsonErrorValue.
+// If the payload cannot be marshaled, the values that cannot, such as channels and
+// functions, are written as a placeholder describing the error, so that the rest of the
+// entry is still written. The values are replaced in a copy, not in the payload.
+func marshalPayload(payload map[string]interface{}) ([]byte, error) {
+	var replaced map[string]interface{}
+
+	replace := func(k string, v interface{}) {
+		if replaced == nil {
+			replaced = maps.Clone(payload)
+		}
+
+		replaced[k] = v
+	}
+
+	for k, v := range payload {
+		if err, ok := v.(error); ok {
+			replace(k, jsonErrorValue(err))
+		}
+	}
+
+	if replaced != nil {
+		payload = replaced
+	}
+
+	b, err := json.Marshal(payload)
+	if err == nil {
+		return b, nil
+	}
+
+	for k, v := range payload {
+		if _, err := json.Marshal(v); err != nil {
+			replace(k, "<unmarshalable: "+err.Error()+">")
+		}
+	}
+
+	if replaced == nil {
+		return nil, err
+	}
+
+	return json.Marshal(replaced)
+}
+
+// jsonErrorValue returns the value written for an error in a JSON payload. Most errors
+// have no exported fields and would be written as {}, so an error is written as its
+// Error string, like in the text-based formatters, unless it marshals itself, as with
+// json.Marshaler or encoding.TextMarshaler, or is a slice, array or map, such as
+// FieldErrors, whose structure is kept. A nil pointer is written as null.
+func jsonErrorValue(err error) interface{} {
+	switch err.(type) {
+	case json.Marshaler, encoding.TextMarshaler:
+		return err
+	}
+
+	switch v := reflect.ValueOf(err); v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return err
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	return err.Error()
+}
+
 // FormatMessageOnly formats only the timestamp, severity, and message fields into logfmt format.
 // This is used internally by the logger to output warnings about invalid keys.
 func (f *jsonFormatter) FormatMessageOnly(e *LogEntry) ([]byte, error) {
 	var b bytes.Buffer
 
-	b.WriteString(`{"timestamp":"`)
-	b.Write(e.Time.AppendFormat(nil, time.RFC3339))
-	b.WriteString(`","severity":"`)
+	b.WriteString(`{"timestamp":`)
+
+	if e.timeEncoder != nil {
+		ts, err := encodeJSONTime(e.timeEncoder, e.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Write(ts)
+	} else if f.timeEncoding.isEpoch() {
+		b.Write(e.appendTime(nil, f.timeEncoding))
+	} else {
+		b.WriteByte('"')
+		b.Write(e.Time.AppendFormat(nil, e.timeLayout()))
+		b.WriteByte('"')
+	}
+
+	b.WriteString(`,"severity":"`)
 	b.WriteString(string(e.Severity))
 	b.WriteString(`","message":`)
 	b.WriteString(strconv.Quote(e.Message))
@@ -212,8 +483,48 @@ func (textOptions) NewFormatter(opts ...TextFormatterOption) *textFormatter {
 // textFormatter formats log entries as human-readable text.
 type textFormatter struct {
 	maskingCore
+	timeEncoding    TimeEncoding
+	rawControlChars bool
+	abbreviateLevel bool
+	fieldDelimiters *fieldDelimiters // nil for defaultFieldDelimiters
+	messageSep      *string          // nil for a space
+}
+
+// levelAbbreviations are the single-character levels written by the text-based
+// formatters with WithLevelAbbreviation.
+var levelAbbreviations = map[LogLevel]string{
+	LogLevelTrace:    "T",
+	LogLevelDebug:    "D",
+	LogLevelInfo:     "I",
+	LogLevelWarn:     "W",
+	LogLevelError:    "E",
+	LogLevelCritical: "C",
 }
 
+// levelToken returns the level as written in a text entry header: "[INFO]", or "I" if
+// abbreviate is true. Levels without an abbreviation are always written in full.
+func levelToken(level LogLevel, abbreviate bool) string {
+	if abbreviate {
+		if a, ok := levelAbbreviations[level]; ok {
+			return a
+		}
+	}
+
+	return "[" + string(level) + "]"
+}
+
+// fieldDelimiters are the strings written around and between the fields of a text entry.
+// open and close include their padding spaces; the leading space of open separates
+// the fields from the message.
+type fieldDelimiters struct {
+	open  string
+	close string
+	sep   string
+}
+
+// defaultFieldDelimiters writes the fields as "{ key=value, key=value }".
+var defaultFieldDelimiters = fieldDelimiters{open: " { ", close: " }", sep: ", "}
+
 // Deprecated: Use harelog.Text.NewFormatter instead.
 func NewTextFormatter() *textFormatter {
 	return Text.NewFormatter()
@@ -221,22 +532,33 @@ func NewTextFormatter() *textFormatter {
 
 // Format converts a logEntry to a single-line text format.
 func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
+	return f.format(e, true)
+}
+
+// format converts a logEntry to a single-line text format.
+// The timestamp and level are written only if header is true.
+func (f *textFormatter) format(e *LogEntry, header bool) ([]byte, error) {
 	var b bytes.Buffer
 	var scratch [64]byte
 	var buf []byte
 
-	// Timestamp
 	b.Grow(128)
-	b.Write(e.Time.AppendFormat(scratch[:0], time.RFC3339))
-	b.WriteByte(' ')
 
-	b.WriteByte('[')
-	b.WriteString(string(e.Severity))
-	b.WriteByte(']')
-	b.WriteByte(' ')
+	if header {
+		// Timestamp
+		b.Write(e.appendTime(scratch[:0], f.timeEncoding))
+		b.WriteByte(' ')
+
+		b.WriteString(levelToken(e.Severity, f.abbreviateLevel))
+		b.WriteByte(' ')
+	}
 
 	// Message
-	b.WriteString(e.Message)
+	if f.rawControlChars {
+		b.WriteString(e.Message)
+	} else {
+		appendEscapedControlChars(&b, strings.TrimSuffix(e.Message, "\n"))
+	}
 
 	buf = b.Bytes()
 
@@ -252,9 +574,17 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 	isLabel := false
 	isPayload := false
 
-	b.WriteByte(' ')
-	b.WriteByte('{')
-	b.WriteByte(' ')
+	d := f.fieldDelimiters
+	if d == nil {
+		d = &defaultFieldDelimiters
+	}
+
+	open := d.open
+	if f.messageSep != nil {
+		open = *f.messageSep + open[1:]
+	}
+
+	b.WriteString(open)
 
 	// Add special fields if they exist and are not already in the payload
 	if e.SourceLocation != nil {
@@ -275,8 +605,7 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 				b.Write(strconv.AppendInt(scratch[:0], int64(e.SourceLocation.Line), 10))
 			}
 
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			b.WriteString(d.sep)
 
 			isSource = true
 		}
@@ -285,9 +614,8 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 	if e.Trace != "" {
 		b.WriteString("trace")
 		b.WriteByte('=')
-		appendStringValue(&b, e.Trace)
-		b.WriteByte(',')
-		b.WriteByte(' ')
+		appendStringValue(&b, e.Trace, f.rawControlChars)
+		b.WriteString(d.sep)
 
 		isTrace = true
 	}
@@ -295,9 +623,8 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 	if e.SpanID != "" {
 		b.WriteString("spanId")
 		b.WriteByte('=')
-		appendStringValue(&b, e.SpanID)
-		b.WriteByte(',')
-		b.WriteByte(' ')
+		appendStringValue(&b, e.SpanID, f.rawControlChars)
+		b.WriteString(d.sep)
 
 		isSpanID = true
 	}
@@ -305,9 +632,8 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 	if e.CorrelationID != "" {
 		b.WriteString("correlationId")
 		b.WriteByte('=')
-		appendStringValue(&b, e.CorrelationID)
-		b.WriteByte(',')
-		b.WriteByte(' ')
+		appendStringValue(&b, e.CorrelationID, f.rawControlChars)
+		b.WriteString(d.sep)
 
 		isCorrelationId = true
 	}
@@ -317,9 +643,8 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 		if e.HTTPRequest.RequestMethod != "" {
 			b.WriteString("http.method")
 			b.WriteByte('=')
-			appendStringValue(&b, e.HTTPRequest.RequestMethod)
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			appendStringValue(&b, e.HTTPRequest.RequestMethod, f.rawControlChars)
+			b.WriteString(d.sep)
 
 			isHttpRequest = true
 		}
@@ -327,17 +652,15 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 			b.WriteString("http.status")
 			b.WriteByte('=')
 			b.Write(strconv.AppendInt(scratch[:0], int64(e.HTTPRequest.Status), 10))
-			b.WriteString(",")
-			b.WriteByte(' ')
+			b.WriteString(d.sep)
 
 			isHttpRequest = true
 		}
 		if e.HTTPRequest.RequestURL != "" {
 			b.WriteString("http.url")
 			b.WriteByte('=')
-			appendStringValue(&b, e.HTTPRequest.RequestURL)
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			appendStringValue(&b, f.maskURL(e.HTTPRequest.RequestURL), f.rawControlChars)
+			b.WriteString(d.sep)
 
 			isHttpRequest = true
 		}
@@ -355,17 +678,16 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 		for _, key := range keys {
 			b.WriteString("label")
 			b.WriteByte('.')
-			b.WriteString(key)
+			b.WriteString(fieldKey(key, f.rawControlChars))
 			b.WriteByte('=')
 
 			if f.isMasking(key) {
 				b.WriteString(maskedValueString)
 			} else {
-				appendStringValue(&b, e.Labels[key])
+				appendStringValue(&b, e.Labels[key], f.rawControlChars)
 			}
 
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			b.WriteString(d.sep)
 
 			isLabel = true
 		}
@@ -397,48 +719,16 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 				continue
 			}
 
-			b.WriteString(key)
+			b.WriteString(fieldKey(key, f.rawControlChars))
 			b.WriteString("=")
 
 			if f.isMasking(key) {
 				b.WriteString(maskedValueString)
 			} else {
-				switch val := e.Payload[key].(type) {
-				case string:
-					appendStringValue(&b, val)
-				case bool:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendBool(scratch[:0], val))
-				case int:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendInt(scratch[:0], int64(val), 10))
-				case int32:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendInt(scratch[:0], int64(val), 10))
-				case int64:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendInt(scratch[:0], val, 10))
-				case float32:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendFloat(scratch[:0], float64(val), 'f', -1, 64))
-				case float64:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendFloat(scratch[:0], val, 'f', -1, 64))
-				case fmt.Stringer:
-					appendStringValue(&b, val.String())
-				default:
-					appendStringValue(&b, fmt.Sprint(val))
-				}
+				appendFieldValue(&b, e.Payload[key], f.rawControlChars)
 			}
 
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			b.WriteString(d.sep)
 
 			isPayload = true
 		}
@@ -447,12 +737,11 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 	buf = b.Bytes()
 
 	if isSource || isTrace || isSpanID || isCorrelationId || isHttpRequest || isLabel || isPayload {
-		b.Truncate(len(buf) - 2)
-		b.WriteByte(' ')
-		b.WriteByte('}')
+		b.Truncate(len(buf) - len(d.sep))
+		b.WriteString(d.close)
 	} else {
-		// space }
-		b.Truncate(len(buf) - 3)
+		// No fields: remove the opening delimiter.
+		b.Truncate(len(buf) - len(open))
 	}
 
 	return b.Bytes(), nil
@@ -461,15 +750,15 @@ func (f *textFormatter) Format(e *LogEntry) ([]byte, error) {
 // FormatMessageOnly formats only the timestamp, severity, and message fields into logfmt format.
 // This is used internally by the logger to output warnings about invalid keys.
 func (f *textFormatter) FormatMessageOnly(e *LogEntry) ([]byte, error) {
-	return formatBasicMessage(e), nil
+	return formatBasicMessage(e, f.timeEncoding), nil
 }
 
-func formatBasicMessage(e *LogEntry) []byte {
+func formatBasicMessage(e *LogEntry, enc TimeEncoding) []byte {
 	var b bytes.Buffer
 
 	// Timestamp
 	b.Grow(32)
-	b.Write(e.Time.AppendFormat(nil, time.RFC3339))
+	b.Write(e.appendTime(nil, enc))
 	b.WriteByte(' ')
 
 	// Log Level
@@ -499,6 +788,98 @@ func (textOptions) WithMaskingKeysIgnoreCase(keys ...string) TextFormatterOption
 	}
 }
 
+// WithMaskingKeysFromEnv adds the keys listed in the environment variable envVar
+// for masking in TextFormatter, ignoring case, like WithMaskingKeysIgnoreCase.
+// The variable is read when the formatter is created and holds a comma-separated
+// list, e.g. "password, token,api_key"; spaces around the keys are trimmed.
+// The keys are merged with those set by the other masking options.
+func (textOptions) WithMaskingKeysFromEnv(envVar string) TextFormatterOption {
+	return func(f *textFormatter) {
+		f.addInsensitiveFromEnv(envVar)
+	}
+}
+
+// WithMaskingQueryParams sets the URL query parameters whose values are masked
+// in the HTTPRequest's RequestURL in TextFormatter. Parameter names are matched case-insensitively.
+func (textOptions) WithMaskingQueryParams(keys ...string) TextFormatterOption {
+	return func(f *textFormatter) {
+		f.addQueryParams(keys...)
+	}
+}
+
+// WithTimeEncoding sets how the timestamp is encoded in TextFormatter.
+// The default is TimeRFC3339.
+func (textOptions) WithTimeEncoding(enc TimeEncoding) TextFormatterOption {
+	validateTimeEncoding(enc)
+
+	return func(f *textFormatter) {
+		f.timeEncoding = enc
+	}
+}
+
+// WithControlCharEscaping sets whether control characters are escaped in TextFormatter,
+// so that each entry stays on exactly one line and cannot emit terminal escape
+// sequences (log injection, CWE-117). Control characters in the message are escaped
+// (e.g. a newline as \n), and field keys and values containing them are quoted.
+// It is enabled by default; disable it only to deliberately write multi-line output.
+func (textOptions) WithControlCharEscaping(enabled bool) TextFormatterOption {
+	return func(f *textFormatter) {
+		f.rawControlChars = !enabled
+	}
+}
+
+// WithLevelAbbreviation makes TextFormatter write the level as a single character
+// (T, D, I, W, E or C) instead of the default "[INFO]" form, for denser output.
+func (textOptions) WithLevelAbbreviation(enabled bool) TextFormatterOption {
+	return func(f *textFormatter) {
+		f.abbreviateLevel = enabled
+	}
+}
+
+// WithMessageFieldSeparator sets the string written between the message and the
+// fields in TextFormatter, in place of the default space, e.g. a tab to split each
+// line into its human-readable and structured parts:
+//
+//	2025-10-14T13:30:00Z [INFO] message\t{ key1=value1, key2=value2 }
+//
+// Entries without fields end after the message, without the separator.
+func (textOptions) WithMessageFieldSeparator(sep string) TextFormatterOption {
+	return func(f *textFormatter) {
+		f.messageSep = &sep
+	}
+}
+
+// WithFieldDelimiters sets the strings written around and between the fields in TextFormatter.
+// open and close are separated from the fields by a space, and may be empty to
+// write the fields without enclosing them. The default is ("{", "}", ", "):
+//
+//	2025-10-14T13:30:00Z [INFO] message { key1=value1, key2=value2 }
+//
+// while ("", "", " | ") writes:
+//
+//	2025-10-14T13:30:00Z [INFO] message key1=value1 | key2=value2
+//
+// It panics if sep is empty.
+func (textOptions) WithFieldDelimiters(open, close, sep string) TextFormatterOption {
+	if sep == "" {
+		panic("harelog: empty separator provided to Text.WithFieldDelimiters")
+	}
+
+	d := &fieldDelimiters{open: " ", close: "", sep: sep}
+
+	if open != "" {
+		d.open = " " + open + " "
+	}
+
+	if close != "" {
+		d.close = " " + close
+	}
+
+	return func(f *textFormatter) {
+		f.fieldDelimiters = d
+	}
+}
+
 // ColorAttribute defines a text attribute like color or style for the ConsoleFormatter.
 type ColorAttribute int
 
@@ -559,7 +940,8 @@ func (consoleOptions) WithKeyHighlight(key string, attrs ...ColorAttribute) Cons
 		var colorAttr color.Attribute
 		isColorSet := false
 
-		styleAttrs := make(map[color.Attribute]struct{})
+		// Styles are kept in the order given, so the escape sequence is deterministic.
+		var styleAttrs []color.Attribute
 
 		for _, attr := range attrs {
 			cAttr := toFatihAttribute(attr)
@@ -567,8 +949,8 @@ func (consoleOptions) WithKeyHighlight(key string, attrs ...ColorAttribute) Cons
 			if cAttr >= color.FgBlack && cAttr <= color.FgWhite {
 				colorAttr = cAttr
 				isColorSet = true
-			} else {
-				styleAttrs[cAttr] = struct{}{}
+			} else if !slices.Contains(styleAttrs, cAttr) {
+				styleAttrs = append(styleAttrs, cAttr)
 			}
 		}
 
@@ -578,9 +960,7 @@ func (consoleOptions) WithKeyHighlight(key string, attrs ...ColorAttribute) Cons
 			finalAttrs = append(finalAttrs, colorAttr)
 		}
 
-		for attr := range styleAttrs {
-			finalAttrs = append(finalAttrs, attr)
-		}
+		finalAttrs = append(finalAttrs, styleAttrs...)
 
 		f.highlightColors[key] = color.New(finalAttrs...)
 	}
@@ -601,13 +981,115 @@ func (consoleOptions) WithMaskingKeysIgnoreCase(keys ...string) ConsoleFormatter
 	}
 }
 
+// WithMaskingKeysFromEnv adds the keys listed in the environment variable envVar
+// for masking in ConsoleFormatter, ignoring case, like WithMaskingKeysIgnoreCase.
+// The variable is read when the formatter is created and holds a comma-separated
+// list, e.g. "password, token,api_key"; spaces around the keys are trimmed.
+// The keys are merged with those set by the other masking options.
+func (consoleOptions) WithMaskingKeysFromEnv(envVar string) ConsoleFormatterOption {
+	return func(f *consoleFormatter) {
+		f.addInsensitiveFromEnv(envVar)
+	}
+}
+
+// WithMaskingQueryParams sets the URL query parameters whose values are masked
+// in the HTTPRequest's RequestURL in ConsoleFormatter. Parameter names are matched case-insensitively.
+func (consoleOptions) WithMaskingQueryParams(keys ...string) ConsoleFormatterOption {
+	return func(f *consoleFormatter) {
+		f.addQueryParams(keys...)
+	}
+}
+
+// WithTimeEncoding sets how the timestamp is encoded in ConsoleFormatter.
+// The default is TimeRFC3339.
+func (consoleOptions) WithTimeEncoding(enc TimeEncoding) ConsoleFormatterOption {
+	validateTimeEncoding(enc)
+
+	return func(f *consoleFormatter) {
+		f.timeEncoding = enc
+	}
+}
+
+// WithControlCharEscaping sets whether control characters are escaped in ConsoleFormatter.
+// It is enabled by default. See Text.WithControlCharEscaping for details.
+func (consoleOptions) WithControlCharEscaping(enabled bool) ConsoleFormatterOption {
+	return func(f *consoleFormatter) {
+		f.rawControlChars = !enabled
+	}
+}
+
+// WithLevelAbbreviation makes ConsoleFormatter write the level as a single character
+// (T, D, I, W, E or C), still colored by level. See Text.WithLevelAbbreviation.
+func (consoleOptions) WithLevelAbbreviation(enabled bool) ConsoleFormatterOption {
+	return func(f *consoleFormatter) {
+		f.abbreviateLevel = enabled
+	}
+}
+
+// WithMessageTemplate makes ConsoleFormatter substitute "{key}" placeholders in the
+// message with the value of the payload field key, e.g. "user {userID} did {action}".
+// A substituted value is highlighted as configured with WithKeyHighlight and masked
+// as configured with the masking options, and its field is removed from the trailing
+// "{ ... }" block. Placeholders without a matching field are written as they are.
+// Write "{{" and "}}" for literal braces. It is disabled by default.
+func (consoleOptions) WithMessageTemplate(enabled bool) ConsoleFormatterOption {
+	return func(f *consoleFormatter) {
+		f.messageTemplate = enabled
+	}
+}
+
+// WithHiddenKeys makes ConsoleFormatter omit the payload fields with the given keys,
+// such as verbose request bodies, from the trailing "{ ... }" block. Other formatters
+// still write them. A hidden field referenced by a placeholder of WithMessageTemplate
+// is still substituted into the message. Calling it more than once adds to the keys;
+// nothing is hidden by default.
+func (consoleOptions) WithHiddenKeys(keys ...string) ConsoleFormatterOption {
+	return func(f *consoleFormatter) {
+		if f.hiddenKeys == nil {
+			f.hiddenKeys = make(map[string]struct{}, len(keys))
+		}
+
+		for _, key := range keys {
+			f.hiddenKeys[key] = struct{}{}
+		}
+	}
+}
+
+// WithMultilineErrors makes ConsoleFormatter write Error and Critical entries in an
+// expanded form for reading in a terminal: the message on the first line, each field
+// indented on a line of its own, and the stack trace, if one was captured (see
+// WithStackTrace), below them. Entries of the other levels stay on a single line.
+//
+// An expanded entry spans several lines, so log collectors that split records on
+// newlines cannot read it; use it only for local development. It is disabled by default.
+func (consoleOptions) WithMultilineErrors(enabled bool) ConsoleFormatterOption {
+	return func(f *consoleFormatter) {
+		f.multilineErrors = enabled
+	}
+}
+
 // consoleFormatter provides a rich, developer-focused text format.
 // It supports highlighting specific key-value pairs to improve readability.
 type consoleFormatter struct {
 	maskingCore
+	timeEncoding     TimeEncoding
+	rawControlChars  bool
+	abbreviateLevel  bool
+	messageTemplate  bool
 	enableColor      bool
 	isEnableColorSet bool
 	highlightColors  map[string]*color.Color
+	hiddenKeys       map[string]struct{}
+	multilineErrors  bool
+}
+
+// multilineFieldDelimiters writes each field on a line of its own (see
+// Console.WithMultilineErrors).
+var multilineFieldDelimiters = fieldDelimiters{open: "\n    ", close: "", sep: "\n    "}
+
+// isErrorLevel reports whether level is Error or Critical.
+func isErrorLevel(level LogLevel) bool {
+	return level == LogLevelError || level == LogLevelCritical
 }
 
 // ConsoleFormatterOption is a functional option for configuring a ConsoleFormatter.
@@ -639,7 +1121,7 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 
 	// Timestamp
 	b.Grow(128)
-	b.Write(e.Time.AppendFormat(scratch[:0], time.RFC3339))
+	b.Write(e.appendTime(scratch[:0], f.timeEncoding))
 	b.WriteByte(' ')
 
 	enableLogLevelColor := f.isEnableColorSet && f.enableColor
@@ -652,17 +1134,15 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 			c.DisableColor()
 		}
 
-		b.WriteString(c.Sprintf("[%s]", e.Severity))
+		b.WriteString(c.Sprint(levelToken(e.Severity, f.abbreviateLevel)))
 	} else {
-		b.WriteByte('[')
-		b.WriteString(string(e.Severity))
-		b.WriteByte(']')
+		b.WriteString(levelToken(e.Severity, f.abbreviateLevel))
 	}
 
 	b.WriteByte(' ')
 
 	// Message
-	b.WriteString(e.Message)
+	substituted := f.appendMessage(&b, e, isUseColor)
 
 	buf = b.Bytes()
 
@@ -678,9 +1158,16 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 	isLabel := false
 	isPayload := false
 
-	b.WriteByte(' ')
-	b.WriteByte('{')
-	b.WriteByte(' ')
+	// With WithMultilineErrors, the fields of Error and Critical entries are written
+	// on lines of their own, followed by the stack trace.
+	d := &defaultFieldDelimiters
+	multiline := f.multilineErrors && isErrorLevel(e.Severity)
+
+	if multiline {
+		d = &multilineFieldDelimiters
+	}
+
+	b.WriteString(d.open)
 
 	// Add special fields if they exist and are not already in the payload
 	if e.SourceLocation != nil {
@@ -701,8 +1188,7 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 				b.Write(strconv.AppendInt(scratch[:0], int64(e.SourceLocation.Line), 10))
 			}
 
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			b.WriteString(d.sep)
 
 			isSource = true
 		}
@@ -711,9 +1197,8 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 	if e.Trace != "" {
 		b.WriteString("trace")
 		b.WriteByte('=')
-		appendStringValue(&b, e.Trace)
-		b.WriteByte(',')
-		b.WriteByte(' ')
+		appendStringValue(&b, e.Trace, f.rawControlChars)
+		b.WriteString(d.sep)
 
 		isTrace = true
 	}
@@ -721,9 +1206,8 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 	if e.SpanID != "" {
 		b.WriteString("spanId")
 		b.WriteByte('=')
-		appendStringValue(&b, e.SpanID)
-		b.WriteByte(',')
-		b.WriteByte(' ')
+		appendStringValue(&b, e.SpanID, f.rawControlChars)
+		b.WriteString(d.sep)
 
 		isSpanID = true
 	}
@@ -731,9 +1215,8 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 	if e.CorrelationID != "" {
 		b.WriteString("correlationId")
 		b.WriteByte('=')
-		appendStringValue(&b, e.CorrelationID)
-		b.WriteByte(',')
-		b.WriteByte(' ')
+		appendStringValue(&b, e.CorrelationID, f.rawControlChars)
+		b.WriteString(d.sep)
 
 		isCorrelationId = true
 	}
@@ -743,9 +1226,8 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 		if e.HTTPRequest.RequestMethod != "" {
 			b.WriteString("http.method")
 			b.WriteByte('=')
-			appendStringValue(&b, e.HTTPRequest.RequestMethod)
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			appendStringValue(&b, e.HTTPRequest.RequestMethod, f.rawControlChars)
+			b.WriteString(d.sep)
 
 			isHttpRequest = true
 		}
@@ -753,17 +1235,15 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 			b.WriteString("http.status")
 			b.WriteByte('=')
 			b.Write(strconv.AppendInt(scratch[:0], int64(e.HTTPRequest.Status), 10))
-			b.WriteString(",")
-			b.WriteByte(' ')
+			b.WriteString(d.sep)
 
 			isHttpRequest = true
 		}
 		if e.HTTPRequest.RequestURL != "" {
 			b.WriteString("http.url")
 			b.WriteByte('=')
-			appendStringValue(&b, e.HTTPRequest.RequestURL)
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			appendStringValue(&b, f.maskURL(e.HTTPRequest.RequestURL), f.rawControlChars)
+			b.WriteString(d.sep)
 
 			isHttpRequest = true
 		}
@@ -781,17 +1261,16 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 		for _, key := range keys {
 			b.WriteString("label")
 			b.WriteByte('.')
-			b.WriteString(key)
+			b.WriteString(fieldKey(key, f.rawControlChars))
 			b.WriteByte('=')
 
 			if f.isMasking(key) {
 				b.WriteString(maskedValueString)
 			} else {
-				appendStringValue(&b, e.Labels[key])
+				appendStringValue(&b, e.Labels[key], f.rawControlChars)
 			}
 
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			b.WriteString(d.sep)
 
 			isLabel = true
 		}
@@ -823,44 +1302,22 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 				continue
 			}
 
-			b2.Reset()
+			if _, ok := substituted[key]; ok {
+				continue
+			}
 
-			switch val := e.Payload[key].(type) {
-			case string:
-				// b2.WriteString(strconv.Quote(val))
-				appendStringValue(&b2, val)
-			case bool:
-				scratch := [64]byte{}
-
-				b2.Write(strconv.AppendBool(scratch[:0], val))
-			case int:
-				scratch := [64]byte{}
-
-				b2.Write(strconv.AppendInt(scratch[:0], int64(val), 10))
-			case int32:
-				scratch := [64]byte{}
-
-				b2.Write(strconv.AppendInt(scratch[:0], int64(val), 10))
-			case int64:
-				scratch := [64]byte{}
-
-				b2.Write(strconv.AppendInt(scratch[:0], val, 10))
-			case float32:
-				scratch := [64]byte{}
-
-				b2.Write(strconv.AppendFloat(scratch[:0], float64(val), 'f', -1, 64))
-			case float64:
-				scratch := [64]byte{}
-
-				b2.Write(strconv.AppendFloat(scratch[:0], val, 'f', -1, 64))
-			case fmt.Stringer:
-				// b2.WriteString(val.String())
-				appendStringValue(&b2, val.String())
-			default:
-				// b2.WriteString(fmt.Sprint(val))
-				appendStringValue(&b, fmt.Sprint(val))
+			if _, ok := f.hiddenKeys[key]; ok {
+				continue
 			}
 
+			if multiline && key == stackTraceKey && !f.isMasking(key) {
+				continue
+			}
+
+			b2.Reset()
+
+			appendFieldValue(&b2, e.Payload[key], f.rawControlChars)
+
 			//-----
 			var b3 []byte
 
@@ -873,16 +1330,15 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 			if c, ok := f.highlightColors[key]; ok && isUseColor {
 				c.EnableColor()
 
-				b.WriteString(c.Sprintf("%s=%s", key, b3))
+				b.WriteString(c.Sprintf("%s=%s", fieldKey(key, f.rawControlChars), b3))
 			} else {
-				b.WriteString(key)
+				b.WriteString(fieldKey(key, f.rawControlChars))
 				b.WriteByte('=')
 				b.Write(b3)
 			}
 			//-----
 
-			b.WriteByte(',')
-			b.WriteByte(' ')
+			b.WriteString(d.sep)
 
 			isPayload = true
 		}
@@ -891,34 +1347,191 @@ func (f *consoleFormatter) Format(e *LogEntry) ([]byte, error) {
 	buf = b.Bytes()
 
 	if isSource || isTrace || isSpanID || isCorrelationId || isHttpRequest || isLabel || isPayload {
-		b.Truncate(len(buf) - 2)
-		b.WriteByte(' ')
-		b.WriteByte('}')
+		b.Truncate(len(buf) - len(d.sep))
+		b.WriteString(d.close)
 	} else {
-		// space }
-		b.Truncate(len(buf) - 3)
+		b.Truncate(len(buf) - len(d.open))
+	}
+
+	if multiline {
+		f.appendStackTrace(&b, e)
 	}
 
 	return b.Bytes(), nil
 }
 
+// appendStackTrace writes the stack trace of the entry below its fields, each line
+// indented, for WithMultilineErrors.
+func (f *consoleFormatter) appendStackTrace(b *bytes.Buffer, e *LogEntry) {
+	stack, ok := e.Payload[stackTraceKey].(string)
+	if !ok || stack == "" || f.isMasking(stackTraceKey) {
+		return
+	}
+
+	if _, ok := f.hiddenKeys[stackTraceKey]; ok {
+		return
+	}
+
+	b.WriteString("\n    ")
+	b.WriteString(stackTraceKey)
+	b.WriteByte(':')
+
+	for _, line := range strings.Split(stack, "\n") {
+		b.WriteString("\n        ")
+
+		// The file lines of a frame are indented with a tab, which is expanded so that
+		// it is not escaped.
+		if rest, ok := strings.CutPrefix(line, "\t"); ok {
+			b.WriteString("    ")
+			line = rest
+		}
+
+		f.appendMessageText(b, line)
+	}
+}
+
+// appendMessageText writes a part of the message, escaping control characters
+// unless they are written raw.
+func (f *consoleFormatter) appendMessageText(b *bytes.Buffer, s string) {
+	if f.rawControlChars {
+		b.WriteString(s)
+	} else {
+		appendEscapedControlChars(b, s)
+	}
+}
+
+// appendMessage writes the message of the entry. With WithMessageTemplate, it
+// substitutes the placeholders and returns the keys of the substituted fields.
+func (f *consoleFormatter) appendMessage(b *bytes.Buffer, e *LogEntry, isUseColor bool) map[string]struct{} {
+	msg := e.Message
+	if !f.rawControlChars {
+		msg = strings.TrimSuffix(msg, "\n")
+	}
+
+	if !f.messageTemplate || !strings.ContainsAny(msg, "{}") {
+		f.appendMessageText(b, msg)
+
+		return nil
+	}
+
+	var substituted map[string]struct{}
+
+	for {
+		i := strings.IndexAny(msg, "{}")
+		if i < 0 {
+			f.appendMessageText(b, msg)
+
+			return substituted
+		}
+
+		f.appendMessageText(b, msg[:i])
+		msg = msg[i:]
+
+		if strings.HasPrefix(msg, "{{") || strings.HasPrefix(msg, "}}") {
+			b.WriteByte(msg[0])
+			msg = msg[2:]
+
+			continue
+		}
+
+		if msg[0] == '{' {
+			if end := strings.IndexByte(msg, '}'); end > 0 {
+				key := msg[1:end]
+
+				if v, ok := e.Payload[key]; ok {
+					f.appendPlaceholderValue(b, key, v, isUseColor)
+
+					if substituted == nil {
+						substituted = make(map[string]struct{})
+					}
+
+					substituted[key] = struct{}{}
+					msg = msg[end+1:]
+
+					continue
+				}
+			}
+		}
+
+		// Not a placeholder of a field; the brace is written as it is.
+		b.WriteByte(msg[0])
+		msg = msg[1:]
+	}
+}
+
+// appendPlaceholderValue writes the value substituted for the placeholder of key.
+func (f *consoleFormatter) appendPlaceholderValue(b *bytes.Buffer, key string, v interface{}, isUseColor bool) {
+	var value bytes.Buffer
+
+	if f.isMasking(key) {
+		value.Write(maskedValueBytes)
+	} else {
+		switch val := v.(type) {
+		case string:
+			// Values read as part of the sentence, so strings are not quoted.
+			f.appendMessageText(&value, val)
+		case error:
+			f.appendMessageText(&value, val.Error())
+		case fmt.Stringer:
+			f.appendMessageText(&value, val.String())
+		default:
+			appendFieldValue(&value, v, f.rawControlChars)
+		}
+	}
+
+	if c, ok := f.highlightColors[key]; ok && isUseColor {
+		c.EnableColor()
+
+		b.WriteString(c.Sprint(value.String()))
+	} else {
+		b.Write(value.Bytes())
+	}
+}
+
 func (f *consoleFormatter) FormatMessageOnly(e *LogEntry) ([]byte, error) {
-	return formatBasicMessage(e), nil
+	return formatBasicMessage(e, f.timeEncoding), nil
 }
 
-// should UseColor determines if color should be used for the output.
+// shouldUseColor determines if color should be used for the output.
+// A non-empty HARELOG_FORCE_COLOR takes precedence. Following https://no-color.org/,
+// HARELOG_NO_COLOR and NO_COLOR disable color when present, even if empty.
 func (f *consoleFormatter) shouldUseColor() bool {
-	if os.Getenv("HARELOG_NO_COLOR") != "" || os.Getenv("NO_COLOR") != "" {
+	if os.Getenv("HARELOG_FORCE_COLOR") != "" {
+		return true
+	}
+
+	if _, ok := os.LookupEnv("HARELOG_NO_COLOR"); ok {
 		return false
 	}
 
-	if os.Getenv("HARELOG_FORCE_COLOR") != "" {
-		return true
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
 	}
 
+	return isTerminal()
+}
+
+// isTerminal reports whether os.Stdout or os.Stderr is attached to a terminal.
+func isTerminal() bool {
 	return isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsTerminal(os.Stderr.Fd())
 }
 
+// AutoFormatter returns a ConsoleFormatter with log level colors if os.Stdout or
+// os.Stderr is a terminal (or HARELOG_FORCE_COLOR is set), and a JSONFormatter otherwise.
+// This gives human-readable output during local development and structured output
+// in production, e.g. New(WithFormatter(AutoFormatter())).
+//
+// The decision is made once, when AutoFormatter is called; later changes to the
+// environment or to the attached terminal are not taken into account.
+// NO_COLOR and HARELOG_NO_COLOR still disable colors of the ConsoleFormatter.
+func AutoFormatter() Formatter {
+	if os.Getenv("HARELOG_FORCE_COLOR") != "" || isTerminal() {
+		return Console.NewFormatter(Console.WithLogLevelColor(true))
+	}
+
+	return JSON.NewFormatter()
+}
+
 // toFatihAttribute converts our public ColorAttribute to an internal fatih/color.Attribute.
 func toFatihAttribute(attr ColorAttribute) color.Attribute {
 	switch attr {
@@ -948,16 +1561,88 @@ func toFatihAttribute(attr ColorAttribute) color.Attribute {
 }
 
 // appendStringValue use Quote for safety if needed
-func appendStringValue(b *bytes.Buffer, value string) {
+func appendStringValue(b *bytes.Buffer, value string, rawControlChars bool) {
 	value = strings.TrimSuffix(value, "\n")
 
-	if needsQuoting(value) {
+	if needsQuoting(value) || (!rawControlChars && containsControlChar(value)) {
 		b.WriteString(strconv.Quote(value))
 	} else {
 		b.WriteString(value)
 	}
 }
 
+// fieldKey returns a label or payload key as it is written, quoted like a value if
+// it contains control characters, so that a key cannot break the entry's line either.
+func fieldKey(key string, rawControlChars bool) string {
+	if !rawControlChars && containsControlChar(key) {
+		return strconv.Quote(key)
+	}
+
+	return key
+}
+
+// appendFieldValue writes a payload value as a key=value value: booleans and numbers
+// as they are, and strings and other values (see stringValue) as strings, quoted if needed.
+func appendFieldValue(b *bytes.Buffer, value interface{}, rawControlChars bool) {
+	var scratch [64]byte
+
+	switch val := value.(type) {
+	case string:
+		appendStringValue(b, val, rawControlChars)
+	case bool:
+		b.Write(strconv.AppendBool(scratch[:0], val))
+	case int:
+		b.Write(strconv.AppendInt(scratch[:0], int64(val), 10))
+	case int32:
+		b.Write(strconv.AppendInt(scratch[:0], int64(val), 10))
+	case int64:
+		b.Write(strconv.AppendInt(scratch[:0], val, 10))
+	case float32:
+		b.Write(strconv.AppendFloat(scratch[:0], float64(val), 'f', -1, 64))
+	case float64:
+		b.Write(strconv.AppendFloat(scratch[:0], val, 'f', -1, 64))
+	default:
+		appendStringValue(b, stringValue(val), rawControlChars)
+	}
+}
+
+// stringValue returns the string form of a payload value for the text-based formatters:
+// the JSON text of a json.RawMessage, the Error of an error, the String of a fmt.Stringer,
+// the MarshalText of an encoding.TextMarshaler, and the fmt.Sprint form of other values.
+// A value implementing several of these interfaces is written by the first one, in this
+// order, which follows the precedence of the fmt package for error and fmt.Stringer.
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case json.RawMessage:
+		return string(val)
+	case error:
+		return val.Error()
+	case fmt.Stringer:
+		return val.String()
+	case encoding.TextMarshaler:
+		if text, err := val.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	return fmt.Sprint(v)
+}
+
+// AppendLogfmtValue appends v to dst as a logfmt value, as LogfmtFormatter writes
+// payload values, and returns the extended slice. Booleans and numbers are written
+// as they are. Strings and other values (json.RawMessage as its JSON text, fmt.Stringer
+// and encoding.TextMarshaler values as their text, and the rest in their fmt.Sprint
+// form) are quoted as Go string literals if they are empty or contain spaces, '=',
+// '"' or control characters. A trailing newline is removed.
+// It is intended for custom hooks and formatters producing logfmt.
+func AppendLogfmtValue(dst []byte, v any) []byte {
+	b := bytes.NewBuffer(dst)
+
+	appendFieldValue(b, v, false)
+
+	return b.Bytes()
+}
+
 var Logfmt = logfmtOptions{}
 
 type LogfmtFormatterOption func(f *logfmtFormatter)
@@ -979,6 +1664,44 @@ func (logfmtOptions) WithMaskingKeysIgnoreCase(keys ...string) LogfmtFormatterOp
 	}
 }
 
+// WithMaskingKeysFromEnv adds the keys listed in the environment variable envVar
+// for masking in LogfmtFormatter, ignoring case, like WithMaskingKeysIgnoreCase.
+// The variable is read when the formatter is created and holds a comma-separated
+// list, e.g. "password, token,api_key"; spaces around the keys are trimmed.
+// The keys are merged with those set by the other masking options.
+func (logfmtOptions) WithMaskingKeysFromEnv(envVar string) LogfmtFormatterOption {
+	return func(f *logfmtFormatter) {
+		f.addInsensitiveFromEnv(envVar)
+	}
+}
+
+// WithMaskingQueryParams sets the URL query parameters whose values are masked
+// in the HTTPRequest's RequestURL in LogfmtFormatter. Parameter names are matched case-insensitively.
+func (logfmtOptions) WithMaskingQueryParams(keys ...string) LogfmtFormatterOption {
+	return func(f *logfmtFormatter) {
+		f.addQueryParams(keys...)
+	}
+}
+
+// WithTimeEncoding sets how the timestamp is encoded in LogfmtFormatter.
+// The default is TimeRFC3339.
+func (logfmtOptions) WithTimeEncoding(enc TimeEncoding) LogfmtFormatterOption {
+	validateTimeEncoding(enc)
+
+	return func(f *logfmtFormatter) {
+		f.timeEncoding = enc
+	}
+}
+
+// WithControlCharEscaping sets whether keys and values containing control characters,
+// including the message, are quoted in LogfmtFormatter, so that each entry stays on
+// exactly one line. It is enabled by default. See Text.WithControlCharEscaping for details.
+func (logfmtOptions) WithControlCharEscaping(enabled bool) LogfmtFormatterOption {
+	return func(f *logfmtFormatter) {
+		f.rawControlChars = !enabled
+	}
+}
+
 // NewLogfmtFormatter creates a new LogfmtFormatter.
 func (logfmtOptions) NewFormatter(opts ...LogfmtFormatterOption) *logfmtFormatter {
 	formatter := &logfmtFormatter{}
@@ -996,6 +1719,8 @@ func (logfmtOptions) NewFormatter(opts ...LogfmtFormatterOption) *logfmtFormatte
 // Values containing spaces, '=', or '"' characters will be double-quoted.
 type logfmtFormatter struct {
 	maskingCore
+	timeEncoding    TimeEncoding
+	rawControlChars bool
 }
 
 // Deprecated: Use harelog.Logfmt.NewFormatter instead.
@@ -1014,7 +1739,7 @@ func (f *logfmtFormatter) Format(e *LogEntry) ([]byte, error) {
 	b.Grow(128)
 	b.WriteString("timestamp")
 	b.WriteByte('=')
-	b.Write(e.Time.AppendFormat(scratch[:0], time.RFC3339))
+	b.Write(e.appendTime(scratch[:0], f.timeEncoding))
 	b.WriteByte(' ')
 
 	// Severity
@@ -1027,7 +1752,7 @@ func (f *logfmtFormatter) Format(e *LogEntry) ([]byte, error) {
 	b.WriteString("message")
 	b.WriteByte('=')
 
-	appendStringValue(&b, e.Message)
+	appendStringValue(&b, e.Message, f.rawControlChars)
 
 	b.WriteByte(' ')
 
@@ -1062,7 +1787,7 @@ func (f *logfmtFormatter) Format(e *LogEntry) ([]byte, error) {
 	if e.Trace != "" {
 		b.WriteString("trace")
 		b.WriteByte('=')
-		appendStringValue(&b, e.Trace)
+		appendStringValue(&b, e.Trace, f.rawControlChars)
 		b.WriteByte(' ')
 
 		isTrace = true
@@ -1071,7 +1796,7 @@ func (f *logfmtFormatter) Format(e *LogEntry) ([]byte, error) {
 	if e.SpanID != "" {
 		b.WriteString("spanId")
 		b.WriteByte('=')
-		appendStringValue(&b, e.SpanID)
+		appendStringValue(&b, e.SpanID, f.rawControlChars)
 		b.WriteByte(' ')
 
 		isSpanID = true
@@ -1080,7 +1805,7 @@ func (f *logfmtFormatter) Format(e *LogEntry) ([]byte, error) {
 	if e.CorrelationID != "" {
 		b.WriteString("correlationId")
 		b.WriteByte('=')
-		appendStringValue(&b, e.CorrelationID)
+		appendStringValue(&b, e.CorrelationID, f.rawControlChars)
 		b.WriteByte(' ')
 
 		isCorrelationId = true
@@ -1091,7 +1816,7 @@ func (f *logfmtFormatter) Format(e *LogEntry) ([]byte, error) {
 		if e.HTTPRequest.RequestMethod != "" {
 			b.WriteString("http.method")
 			b.WriteByte('=')
-			appendStringValue(&b, e.HTTPRequest.RequestMethod)
+			appendStringValue(&b, e.HTTPRequest.RequestMethod, f.rawControlChars)
 			b.WriteByte(' ')
 
 			isHttpRequest = true
@@ -1107,7 +1832,7 @@ func (f *logfmtFormatter) Format(e *LogEntry) ([]byte, error) {
 		if e.HTTPRequest.RequestURL != "" {
 			b.WriteString("http.url")
 			b.WriteByte('=')
-			appendStringValue(&b, e.HTTPRequest.RequestURL)
+			appendStringValue(&b, f.maskURL(e.HTTPRequest.RequestURL), f.rawControlChars)
 			b.WriteByte(' ')
 
 			isHttpRequest = true
@@ -1126,13 +1851,13 @@ func (f *logfmtFormatter) Format(e *LogEntry) ([]byte, error) {
 		for _, key := range keys {
 			b.WriteString("label")
 			b.WriteByte('.')
-			b.WriteString(key)
+			b.WriteString(fieldKey(key, f.rawControlChars))
 			b.WriteByte('=')
 
 			if f.isMasking(key) {
 				b.WriteString(maskedValueString)
 			} else {
-				appendStringValue(&b, e.Labels[key])
+				appendStringValue(&b, e.Labels[key], f.rawControlChars)
 			}
 
 			b.WriteByte(' ')
@@ -1165,44 +1890,13 @@ func (f *logfmtFormatter) Format(e *LogEntry) ([]byte, error) {
 				continue
 			}
 
-			b.WriteString(key)
+			b.WriteString(fieldKey(key, f.rawControlChars))
 			b.WriteString("=")
 
 			if f.isMasking(key) {
 				b.WriteString(maskedValueString)
 			} else {
-				switch val := e.Payload[key].(type) {
-				case string:
-					appendStringValue(&b, val)
-				case bool:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendBool(scratch[:0], val))
-				case int:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendInt(scratch[:0], int64(val), 10))
-				case int32:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendInt(scratch[:0], int64(val), 10))
-				case int64:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendInt(scratch[:0], val, 10))
-				case float32:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendFloat(scratch[:0], float64(val), 'f', -1, 64))
-				case float64:
-					scratch := [64]byte{}
-
-					b.Write(strconv.AppendFloat(scratch[:0], val, 'f', -1, 64))
-				case fmt.Stringer:
-					appendStringValue(&b, val.String())
-				default:
-					appendStringValue(&b, fmt.Sprint(val))
-				}
+				appendFieldValue(&b, e.Payload[key], f.rawControlChars)
 			}
 
 			b.WriteByte(' ')
@@ -1224,7 +1918,7 @@ func (f *logfmtFormatter) FormatMessageOnly(e *LogEntry) ([]byte, error) {
 	b.Grow(42)
 	b.WriteString("timestamp")
 	b.WriteByte('=')
-	b.Write(e.Time.AppendFormat(nil, time.RFC3339))
+	b.Write(e.appendTime(nil, f.timeEncoding))
 	b.WriteByte(' ')
 
 	// Severity
@@ -1237,7 +1931,7 @@ func (f *logfmtFormatter) FormatMessageOnly(e *LogEntry) ([]byte, error) {
 	b.WriteString("message")
 	b.WriteByte('=')
 
-	appendStringValue(&b, e.Message)
+	appendStringValue(&b, e.Message, f.rawControlChars)
 
 	return b.Bytes(), nil
 }
@@ -0,0 +1,207 @@
+package harelog
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// accessLogTimeLayout is the timestamp layout used by the Common and Combined Log Formats.
+const accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+var AccessLog = accessLogOptions{}
+
+// AccessLogFormatterOption is a functional option for configuring an AccessLogFormatter.
+type AccessLogFormatterOption func(f *accessLogFormatter)
+
+type accessLogOptions struct{}
+
+// NewFormatter creates a new AccessLogFormatter.
+func (accessLogOptions) NewFormatter(opts ...AccessLogFormatterOption) *accessLogFormatter {
+	formatter := &accessLogFormatter{}
+
+	for _, opt := range opts {
+		opt(formatter)
+	}
+
+	return formatter
+}
+
+// WithMaskingQueryParams sets the URL query parameters whose values are masked
+// in the request line of AccessLogFormatter. Parameter names are matched case-insensitively.
+func (accessLogOptions) WithMaskingQueryParams(keys ...string) AccessLogFormatterOption {
+	return func(f *accessLogFormatter) {
+		f.addQueryParams(keys...)
+	}
+}
+
+// accessLogFormatter formats log entries in the Apache/Nginx Combined Log Format:
+//
+//	%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+//
+// The values are taken from the entry's HTTPRequest, its timestamp, and the
+// well-known payload keys "user", "referer", "responseSize", and "protocol".
+// Missing values are written as "-". All other fields, including the message, are ignored.
+type accessLogFormatter struct {
+	maskingCore
+}
+
+// Format converts a logEntry into a line in the Combined Log Format.
+func (f *accessLogFormatter) Format(e *LogEntry) ([]byte, error) {
+	var b bytes.Buffer
+	var scratch [64]byte
+
+	req := e.HTTPRequest
+	if req == nil {
+		req = &HTTPRequest{}
+	}
+
+	b.Grow(256)
+
+	// %h
+	appendAccessLogValue(&b, req.RemoteIP)
+	b.WriteByte(' ')
+
+	// %l
+	b.WriteByte('-')
+	b.WriteByte(' ')
+
+	// %u
+	appendAccessLogValue(&b, payloadString(e.Payload, "user"))
+	b.WriteByte(' ')
+
+	// %t
+	b.WriteByte('[')
+	if e.timeEncoder != nil {
+		b.Write(e.timeEncoder(nil, e.Time))
+	} else {
+		b.Write(e.Time.AppendFormat(scratch[:0], accessLogTimeLayout))
+	}
+	b.WriteByte(']')
+	b.WriteByte(' ')
+
+	// "%r"
+	b.WriteByte('"')
+
+	if req.RequestMethod == "" && req.RequestURL == "" {
+		b.WriteByte('-')
+	} else {
+		writeQuotedContent(&b, req.RequestMethod)
+		b.WriteByte(' ')
+		writeQuotedContent(&b, requestURI(f.maskURL(req.RequestURL)))
+
+		if protocol := payloadString(e.Payload, "protocol"); protocol != "" {
+			b.WriteByte(' ')
+			writeQuotedContent(&b, protocol)
+		}
+	}
+
+	b.WriteByte('"')
+	b.WriteByte(' ')
+
+	// %>s
+	if req.Status != 0 {
+		b.Write(strconv.AppendInt(scratch[:0], int64(req.Status), 10))
+	} else {
+		b.WriteByte('-')
+	}
+
+	b.WriteByte(' ')
+
+	// %b
+	appendAccessLogValue(&b, payloadString(e.Payload, "responseSize"))
+	b.WriteByte(' ')
+
+	// "%{Referer}i"
+	b.WriteByte('"')
+	appendQuotedAccessLogValue(&b, payloadString(e.Payload, "referer"))
+	b.WriteByte('"')
+	b.WriteByte(' ')
+
+	// "%{User-agent}i"
+	b.WriteByte('"')
+	appendQuotedAccessLogValue(&b, req.UserAgent)
+	b.WriteByte('"')
+
+	return b.Bytes(), nil
+}
+
+// FormatMessageOnly formats only the timestamp, severity, and message fields.
+// This is used internally by the logger to output warnings about invalid keys.
+func (f *accessLogFormatter) FormatMessageOnly(e *LogEntry) ([]byte, error) {
+	return formatBasicMessage(e, TimeRFC3339), nil
+}
+
+// requestURI returns the path and query of rawURL for the request line.
+// If rawURL cannot be parsed or has no path, it is returned unchanged.
+func requestURI(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	return u.RequestURI()
+}
+
+// payloadString returns the payload value for key as a string, or "" if it is absent.
+func payloadString(payload map[string]interface{}, key string) string {
+	v, ok := payload[key]
+	if !ok || v == nil {
+		return ""
+	}
+
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	return stringValue(v)
+}
+
+// appendAccessLogValue writes an unquoted field, replacing empty values with "-"
+// and whitespace with '_' so that the line stays parseable.
+func appendAccessLogValue(b *bytes.Buffer, value string) {
+	if value == "" {
+		b.WriteByte('-')
+
+		return
+	}
+
+	b.WriteString(strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return '_'
+		}
+
+		return r
+	}, value))
+}
+
+// appendQuotedAccessLogValue writes the content of a quoted field, replacing empty values with "-".
+func appendQuotedAccessLogValue(b *bytes.Buffer, value string) {
+	if value == "" {
+		b.WriteByte('-')
+
+		return
+	}
+
+	writeQuotedContent(b, value)
+}
+
+// writeQuotedContent writes value escaping '"', '\' and control characters,
+// as done by Apache and Nginx for quoted fields.
+func writeQuotedContent(b *bytes.Buffer, value string) {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c == 0x7f:
+			fmt.Fprintf(b, "\\x%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package harelog
+
+import "strings"
+
+var Bare = bareOptions{}
+
+// BareFormatterOption is a functional option for configuring a BareFormatter.
+type BareFormatterOption func(*bareFormatter)
+
+type bareOptions struct{}
+
+// NewFormatter creates a new BareFormatter.
+func (bareOptions) NewFormatter(opts ...BareFormatterOption) *bareFormatter {
+	formatter := &bareFormatter{}
+
+	for _, opt := range opts {
+		opt(formatter)
+	}
+
+	return formatter
+}
+
+// WithMaskingKeys sets the keys for masking in BareFormatter.
+func (bareOptions) WithMaskingKeys(keys ...string) BareFormatterOption {
+	return func(f *bareFormatter) {
+		f.addSensitive(keys...)
+	}
+}
+
+// WithMaskingKeysIgnoreCase sets the keys for masking in BareFormatter,
+// ignoring case.
+func (bareOptions) WithMaskingKeysIgnoreCase(keys ...string) BareFormatterOption {
+	return func(f *bareFormatter) {
+		f.addInsensitive(keys...)
+	}
+}
+
+// WithMaskingKeysFromEnv adds the keys listed in the environment variable envVar
+// for masking in BareFormatter, ignoring case, like WithMaskingKeysIgnoreCase.
+// The variable is read when the formatter is created and holds a comma-separated
+// list, e.g. "password, token,api_key"; spaces around the keys are trimmed.
+// The keys are merged with those set by the other masking options.
+func (bareOptions) WithMaskingKeysFromEnv(envVar string) BareFormatterOption {
+	return func(f *bareFormatter) {
+		f.addInsensitiveFromEnv(envVar)
+	}
+}
+
+// WithMaskingQueryParams sets the URL query parameters whose values are masked
+// in the http.url field in BareFormatter. Parameter names are matched case-insensitively.
+func (bareOptions) WithMaskingQueryParams(keys ...string) BareFormatterOption {
+	return func(f *bareFormatter) {
+		f.addQueryParams(keys...)
+	}
+}
+
+// WithControlCharEscaping sets whether control characters are escaped in BareFormatter.
+// It is enabled by default. See Text.WithControlCharEscaping for details.
+func (bareOptions) WithControlCharEscaping(enabled bool) BareFormatterOption {
+	return func(f *bareFormatter) {
+		f.rawControlChars = !enabled
+	}
+}
+
+// bareFormatter formats log entries like textFormatter, but without the timestamp
+// and level, so that the output nests cleanly in another logger that adds its own.
+//
+//	user logged in { userID=user-123 }
+type bareFormatter struct {
+	textFormatter
+}
+
+// Format converts a logEntry to a single line of the message and fields.
+func (f *bareFormatter) Format(e *LogEntry) ([]byte, error) {
+	return f.format(e, false)
+}
+
+// FormatMessageOnly formats only the message.
+// This is used internally by the logger to output warnings about invalid keys.
+func (f *bareFormatter) FormatMessageOnly(e *LogEntry) ([]byte, error) {
+	return []byte(strings.TrimSuffix(e.Message, "\n")), nil
+}
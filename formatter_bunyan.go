@@ -0,0 +1,238 @@
+package harelog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	json "github.com/goccy/go-json"
+)
+
+// bunyanTimeLayout is the timestamp layout of Bunyan records, as written by
+// JavaScript's Date.prototype.toISOString.
+const bunyanTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// bunyanLevels maps each LogLevel to its Bunyan level number.
+var bunyanLevels = map[LogLevel]int{
+	LogLevelTrace:    10,
+	LogLevelDebug:    20,
+	LogLevelInfo:     30,
+	LogLevelWarn:     40,
+	LogLevelError:    50,
+	LogLevelCritical: 60,
+}
+
+// bunyanCoreFields are the keys of the core fields of a Bunyan record.
+// Payload fields with these keys are dropped.
+var bunyanCoreFields = map[string]struct{}{
+	"v": {}, "name": {}, "hostname": {}, "pid": {}, "level": {}, "msg": {}, "time": {}, "src": {},
+}
+
+var Bunyan = bunyanOptions{}
+
+// BunyanFormatterOption is a functional option for configuring a BunyanFormatter.
+type BunyanFormatterOption func(f *bunyanFormatter)
+
+type bunyanOptions struct{}
+
+// NewFormatter creates a new BunyanFormatter.
+// The hostname and process ID are read once, when the formatter is created.
+func (bunyanOptions) NewFormatter(opts ...BunyanFormatterOption) *bunyanFormatter {
+	hostname, _ := os.Hostname()
+
+	formatter := &bunyanFormatter{
+		name:     filepath.Base(os.Args[0]),
+		hostname: hostname,
+		pid:      os.Getpid(),
+	}
+
+	for _, opt := range opts {
+		opt(formatter)
+	}
+
+	return formatter
+}
+
+// WithName sets the "name" field of BunyanFormatter, which Bunyan uses for the
+// logger name. The default is the base name of the executable.
+func (bunyanOptions) WithName(name string) BunyanFormatterOption {
+	return func(f *bunyanFormatter) {
+		f.name = name
+	}
+}
+
+// WithMaskingKeys sets the keys for masking in BunyanFormatter.
+func (bunyanOptions) WithMaskingKeys(keys ...string) BunyanFormatterOption {
+	return func(f *bunyanFormatter) {
+		f.addSensitive(keys...)
+	}
+}
+
+// WithMaskingKeysIgnoreCase sets the keys for masking in BunyanFormatter,
+// ignoring case.
+func (bunyanOptions) WithMaskingKeysIgnoreCase(keys ...string) BunyanFormatterOption {
+	return func(f *bunyanFormatter) {
+		f.addInsensitive(keys...)
+	}
+}
+
+// WithMaskingKeysFromEnv adds the keys listed in the environment variable envVar
+// for masking in BunyanFormatter, ignoring case. See JSON.WithMaskingKeysFromEnv.
+func (bunyanOptions) WithMaskingKeysFromEnv(envVar string) BunyanFormatterOption {
+	return func(f *bunyanFormatter) {
+		f.addInsensitiveFromEnv(envVar)
+	}
+}
+
+// WithMaskingQueryParams sets the URL query parameters whose values are masked
+// in the HTTPRequest's RequestURL in BunyanFormatter. Parameter names are matched case-insensitively.
+func (bunyanOptions) WithMaskingQueryParams(keys ...string) BunyanFormatterOption {
+	return func(f *bunyanFormatter) {
+		f.addQueryParams(keys...)
+	}
+}
+
+// bunyanFormatter formats log entries as Bunyan JSON records, which the bunyan
+// and pino-pretty command-line tools read:
+//
+//	{"v":0,"name":"app","hostname":"host","pid":42,"level":30,"msg":"started","time":"2025-10-14T13:30:00.123Z"}
+//
+// The levels are written as Bunyan level numbers: TRACE=10, DEBUG=20, INFO=30,
+// WARN=40, ERROR=50 and CRITICAL=60 (Bunyan's fatal). The time is written in UTC
+// with millisecond precision, unless the logger has a TimeEncoder. The source
+// location is written as Bunyan's "src" object; the other fields, such as the
+// trace and labels, keep their usual names, and the payload fields follow.
+type bunyanFormatter struct {
+	maskingCore
+	name     string
+	hostname string
+	pid      int
+}
+
+// Format converts a logEntry into a Bunyan JSON record.
+func (f *bunyanFormatter) Format(e *LogEntry) ([]byte, error) {
+	for k := range e.Labels {
+		if f.isMasking(k) {
+			e.Labels[k] = maskedValueString
+		}
+	}
+
+	for k := range e.Payload {
+		if _, ok := bunyanCoreFields[k]; ok {
+			delete(e.Payload, k)
+		} else if f.isMasking(k) {
+			e.Payload[k] = maskedValueString
+		}
+	}
+
+	b, err := f.appendCore(make([]byte, 0, 256), e)
+	if err != nil {
+		return nil, err
+	}
+
+	var src interface{}
+	if e.SourceLocation != nil {
+		src = struct {
+			File string `json:"file"`
+			Line int    `json:"line"`
+			Func string `json:"func,omitempty"`
+		}{e.SourceLocation.File, e.SourceLocation.Line, e.SourceLocation.Function}
+	}
+
+	fields := []struct {
+		key   string
+		value interface{}
+		ok    bool
+	}{
+		{"src", src, e.SourceLocation != nil},
+		{"logging.googleapis.com/trace", e.Trace, e.Trace != ""},
+		{"logging.googleapis.com/spanId", e.SpanID, e.SpanID != ""},
+		{"logging.googleapis.com/trace_sampled", e.TraceSampled, e.TraceSampled != nil},
+		{"httpRequest", f.maskHTTPRequest(e.HTTPRequest), e.HTTPRequest != nil},
+		{"labels", e.Labels, len(e.Labels) > 0},
+		{"correlationId", e.CorrelationID, e.CorrelationID != ""},
+	}
+
+	for _, field := range fields {
+		if !field.ok {
+			continue
+		}
+
+		v, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, ',')
+		b = strconv.AppendQuote(b, field.key)
+		b = append(b, ':')
+		b = append(b, v...)
+	}
+
+	if len(e.Payload) > 0 {
+		payloadBytes, err := marshalPayload(e.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, ',')
+		b = append(b, payloadBytes[1:len(payloadBytes)-1]...)
+	}
+
+	return append(b, '}'), nil
+}
+
+// FormatMessageOnly formats only the core fields of a Bunyan record.
+// This is used internally by the logger to output warnings about invalid keys.
+func (f *bunyanFormatter) FormatMessageOnly(e *LogEntry) ([]byte, error) {
+	b, err := f.appendCore(make([]byte, 0, 128), e)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '}'), nil
+}
+
+// appendCore appends the opening brace and the core fields of a Bunyan record to b.
+func (f *bunyanFormatter) appendCore(b []byte, e *LogEntry) ([]byte, error) {
+	b = append(b, `{"v":0,"name":`...)
+	b = strconv.AppendQuote(b, f.name)
+	b = append(b, `,"hostname":`...)
+	b = strconv.AppendQuote(b, f.hostname)
+	b = append(b, `,"pid":`...)
+	b = strconv.AppendInt(b, int64(f.pid), 10)
+	b = append(b, `,"level":`...)
+	b = strconv.AppendInt(b, int64(bunyanLevel(e.Severity)), 10)
+
+	msg, err := json.Marshal(e.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, `,"msg":`...)
+	b = append(b, msg...)
+	b = append(b, `,"time":`...)
+
+	if e.timeEncoder != nil {
+		ts, err := encodeJSONTime(e.timeEncoder, e.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(b, ts...), nil
+	}
+
+	b = append(b, '"')
+	b = e.Time.UTC().AppendFormat(b, bunyanTimeLayout)
+
+	return append(b, '"'), nil
+}
+
+// bunyanLevel returns the Bunyan level number of level. Unknown levels are written as INFO.
+func bunyanLevel(level LogLevel) int {
+	if n, ok := bunyanLevels[level]; ok {
+		return n
+	}
+
+	return bunyanLevels[LogLevelInfo]
+}
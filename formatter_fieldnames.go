@@ -0,0 +1,161 @@
+package harelog
+
+import (
+	"strconv"
+
+	json "github.com/goccy/go-json"
+)
+
+// jsonFieldNames defines the names of the core fields written by a JSONFormatter
+// using a non-GCP field convention, and the level strings it uses.
+type jsonFieldNames struct {
+	message        string
+	level          string
+	time           string
+	sourceLocation string // empty to write the source location under its GCP name
+	levels         map[LogLevel]string
+}
+
+// zapFieldNames matches the field names and lowercase levels of zap's production encoder.
+var zapFieldNames = &jsonFieldNames{
+	message:        "msg",
+	level:          "level",
+	time:           "ts",
+	sourceLocation: "caller",
+	levels: map[LogLevel]string{
+		LogLevelCritical: "fatal",
+		LogLevelError:    "error",
+		LogLevelWarn:     "warn",
+		LogLevelInfo:     "info",
+		LogLevelDebug:    "debug",
+		LogLevelTrace:    "debug", // zap has no trace level
+	},
+}
+
+// logrusFieldNames matches the field names and lowercase levels of logrus's JSONFormatter.
+var logrusFieldNames = &jsonFieldNames{
+	message: "msg",
+	level:   "level",
+	time:    "time",
+	levels: map[LogLevel]string{
+		LogLevelCritical: "fatal",
+		LogLevelError:    "error",
+		LogLevelWarn:     "warning",
+		LogLevelInfo:     "info",
+		LogLevelDebug:    "debug",
+		LogLevelTrace:    "trace",
+	},
+}
+
+// ZapFieldNames makes JSONFormatter use zap's field conventions: "msg", "level", "ts"
+// and "caller" ("file:line"), with lowercase levels ("warn"; Critical is "fatal").
+// The timestamp is written as epoch seconds (TimeEpochFloat) as zap's production
+// encoder does; a later WithTimeEncoding overrides it.
+// The remaining fields, such as the trace and labels, keep their usual names.
+func (jsonOptions) ZapFieldNames() JSONFormatterOption {
+	return func(f *jsonFormatter) {
+		f.fieldNames = zapFieldNames
+		f.timeEncoding = TimeEpochFloat
+	}
+}
+
+// WithCallerField makes JSONFormatter write the source location as a "caller" field
+// holding "file:line" (e.g. "app/server.go:152"), as zap and logrus do, instead of
+// the nested "logging.googleapis.com/sourceLocation" object, which is the default.
+// It is meant for non-GCP backends, alone or with LogrusFieldNames; ZapFieldNames
+// already implies it.
+func (jsonOptions) WithCallerField(enabled bool) JSONFormatterOption {
+	return func(f *jsonFormatter) {
+		f.callerField = enabled
+	}
+}
+
+// LogrusFieldNames makes JSONFormatter use logrus's field conventions: "msg", "level"
+// and "time", with lowercase levels ("warning"; Critical is "fatal").
+// The remaining fields, such as the trace and labels, keep their usual names.
+func (jsonOptions) LogrusFieldNames() JSONFormatterOption {
+	return func(f *jsonFormatter) {
+		f.fieldNames = logrusFieldNames
+	}
+}
+
+// formatWithFieldNames formats the entry as JSON using f.fieldNames.
+// Masking must already have been applied to the labels and payload.
+func (f *jsonFormatter) formatWithFieldNames(e *LogEntry) ([]byte, error) {
+	names := f.fieldNames
+
+	b := make([]byte, 0, 256)
+	b = append(b, '{')
+
+	appendField := func(key string, value interface{}) error {
+		v, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		if len(b) > 1 {
+			b = append(b, ',')
+		}
+
+		b = strconv.AppendQuote(b, key)
+		b = append(b, ':')
+		b = append(b, v...)
+
+		return nil
+	}
+
+	// The source location is written as "file:line" under the convention's name, if any,
+	// or under "caller" with WithCallerField.
+	var caller interface{} = e.SourceLocation
+
+	callerKey := "logging.googleapis.com/sourceLocation"
+	if (names.sourceLocation != "" || f.callerField) && e.SourceLocation != nil {
+		caller = e.SourceLocation.caller()
+		callerKey = names.sourceLocation
+		if callerKey == "" {
+			callerKey = "caller"
+		}
+	}
+
+	fields := []struct {
+		key   string
+		value interface{}
+		ok    bool
+	}{
+		{names.time, jsonTime{Time: e.Time, encoding: f.timeEncoding, encoder: e.timeEncoder}, !e.Time.IsZero()},
+		{names.level, names.levels[e.Severity], e.Severity != ""},
+		{callerKey, caller, e.SourceLocation != nil},
+		{names.message, e.Message, true},
+		{"logging.googleapis.com/trace", e.Trace, e.Trace != ""},
+		{"logging.googleapis.com/spanId", e.SpanID, e.SpanID != ""},
+		{"logging.googleapis.com/trace_sampled", e.TraceSampled, e.TraceSampled != nil},
+		{"httpRequest", f.maskHTTPRequest(e.HTTPRequest), e.HTTPRequest != nil},
+		{"labels", e.Labels, len(e.Labels) > 0},
+		{"correlationId", e.CorrelationID, e.CorrelationID != ""},
+	}
+
+	for _, field := range fields {
+		if !field.ok {
+			continue
+		}
+
+		if err := appendField(field.key, field.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(e.Payload) == 0 {
+		return append(b, '}'), nil
+	}
+
+	payloadBytes, err := marshalPayload(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > 1 {
+		b = append(b, ',')
+	}
+
+	return append(b, payloadBytes[1:]...), nil
+}
@@ -0,0 +1,196 @@
+package harelog
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	json "github.com/goccy/go-json"
+)
+
+var Hybrid = hybridOptions{}
+
+// HybridFormatterOption is a functional option for configuring a HybridFormatter.
+type HybridFormatterOption func(*hybridFormatter)
+
+type hybridOptions struct{}
+
+// NewFormatter creates a new HybridFormatter.
+func (hybridOptions) NewFormatter(opts ...HybridFormatterOption) *hybridFormatter {
+	formatter := &hybridFormatter{}
+
+	for _, opt := range opts {
+		opt(formatter)
+	}
+
+	return formatter
+}
+
+// WithMaskingKeys sets the keys for masking in HybridFormatter.
+func (hybridOptions) WithMaskingKeys(keys ...string) HybridFormatterOption {
+	return func(f *hybridFormatter) {
+		f.addSensitive(keys...)
+	}
+}
+
+// WithMaskingKeysIgnoreCase sets the keys for masking in HybridFormatter,
+// ignoring case.
+func (hybridOptions) WithMaskingKeysIgnoreCase(keys ...string) HybridFormatterOption {
+	return func(f *hybridFormatter) {
+		f.addInsensitive(keys...)
+	}
+}
+
+// WithMaskingKeysFromEnv adds the keys listed in the environment variable envVar
+// for masking in HybridFormatter, ignoring case. See JSON.WithMaskingKeysFromEnv.
+func (hybridOptions) WithMaskingKeysFromEnv(envVar string) HybridFormatterOption {
+	return func(f *hybridFormatter) {
+		f.addInsensitiveFromEnv(envVar)
+	}
+}
+
+// WithMaskingQueryParams sets the URL query parameters whose values are masked
+// in the HTTPRequest's RequestURL in HybridFormatter. Parameter names are matched case-insensitively.
+func (hybridOptions) WithMaskingQueryParams(keys ...string) HybridFormatterOption {
+	return func(f *hybridFormatter) {
+		f.addQueryParams(keys...)
+	}
+}
+
+// WithTimeEncoding sets how the timestamp is encoded in HybridFormatter.
+// The default is TimeRFC3339.
+func (hybridOptions) WithTimeEncoding(enc TimeEncoding) HybridFormatterOption {
+	validateTimeEncoding(enc)
+
+	return func(f *hybridFormatter) {
+		f.timeEncoding = enc
+	}
+}
+
+// WithControlCharEscaping sets whether control characters in the message are escaped
+// in HybridFormatter. It is enabled by default. See Text.WithControlCharEscaping for
+// details. The JSON object is always escaped.
+func (hybridOptions) WithControlCharEscaping(enabled bool) HybridFormatterOption {
+	return func(f *hybridFormatter) {
+		f.rawControlChars = !enabled
+	}
+}
+
+// hybridFormatter formats log entries as the header of TextFormatter followed by a
+// compact JSON object of the other fields, so that the output is both easy to scan
+// and machine-parseable:
+//
+//	2025-10-14T13:30:00Z [INFO] user logged in {"userID":"123"}
+//
+// The object holds the fields JSONFormatter writes, except the timestamp, severity
+// and message, under the same names, e.g. "logging.googleapis.com/trace" and "labels",
+// followed by the payload fields. It is omitted if the entry has no other fields.
+type hybridFormatter struct {
+	maskingCore
+	timeEncoding    TimeEncoding
+	rawControlChars bool
+}
+
+// Format converts a logEntry to a text header and a JSON object of the fields.
+func (f *hybridFormatter) Format(e *LogEntry) ([]byte, error) {
+	var b bytes.Buffer
+	var scratch [64]byte
+
+	b.Grow(128)
+
+	b.Write(e.appendTime(scratch[:0], f.timeEncoding))
+	b.WriteByte(' ')
+	b.WriteString(levelToken(e.Severity, false))
+	b.WriteByte(' ')
+
+	if f.rawControlChars {
+		b.WriteString(strings.TrimSuffix(e.Message, "\n"))
+	} else {
+		appendEscapedControlChars(&b, strings.TrimSuffix(e.Message, "\n"))
+	}
+
+	fields, err := f.appendFields(make([]byte, 0, 128), e)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) > 0 {
+		b.WriteByte(' ')
+		b.Write(fields)
+	}
+
+	return b.Bytes(), nil
+}
+
+// appendFields appends the JSON object of the fields of the entry other than the
+// timestamp, severity and message to b. It appends nothing if there are none.
+func (f *hybridFormatter) appendFields(b []byte, e *LogEntry) ([]byte, error) {
+	for k := range e.Labels {
+		if f.isMasking(k) {
+			e.Labels[k] = maskedValueString
+		}
+	}
+
+	for k := range e.Payload {
+		if f.isMasking(k) {
+			e.Payload[k] = maskedValueString
+		}
+	}
+
+	fields := []struct {
+		key   string
+		value interface{}
+		ok    bool
+	}{
+		{"logging.googleapis.com/sourceLocation", e.SourceLocation, e.SourceLocation != nil},
+		{"logging.googleapis.com/trace", e.Trace, e.Trace != ""},
+		{"logging.googleapis.com/spanId", e.SpanID, e.SpanID != ""},
+		{"logging.googleapis.com/trace_sampled", e.TraceSampled, e.TraceSampled != nil},
+		{"httpRequest", f.maskHTTPRequest(e.HTTPRequest), e.HTTPRequest != nil},
+		{"labels", e.Labels, len(e.Labels) > 0},
+		{"correlationId", e.CorrelationID, e.CorrelationID != ""},
+	}
+
+	start := len(b)
+
+	for _, field := range fields {
+		if !field.ok {
+			continue
+		}
+
+		v, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, ',')
+		b = strconv.AppendQuote(b, field.key)
+		b = append(b, ':')
+		b = append(b, v...)
+	}
+
+	if len(e.Payload) > 0 {
+		payloadBytes, err := marshalPayload(e.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, ',')
+		b = append(b, payloadBytes[1:len(payloadBytes)-1]...)
+	}
+
+	if len(b) == start {
+		return b, nil
+	}
+
+	// The first field was written with a leading comma, which becomes the opening brace.
+	b[start] = '{'
+
+	return append(b, '}'), nil
+}
+
+// FormatMessageOnly formats only the timestamp, severity, and message fields.
+// This is used internally by the logger to output warnings about invalid keys.
+func (f *hybridFormatter) FormatMessageOnly(e *LogEntry) ([]byte, error) {
+	return formatBasicMessage(e, f.timeEncoding), nil
+}
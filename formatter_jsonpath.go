@@ -0,0 +1,215 @@
+package harelog
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	json "github.com/goccy/go-json"
+)
+
+// jsonPathSegment is a step of a path of WithMaskingJSONPaths: an object key, any key
+// ("*"), an array index ("[0]") or any index ("[*]").
+type jsonPathSegment struct {
+	key   string
+	index int // index of an array step, or -1 for any index
+	array bool
+	any   bool
+}
+
+// matchesKey reports whether the segment selects the object key k.
+func (s jsonPathSegment) matchesKey(k string) bool {
+	return !s.array && (s.any || s.key == k)
+}
+
+// matchesIndex reports whether the segment selects the array index i.
+func (s jsonPathSegment) matchesIndex(i int) bool {
+	return s.array && (s.index < 0 || s.index == i)
+}
+
+// parseJSONPath parses a path of WithMaskingJSONPaths, such as "cards[*].number".
+// It panics if the path is malformed.
+func parseJSONPath(path string) []jsonPathSegment {
+	invalid := func() {
+		panic(fmt.Sprintf("harelog: invalid path provided to WithMaskingJSONPaths: %q", path))
+	}
+
+	var segments []jsonPathSegment
+
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key == "" && (len(segments) == 0 || !strings.HasPrefix(part, "[")) {
+			invalid()
+		}
+
+		if key != "" {
+			if strings.ContainsAny(key, "]*") && key != "*" {
+				invalid()
+			}
+
+			segments = append(segments, jsonPathSegment{key: key, any: key == "*"})
+		}
+
+		if !strings.HasPrefix(part[len(key):], "[") {
+			continue
+		}
+
+		// The indexes of the part, e.g. "0]" and "*]" for "matrix[0][*]".
+		for _, index := range strings.Split(rest, "[") {
+			index, ok := strings.CutSuffix(index, "]")
+			if !ok {
+				invalid()
+			}
+
+			if index == "*" {
+				segments = append(segments, jsonPathSegment{index: -1, array: true, any: true})
+
+				continue
+			}
+
+			i, err := strconv.Atoi(index)
+			if err != nil || i < 0 {
+				invalid()
+			}
+
+			segments = append(segments, jsonPathSegment{index: i, array: true})
+		}
+	}
+
+	return segments
+}
+
+// maskJSONPaths masks the values of the payload at the paths of WithMaskingJSONPaths.
+// Only the values a path leads into are copied; the caller's values are never modified.
+func (f *jsonFormatter) maskJSONPaths(payload map[string]interface{}) {
+	for _, path := range f.jsonPaths {
+		for k, v := range payload {
+			if path[0].matchesKey(k) {
+				payload[k] = maskJSONPathValue(reflect.ValueOf(v), path[1:])
+			}
+		}
+	}
+}
+
+// maskJSONPathValue returns rv with the value at path masked. Objects are followed
+// through maps with string keys, structs (by their JSON field names) and values that
+// marshal themselves; arrays through slices and arrays. A path that does not match
+// the value leaves it unchanged.
+func maskJSONPathValue(rv reflect.Value, path []jsonPathSegment) interface{} {
+	if !rv.IsValid() {
+		return nil
+	}
+
+	if len(path) == 0 {
+		return maskedValueString
+	}
+
+	v := rv.Interface()
+	t := rv.Type()
+
+	if s, ok := v.(maskedStruct); ok {
+		return maskStructPath(s, path)
+	}
+
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		// The value is walked in its JSON form.
+		generic, ok := jsonGeneric(v)
+		if !ok {
+			return v
+		}
+
+		return maskJSONPathValue(reflect.ValueOf(generic), path)
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if rv.IsNil() {
+			return v
+		}
+
+		return maskJSONPathValue(rv.Elem(), path)
+	case reflect.Slice, reflect.Array:
+		// Byte slices are written as base64 strings.
+		if !path[0].array || t.Elem().Kind() == reflect.Uint8 || (rv.Kind() == reflect.Slice && rv.IsNil()) {
+			return v
+		}
+
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			if path[0].matchesIndex(i) {
+				out[i] = maskJSONPathValue(rv.Index(i), path[1:])
+			} else {
+				out[i] = rv.Index(i).Interface()
+			}
+		}
+
+		return out
+	case reflect.Map:
+		if path[0].array || t.Key().Kind() != reflect.String || rv.IsNil() {
+			return v
+		}
+
+		out := make(map[string]interface{}, rv.Len())
+
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+
+			if path[0].matchesKey(k) {
+				out[k] = maskJSONPathValue(iter.Value(), path[1:])
+			} else {
+				out[k] = iter.Value().Interface()
+			}
+		}
+
+		return out
+	case reflect.Struct:
+		if path[0].array {
+			return v
+		}
+
+		return maskStructPath(appendStructFields(nil, rv, false), path)
+	}
+
+	return v
+}
+
+// maskStructPath masks the fields of s selected by path, which is not empty.
+func maskStructPath(s maskedStruct, path []jsonPathSegment) interface{} {
+	if path[0].array {
+		return s
+	}
+
+	out := make(maskedStruct, len(s))
+
+	for i, field := range s {
+		if path[0].matchesKey(field.name) {
+			field.value = maskJSONPathValue(reflect.ValueOf(field.value), path[1:])
+		}
+
+		out[i] = field
+	}
+
+	return out
+}
+
+// jsonGeneric returns v decoded from its JSON form into maps, slices and scalars,
+// keeping numbers as they are written.
+func jsonGeneric(v interface{}) (interface{}, bool) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, false
+	}
+
+	return generic, true
+}
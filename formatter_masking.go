@@ -1,12 +1,18 @@
 package harelog
 
-import "strings"
+import (
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
 
 // maskingCore holds the logic for storing and checking sensitive keys.
 // This struct is intended to be embedded in formatters.
 type maskingCore struct {
 	sensitiveKeys   map[string]struct{}
 	insensitiveKeys map[string]struct{}
+	queryParams     map[string]struct{}
 }
 
 // addSensitive adds one or more keys for case-sensitive matching.
@@ -32,6 +38,44 @@ func (mc *maskingCore) addInsensitive(keys ...string) {
 	}
 }
 
+// addInsensitiveFromEnv adds the keys listed in the environment variable envVar
+// for case-insensitive matching. The list is comma-separated; surrounding spaces
+// and empty entries are ignored. An unset variable adds no keys.
+func (mc *maskingCore) addInsensitiveFromEnv(envVar string) {
+	for _, k := range strings.Split(os.Getenv(envVar), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			mc.addInsensitive(k)
+		}
+	}
+}
+
+// MaskingKeys returns the configured masking keys, sorted, for audit purposes.
+// Insensitive keys are returned in their normalized lower-case form.
+// The returned slices are copies and may be modified freely.
+func (mc *maskingCore) MaskingKeys() (sensitive []string, insensitive []string) {
+	return sortedKeys(mc.sensitiveKeys), sortedKeys(mc.insensitiveKeys)
+}
+
+// MaskingQueryParams returns the configured URL query parameters to be masked,
+// in their normalized lower-case form and sorted.
+// The returned slice is a copy and may be modified freely.
+func (mc *maskingCore) MaskingQueryParams() []string {
+	return sortedKeys(mc.queryParams)
+}
+
+// sortedKeys returns the keys of m as a new sorted slice.
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
 // isMasking checks if the given key should be masked.
 // It performs a zero-cost check first if no keys are registered.
 // It checks sensitive keys first, then falls back to insensitive keys.
@@ -54,3 +98,73 @@ func (mc *maskingCore) isMasking(key string) bool {
 
 	return false
 }
+
+// addQueryParams adds one or more URL query parameter names to be masked.
+// The names are stored in lower-case, as query parameters are matched case-insensitively.
+func (mc *maskingCore) addQueryParams(keys ...string) {
+	if mc.queryParams == nil {
+		mc.queryParams = make(map[string]struct{})
+	}
+
+	for _, k := range keys {
+		mc.queryParams[strings.ToLower(k)] = struct{}{}
+	}
+}
+
+// maskURL masks the values of the configured query parameters in rawURL.
+// The order and encoding of the other parameters are preserved.
+// If no query parameters are registered, or the URL cannot be parsed,
+// rawURL is returned unchanged.
+func (mc *maskingCore) maskURL(rawURL string) string {
+	if len(mc.queryParams) == 0 {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+
+	params := strings.Split(u.RawQuery, "&")
+	masked := false
+
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+
+		if _, ok := mc.queryParams[strings.ToLower(name)]; ok {
+			params[i] = key + "=" + maskedValueString
+			masked = true
+		}
+	}
+
+	if !masked {
+		return rawURL
+	}
+
+	u.RawQuery = strings.Join(params, "&")
+
+	return u.String()
+}
+
+// maskHTTPRequest returns req with its RequestURL masked.
+// A copy is returned if the URL changed, so the caller's value is never modified.
+func (mc *maskingCore) maskHTTPRequest(req *HTTPRequest) *HTTPRequest {
+	if req == nil || len(mc.queryParams) == 0 {
+		return req
+	}
+
+	maskedURL := mc.maskURL(req.RequestURL)
+	if maskedURL == req.RequestURL {
+		return req
+	}
+
+	reqCopy := *req
+	reqCopy.RequestURL = maskedURL
+
+	return &reqCopy
+}
@@ -0,0 +1,197 @@
+package harelog
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/goccy/go-json"
+)
+
+// defaultJSONFormatter is the formatter whose output ParseJSONEntry reads.
+var defaultJSONFormatter = JSON.NewFormatter()
+
+// ParseJSONEntry parses a record written by the default JSONFormatter back into a
+// LogEntry, for log-processing tools and tests. The known fields, such as "message",
+// "severity", "timestamp" and the "logging.googleapis.com/..." fields, are set on the
+// entry, and the other root-level fields become its payload. To read records of a
+// JSONFormatter with other options, such as ZapFieldNames, use its ParseEntry method.
+func ParseJSONEntry(data []byte) (*LogEntry, error) {
+	return defaultJSONFormatter.ParseEntry(data)
+}
+
+// ParseEntry parses a record written by this formatter back into a LogEntry, reversing
+// its field names, level strings and time encoding. See ParseJSONEntry for details.
+//
+// Payload values are decoded as by encoding/json into an interface{}, so numbers become
+// float64 and objects map[string]interface{}. Masked values stay masked, and a timestamp
+// written by a TimeEncoder is read only if it is an RFC 3339 string or an epoch number
+// of the formatter's TimeEncoding (seconds for TimeRFC3339).
+func (f *jsonFormatter) ParseEntry(data []byte) (*LogEntry, error) {
+	var fields map[string]json.RawMessage
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("harelog: failed to parse JSON entry: %w", err)
+	}
+
+	messageKey, levelKey, timeKey, callerKey := "message", "severity", "timestamp", ""
+	if names := f.fieldNames; names != nil {
+		messageKey, levelKey, timeKey, callerKey = names.message, names.level, names.time, names.sourceLocation
+	}
+
+	if callerKey == "" && f.callerField {
+		callerKey = "caller"
+	}
+
+	e := &LogEntry{Payload: make(map[string]interface{})}
+
+	for key, raw := range fields {
+		var err error
+
+		switch key {
+		case messageKey:
+			err = json.Unmarshal(raw, &e.Message)
+		case levelKey:
+			e.Severity, err = f.parseLevel(raw)
+		case timeKey:
+			e.Time, err = parseJSONTime(raw, f.timeEncoding)
+		case callerKey:
+			e.SourceLocation, err = parseCaller(raw)
+		case "logging.googleapis.com/sourceLocation":
+			err = json.Unmarshal(raw, &e.SourceLocation)
+		case "logging.googleapis.com/trace":
+			err = json.Unmarshal(raw, &e.Trace)
+		case "logging.googleapis.com/spanId":
+			err = json.Unmarshal(raw, &e.SpanID)
+		case "logging.googleapis.com/trace_sampled":
+			err = json.Unmarshal(raw, &e.TraceSampled)
+		case "httpRequest":
+			err = json.Unmarshal(raw, &e.HTTPRequest)
+		case "labels":
+			err = json.Unmarshal(raw, &e.Labels)
+		case "correlationId":
+			err = json.Unmarshal(raw, &e.CorrelationID)
+		case f.typeKey:
+			// The constant field of WithTypeField is not part of the entry.
+		default:
+			var v interface{}
+
+			err = json.Unmarshal(raw, &v)
+			e.Payload[key] = v
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("harelog: invalid %q field in JSON entry: %w", key, err)
+		}
+	}
+
+	return e, nil
+}
+
+// parseLevel returns the LogLevel of a level string written by the formatter.
+func (f *jsonFormatter) parseLevel(raw json.RawMessage) (LogLevel, error) {
+	var s string
+
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", err
+	}
+
+	if f.fieldNames == nil {
+		if _, ok := levelMap[LogLevel(s)]; !ok {
+			return "", fmt.Errorf("unknown level %q", s)
+		}
+
+		return LogLevel(s), nil
+	}
+
+	// Several levels may share a string, such as Trace and Debug with zap's names;
+	// the most severe one is chosen, so that the result does not depend on the map order.
+	var level LogLevel
+
+	for lv, name := range f.fieldNames.levels {
+		if name == s && (level == "" || levelMap[lv] < levelMap[level]) {
+			level = lv
+		}
+	}
+
+	if level == "" {
+		return "", fmt.Errorf("unknown level %q", s)
+	}
+
+	return level, nil
+}
+
+// parseJSONTime parses a timestamp written as an RFC 3339 string or as an epoch
+// number of the encoding enc.
+func parseJSONTime(raw json.RawMessage, enc TimeEncoding) (time.Time, error) {
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return time.Time{}, err
+		}
+
+		return time.Parse(time.RFC3339Nano, s)
+	}
+
+	var n json.Number
+
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return time.Time{}, err
+	}
+
+	switch enc {
+	case TimeEpochMillis, TimeEpochNanos:
+		i, err := n.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if enc == TimeEpochMillis {
+			return time.UnixMilli(i), nil
+		}
+
+		return time.Unix(0, i), nil
+	default:
+		f, err := n.Float64()
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		sec, frac := math.Modf(f)
+
+		return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3), nil
+	}
+}
+
+// parseCaller parses a source location written as "file:line".
+func parseCaller(raw json.RawMessage) (*SourceLocation, error) {
+	var s string
+
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+
+	file, lineStr, ok := cutLast(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid caller %q", s)
+	}
+
+	line, err := strconv.Atoi(lineStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid caller %q", s)
+	}
+
+	return &SourceLocation{File: file, Line: line}, nil
+}
+
+// cutLast slices s around the last instance of sep, like strings.Cut.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
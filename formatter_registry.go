@@ -0,0 +1,93 @@
+package harelog
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// formatterRegistryMu guards formatterRegistry.
+var formatterRegistryMu sync.RWMutex
+
+// formatterRegistry maps lower-case format names to formatter factories (see ParseFormat).
+var formatterRegistry = map[string]func() Formatter{
+	"json":      func() Formatter { return JSON.NewFormatter() },
+	"text":      func() Formatter { return Text.NewFormatter() },
+	"console":   func() Formatter { return Console.NewFormatter() },
+	"logfmt":    func() Formatter { return Logfmt.NewFormatter() },
+	"bare":      func() Formatter { return Bare.NewFormatter() },
+	"accesslog": func() Formatter { return AccessLog.NewFormatter() },
+	"bunyan":    func() Formatter { return Bunyan.NewFormatter() },
+	"hybrid":    func() Formatter { return Hybrid.NewFormatter() },
+	"auto":      AutoFormatter,
+}
+
+// RegisterFormatter makes a formatter available by name to ParseFormat, so that
+// custom formatters can be selected from configuration. Names are case-insensitive.
+// The factory is called for each ParseFormat call and should return a new formatter
+// with its default options.
+//
+// RegisterFormatter is safe for concurrent use, but it is intended to be called from
+// an init function, so that the name is available before configuration is read.
+// It panics if name is empty, factory is nil, or name is already registered,
+// including the built-in names.
+func RegisterFormatter(name string, factory func() Formatter) {
+	if name == "" {
+		panic("harelog: empty name provided to RegisterFormatter")
+	}
+
+	if factory == nil {
+		panic("harelog: nil factory provided to RegisterFormatter")
+	}
+
+	key := strings.ToLower(name)
+
+	formatterRegistryMu.Lock()
+	defer formatterRegistryMu.Unlock()
+
+	if _, ok := formatterRegistry[key]; ok {
+		panic("harelog: RegisterFormatter called twice for format " + name)
+	}
+
+	formatterRegistry[key] = factory
+}
+
+// ParseFormat returns a new formatter for the format name, with its default options.
+// It is case-insensitive and recognizes "json", "text", "console", "logfmt", "bare",
+// "accesslog", "bunyan", "hybrid" and "auto" (see AutoFormatter), plus the names added
+// with RegisterFormatter.
+// It returns an error if the name is not registered. It is safe for concurrent use.
+func ParseFormat(name string) (Formatter, error) {
+	formatterRegistryMu.RLock()
+	factory, ok := formatterRegistry[strings.ToLower(name)]
+	formatterRegistryMu.RUnlock()
+
+	if !ok {
+		return nil, errors.New("invalid format: " + name)
+	}
+
+	return factory(), nil
+}
+
+// setupFormatterFromEnv reads the HARELOG_FORMAT environment variable and
+// configures the default logger's formatter accordingly.
+// It runs at package initialization, before any RegisterFormatter call of an
+// importing package, so only the built-in formats can be selected.
+func setupFormatterFromEnv() {
+	name := os.Getenv("HARELOG_FORMAT")
+
+	if name == "" {
+		return
+	}
+
+	f, err := ParseFormat(name)
+	if err != nil {
+		log.Printf("harelog: invalid HARELOG_FORMAT value %q, using default format", name)
+
+		return
+	}
+
+	SetDefaultFormatter(f)
+}
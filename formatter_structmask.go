@@ -0,0 +1,298 @@
+package harelog
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"sync"
+
+	json "github.com/goccy/go-json"
+)
+
+// structTagKey is the struct tag read by JSONFormatter when struct tag masking is enabled.
+// `log:"-"` omits the field and `log:"mask"` writes it as "[MASKED]".
+const structTagKey = "log"
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	interfaceType     = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// structTagCache caches, per type, whether values of the type may hold a field
+// with a log tag, so that untagged values skip the reflective copy.
+var structTagCache sync.Map // map[reflect.Type]bool
+
+// maskStructTags returns v with the struct fields tagged `log:"-"` omitted and
+// those tagged `log:"mask"` masked, at any depth. Values whose type holds no log
+// tags, or implements json.Marshaler or encoding.TextMarshaler, are returned
+// unchanged. The caller's value is never modified.
+func maskStructTags(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if !hasStructTags(rv.Type()) {
+		return v
+	}
+
+	return maskValue(rv)
+}
+
+// hasStructTags reports whether values of type t may hold a field with a log tag.
+// Interface types report true, as their dynamic type is only known at runtime.
+func hasStructTags(t reflect.Type) bool {
+	if cached, ok := structTagCache.Load(t); ok {
+		return cached.(bool)
+	}
+
+	has := scanStructTags(t, map[reflect.Type]bool{})
+	structTagCache.Store(t, has)
+
+	return has
+}
+
+// scanStructTags implements hasStructTags. Types being scanned are tracked in
+// visiting to stop at recursive types.
+func scanStructTags(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return false
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return scanStructTags(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+
+			if _, ok := sf.Tag.Lookup(structTagKey); ok {
+				return true
+			}
+
+			if scanStructTags(sf.Type, visiting) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// maskValue returns a copy of rv with the log tags applied, built from ordered
+// structs, slices and maps that marshal to the same JSON as the original value.
+func maskValue(rv reflect.Value) interface{} {
+	if !rv.IsValid() {
+		return nil
+	}
+
+	if !hasStructTags(rv.Type()) {
+		return rv.Interface()
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+
+		return maskValue(rv.Elem())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = maskValue(rv.Index(i))
+		}
+
+		return out
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+
+		out := reflect.MakeMapWithSize(reflect.MapOf(rv.Type().Key(), interfaceType), rv.Len())
+
+		iter := rv.MapRange()
+		for iter.Next() {
+			elem := reflect.ValueOf(maskValue(iter.Value()))
+			if !elem.IsValid() {
+				elem = reflect.Zero(interfaceType)
+			}
+
+			out.SetMapIndex(iter.Key(), elem)
+		}
+
+		return out.Interface()
+	case reflect.Struct:
+		out := make(maskedStruct, 0, rv.NumField())
+
+		return appendStructFields(out, rv, true)
+	}
+
+	return rv.Interface()
+}
+
+// maskedStruct is a struct with its log tags applied. It keeps the field order
+// of the struct, which a map would lose.
+type maskedStruct []maskedField
+
+type maskedField struct {
+	name  string
+	value interface{}
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s maskedStruct) MarshalJSON() ([]byte, error) {
+	b := []byte{'{'}
+
+	for i, f := range s {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		name, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		b = append(b, name...)
+		b = append(b, ':')
+		b = append(b, value...)
+	}
+
+	return append(b, '}'), nil
+}
+
+// appendStructFields appends the fields of the struct rv to out, following the
+// naming and omission rules of encoding/json, and the log tags if tags is true.
+// Untagged embedded structs are inlined; fields already present are not overwritten.
+func appendStructFields(out maskedStruct, rv reflect.Value, tags bool) maskedStruct {
+	t := rv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		// Unlike encoding/json, fields of unexported embedded structs are not written,
+		// as reflection cannot read them.
+		jsonTag := sf.Tag.Get("json")
+		if !sf.IsExported() || jsonTag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(jsonTag, ",")
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			fv := rv.Field(i)
+
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+
+				if fv.IsNil() {
+					continue
+				}
+
+				fv = fv.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct && !ft.Implements(jsonMarshalerType) {
+				out = appendStructFields(out, fv, tags)
+
+				continue
+			}
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		fv := rv.Field(i)
+
+		if hasOption(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+
+		if hasField(out, name) {
+			continue
+		}
+
+		if !tags {
+			out = append(out, maskedField{name: name, value: fv.Interface()})
+
+			continue
+		}
+
+		switch sf.Tag.Get(structTagKey) {
+		case "-":
+			continue
+		case "mask":
+			out = append(out, maskedField{name: name, value: maskedValueString})
+		default:
+			out = append(out, maskedField{name: name, value: maskValue(fv)})
+		}
+	}
+
+	return out
+}
+
+// hasOption reports whether the comma-separated tag options contain opt.
+func hasOption(opts, opt string) bool {
+	for opts != "" {
+		var o string
+
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == opt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isEmptyValue reports whether v is empty as defined by the omitempty option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+
+	return false
+}
+
+// hasField reports whether out already holds a field named name.
+func hasField(out maskedStruct, name string) bool {
+	for _, f := range out {
+		if f.name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,7 +1,14 @@
 package harelog
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -46,6 +53,62 @@ func TestJSONFormatter_Format(t *testing.T) {
 	}
 }
 
+// TestJSONFormatter_UnmarshalableValue verifies that a value that cannot be marshaled
+// is replaced by a placeholder instead of dropping the entry, without changing the entry.
+func TestJSONFormatter_UnmarshalableValue(t *testing.T) {
+	t.Parallel()
+
+	formatters := map[string]Formatter{
+		"default":     JSON.NewFormatter(),
+		"field names": JSON.NewFormatter(JSON.ZapFieldNames()),
+	}
+
+	for name, f := range formatters {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ch := make(chan int)
+			cause := errors.New("boom")
+
+			entry := &LogEntry{
+				Message:  "with a channel",
+				Severity: LogLevelInfo,
+				Time:     time.Date(2025, 9, 25, 12, 0, 0, 0, time.UTC),
+				Payload: map[string]interface{}{
+					"ch":    ch,
+					"cause": cause,
+					"user":  "gopher",
+				},
+			}
+
+			b, err := f.Format(entry)
+			if err != nil {
+				t.Fatalf("Format() returned an error: %v", err)
+			}
+
+			var got map[string]interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("output is not valid JSON: %v: %s", err, b)
+			}
+
+			if got["user"] != "gopher" || !strings.Contains(string(b), "with a channel") {
+				t.Errorf("expected the rest of the entry, got %s", b)
+			}
+			if ch, _ := got["ch"].(string); !strings.HasPrefix(ch, "<unmarshalable: ") {
+				t.Errorf("expected a placeholder for the channel, got %v", got["ch"])
+			}
+			if got["cause"] != "boom" {
+				t.Errorf("expected the error message, got %v", got["cause"])
+			}
+
+			// A second formatter or output sees the values of the log call.
+			if entry.Payload["ch"] != ch || entry.Payload["cause"] != cause {
+				t.Errorf("expected the entry to keep its values, got %v", entry.Payload)
+			}
+		})
+	}
+}
+
 // TestJSONFormatter_FormatMessageOnly tests the simplified JSON output for warnings.
 func TestJSONFormatter_FormatMessageOnly(t *testing.T) {
 	t.Parallel()
@@ -558,6 +621,37 @@ func TestConsoleFormatter(t *testing.T) {
 				t.Errorf("output should not contain any ANSI escape codes in a non-TTY environment, but found some in %q", got)
 			}
 		})
+
+		t.Run("Empty NO_COLOR disables color", func(t *testing.T) {
+			t.Setenv("HARELOG_FORCE_COLOR", "")
+			t.Setenv("NO_COLOR", "")
+
+			f := Console.NewFormatter()
+			if f.shouldUseColor() {
+				t.Error("expected a set but empty NO_COLOR to disable color")
+			}
+		})
+
+		t.Run("Empty HARELOG_NO_COLOR disables color", func(t *testing.T) {
+			t.Setenv("HARELOG_FORCE_COLOR", "")
+			t.Setenv("HARELOG_NO_COLOR", "")
+
+			f := Console.NewFormatter()
+			if f.shouldUseColor() {
+				t.Error("expected a set but empty HARELOG_NO_COLOR to disable color")
+			}
+		})
+
+		t.Run("HARELOG_FORCE_COLOR takes precedence over NO_COLOR", func(t *testing.T) {
+			t.Setenv("HARELOG_FORCE_COLOR", "1")
+			t.Setenv("NO_COLOR", "")
+			t.Setenv("HARELOG_NO_COLOR", "1")
+
+			f := Console.NewFormatter()
+			if !f.shouldUseColor() {
+				t.Error("expected HARELOG_FORCE_COLOR to enable color")
+			}
+		})
 	})
 
 	t.Run("Basic Highlighting", func(t *testing.T) {
@@ -793,6 +887,215 @@ func TestConsoleFormatter_FormatMessageOnly(t *testing.T) {
 	}
 }
 
+func TestConsoleFormatter_MessageTemplate(t *testing.T) {
+	entry := &LogEntry{
+		Message:  "user {userID} did {action} in {{tenant}} {unknown}\n",
+		Severity: LogLevelInfo,
+		Time:     time.Date(2025, 10, 14, 13, 30, 0, 0, time.UTC),
+		Payload: map[string]interface{}{
+			"userID":    "user 123",
+			"action":    "logout",
+			"requestID": "req abc",
+			"password":  "secret",
+		},
+	}
+
+	t.Run("substitutes fields and removes them from the block", func(t *testing.T) {
+		f := Console.NewFormatter(Console.WithMessageTemplate(true))
+
+		b, err := f.Format(entry)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		want := `2025-10-14T13:30:00Z [INFO] user user 123 did logout in {tenant} {unknown} { password=secret, requestID="req abc" }`
+		if got := string(b); got != want {
+			t.Errorf("unexpected console output:\ngot:  %q\nwant: %q", got, want)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		f := Console.NewFormatter()
+
+		b, err := f.Format(entry)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		if got := string(b); !strings.Contains(got, "user {userID} did {action} in {{tenant}}") || !strings.Contains(got, "userID=") {
+			t.Errorf("message should be written as it is, got: %q", got)
+		}
+	})
+
+	t.Run("masks and highlights substituted values", func(t *testing.T) {
+		t.Setenv("HARELOG_FORCE_COLOR", "1")
+
+		f := Console.NewFormatter(
+			Console.WithMessageTemplate(true),
+			Console.WithMaskingKeys("password"),
+			Console.WithKeyHighlight("userID", FgCyan),
+		)
+
+		e := &LogEntry{
+			Message:  "login by {userID} with {password}",
+			Severity: LogLevelInfo,
+			Time:     entry.Time,
+			Payload:  map[string]interface{}{"userID": "gopher", "password": "secret", "attempt": 2},
+		}
+
+		b, err := f.Format(e)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		cyan := color.New(color.FgCyan)
+		cyan.EnableColor()
+
+		want := fmt.Sprintf(`2025-10-14T13:30:00Z [INFO] login by %s with %s { attempt=2 }`, cyan.Sprint("gopher"), maskedValueString)
+		if got := string(b); got != want {
+			t.Errorf("unexpected console output:\ngot:  %q\nwant: %q", got, want)
+		}
+	})
+}
+
+// TestConsoleFormatter_MultilineErrors verifies that Console.WithMultilineErrors expands
+// Error and Critical entries over several lines and keeps the other levels on one line.
+func TestConsoleFormatter_MultilineErrors(t *testing.T) {
+	t.Parallel()
+
+	f := Console.NewFormatter(Console.WithMultilineErrors(true), Console.WithMaskingKeys("password"))
+
+	entry := func(level LogLevel, payload map[string]interface{}) *LogEntry {
+		return &LogEntry{
+			Message:  "failed to connect",
+			Severity: level,
+			Time:     time.Date(2025, 10, 14, 13, 30, 0, 0, time.UTC),
+			Labels:   map[string]string{"env": "dev"},
+			Payload:  payload,
+		}
+	}
+
+	t.Run("expanded with stack trace", func(t *testing.T) {
+		b, err := f.Format(entry(LogLevelCritical, map[string]interface{}{
+			"error":       "connection refused",
+			"password":    "secret",
+			stackTraceKey: "main.connect()\n\t/app/main.go:10\nmain.main()\n\t/app/main.go:5",
+		}))
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		want := "2025-10-14T13:30:00Z [CRITICAL] failed to connect\n" +
+			"    label.env=dev\n" +
+			`    error="connection refused"` + "\n" +
+			"    password=" + maskedValueString + "\n" +
+			"    stack_trace:\n" +
+			"        main.connect()\n" +
+			"            /app/main.go:10\n" +
+			"        main.main()\n" +
+			"            /app/main.go:5"
+		if got := string(b); got != want {
+			t.Errorf("unexpected console output:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("expanded without fields", func(t *testing.T) {
+		e := entry(LogLevelError, nil)
+		e.Labels = nil
+
+		b, err := f.Format(e)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		if want := "2025-10-14T13:30:00Z [ERROR] failed to connect"; string(b) != want {
+			t.Errorf("got %q, want %q", b, want)
+		}
+	})
+
+	t.Run("lower levels stay single-line", func(t *testing.T) {
+		b, err := f.Format(entry(LogLevelWarn, map[string]interface{}{"retry": 3}))
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		if want := "2025-10-14T13:30:00Z [WARN] failed to connect { label.env=dev, retry=3 }"; string(b) != want {
+			t.Errorf("got %q, want %q", b, want)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		b, err := Console.NewFormatter().Format(entry(LogLevelError, map[string]interface{}{"retry": 3}))
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		if strings.Contains(string(b), "\n") {
+			t.Errorf("expected a single line, got %q", b)
+		}
+	})
+}
+
+// TestConsoleFormatter_HiddenKeys verifies that Console.WithHiddenKeys omits the
+// given payload fields from the console output only.
+func TestConsoleFormatter_HiddenKeys(t *testing.T) {
+	t.Parallel()
+
+	entry := &LogEntry{
+		Message:  "request received",
+		Severity: LogLevelInfo,
+		Time:     time.Date(2025, 10, 14, 13, 30, 0, 0, time.UTC),
+		Payload: map[string]interface{}{
+			"raw_body": `{"name":"gopher"}`,
+			"headers":  "Accept: */*",
+			"path":     "/users",
+			"password": "secret",
+		},
+	}
+
+	t.Run("omits hidden keys", func(t *testing.T) {
+		f := Console.NewFormatter(
+			Console.WithHiddenKeys("raw_body"),
+			Console.WithHiddenKeys("headers"),
+			Console.WithMaskingKeys("password"),
+		)
+
+		b, err := f.Format(entry)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		want := fmt.Sprintf(`2025-10-14T13:30:00Z [INFO] request received { password=%s, path=/users }`, maskedValueString)
+		if got := string(b); got != want {
+			t.Errorf("unexpected console output:\ngot:  %q\nwant: %q", got, want)
+		}
+	})
+
+	t.Run("nothing hidden by default", func(t *testing.T) {
+		b, err := Console.NewFormatter().Format(entry)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		if got := string(b); !strings.Contains(got, "raw_body=") || !strings.Contains(got, "headers=") {
+			t.Errorf("expected all fields, got: %q", got)
+		}
+	})
+
+	t.Run("other formatters keep hidden keys", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf))
+		logger.WithFormatter(Console.NewFormatter(Console.WithHiddenKeys("raw_body"))).Infow("hidden", "raw_body", "x")
+		logger.Infow("kept", "raw_body", "x")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 || strings.Contains(lines[0], "raw_body") || !strings.Contains(lines[1], `"raw_body":"x"`) {
+			t.Errorf("expected raw_body only in the JSON output, got %q", lines)
+		}
+	})
+}
+
 func TestConsoleFormatter_Masking(t *testing.T) {
 	t.Parallel()
 
@@ -1043,6 +1346,41 @@ func TestLogfmtFormatter_Format(t *testing.T) {
 	}
 }
 
+// TestAppendLogfmtValue verifies that the public helper quotes values as LogfmtFormatter does.
+func TestAppendLogfmtValue(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"simple", "value", `value`},
+		{"empty", "", `""`},
+		{"equals sign", "key=value", `"key=value"`},
+		{"embedded quotes", "a \"quoted\" str", `"a \"quoted\" str"`},
+		{"space", "two words", `"two words"`},
+		{"control character", "a\tb", `"a\tb"`},
+		{"trailing newline", "line\n", `line`},
+		{"bool", true, `true`},
+		{"int", 42, `42`},
+		{"float", 1.5, `1.5`},
+		{"stringer", time.Duration(1500) * time.Millisecond, `1.5s`},
+		{"other", []string{"a", "b"}, `"[a b]"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := string(AppendLogfmtValue([]byte("k="), tt.value))
+			if got != "k="+tt.want {
+				t.Errorf("AppendLogfmtValue(%#v) = %s, want k=%s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
 // TestLogfmtFormatter_FormatMessageOnly tests the simplified logfmt output for warnings.
 func TestLogfmtFormatter_FormatMessageOnly(t *testing.T) {
 	t.Parallel()
@@ -1306,6 +1644,39 @@ func BenchmarkJsonFormatter_Simple(b *testing.B) {
 	}
 }
 
+// BenchmarkFormatter_TimeEncoder compares the default timestamp encoding with a TimeEncoder.
+func BenchmarkFormatter_TimeEncoder(b *testing.B) {
+	epochMillis := func(dst []byte, t time.Time) []byte {
+		return strconv.AppendInt(dst, t.UnixMilli(), 10)
+	}
+
+	formatters := []struct {
+		name string
+		f    Formatter
+	}{
+		{"JSON", JSON.NewFormatter()},
+		{"Text", Text.NewFormatter()},
+		{"Logfmt", Logfmt.NewFormatter()},
+	}
+
+	for _, tt := range formatters {
+		for _, encoder := range []struct {
+			name string
+			enc  TimeEncoder
+		}{{"Default", nil}, {"Custom", epochMillis}} {
+			b.Run(tt.name+"/"+encoder.name, func(b *testing.B) {
+				entry := *benchmarkEntrySimple
+				entry.timeEncoder = encoder.enc
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, _ = tt.f.Format(&entry)
+				}
+			})
+		}
+	}
+}
+
 // BenchmarkJsonFormatter_Complex benchmarks formatting a complex log entry.
 func BenchmarkJsonFormatter_Complex(b *testing.B) {
 	f := &jsonFormatter{}
@@ -1449,3 +1820,1670 @@ func BenchmarkLogfmtFormatter_Complex_Masking(b *testing.B) {
 		_, _ = f.Format(cloneEntry(benchmarkEntryComplexMasking))
 	}
 }
+
+// TestFormatter_MaskingQueryParams verifies that query parameters in the HTTPRequest URL
+// are masked by all formatters.
+func TestFormatter_MaskingQueryParams(t *testing.T) {
+	t.Parallel()
+
+	newEntry := func(rawURL string) *LogEntry {
+		return &LogEntry{
+			Message:  "request",
+			Severity: LogLevelInfo,
+			Time:     time.Date(2025, 9, 25, 12, 0, 0, 0, time.UTC),
+			HTTPRequest: &HTTPRequest{
+				RequestMethod: "GET",
+				RequestURL:    rawURL,
+			},
+		}
+	}
+
+	formatters := map[string]Formatter{
+		"JSON":    JSON.NewFormatter(JSON.WithMaskingQueryParams("token")),
+		"Text":    Text.NewFormatter(Text.WithMaskingQueryParams("token")),
+		"Console": Console.NewFormatter(Console.WithMaskingQueryParams("token")),
+		"Logfmt":  Logfmt.NewFormatter(Logfmt.WithMaskingQueryParams("token")),
+	}
+
+	for name, f := range formatters {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := newEntry("https://example.com/api?user=gopher&Token=secret#top")
+
+			b, err := f.Format(req)
+			if err != nil {
+				t.Fatalf("Format() returned an error: %v", err)
+			}
+
+			s := string(b)
+			if strings.Contains(s, "secret") {
+				t.Errorf("expected token to be masked, got %s", s)
+			}
+			if !strings.Contains(s, "https://example.com/api?user=gopher") || !strings.Contains(s, "Token=[MASKED]#top") {
+				t.Errorf("expected masked URL with other params preserved, got %s", s)
+			}
+			if req.HTTPRequest.RequestURL != "https://example.com/api?user=gopher&Token=secret#top" {
+				t.Errorf("original HTTPRequest must not be modified, got %s", req.HTTPRequest.RequestURL)
+			}
+
+			malformed := newEntry("http://[::1]:namedport?token=secret")
+
+			b, err = f.Format(malformed)
+			if err != nil {
+				t.Fatalf("Format() returned an error: %v", err)
+			}
+			if !strings.Contains(string(b), "token=secret") {
+				t.Errorf("expected malformed URL to be left unchanged, got %s", b)
+			}
+		})
+	}
+}
+
+// TestFormatter_TimeEncoding verifies the WithTimeEncoding option of each formatter.
+func TestFormatter_TimeEncoding(t *testing.T) {
+	t.Parallel()
+
+	entry := func() *LogEntry {
+		return &LogEntry{
+			Message:  "time test",
+			Severity: LogLevelInfo,
+			Time:     time.Date(2025, 9, 25, 12, 0, 0, 123456789, time.UTC),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		want      string
+	}{
+		{"JSON default", JSON.NewFormatter(), `"timestamp":"2025-09-25T12:00:00.123456789Z"`},
+		{"JSON millis", JSON.NewFormatter(JSON.WithTimeEncoding(TimeEpochMillis)), `"timestamp":1758801600123`},
+		{"JSON nanos", JSON.NewFormatter(JSON.WithTimeEncoding(TimeEpochNanos)), `"timestamp":1758801600123456789`},
+		{"JSON float", JSON.NewFormatter(JSON.WithTimeEncoding(TimeEpochFloat)), `"timestamp":1758801600.123456`},
+		{"Text millis", Text.NewFormatter(Text.WithTimeEncoding(TimeEpochMillis)), `1758801600123 [INFO] time test`},
+		{"Console nanos", Console.NewFormatter(Console.WithTimeEncoding(TimeEpochNanos)), `1758801600123456789 [INFO] time test`},
+		{"Logfmt float", Logfmt.NewFormatter(Logfmt.WithTimeEncoding(TimeEpochFloat)), `timestamp=1758801600.123456 `},
+		{"Logfmt default", Logfmt.NewFormatter(), `timestamp=2025-09-25T12:00:00Z `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := tt.formatter.Format(entry())
+			if err != nil {
+				t.Fatalf("Format() returned an error: %v", err)
+			}
+			if !strings.Contains(string(b), tt.want) {
+				t.Errorf("output %s does not contain %s", b, tt.want)
+			}
+
+			b, err = tt.formatter.FormatMessageOnly(entry())
+			if err != nil {
+				t.Fatalf("FormatMessageOnly() returned an error: %v", err)
+			}
+			if strings.HasPrefix(tt.name, "JSON") && !json.Valid(b) {
+				t.Errorf("FormatMessageOnly produced invalid JSON: %s", b)
+			}
+		})
+	}
+}
+
+// TestFormatter_MaskingKeys verifies the read-only introspection of masking configuration.
+func TestFormatter_MaskingKeys(t *testing.T) {
+	t.Parallel()
+
+	f := JSON.NewFormatter(
+		JSON.WithMaskingKeys("password", "api_key"),
+		JSON.WithMaskingKeysIgnoreCase("Authorization"),
+		JSON.WithMaskingQueryParams("Token"),
+	)
+
+	sensitive, insensitive := f.MaskingKeys()
+
+	if strings.Join(sensitive, ",") != "api_key,password" {
+		t.Errorf("unexpected sensitive keys: %v", sensitive)
+	}
+	if strings.Join(insensitive, ",") != "authorization" {
+		t.Errorf("unexpected insensitive keys: %v", insensitive)
+	}
+	if params := f.MaskingQueryParams(); strings.Join(params, ",") != "token" {
+		t.Errorf("unexpected query params: %v", params)
+	}
+
+	// Mutating the returned slices must not affect the formatter.
+	sensitive[0] = "changed"
+	if !f.isMasking("api_key") {
+		t.Error("formatter configuration was modified through the returned slice")
+	}
+
+	sensitive, insensitive = Text.NewFormatter().MaskingKeys()
+	if len(sensitive) != 0 || len(insensitive) != 0 {
+		t.Errorf("expected no keys for an unconfigured formatter, got %v, %v", sensitive, insensitive)
+	}
+}
+
+// TestFormatter_MaskingKeysFromEnv verifies that masking keys are read from an environment variable.
+// It cannot run in parallel because it sets an environment variable.
+func TestFormatter_MaskingKeysFromEnv(t *testing.T) {
+	t.Setenv("HARELOG_TEST_MASKING_KEYS", " Password, api_key ,,X-Secret ")
+
+	entry := func() *LogEntry {
+		return &LogEntry{
+			Message:  "login",
+			Severity: LogLevelInfo,
+			Payload: map[string]interface{}{
+				"password": "p4ss",
+				"API_KEY":  "k3y",
+				"x-secret": "s3cret",
+				"token":    "t0ken",
+				"user":     "alice",
+			},
+		}
+	}
+
+	formatters := map[string]Formatter{
+		"json":    JSON.NewFormatter(JSON.WithMaskingKeysFromEnv("HARELOG_TEST_MASKING_KEYS"), JSON.WithMaskingKeys("token")),
+		"text":    Text.NewFormatter(Text.WithMaskingKeysFromEnv("HARELOG_TEST_MASKING_KEYS"), Text.WithMaskingKeys("token")),
+		"console": Console.NewFormatter(Console.WithMaskingKeysFromEnv("HARELOG_TEST_MASKING_KEYS"), Console.WithMaskingKeys("token")),
+		"logfmt":  Logfmt.NewFormatter(Logfmt.WithMaskingKeysFromEnv("HARELOG_TEST_MASKING_KEYS"), Logfmt.WithMaskingKeys("token")),
+		"bare":    Bare.NewFormatter(Bare.WithMaskingKeysFromEnv("HARELOG_TEST_MASKING_KEYS"), Bare.WithMaskingKeys("token")),
+	}
+
+	for name, f := range formatters {
+		out, err := f.Format(entry())
+		if err != nil {
+			t.Fatalf("%s: Format returned an error: %v", name, err)
+		}
+
+		for _, secret := range []string{"p4ss", "k3y", "s3cret", "t0ken"} {
+			if strings.Contains(string(out), secret) {
+				t.Errorf("%s: expected %q to be masked, got %q", name, secret, out)
+			}
+		}
+		if !strings.Contains(string(out), "alice") {
+			t.Errorf("%s: expected the unlisted field to be kept, got %q", name, out)
+		}
+	}
+
+	_, insensitive := JSON.NewFormatter(JSON.WithMaskingKeysFromEnv("HARELOG_TEST_MASKING_KEYS")).MaskingKeys()
+	if strings.Join(insensitive, ",") != "api_key,password,x-secret" {
+		t.Errorf("unexpected insensitive keys: %v", insensitive)
+	}
+
+	_, insensitive = JSON.NewFormatter(JSON.WithMaskingKeysFromEnv("HARELOG_TEST_UNSET_MASKING_KEYS")).MaskingKeys()
+	if len(insensitive) != 0 {
+		t.Errorf("expected no keys for an unset variable, got %v", insensitive)
+	}
+}
+
+// TestAccessLogFormatter_Format verifies the Combined Log Format output.
+func TestAccessLogFormatter_Format(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2025, 9, 25, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		entry     *LogEntry
+		want      string
+	}{
+		{
+			name:      "Full request",
+			formatter: AccessLog.NewFormatter(),
+			entry: &LogEntry{
+				Message: "ignored",
+				Time:    ts,
+				HTTPRequest: &HTTPRequest{
+					RequestMethod: "GET",
+					RequestURL:    "https://example.com/index.html?q=1",
+					Status:        200,
+					UserAgent:     `Mozilla/5.0 "test"`,
+					RemoteIP:      "192.0.2.1",
+				},
+				Payload: map[string]interface{}{
+					"user":         "frank",
+					"referer":      "https://example.com/",
+					"responseSize": 2326,
+					"protocol":     "HTTP/1.1",
+					"other":        "ignored",
+				},
+			},
+			want: `192.0.2.1 - frank [25/Sep/2025:12:00:00 +0000] "GET /index.html?q=1 HTTP/1.1" 200 2326 "https://example.com/" "Mozilla/5.0 \"test\""`,
+		},
+		{
+			name:      "Missing values",
+			formatter: AccessLog.NewFormatter(),
+			entry: &LogEntry{
+				Time: ts,
+			},
+			want: `- - - [25/Sep/2025:12:00:00 +0000] "-" - - "-" "-"`,
+		},
+		{
+			name:      "Masked query parameter",
+			formatter: AccessLog.NewFormatter(AccessLog.WithMaskingQueryParams("token")),
+			entry: &LogEntry{
+				Time: ts,
+				HTTPRequest: &HTTPRequest{
+					RequestMethod: "POST",
+					RequestURL:    "/login?token=secret",
+					Status:        302,
+				},
+			},
+			want: `- - - [25/Sep/2025:12:00:00 +0000] "POST /login?token=[MASKED]" 302 - "-" "-"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := tt.formatter.Format(tt.entry)
+			if err != nil {
+				t.Fatalf("Format() returned an error: %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("unexpected output:\ngot:  %s\nwant: %s", b, tt.want)
+			}
+		})
+	}
+}
+
+// TestAutoFormatter verifies the formatter selection of AutoFormatter.
+// Tests run without a terminal, so the JSON formatter is expected unless color is forced.
+func TestAutoFormatter(t *testing.T) {
+	t.Run("Non-terminal selects JSON", func(t *testing.T) {
+		t.Setenv("HARELOG_FORCE_COLOR", "")
+
+		if isTerminal() {
+			t.Skip("test requires a non-terminal environment")
+		}
+
+		if _, ok := AutoFormatter().(*jsonFormatter); !ok {
+			t.Errorf("expected jsonFormatter, got %T", AutoFormatter())
+		}
+	})
+
+	t.Run("HARELOG_FORCE_COLOR selects Console", func(t *testing.T) {
+		t.Setenv("HARELOG_FORCE_COLOR", "1")
+
+		f, ok := AutoFormatter().(*consoleFormatter)
+		if !ok {
+			t.Fatalf("expected consoleFormatter, got %T", AutoFormatter())
+		}
+		if !f.enableColor {
+			t.Error("expected log level color to be enabled")
+		}
+	})
+}
+
+// TestFormatter_Metric verifies that each formatter renders MetricValue fields.
+func TestFormatter_Metric(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		want      string
+	}{
+		{"JSON", JSON.NewFormatter(), `"latency":{"value":12.3,"unit":"ms"}`},
+		{"JSON without unit", JSON.NewFormatter(), `"requests":{"value":3}`},
+		{"Text", Text.NewFormatter(), `latency=12.3ms`},
+		{"Console", Console.NewFormatter(), `latency=12.3ms`},
+		{"Logfmt", Logfmt.NewFormatter(), `latency=12.3ms`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			logger := New(WithOutput(&buf), WithFormatter(tt.formatter))
+
+			logger.Infow("done", Metric("latency", 12.3, "ms"), "user", "u-1", Metric("requests", 3, ""))
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("output %s does not contain %s", buf.String(), tt.want)
+			}
+			if !strings.Contains(buf.String(), "u-1") {
+				t.Errorf("expected fields following a metric to be kept, got %s", buf.String())
+			}
+			if strings.Contains(buf.String(), "logging_error") {
+				t.Errorf("expected metrics not to count as odd arguments, got %s", buf.String())
+			}
+		})
+	}
+
+	t.Run("With and EntryBuilder", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithFormatter(Logfmt.NewFormatter())).With(Metric("capacity", 64, "MiB"))
+
+		logger.Entry().Metric("latency", 1.5, "s").Msg("done")
+
+		if !strings.Contains(buf.String(), "capacity=64MiB") || !strings.Contains(buf.String(), "latency=1.5s") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+}
+
+// TestJSONFormatter_FieldNamePresets verifies the zap and logrus field name presets.
+func TestJSONFormatter_FieldNamePresets(t *testing.T) {
+	t.Parallel()
+
+	entry := func() *LogEntry {
+		return &LogEntry{
+			Message:        "preset test",
+			Severity:       LogLevelWarn,
+			Time:           time.Date(2025, 9, 25, 12, 0, 0, 500000000, time.UTC),
+			SourceLocation: &SourceLocation{File: "main.go", Line: 42, Function: "main.main"},
+			Labels:         map[string]string{"env": "test"},
+			Payload:        map[string]interface{}{"user": "u-1", "password": "secret"},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		want      map[string]interface{}
+		absent    []string
+	}{
+		{
+			name:      "Zap",
+			formatter: JSON.NewFormatter(JSON.ZapFieldNames(), JSON.WithMaskingKeys("password")),
+			want: map[string]interface{}{
+				"msg":      "preset test",
+				"level":    "warn",
+				"ts":       1758801600.5,
+				"caller":   "main.go:42",
+				"user":     "u-1",
+				"password": maskedValueString,
+			},
+			absent: []string{"message", "severity", "timestamp", "logging.googleapis.com/sourceLocation"},
+		},
+		{
+			name:      "Logrus",
+			formatter: JSON.NewFormatter(JSON.LogrusFieldNames()),
+			want: map[string]interface{}{
+				"msg":   "preset test",
+				"level": "warning",
+				"time":  "2025-09-25T12:00:00.5Z",
+				"user":  "u-1",
+			},
+			absent: []string{"message", "severity", "timestamp", "caller"},
+		},
+		{
+			name:      "Logrus with caller field",
+			formatter: JSON.NewFormatter(JSON.LogrusFieldNames(), JSON.WithCallerField(true)),
+			want: map[string]interface{}{
+				"msg":    "preset test",
+				"caller": "main.go:42",
+			},
+			absent: []string{"logging.googleapis.com/sourceLocation"},
+		},
+		{
+			name:      "Default names with caller field",
+			formatter: JSON.NewFormatter(JSON.WithCallerField(true)),
+			want: map[string]interface{}{
+				"message":  "preset test",
+				"severity": "WARN",
+				"caller":   "main.go:42",
+			},
+			absent: []string{"logging.googleapis.com/sourceLocation"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := tt.formatter.Format(entry())
+			if err != nil {
+				t.Fatalf("Format() returned an error: %v", err)
+			}
+
+			var got map[string]interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("failed to unmarshal output %s: %v", b, err)
+			}
+
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("key %q: got %v, want %v", k, got[k], v)
+				}
+			}
+			for _, k := range tt.absent {
+				if _, ok := got[k]; ok {
+					t.Errorf("expected key %q to be absent in %s", k, b)
+				}
+			}
+			if labels, _ := got["labels"].(map[string]interface{}); labels["env"] != "test" {
+				t.Errorf("expected labels to be kept, got %v", got["labels"])
+			}
+		})
+	}
+}
+
+// TestBareFormatter verifies that BareFormatter omits the timestamp and level.
+func TestBareFormatter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		formatter *bareFormatter
+		entry     *LogEntry
+		want      string
+	}{
+		{
+			name:      "Message only",
+			formatter: Bare.NewFormatter(),
+			entry:     &LogEntry{Message: "hello\n", Severity: LogLevelInfo, Time: time.Now()},
+			want:      "hello",
+		},
+		{
+			name:      "With fields",
+			formatter: Bare.NewFormatter(),
+			entry: &LogEntry{
+				Message:  "user logged in",
+				Severity: LogLevelInfo,
+				Time:     time.Now(),
+				Labels:   map[string]string{"env": "test"},
+				Payload:  map[string]interface{}{"userID": "user-123", "count": 2},
+			},
+			want: "user logged in { label.env=test, count=2, userID=user-123 }",
+		},
+		{
+			name:      "With masking",
+			formatter: Bare.NewFormatter(Bare.WithMaskingKeys("password"), Bare.WithMaskingKeysIgnoreCase("TOKEN")),
+			entry: &LogEntry{
+				Message:  "login",
+				Severity: LogLevelWarn,
+				Time:     time.Now(),
+				Payload:  map[string]interface{}{"password": "secret", "token": "abc"},
+			},
+			want: "login { password=[MASKED], token=[MASKED] }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := tt.formatter.Format(tt.entry)
+			if err != nil {
+				t.Fatalf("Format() returned an error: %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %q, want %q", b, tt.want)
+			}
+		})
+	}
+
+	b, _ := Bare.NewFormatter().FormatMessageOnly(&LogEntry{Message: "warning", Severity: LogLevelWarn, Time: time.Now()})
+	if string(b) != "warning" {
+		t.Errorf("FormatMessageOnly: got %q, want %q", b, "warning")
+	}
+}
+
+// TestFormatter_ControlCharEscaping verifies that control characters cannot inject
+// line breaks or escape sequences into text-based output.
+func TestFormatter_ControlCharEscaping(t *testing.T) {
+	t.Parallel()
+
+	entry := func() *LogEntry {
+		return &LogEntry{
+			Message:  "frame\n\x1b[31mfake\tentry\u0085\xff\n",
+			Severity: LogLevelInfo,
+			Time:     time.Date(2025, 9, 25, 12, 0, 0, 0, time.UTC),
+			Payload:  map[string]interface{}{"preview": "a\r\nb"},
+		}
+	}
+
+	const escaped = `frame\n\x1b[31mfake\tentry\u0085\xff`
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		want      []string
+		raw       bool
+	}{
+		{"Text", Text.NewFormatter(), []string{escaped, `preview="a\r\nb"`}, false},
+		{"Console", Console.NewFormatter(Console.WithLogLevelColor(false)), []string{escaped, `preview="a\r\nb"`}, false},
+		{"Bare", Bare.NewFormatter(), []string{escaped, `preview="a\r\nb"`}, false},
+		{"Logfmt", Logfmt.NewFormatter(), []string{`preview="a\r\nb"`}, false},
+		{"Text raw", Text.NewFormatter(Text.WithControlCharEscaping(false)), []string{"frame\n\x1b[31mfake", "preview=a\r\nb"}, true},
+		{"Logfmt raw", Logfmt.NewFormatter(Logfmt.WithControlCharEscaping(false)), []string{"preview=a\r\nb"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := tt.formatter.Format(entry())
+			if err != nil {
+				t.Fatalf("Format() returned an error: %v", err)
+			}
+
+			got := string(b)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output %q does not contain %q", got, want)
+				}
+			}
+			if !tt.raw && strings.ContainsAny(got, "\n\r\x1b") {
+				t.Errorf("expected no raw control characters, got %q", got)
+			}
+		})
+	}
+}
+
+// TestFormatter_SingleLineValues verifies that a key or value containing newlines
+// cannot forge additional log lines in the text-based formatters (CWE-117).
+func TestFormatter_SingleLineValues(t *testing.T) {
+	t.Parallel()
+
+	formatters := map[string]Formatter{
+		"Text":    Text.NewFormatter(),
+		"Console": Console.NewFormatter(Console.WithLogLevelColor(false)),
+		"Logfmt":  Logfmt.NewFormatter(),
+		"Bare":    Bare.NewFormatter(),
+	}
+
+	for name, formatter := range formatters {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			logger := New(WithOutput(&buf), WithFormatter(formatter), WithLabels(map[string]string{"env": "a\nb", "re\ngion": "x"}))
+
+			logger.Infow("login a\nb", "user", "a\nb", "comment", "x\r\n2025-01-01T00:00:00Z [ERROR] forged", "a\nb", "v")
+
+			out := strings.TrimSuffix(buf.String(), "\n")
+			if strings.ContainsAny(out, "\r\n") {
+				t.Errorf("expected a single physical line, got %q", buf.String())
+			}
+			if !strings.Contains(out, `user="a\nb"`) {
+				t.Errorf("expected the escaped value to be kept, got %q", out)
+			}
+			if name != "Bare" && !strings.Contains(out, `"a\nb"=v`) {
+				t.Errorf("expected the escaped key to be kept, got %q", out)
+			}
+		})
+	}
+}
+
+// diffUser is a struct used by TestDiff.
+type diffUser struct {
+	Name     string `json:"name"`
+	Age      int    `json:"age"`
+	Password string `json:"password" log:"mask"`
+	Internal string `log:"-"`
+	Address  struct {
+		City string `json:"city"`
+	} `json:"address"`
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// TestDiff verifies the changes computed by Diff and how each formatter renders them.
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	before := diffUser{Name: "alice", Age: 3, Password: "old", Internal: "a", Tags: map[string]string{"role": "user", "team": "x"}}
+	before.Address.City = "Tokyo"
+
+	after := before
+	after.Age = 4
+	after.Password = "new"
+	after.Internal = "b"
+	after.Address.City = "Osaka"
+	after.Tags = map[string]string{"role": "admin", "plan": "pro"}
+
+	t.Run("Changes", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name   string
+			diff   DiffValue
+			want   string
+			wantJS string
+		}{
+			{
+				name:   "Struct",
+				diff:   Diff(before, &after),
+				want:   "age: 3 -> 4; password: [MASKED] -> [MASKED]; address.city: Tokyo -> Osaka; tags.plan: <nil> -> pro; tags.role: user -> admin; tags.team: x -> <nil>",
+				wantJS: `{"age":{"old":3,"new":4},"password":{"old":"[MASKED]","new":"[MASKED]"},"address.city":{"old":"Tokyo","new":"Osaka"},"tags.plan":{"old":null,"new":"pro"},"tags.role":{"old":"user","new":"admin"},"tags.team":{"old":"x","new":null}}`,
+			},
+			{
+				name:   "Map",
+				diff:   Diff(map[string]any{"a": 1, "b": 2}, map[string]any{"a": 1, "b": 3}),
+				want:   "b: 2 -> 3",
+				wantJS: `{"b":{"old":2,"new":3}}`,
+			},
+			{
+				name:   "Created",
+				diff:   Diff(nil, map[string]int{"a": 1}),
+				want:   "a: <nil> -> 1",
+				wantJS: `{"a":{"old":null,"new":1}}`,
+			},
+			{
+				name:   "Whole value",
+				diff:   Diff("draft", "published"),
+				want:   "draft -> published",
+				wantJS: `{"":{"old":"draft","new":"published"}}`,
+			},
+			{
+				name:   "Time",
+				diff:   Diff(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)),
+				want:   "",
+				wantJS: `{}`,
+			},
+		}
+
+		for _, tt := range tests {
+			if got := tt.diff.String(); got != tt.want {
+				t.Errorf("%s: String() = %q, want %q", tt.name, got, tt.want)
+			}
+
+			js, err := tt.diff.MarshalJSON()
+			if err != nil {
+				t.Fatalf("%s: MarshalJSON() returned an error: %v", tt.name, err)
+			}
+			if string(js) != tt.wantJS {
+				t.Errorf("%s: MarshalJSON() = %s, want %s", tt.name, js, tt.wantJS)
+			}
+		}
+	})
+
+	t.Run("Cyclic values", func(t *testing.T) {
+		t.Parallel()
+
+		type node struct {
+			Name string `json:"name"`
+			Next *node  `json:"next"`
+		}
+
+		before := &node{Name: "a"}
+		before.Next = before
+
+		after := &node{Name: "b"}
+		after.Next = after
+
+		m := map[string]any{"n": 1}
+		m["self"] = m
+
+		tests := []struct {
+			name string
+			diff DiffValue
+			want string
+		}{
+			{"Struct", Diff(before, after), "name: a -> b"},
+			{"Created", Diff(nil, after), "name: <nil> -> b"},
+			{"Map", Diff(m, map[string]any{"n": 2}), "n: 1 -> 2; self.n: 1 -> <nil>"},
+		}
+
+		for _, tt := range tests {
+			if got := tt.diff.String(); got != tt.want {
+				t.Errorf("%s: String() = %q, want %q", tt.name, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("Formatters", func(t *testing.T) {
+		t.Parallel()
+
+		diff := Diff(map[string]string{"name": "alice", "plan": "free"}, map[string]string{"name": "bob", "plan": "free"})
+
+		tests := []struct {
+			name      string
+			formatter Formatter
+			want      string
+		}{
+			{"JSON", JSON.NewFormatter(), `"changes":{"name":{"old":"alice","new":"bob"}}`},
+			{"Text", Text.NewFormatter(), `changes="name: alice -> bob"`},
+			{"Logfmt", Logfmt.NewFormatter(), `changes="name: alice -> bob"`},
+		}
+
+		for _, tt := range tests {
+			var buf bytes.Buffer
+
+			logger := New(WithOutput(&buf), WithFormatter(tt.formatter))
+
+			logger.Infow("user updated", "changes", diff)
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("%s: output %s does not contain %s", tt.name, buf.String(), tt.want)
+			}
+		}
+	})
+}
+
+// TestFormatter_FieldErrors verifies that each formatter renders FieldErrors.
+func TestFormatter_FieldErrors(t *testing.T) {
+	t.Parallel()
+
+	errs := FieldErrors{}.Add("email", "required").Add("age", "must be positive")
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		want      string
+	}{
+		{"JSON", JSON.NewFormatter(), `"errors":[{"field":"email","message":"required"},{"field":"age","message":"must be positive"}]`},
+		{"Text", Text.NewFormatter(), `errors="email: required; age: must be positive"`},
+		{"Console", Console.NewFormatter(Console.WithLogLevelColor(false)), `errors="email: required; age: must be positive"`},
+		{"Logfmt", Logfmt.NewFormatter(), `errors="email: required; age: must be positive"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			logger := New(WithOutput(&buf), WithFormatter(tt.formatter))
+
+			logger.Warnw("invalid input", "errors", errs)
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("output %s does not contain %s", buf.String(), tt.want)
+			}
+		})
+	}
+
+	t.Run("Under the error key", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf))
+
+		logger.Warnw("invalid input", "error", errs)
+
+		if !strings.Contains(buf.String(), `"error":"email: required; age: must be positive"`) {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+}
+
+// TestFormatter_SQLQuery verifies that each formatter renders SQLQuery fields
+// with their arguments written according to the SQLArgMode.
+func TestFormatter_SQLQuery(t *testing.T) {
+	t.Parallel()
+
+	query := SQLQuery{
+		Query: "SELECT id FROM users WHERE email = $1 AND age = $2",
+		Args:  []any{"alice@example.com", 30},
+	}
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		mode      SQLArgMode
+		want      string
+	}{
+		{"JSON", JSON.NewFormatter(), SQLArgModeRedact, `"sql":{"query":"SELECT id FROM users WHERE email = $1 AND age = $2","args":["[MASKED]","[MASKED]"]}`},
+		{"JSON types", JSON.NewFormatter(), SQLArgModeTypes, `"args":["string","int"]`},
+		{"JSON values", JSON.NewFormatter(), SQLArgModeValues, `"args":["alice@example.com",30]`},
+		{"Text", Text.NewFormatter(), SQLArgModeRedact, `sql="SELECT id FROM users WHERE email = $1 AND age = $2 [args: [MASKED], [MASKED]]"`},
+		{"Text types", Text.NewFormatter(), SQLArgModeTypes, `[args: string, int]`},
+		{"Console", Console.NewFormatter(Console.WithLogLevelColor(false)), SQLArgModeRedact, `[args: [MASKED], [MASKED]]`},
+		{"Logfmt", Logfmt.NewFormatter(), SQLArgModeRedact, `sql="SELECT id FROM users WHERE email = $1 AND age = $2 [args: [MASKED], [MASKED]]"`},
+		{"Logfmt values", Logfmt.NewFormatter(), SQLArgModeValues, `[args: alice@example.com, 30]`},
+		{"Bunyan", Bunyan.NewFormatter(), SQLArgModeRedact, `"args":["[MASKED]","[MASKED]"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			logger := New(WithOutput(&buf), WithFormatter(tt.formatter), WithSQLArgMode(tt.mode))
+
+			logger.Infow("query", "sql", query)
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("output %s does not contain %s", buf.String(), tt.want)
+			}
+			if tt.mode != SQLArgModeValues && strings.Contains(buf.String(), "alice@example.com") {
+				t.Errorf("expected the arguments to be hidden, got %s", buf.String())
+			}
+		})
+	}
+
+	t.Run("Without arguments", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf))
+
+		logger.Infow("query", "sql", SQLQuery{Query: "SELECT 1"})
+
+		if !strings.Contains(buf.String(), `"sql":{"query":"SELECT 1"}`) {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("Invalid mode panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for an invalid SQLArgMode")
+			}
+		}()
+
+		WithSQLArgMode(SQLArgMode(99))
+	})
+}
+
+// textColor implements only encoding.TextMarshaler.
+type textColor int
+
+func (c textColor) MarshalText() ([]byte, error) {
+	return []byte([]string{"red", "green"}[c]), nil
+}
+
+// TestFormatter_RawMessageAndTextMarshaler verifies that the text-based formatters
+// write json.RawMessage values as their JSON text and encoding.TextMarshaler values
+// as their text, like the JSON formatter.
+func TestFormatter_RawMessageAndTextMarshaler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		want      []string
+	}{
+		{"JSON", JSON.NewFormatter(), []string{`"raw":{"id":1}`, `"color":"green"`}},
+		{"Text", Text.NewFormatter(), []string{`raw="{\"id\":1}"`, `color=green`}},
+		{"Console", Console.NewFormatter(Console.WithLogLevelColor(false)), []string{`raw="{\"id\":1}"`, `color=green`}},
+		{"Logfmt", Logfmt.NewFormatter(), []string{`raw="{\"id\":1}"`, `color=green`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			logger := New(WithOutput(&buf), WithFormatter(tt.formatter))
+
+			logger.Infow("values", "raw", json.RawMessage(`{"id":1}`), "color", textColor(1))
+
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output %s does not contain %s", buf.String(), want)
+				}
+			}
+		})
+	}
+
+	if got := string(AppendLogfmtValue(nil, json.RawMessage(`[1,2]`))); got != "[1,2]" {
+		t.Errorf("AppendLogfmtValue() = %s, want [1,2]", got)
+	}
+}
+
+// stringerError implements both error and fmt.Stringer, with different outputs.
+type stringerError struct{}
+
+func (stringerError) Error() string  { return "error form" }
+func (stringerError) String() string { return "string form" }
+
+// TestFormatter_ErrorPrecedence verifies that every formatter writes a value
+// implementing both error and fmt.Stringer by its Error method, and that the JSON
+// formatters write plain errors as their Error string.
+func TestFormatter_ErrorPrecedence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		want      []string
+	}{
+		{"JSON", JSON.NewFormatter(), []string{`"cause":"error form"`, `"plain":"boom"`}},
+		{"JSON with field names", JSON.NewFormatter(JSON.ZapFieldNames()), []string{`"cause":"error form"`, `"plain":"boom"`}},
+		{"Bunyan", Bunyan.NewFormatter(), []string{`"cause":"error form"`, `"plain":"boom"`}},
+		{"Text", Text.NewFormatter(), []string{`cause="error form"`, `plain=boom`}},
+		{"Console", Console.NewFormatter(Console.WithLogLevelColor(false)), []string{`cause="error form"`, `plain=boom`}},
+		{"Logfmt", Logfmt.NewFormatter(), []string{`cause="error form"`, `plain=boom`}},
+		{"Console template", Console.NewFormatter(Console.WithLogLevelColor(false), Console.WithMessageTemplate(true)), []string{`failed: error form`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			logger := New(WithOutput(&buf), WithFormatter(tt.formatter))
+
+			logger.Infow("failed: {cause}", "cause", stringerError{}, "plain", errors.New("boom"))
+
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output %s does not contain %s", buf.String(), want)
+				}
+			}
+			if strings.Contains(buf.String(), "string form") {
+				t.Errorf("expected the Error form to take precedence, got %s", buf.String())
+			}
+		})
+	}
+}
+
+// TestParseJSONEntry verifies that entries formatted by the JSON formatter are
+// parsed back into equal fields.
+func TestParseJSONEntry(t *testing.T) {
+	t.Parallel()
+
+	sampled := true
+
+	entry := func() *LogEntry {
+		return &LogEntry{
+			Message:        "round trip",
+			Severity:       LogLevelWarn,
+			Time:           time.Date(2025, 9, 25, 12, 0, 0, 123456789, time.UTC),
+			Trace:          "projects/p/traces/t-1",
+			SpanID:         "s-1",
+			TraceSampled:   &sampled,
+			HTTPRequest:    &HTTPRequest{RequestMethod: "GET", RequestURL: "/users", Status: 200},
+			SourceLocation: &SourceLocation{File: "main.go", Line: 42, Function: "main.main"},
+			Labels:         map[string]string{"env": "test"},
+			CorrelationID:  "c-1",
+			Payload: map[string]interface{}{
+				"user":   "u-1",
+				"count":  3,
+				"ok":     true,
+				"nested": map[string]interface{}{"a": "b"},
+			},
+		}
+	}
+
+	wantPayload := map[string]interface{}{
+		"user":   "u-1",
+		"count":  float64(3),
+		"ok":     true,
+		"nested": map[string]interface{}{"a": "b"},
+	}
+
+	t.Run("Default field names", func(t *testing.T) {
+		t.Parallel()
+
+		want := entry()
+
+		out, err := JSON.NewFormatter().Format(entry())
+		if err != nil {
+			t.Fatalf("Format() failed: %v", err)
+		}
+
+		got, err := ParseJSONEntry(out)
+		if err != nil {
+			t.Fatalf("ParseJSONEntry() failed: %v", err)
+		}
+
+		if got.Message != want.Message || got.Severity != want.Severity || !got.Time.Equal(want.Time) ||
+			got.Trace != want.Trace || got.SpanID != want.SpanID || *got.TraceSampled != *want.TraceSampled ||
+			got.CorrelationID != want.CorrelationID {
+			t.Errorf("core fields differ:\ngot:  %+v\nwant: %+v", got, want)
+		}
+		if !reflect.DeepEqual(got.HTTPRequest, want.HTTPRequest) || !reflect.DeepEqual(got.SourceLocation, want.SourceLocation) ||
+			!reflect.DeepEqual(got.Labels, want.Labels) {
+			t.Errorf("structured fields differ:\ngot:  %+v %+v %v\nwant: %+v %+v %v",
+				got.HTTPRequest, got.SourceLocation, got.Labels, want.HTTPRequest, want.SourceLocation, want.Labels)
+		}
+		if !reflect.DeepEqual(got.Payload, wantPayload) {
+			t.Errorf("payload differs:\ngot:  %v\nwant: %v", got.Payload, wantPayload)
+		}
+	})
+
+	t.Run("Zap field names", func(t *testing.T) {
+		t.Parallel()
+
+		f := JSON.NewFormatter(JSON.ZapFieldNames())
+
+		out, err := f.Format(entry())
+		if err != nil {
+			t.Fatalf("Format() failed: %v", err)
+		}
+
+		got, err := f.ParseEntry(out)
+		if err != nil {
+			t.Fatalf("ParseEntry() failed: %v", err)
+		}
+
+		if got.Message != "round trip" || got.Severity != LogLevelWarn {
+			t.Errorf("unexpected message or severity: %q %q", got.Message, got.Severity)
+		}
+		if want := time.Date(2025, 9, 25, 12, 0, 0, 123456000, time.UTC); !got.Time.Equal(want) {
+			t.Errorf("expected the time %v with microsecond precision, got %v", want, got.Time)
+		}
+		if got.SourceLocation == nil || got.SourceLocation.File != "main.go" || got.SourceLocation.Line != 42 {
+			t.Errorf("unexpected caller: %+v", got.SourceLocation)
+		}
+		if !reflect.DeepEqual(got.Payload, wantPayload) {
+			t.Errorf("payload differs:\ngot:  %v\nwant: %v", got.Payload, wantPayload)
+		}
+	})
+
+	t.Run("Epoch millis", func(t *testing.T) {
+		t.Parallel()
+
+		f := JSON.NewFormatter(JSON.WithTimeEncoding(TimeEpochMillis))
+
+		out, err := f.Format(entry())
+		if err != nil {
+			t.Fatalf("Format() failed: %v", err)
+		}
+
+		got, err := f.ParseEntry(out)
+		if err != nil {
+			t.Fatalf("ParseEntry() failed: %v", err)
+		}
+
+		if want := time.Date(2025, 9, 25, 12, 0, 0, 123000000, time.UTC); !got.Time.Equal(want) {
+			t.Errorf("expected %v, got %v", want, got.Time)
+		}
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		t.Parallel()
+
+		for _, in := range []string{`not json`, `{"severity":"LOUD"}`, `{"timestamp":"yesterday"}`} {
+			if _, err := ParseJSONEntry([]byte(in)); err == nil {
+				t.Errorf("expected an error for %s", in)
+			}
+		}
+	})
+}
+
+// TestJSONFormatter_StructTagMasking verifies that struct fields are omitted or
+// masked by their log tag, at any depth, only when the option is enabled.
+func TestJSONFormatter_StructTagMasking(t *testing.T) {
+	t.Parallel()
+
+	type Card struct {
+		Number string `json:"number" log:"mask"`
+		Brand  string `json:"brand"`
+	}
+
+	type Audit struct {
+		CreatedBy string `json:"createdBy"`
+		Token     string `json:"token" log:"-"`
+	}
+
+	type User struct {
+		Audit
+		ID       int               `json:"id"`
+		Name     string            `json:"name"`
+		SSN      string            `json:"ssn" log:"mask"`
+		Password string            `json:"-"`
+		Secret   string            `json:"secret" log:"-"`
+		Nickname string            `json:"nickname,omitempty"`
+		Card     *Card             `json:"card"`
+		Cards    []Card            `json:"cards"`
+		ByName   map[string]Card   `json:"byName"`
+		Extra    interface{}       `json:"extra"`
+		Created  time.Time         `json:"created"`
+		Tags     map[string]string `json:"tags,omitempty"`
+	}
+
+	user := User{
+		Audit:    Audit{CreatedBy: "admin", Token: "t0k3n"},
+		ID:       1,
+		Name:     "alice",
+		SSN:      "123-45-6789",
+		Password: "hunter2",
+		Secret:   "s3cr3t",
+		Card:     &Card{Number: "4111", Brand: "visa"},
+		Cards:    []Card{{Number: "5500", Brand: "mc"}},
+		ByName:   map[string]Card{"main": {Number: "3400", Brand: "amex"}},
+		Extra:    Card{Number: "6011", Brand: "discover"},
+		Created:  time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	t.Run("Enabled", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithFormatter(JSON.NewFormatter(JSON.WithStructTagMasking(true))))
+
+		logger.Infow("user", "user", &user, "count", 3)
+
+		want := `"user":{"createdBy":"admin","id":1,"name":"alice","ssn":"[MASKED]",` +
+			`"card":{"number":"[MASKED]","brand":"visa"},` +
+			`"cards":[{"number":"[MASKED]","brand":"mc"}],` +
+			`"byName":{"main":{"number":"[MASKED]","brand":"amex"}},` +
+			`"extra":{"number":"[MASKED]","brand":"discover"},` +
+			`"created":"2025-01-02T03:04:05Z"}`
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output %s does not contain %s", buf.String(), want)
+		}
+
+		for _, secret := range []string{"t0k3n", "123-45-6789", "hunter2", "s3cr3t", "4111", "5500", "3400", "6011"} {
+			if strings.Contains(buf.String(), secret) {
+				t.Errorf("output %s contains %s", buf.String(), secret)
+			}
+		}
+
+		if !strings.Contains(buf.String(), `"count":3`) {
+			t.Errorf("expected untagged values to be kept, got %s", buf.String())
+		}
+
+		if user.SSN != "123-45-6789" || user.Card.Number != "4111" {
+			t.Error("expected the logged value not to be modified")
+		}
+	})
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf))
+
+		logger.Infow("user", "user", user)
+
+		if !strings.Contains(buf.String(), `"ssn":"123-45-6789"`) {
+			t.Errorf("expected the struct to be written as is, got %s", buf.String())
+		}
+	})
+}
+
+// TestJSONFormatter_MaskingJSONPaths verifies that JSON.WithMaskingJSONPaths masks
+// values nested in maps, slices and structs without modifying the caller's values.
+func TestJSONFormatter_MaskingJSONPaths(t *testing.T) {
+	t.Parallel()
+
+	type Card struct {
+		Number string `json:"number"`
+		Brand  string `json:"brand"`
+	}
+
+	type Order struct {
+		ID    int    `json:"id"`
+		Cards []Card `json:"cards"`
+	}
+
+	user := map[string]interface{}{
+		"name": "alice",
+		"ssn":  "123-45-6789",
+		"address": map[string]interface{}{
+			"city": "Tokyo",
+			"zip":  "100-0001",
+		},
+	}
+	cards := []interface{}{
+		map[string]interface{}{"number": "4111", "brand": "visa"},
+		map[string]interface{}{"number": "5500", "brand": "mc"},
+	}
+	order := &Order{ID: 7, Cards: []Card{{Number: "3400", Brand: "amex"}, {Number: "6011", Brand: "discover"}}}
+
+	f := JSON.NewFormatter(JSON.WithMaskingJSONPaths(
+		"user.ssn",
+		"user.address.zip",
+		"cards[*].number",
+		"order.cards[1].number",
+		"missing.path",
+	))
+
+	b, err := f.Format(&LogEntry{
+		Message:  "checkout",
+		Severity: LogLevelInfo,
+		Payload:  map[string]interface{}{"user": user, "cards": cards, "order": order, "ssn": "top-level"},
+	})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	for _, want := range []string{
+		`"user":{"address":{"city":"Tokyo","zip":"[MASKED]"},"name":"alice","ssn":"[MASKED]"}`,
+		`"cards":[{"brand":"visa","number":"[MASKED]"},{"brand":"mc","number":"[MASKED]"}]`,
+		`"order":{"id":7,"cards":[{"number":"3400","brand":"amex"},{"number":"[MASKED]","brand":"discover"}]}`,
+		`"ssn":"top-level"`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("output %s does not contain %s", b, want)
+		}
+	}
+
+	if user["ssn"] != "123-45-6789" || cards[0].(map[string]interface{})["number"] != "4111" || order.Cards[1].Number != "6011" {
+		t.Error("the caller's values were modified")
+	}
+
+	t.Run("Wildcard Key And Marshaler", func(t *testing.T) {
+		t.Parallel()
+
+		f := JSON.NewFormatter(JSON.WithMaskingJSONPaths("*.password", "raw.token"))
+
+		b, err := f.Format(&LogEntry{
+			Message:  "login",
+			Severity: LogLevelInfo,
+			Payload: map[string]interface{}{
+				"req": map[string]string{"user": "bob", "password": "p"},
+				"raw": json.RawMessage(`{"token":"abc","n":1.50}`),
+			},
+		})
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		for _, want := range []string{
+			`"req":{"password":"[MASKED]","user":"bob"}`,
+			`"raw":{"n":1.50,"token":"[MASKED]"}`,
+		} {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("output %s does not contain %s", b, want)
+			}
+		}
+	})
+
+	t.Run("Invalid Paths Panic", func(t *testing.T) {
+		t.Parallel()
+
+		for _, path := range []string{"", "[0]", "a..b", "a[x]", "a[0", "a[0]b", "a]"} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("expected a panic for path %q", path)
+					}
+				}()
+
+				JSON.WithMaskingJSONPaths(path)
+			}()
+		}
+	})
+}
+
+// TestTextFormatter_FieldDelimiters verifies the delimiters written around and
+// between the fields, and that entries without fields end after the message.
+func TestTextFormatter_FieldDelimiters(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2025, 10, 14, 13, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		opts      []TextFormatterOption
+		payload   map[string]interface{}
+		want      string
+		wantEmpty string
+	}{
+		{
+			name:      "Default",
+			payload:   map[string]interface{}{"a": 1, "b": "x"},
+			want:      "2025-10-14T13:30:00Z [INFO] msg { a=1, b=x }",
+			wantEmpty: "2025-10-14T13:30:00Z [INFO] msg",
+		},
+		{
+			name:      "No braces with pipes",
+			opts:      []TextFormatterOption{Text.WithFieldDelimiters("", "", " | ")},
+			payload:   map[string]interface{}{"a": 1, "b": "x"},
+			want:      "2025-10-14T13:30:00Z [INFO] msg a=1 | b=x",
+			wantEmpty: "2025-10-14T13:30:00Z [INFO] msg",
+		},
+		{
+			name:      "Brackets",
+			opts:      []TextFormatterOption{Text.WithFieldDelimiters("[", "]", "; ")},
+			payload:   map[string]interface{}{"a": 1},
+			want:      "2025-10-14T13:30:00Z [INFO] msg [ a=1 ]",
+			wantEmpty: "2025-10-14T13:30:00Z [INFO] msg",
+		},
+		{
+			name:      "Tab message separator",
+			opts:      []TextFormatterOption{Text.WithMessageFieldSeparator("\t")},
+			payload:   map[string]interface{}{"a": 1, "b": "x"},
+			want:      "2025-10-14T13:30:00Z [INFO] msg\t{ a=1, b=x }",
+			wantEmpty: "2025-10-14T13:30:00Z [INFO] msg",
+		},
+		{
+			name: "Message separator with custom delimiters",
+			opts: []TextFormatterOption{
+				Text.WithMessageFieldSeparator(" || "),
+				Text.WithFieldDelimiters("", "", " | "),
+			},
+			payload:   map[string]interface{}{"a": 1, "b": "x"},
+			want:      "2025-10-14T13:30:00Z [INFO] msg || a=1 | b=x",
+			wantEmpty: "2025-10-14T13:30:00Z [INFO] msg",
+		},
+		{
+			name:      "Empty message separator",
+			opts:      []TextFormatterOption{Text.WithMessageFieldSeparator("")},
+			payload:   map[string]interface{}{"a": 1},
+			want:      "2025-10-14T13:30:00Z [INFO] msg{ a=1 }",
+			wantEmpty: "2025-10-14T13:30:00Z [INFO] msg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := Text.NewFormatter(tt.opts...)
+
+			got, err := f.Format(&LogEntry{Message: "msg", Severity: LogLevelInfo, Time: ts, Payload: tt.payload})
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+
+			got, err = f.Format(&LogEntry{Message: "msg", Severity: LogLevelInfo, Time: ts})
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if string(got) != tt.wantEmpty {
+				t.Errorf("got %q, want %q", got, tt.wantEmpty)
+			}
+		})
+	}
+
+	t.Run("Panics on empty separator", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+
+		Text.WithFieldDelimiters("{", "}", "")
+	})
+}
+
+// TestFormatter_LevelAbbreviation verifies the single-character level of the
+// text and console formatters, including its color in the console formatter.
+func TestFormatter_LevelAbbreviation(t *testing.T) {
+	entry := &LogEntry{
+		Message:  "msg",
+		Severity: LogLevelWarn,
+		Time:     time.Date(2025, 10, 14, 13, 30, 0, 0, time.UTC),
+	}
+
+	t.Run("Text", func(t *testing.T) {
+		got, _ := Text.NewFormatter(Text.WithLevelAbbreviation(true)).Format(entry)
+		if want := "2025-10-14T13:30:00Z W msg"; string(got) != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		got, _ = Text.NewFormatter().Format(entry)
+		if want := "2025-10-14T13:30:00Z [WARN] msg"; string(got) != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Console with color", func(t *testing.T) {
+		t.Setenv("HARELOG_FORCE_COLOR", "1")
+
+		f := Console.NewFormatter(Console.WithLogLevelColor(true), Console.WithLevelAbbreviation(true))
+
+		got, _ := f.Format(entry)
+
+		warn := levelColorMap[LogLevelWarn]
+		warn.EnableColor()
+
+		if want := "2025-10-14T13:30:00Z " + warn.Sprint("W") + " msg"; string(got) != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+// TestBunyanFormatter verifies the Bunyan record written for an entry.
+func TestBunyanFormatter(t *testing.T) {
+	t.Parallel()
+
+	f := Bunyan.NewFormatter(Bunyan.WithName("api"), Bunyan.WithMaskingKeys("password"))
+
+	entry := &LogEntry{
+		Message:        "user created",
+		Severity:       LogLevelWarn,
+		Time:           time.Date(2025, 10, 14, 22, 30, 0, 123456789, time.FixedZone("JST", 9*60*60)),
+		SourceLocation: &SourceLocation{File: "main.go", Line: 42, Function: "main.main"},
+		Labels:         map[string]string{"env": "prod"},
+		Payload:        map[string]interface{}{"user": "u-1", "password": "secret", "msg": "dropped"},
+	}
+
+	b, err := f.Format(entry)
+	if err != nil {
+		t.Fatalf("Format() returned an error: %v", err)
+	}
+
+	wantPrefix := fmt.Sprintf(`{"v":0,"name":"api","hostname":%q,"pid":%d,"level":40,"msg":"user created","time":"2025-10-14T13:30:00.123Z",`, f.hostname, os.Getpid())
+	if !strings.HasPrefix(string(b), wantPrefix) {
+		t.Errorf("got %s, want prefix %s", b, wantPrefix)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("failed to unmarshal output %s: %v", b, err)
+	}
+
+	if src, _ := got["src"].(map[string]interface{}); src["file"] != "main.go" || src["line"] != float64(42) || src["func"] != "main.main" {
+		t.Errorf("unexpected src: %v", got["src"])
+	}
+	if got["user"] != "u-1" || got["password"] != maskedValueString || got["msg"] != "user created" {
+		t.Errorf("unexpected payload fields: %s", b)
+	}
+
+	for level, want := range map[LogLevel]string{
+		LogLevelTrace:    `"level":10`,
+		LogLevelDebug:    `"level":20`,
+		LogLevelInfo:     `"level":30`,
+		LogLevelError:    `"level":50`,
+		LogLevelCritical: `"level":60`,
+	} {
+		b, err := f.FormatMessageOnly(&LogEntry{Message: "m", Severity: level, Time: entry.Time})
+		if err != nil {
+			t.Fatalf("FormatMessageOnly() returned an error: %v", err)
+		}
+		if !strings.Contains(string(b), want) || !json.Valid(b) {
+			t.Errorf("%s: got %s, want %s", level, b, want)
+		}
+	}
+
+	if name := Bunyan.NewFormatter().name; name != filepath.Base(os.Args[0]) {
+		t.Errorf("expected the executable name by default, got %q", name)
+	}
+}
+
+// TestHybridFormatter verifies that HybridFormatter writes a text header followed by
+// a JSON object of the other fields.
+func TestHybridFormatter(t *testing.T) {
+	t.Parallel()
+
+	entry := func() *LogEntry {
+		return &LogEntry{
+			Message:  "user logged in\n",
+			Severity: LogLevelInfo,
+			Time:     time.Date(2025, 10, 14, 13, 30, 0, 0, time.UTC),
+			Trace:    "projects/p/traces/t1",
+			Labels:   map[string]string{"env": "dev"},
+			Payload:  map[string]interface{}{"userID": "123", "password": "secret", "err": errors.New("boom")},
+		}
+	}
+
+	t.Run("header and fields", func(t *testing.T) {
+		f := Hybrid.NewFormatter(Hybrid.WithMaskingKeys("password"))
+
+		b, err := f.Format(entry())
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		want := `2025-10-14T13:30:00Z [INFO] user logged in {"logging.googleapis.com/trace":"projects/p/traces/t1","labels":{"env":"dev"},"err":"boom","password":"` + maskedValueString + `","userID":"123"}`
+		if got := string(b); got != want {
+			t.Errorf("unexpected hybrid output:\ngot:  %s\nwant: %s", got, want)
+		}
+
+		header, blob, _ := strings.Cut(string(b), " {")
+		if header != "2025-10-14T13:30:00Z [INFO] user logged in" || !json.Valid([]byte("{"+blob)) {
+			t.Errorf("expected a text header and a valid JSON object, got %q", b)
+		}
+	})
+
+	t.Run("no fields", func(t *testing.T) {
+		b, err := Hybrid.NewFormatter().Format(&LogEntry{Message: "bare\x1b[31m", Severity: LogLevelWarn, Time: time.Date(2025, 10, 14, 13, 30, 0, 0, time.UTC)})
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		if want := `2025-10-14T13:30:00Z [WARN] bare\x1b[31m`; string(b) != want {
+			t.Errorf("got %q, want %q", b, want)
+		}
+	})
+
+	t.Run("FormatMessageOnly", func(t *testing.T) {
+		b, err := Hybrid.NewFormatter().FormatMessageOnly(entry())
+		if err != nil {
+			t.Fatalf("FormatMessageOnly() error = %v", err)
+		}
+
+		if want := "2025-10-14T13:30:00Z [INFO] user logged in"; string(b) != want {
+			t.Errorf("got %q, want %q", b, want)
+		}
+	})
+}
+
+// TestParseFormat verifies the built-in format names and RegisterFormatter.
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want Formatter
+	}{
+		{"json", &jsonFormatter{}},
+		{"JSON", &jsonFormatter{}},
+		{"text", &textFormatter{}},
+		{"console", &consoleFormatter{}},
+		{"logfmt", &logfmtFormatter{}},
+		{"bare", &bareFormatter{}},
+		{"accesslog", &accessLogFormatter{}},
+		{"bunyan", &bunyanFormatter{}},
+		{"hybrid", &hybridFormatter{}},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseFormat(tt.name)
+		if err != nil {
+			t.Fatalf("ParseFormat(%q) error = %v", tt.name, err)
+		}
+
+		if reflect.TypeOf(f) != reflect.TypeOf(tt.want) {
+			t.Errorf("ParseFormat(%q) = %T, want %T", tt.name, f, tt.want)
+		}
+	}
+
+	if _, err := ParseFormat("gelf"); err == nil {
+		t.Error("expected an error for an unregistered format")
+	}
+
+	RegisterFormatter("Test-Custom", func() Formatter { return Text.NewFormatter(Text.WithLevelAbbreviation(true)) })
+
+	f, err := ParseFormat("test-custom")
+	if err != nil {
+		t.Fatalf("ParseFormat(test-custom) error = %v", err)
+	}
+
+	if tf, ok := f.(*textFormatter); !ok || !tf.abbreviateLevel {
+		t.Errorf("expected the registered formatter, got %#v", f)
+	}
+
+	for name, register := range map[string]func(){
+		"duplicate": func() { RegisterFormatter("TEST-CUSTOM", func() Formatter { return Text.NewFormatter() }) },
+		"built-in":  func() { RegisterFormatter("json", func() Formatter { return Text.NewFormatter() }) },
+		"nil":       func() { RegisterFormatter("test-nil", nil) },
+		"empty":     func() { RegisterFormatter("", func() Formatter { return Text.NewFormatter() }) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+
+			register()
+		}()
+	}
+}
+
+// TestJSONFormatter_TypeField verifies that WithTypeField writes a constant first field.
+func TestJSONFormatter_TypeField(t *testing.T) {
+	t.Parallel()
+
+	newEntry := func() *LogEntry {
+		return &LogEntry{
+			Message:  "hello",
+			Severity: LogLevelInfo,
+			Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Payload:  map[string]interface{}{"_type": "from-payload", "user": "u-1"},
+		}
+	}
+
+	tests := []struct {
+		name string
+		f    Formatter
+		want string
+	}{
+		{"Default Is Absent", JSON.NewFormatter(), `{"message":"hello","severity":"INFO","timestamp":"2024-01-02T03:04:05Z","_type":"from-payload","user":"u-1"}`},
+		{"First Field", JSON.NewFormatter(JSON.WithTypeField("_type", "applog")), `{"_type":"applog","message":"hello","severity":"INFO","timestamp":"2024-01-02T03:04:05Z","user":"u-1"}`},
+		{"With Field Names", JSON.NewFormatter(JSON.WithTypeField("_type", "applog"), JSON.LogrusFieldNames()), `{"_type":"applog","time":"2024-01-02T03:04:05Z","level":"info","msg":"hello","user":"u-1"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			entry := newEntry()
+
+			out, err := tt.f.Format(entry)
+			if err != nil {
+				t.Fatalf("Format returned an error: %v", err)
+			}
+
+			if string(out) != tt.want {
+				t.Errorf("unexpected output:\ngot:  %s\nwant: %s", out, tt.want)
+			}
+
+			if entry.Payload["_type"] != "from-payload" {
+				t.Errorf("expected the entry to keep its payload field, got %v", entry.Payload)
+			}
+		})
+	}
+
+	t.Run("Empty Entry", func(t *testing.T) {
+		t.Parallel()
+
+		f := JSON.NewFormatter(JSON.WithTypeField("_type", "applog"), JSON.LogrusFieldNames())
+
+		out, err := f.Format(&LogEntry{})
+		if err != nil {
+			t.Fatalf("Format returned an error: %v", err)
+		}
+
+		if !json.Valid(out) || !strings.HasPrefix(string(out), `{"_type":"applog"`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("Empty Key Panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for an empty key")
+			}
+		}()
+
+		JSON.WithTypeField("", "applog")
+	})
+}
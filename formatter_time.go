@@ -0,0 +1,92 @@
+package harelog
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	json "github.com/goccy/go-json"
+)
+
+// TimeEncoding defines how formatters encode the timestamp of a log entry.
+type TimeEncoding int
+
+const (
+	// TimeRFC3339 encodes the timestamp as an RFC 3339 string. This is the default.
+	// The JSON formatter keeps nanosecond precision; the text-based formatters use seconds.
+	TimeRFC3339 TimeEncoding = iota
+
+	// TimeEpochMillis encodes the timestamp as the number of milliseconds since the Unix epoch.
+	TimeEpochMillis
+
+	// TimeEpochNanos encodes the timestamp as the number of nanoseconds since the Unix epoch.
+	TimeEpochNanos
+
+	// TimeEpochFloat encodes the timestamp as fractional seconds since the Unix epoch,
+	// with microsecond precision (e.g. 1758801600.123456).
+	TimeEpochFloat
+)
+
+// TimeEncoder appends the encoding of t to dst and returns the extended slice, like
+// time.Time.AppendFormat. It is set with WithTimeEncoder.
+type TimeEncoder func(dst []byte, t time.Time) []byte
+
+// validateTimeEncoding panics if enc is not a known TimeEncoding.
+func validateTimeEncoding(enc TimeEncoding) {
+	if enc < TimeRFC3339 || enc > TimeEpochFloat {
+		panic(fmt.Sprintf("harelog: invalid TimeEncoding provided: %d", enc))
+	}
+}
+
+// isEpoch reports whether the encoding produces a number rather than a string.
+func (enc TimeEncoding) isEpoch() bool {
+	return enc != TimeRFC3339
+}
+
+// appendTime appends t to dst using enc. For TimeRFC3339, layout is used.
+func appendTime(dst []byte, t time.Time, enc TimeEncoding, layout string) []byte {
+	switch enc {
+	case TimeEpochMillis:
+		return strconv.AppendInt(dst, t.UnixMilli(), 10)
+	case TimeEpochNanos:
+		return strconv.AppendInt(dst, t.UnixNano(), 10)
+	case TimeEpochFloat:
+		return strconv.AppendFloat(dst, float64(t.UnixMicro())/1e6, 'f', -1, 64)
+	default:
+		return t.AppendFormat(dst, layout)
+	}
+}
+
+// encodeJSONTime encodes t with a TimeEncoder as a JSON value: as a number if the
+// encoder produces one (e.g. an epoch), and as a string otherwise.
+func encodeJSONTime(encoder TimeEncoder, t time.Time) ([]byte, error) {
+	b := encoder(nil, t)
+
+	if len(b) > 0 && (b[0] == '-' || (b[0] >= '0' && b[0] <= '9')) && json.Valid(b) {
+		return b, nil
+	}
+
+	return json.Marshal(string(b))
+}
+
+// jsonTime is a time.Time that is marshaled according to a TimeEncoding, or to
+// the logger's TimeEncoder if set.
+// Epoch encodings are marshaled as JSON numbers, TimeRFC3339 as a string.
+type jsonTime struct {
+	time.Time
+	encoding TimeEncoding
+	encoder  TimeEncoder
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t jsonTime) MarshalJSON() ([]byte, error) {
+	if t.encoder != nil {
+		return encodeJSONTime(t.encoder, t.Time)
+	}
+
+	if !t.encoding.isEpoch() {
+		return t.Time.MarshalJSON()
+	}
+
+	return appendTime(nil, t.Time, t.encoding, ""), nil
+}
@@ -6,9 +6,7 @@ require (
 	github.com/fatih/color v1.18.0
 	github.com/goccy/go-json v0.10.5
 	github.com/mattn/go-isatty v0.0.20
+	golang.org/x/sys v0.36.0
 )
 
-require (
-	github.com/mattn/go-colorable v0.1.14 // indirect
-	golang.org/x/sys v0.36.0 // indirect
-)
+require github.com/mattn/go-colorable v0.1.14 // indirect
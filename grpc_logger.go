@@ -0,0 +1,94 @@
+package harelog
+
+// GRPCLogger adapts a Logger to gRPC's grpclog.LoggerV2 interface, so that gRPC's
+// internal logs are routed through harelog:
+//
+//	grpclog.SetLoggerV2(harelog.NewGRPCLogger(logger, 0))
+//
+// It implements the interface's methods without importing gRPC, so harelog does not
+// depend on it. Info, Warning and Error map to the corresponding harelog levels, and
+// Fatal logs at Critical and then exits, as gRPC requires.
+type GRPCLogger struct {
+	l         *Logger
+	verbosity int
+}
+
+// NewGRPCLogger returns a GRPCLogger writing to l.
+// V(level) reports true for levels up to verbosity, like gRPC's
+// GRPC_GO_LOG_VERBOSITY_LEVEL environment variable.
+func NewGRPCLogger(l *Logger, verbosity int) *GRPCLogger {
+	if l == nil {
+		panic("harelog: nil logger provided to NewGRPCLogger")
+	}
+
+	return &GRPCLogger{l: l, verbosity: verbosity}
+}
+
+// Info logs to the Info level.
+func (g *GRPCLogger) Info(args ...interface{}) {
+	g.l.Print(args...)
+}
+
+// Infoln logs to the Info level.
+func (g *GRPCLogger) Infoln(args ...interface{}) {
+	g.l.Infoln(args...)
+}
+
+// Infof logs to the Info level.
+func (g *GRPCLogger) Infof(format string, args ...interface{}) {
+	g.l.Infof(format, args...)
+}
+
+// Warning logs to the Warn level.
+func (g *GRPCLogger) Warning(args ...interface{}) {
+	if g.l.IsWarnEnabled() {
+		g.l.Warnw(sprintMessage(args...))
+	}
+}
+
+// Warningln logs to the Warn level.
+func (g *GRPCLogger) Warningln(args ...interface{}) {
+	g.l.Warnln(args...)
+}
+
+// Warningf logs to the Warn level.
+func (g *GRPCLogger) Warningf(format string, args ...interface{}) {
+	g.l.Warnf(format, args...)
+}
+
+// Error logs to the Error level.
+func (g *GRPCLogger) Error(args ...interface{}) {
+	if g.l.IsErrorEnabled() {
+		g.l.Errorw(sprintMessage(args...))
+	}
+}
+
+// Errorln logs to the Error level.
+func (g *GRPCLogger) Errorln(args ...interface{}) {
+	g.l.Errorln(args...)
+}
+
+// Errorf logs to the Error level.
+func (g *GRPCLogger) Errorf(format string, args ...interface{}) {
+	g.l.Errorf(format, args...)
+}
+
+// Fatal logs to the Critical level and then calls os.Exit(1).
+func (g *GRPCLogger) Fatal(args ...interface{}) {
+	g.l.Fatal(args...)
+}
+
+// Fatalln logs to the Critical level and then calls os.Exit(1).
+func (g *GRPCLogger) Fatalln(args ...interface{}) {
+	g.l.Fatalln(args...)
+}
+
+// Fatalf logs to the Critical level and then calls os.Exit(1).
+func (g *GRPCLogger) Fatalf(format string, args ...interface{}) {
+	g.l.Fatalf(format, args...)
+}
+
+// V reports whether verbosity level level is enabled.
+func (g *GRPCLogger) V(level int) bool {
+	return level <= g.verbosity
+}
@@ -0,0 +1,116 @@
+package harelog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultGzipFlushInterval is how often a gzip writer flushes buffered output.
+const defaultGzipFlushInterval = time.Second
+
+// gzipWriter is the io.WriteCloser returned by NewGzipWriter.
+type gzipWriter struct {
+	mu     sync.Mutex
+	gz     *gzip.Writer
+	w      io.WriteCloser
+	dirty  bool // data written since the last flush
+	closed bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewGzipWriter returns a writer that gzip-compresses everything written to it
+// at the given compression level (e.g. gzip.DefaultCompression) and writes the
+// compressed stream to w. It is intended as a logger output, set with WithOutput;
+// Logger.Close then closes it.
+//
+// Compressed data is flushed to w every second, so that a reader of the stream
+// sees recent entries without waiting for Close. Close flushes the remaining data,
+// writes the gzip footer and closes w.
+//
+// It panics if w is nil or level is not a valid gzip compression level.
+func NewGzipWriter(w io.WriteCloser, level int) io.WriteCloser {
+	return newGzipWriter(w, level, defaultGzipFlushInterval)
+}
+
+// newGzipWriter is NewGzipWriter with a configurable flush interval.
+func newGzipWriter(w io.WriteCloser, level int, interval time.Duration) *gzipWriter {
+	if w == nil {
+		panic("harelog: nil writer provided to NewGzipWriter")
+	}
+
+	gz, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		panic("harelog: " + err.Error())
+	}
+
+	g := &gzipWriter{
+		gz:   gz,
+		w:    w,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go g.flushLoop(interval)
+
+	return g
+}
+
+// flushLoop periodically flushes written data until Close is called.
+func (g *gzipWriter) flushLoop(interval time.Duration) {
+	defer close(g.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.mu.Lock()
+			if g.dirty && !g.closed {
+				// A failed flush is reported by the next Write or Close.
+				_ = g.gz.Flush()
+				g.dirty = false
+			}
+			g.mu.Unlock()
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// Write implements io.Writer.
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return 0, errors.New("harelog: write to closed gzip writer")
+	}
+
+	g.dirty = true
+
+	return g.gz.Write(p)
+}
+
+// Close implements io.Closer. It flushes the compressed stream, writes the gzip
+// footer and closes the underlying writer. Calling Close more than once is a no-op.
+func (g *gzipWriter) Close() error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+
+		return nil
+	}
+	g.closed = true
+	g.mu.Unlock()
+
+	close(g.stop)
+	<-g.done
+
+	return errors.Join(g.gz.Close(), g.w.Close())
+}
@@ -0,0 +1,138 @@
+// Package harelogotel provides a harelog hook that exports entries to OpenTelemetry.
+//
+// It is a module of its own, so that harelog does not depend on OpenTelemetry.
+package harelogotel
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/taknb2nch/harelog"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewHook returns a Hook that emits each entry as a log.Record to logger, a Logger
+// of the OpenTelemetry Logs API, to feed the logs pipeline of the OpenTelemetry Collector:
+//
+//	logger := harelog.New(harelog.WithHooks(harelogotel.NewHook(provider.Logger("my-service"))))
+//
+// The entry is converted as by harelog.OTelRecordHook. The attributes are added in key
+// order, with nested maps and slices kept as map and slice values, and other values
+// written as by fmt.Sprint. The trace and span IDs are passed in the context of Emit,
+// as the OpenTelemetry SDK expects them.
+// The hook fires for all levels. It panics if logger is nil.
+func NewHook(logger otellog.Logger) harelog.Hook {
+	if logger == nil {
+		panic("harelogotel: nil logger provided to NewHook")
+	}
+
+	return harelog.OTelRecordHook(func(r harelog.OTelRecord) {
+		logger.Emit(otelContext(r), toOTelLogRecord(r))
+	})
+}
+
+// toOTelLogRecord converts an OTelRecord to a record of the OpenTelemetry Logs API.
+func toOTelLogRecord(r harelog.OTelRecord) otellog.Record {
+	var rec otellog.Record
+
+	rec.SetTimestamp(r.Timestamp)
+	rec.SetSeverity(otellog.Severity(r.SeverityNumber))
+	rec.SetSeverityText(r.SeverityText)
+	rec.SetBody(attribute.StringValue(r.Body))
+
+	keys := make([]string, 0, len(r.Attributes))
+	for k := range r.Attributes {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	attrs := make([]attribute.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, attribute.KeyValue{Key: attribute.Key(k), Value: otelValue(r.Attributes[k])})
+	}
+
+	rec.AddAttributes(attrs...)
+
+	return rec
+}
+
+// otelContext returns a context carrying the span context of the record's trace,
+// or context.Background if the record has no valid trace.
+func otelContext(r harelog.OTelRecord) context.Context {
+	ctx := context.Background()
+
+	traceID, err := trace.TraceIDFromHex(r.TraceID)
+	if err != nil {
+		return ctx
+	}
+
+	cfg := trace.SpanContextConfig{TraceID: traceID}
+
+	if spanID, err := trace.SpanIDFromHex(r.SpanID); err == nil {
+		cfg.SpanID = spanID
+	}
+
+	if r.TraceFlags == 1 {
+		cfg.TraceFlags = trace.FlagsSampled
+	}
+
+	return trace.ContextWithSpanContext(ctx, trace.NewSpanContext(cfg))
+}
+
+// otelValue converts an attribute value to a value of the OpenTelemetry Logs API.
+func otelValue(v interface{}) attribute.Value {
+	switch v := v.(type) {
+	case nil:
+		return attribute.Value{}
+	case string:
+		return attribute.StringValue(v)
+	case bool:
+		return attribute.BoolValue(v)
+	case int:
+		return attribute.IntValue(v)
+	case int64:
+		return attribute.Int64Value(v)
+	case int32:
+		return attribute.Int64Value(int64(v))
+	case uint32:
+		return attribute.Int64Value(int64(v))
+	case float64:
+		return attribute.Float64Value(v)
+	case float32:
+		return attribute.Float64Value(float64(v))
+	case []byte:
+		return attribute.ByteSliceValue(v)
+	case time.Time:
+		return attribute.StringValue(v.Format(time.RFC3339Nano))
+	case error:
+		return attribute.StringValue(v.Error())
+	case []interface{}:
+		values := make([]attribute.Value, len(v))
+		for i, e := range v {
+			values[i] = otelValue(e)
+		}
+
+		return attribute.SliceValue(values...)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		slices.Sort(keys)
+
+		kvs := make([]attribute.KeyValue, len(keys))
+		for i, k := range keys {
+			kvs[i] = attribute.KeyValue{Key: attribute.Key(k), Value: otelValue(v[k])}
+		}
+
+		return attribute.MapValue(kvs...)
+	default:
+		return attribute.StringValue(fmt.Sprint(v))
+	}
+}
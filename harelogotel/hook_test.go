@@ -0,0 +1,81 @@
+package harelogotel
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/taknb2nch/harelog"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/embedded"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeOTelLogger is an OpenTelemetry Logger that captures the emitted records.
+type fakeOTelLogger struct {
+	embedded.Logger
+
+	records  []otellog.Record
+	contexts []context.Context
+}
+
+func (l *fakeOTelLogger) Emit(ctx context.Context, record otellog.Record) {
+	l.records = append(l.records, record)
+	l.contexts = append(l.contexts, ctx)
+}
+
+func (l *fakeOTelLogger) Enabled(context.Context, otellog.EnabledParameters) bool {
+	return true
+}
+
+// TestNewHook verifies that the hook emits entries as records of the OpenTelemetry
+// Logs API.
+func TestNewHook(t *testing.T) {
+	t.Parallel()
+
+	otelLogger := &fakeOTelLogger{}
+
+	sampled := true
+	logger := harelog.New(
+		harelog.WithOutput(io.Discard),
+		harelog.WithLabels(map[string]string{"service": "api"}),
+		harelog.WithHooks(NewHook(otelLogger)),
+	).WithTrace("projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736").
+		WithSpanId("00f067aa0ba902b7").
+		WithTraceSampled(&sampled)
+
+	logger.Warnw("disk almost full", "usage", 0.93, "disk", map[string]interface{}{"name": "sda"})
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	if len(otelLogger.records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(otelLogger.records))
+	}
+
+	r := otelLogger.records[0]
+	if r.Severity() != otellog.SeverityWarn || r.SeverityText() != "WARN" || r.Body().AsString() != "disk almost full" {
+		t.Errorf("unexpected record: %v %q %v", r.Severity(), r.SeverityText(), r.Body())
+	}
+
+	attrs := make(map[string]attribute.Value)
+	r.WalkAttributes(func(kv attribute.KeyValue) bool {
+		attrs[string(kv.Key)] = kv.Value
+
+		return true
+	})
+
+	if attrs["usage"].AsFloat64() != 0.93 || attrs["label.service"].AsString() != "api" {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	if disk := attrs["disk"]; disk.Type() != attribute.MAP || disk.AsMap()[0].Value.AsString() != "sda" {
+		t.Errorf("expected the nested map to be kept, got %v", disk)
+	}
+
+	sc := trace.SpanContextFromContext(otelLogger.contexts[0])
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID().String() != "00f067aa0ba902b7" || !sc.IsSampled() {
+		t.Errorf("unexpected span context: %v", sc)
+	}
+}
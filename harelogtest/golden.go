@@ -0,0 +1,94 @@
+// Package harelogtest provides helpers for testing harelog formatters.
+package harelogtest
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taknb2nch/harelog"
+)
+
+// PinnedTime is the timestamp Golden sets on the entries it formats, so that the
+// output does not depend on when the test runs.
+var PinnedTime = time.Date(2025, 10, 14, 13, 30, 0, 0, time.UTC)
+
+// update is the -update flag, which makes Golden rewrite the golden files.
+var update = flag.Bool("update", false, "rewrite the golden files of harelogtest.Golden")
+
+// Golden formats entry with f and compares the output with the golden file
+// testdata/<test name>.golden of the package under test, reporting a mismatch with
+// t.Errorf. The entry's Time is set to PinnedTime first, and its labels and payload
+// are copied, so that entry is not modified by masking.
+//
+// Run the tests with -update to write the golden files from the current output,
+// e.g. "go test ./... -run TestMyFormatter -update", and review the changes before
+// committing them. A package using Golden must not define an -update flag itself.
+func Golden(t testing.TB, f harelog.Formatter, entry *harelog.LogEntry) {
+	t.Helper()
+
+	e := *entry
+	e.Time = PinnedTime
+
+	if entry.Labels != nil {
+		e.Labels = make(map[string]string, len(entry.Labels))
+		for k, v := range entry.Labels {
+			e.Labels[k] = v
+		}
+	}
+
+	if entry.Payload != nil {
+		e.Payload = make(map[string]interface{}, len(entry.Payload))
+		for k, v := range entry.Payload {
+			e.Payload[k] = v
+		}
+	}
+
+	got, err := f.Format(&e)
+	if err != nil {
+		t.Fatalf("harelogtest: Format returned an error: %v", err)
+	}
+
+	path := goldenPath(t)
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("harelogtest: failed to create the golden file directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("harelogtest: failed to write the golden file: %v", err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("harelogtest: failed to read the golden file (run with -update to create it): %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("harelogtest: output does not match %s (run with -update to accept it):\ngot:  %s\nwant: %s", path, got, want)
+	}
+}
+
+// goldenPath returns the path of the golden file of the test, whose name is made
+// safe for the file system, e.g. "testdata/TestJSON_with_labels.golden" for the
+// subtest "with labels" of TestJSON.
+func goldenPath(t testing.TB) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ' ', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+
+		return r
+	}, t.Name())
+
+	return filepath.Join("testdata", name+".golden")
+}
@@ -0,0 +1,35 @@
+package harelogtest
+
+import (
+	"testing"
+
+	"github.com/taknb2nch/harelog"
+)
+
+// TestGolden verifies the built-in formatters against their golden files.
+func TestGolden(t *testing.T) {
+	entry := &harelog.LogEntry{
+		Message:  "user logged in",
+		Severity: harelog.LogLevelInfo,
+		Trace:    "projects/p/traces/t1",
+		Labels:   map[string]string{"env": "dev"},
+		Payload:  map[string]interface{}{"userID": "123", "password": "secret"},
+	}
+
+	formatters := map[string]harelog.Formatter{
+		"json":   harelog.JSON.NewFormatter(harelog.JSON.WithMaskingKeys("password")),
+		"text":   harelog.Text.NewFormatter(harelog.Text.WithMaskingKeys("password")),
+		"logfmt": harelog.Logfmt.NewFormatter(harelog.Logfmt.WithMaskingKeys("password")),
+		"hybrid": harelog.Hybrid.NewFormatter(harelog.Hybrid.WithMaskingKeys("password")),
+	}
+
+	for name, f := range formatters {
+		t.Run(name, func(t *testing.T) {
+			Golden(t, f, entry)
+		})
+	}
+
+	if entry.Payload["password"] != "secret" || !entry.Time.IsZero() {
+		t.Errorf("expected the entry to be left unmodified, got %+v", entry)
+	}
+}
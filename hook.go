@@ -1,5 +1,10 @@
 package harelog
 
+import (
+	"fmt"
+	"sync"
+)
+
 // Hook is an interface that allows you to process log entries.
 // Hooks can be used to send logs to external services like Sentry or Slack.
 //
@@ -16,3 +21,117 @@ type Hook interface {
 	// to it will not affect other hooks or the main log output.
 	Fire(entry *LogEntry) error
 }
+
+// StatefulHook is a Hook that manages resources, such as an HTTP client or a batch
+// buffer, over the lifetime of the logger that owns it.
+//
+// The owner is the logger created by New (or NewFromDefault, SetDefaultHooks and the
+// like) with the hook. The loggers derived from it with Clone, With and the other
+// With... methods share its hooks, so they do not call Init again, and closing any of
+// them shuts the hooks down. A hook passed to several loggers is initialized and shut
+// down by each of them, so it should then keep its state per logger.
+type StatefulHook interface {
+	Hook
+
+	// Init is called once by New with the owning logger, before the first Fire.
+	Init(logger *Logger)
+
+	// Shutdown is called once when the owning logger is closed, after the last Fire.
+	// Its error is returned by Close.
+	Shutdown() error
+}
+
+// hookWorker owns the channel and the background goroutine that deliver entries to hooks.
+// It is shared by a logger and all loggers derived from it.
+type hookWorker struct {
+	ch chan *LogEntry
+
+	shutdownOnce sync.Once // guards the Shutdown calls of the StatefulHooks
+
+	// mu guards closed and protects ch from being sent to after it is closed.
+	mu     sync.RWMutex
+	closed bool
+
+	done      chan struct{} // closed when the worker goroutine exits
+	abort     chan struct{} // closed to skip the remaining entries
+	abortOnce sync.Once
+}
+
+// newHookWorker creates a hookWorker with the given buffer size.
+func newHookWorker(size int) *hookWorker {
+	return &hookWorker{
+		ch:    make(chan *LogEntry, size),
+		done:  make(chan struct{}),
+		abort: make(chan struct{}),
+	}
+}
+
+// send enqueues an entry without blocking.
+// It returns false if the worker is closed or the buffer is full.
+func (w *hookWorker) send(entry *LogEntry) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return false
+	}
+
+	select {
+	case w.ch <- entry:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops accepting new entries. It is safe to call multiple times.
+func (w *hookWorker) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	w.closed = true
+
+	close(w.ch)
+}
+
+// run processes entries with fire until the channel is closed and drained.
+// After abort is closed, the remaining entries are discarded.
+func (w *hookWorker) run(fire func(*LogEntry)) {
+	defer close(w.done)
+
+	for entry := range w.ch {
+		select {
+		case <-w.abort:
+			continue
+		default:
+		}
+
+		if entry != nil {
+			fire(entry)
+		}
+	}
+}
+
+// UnprocessedHookEntriesError is returned by CloseContext when the context is done
+// before all buffered hook entries could be processed.
+type UnprocessedHookEntriesError struct {
+	// Count is the number of buffered entries that were discarded.
+	Count int
+
+	// Err is the context's error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *UnprocessedHookEntriesError) Error() string {
+	return fmt.Sprintf("harelog: %d hook entries were not processed: %v", e.Count, e.Err)
+}
+
+// Unwrap returns the context's error.
+func (e *UnprocessedHookEntriesError) Unwrap() error {
+	return e.Err
+}
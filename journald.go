@@ -0,0 +1,30 @@
+package harelog
+
+// journaldPriorities maps each LogLevel to the prefix of its syslog priority,
+// as read by systemd-journald (see WithJournaldPriorityPrefix):
+//
+//	CRITICAL -> <2> (crit)
+//	ERROR    -> <3> (err)
+//	WARN     -> <4> (warning)
+//	INFO     -> <6> (info)
+//	DEBUG    -> <7> (debug)
+//	TRACE    -> <7> (debug)
+var journaldPriorities = map[LogLevel][]byte{
+	LogLevelCritical: []byte("<2>"),
+	LogLevelError:    []byte("<3>"),
+	LogLevelWarn:     []byte("<4>"),
+	LogLevelInfo:     []byte("<6>"),
+	LogLevelDebug:    []byte("<7>"),
+	LogLevelTrace:    []byte("<7>"),
+}
+
+// journaldPriority returns the syslog priority prefix for level.
+// Levels without a priority, such as those of entries printed outside of a log call,
+// use the info priority.
+func journaldPriority(level LogLevel) []byte {
+	if p, ok := journaldPriorities[level]; ok {
+		return p
+	}
+
+	return journaldPriorities[LogLevelInfo]
+}
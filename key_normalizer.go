@@ -0,0 +1,128 @@
+package harelog
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// SnakeCase converts a field key to snake_case, e.g. "userID" and "user-id" to
+// "user_id" and "HTTPStatus" to "http_status". Dots separating the segments of
+// nested keys, as in "panic.value", are kept. It is a preset for WithKeyNormalizer.
+func SnakeCase(key string) string {
+	if !strings.ContainsFunc(key, func(r rune) bool { return unicode.IsUpper(r) || r == '-' }) {
+		return key
+	}
+
+	runes := []rune(key)
+
+	var b strings.Builder
+
+	b.Grow(len(key) + 4)
+
+	for i, r := range runes {
+		if r == '-' {
+			r = '_'
+		}
+
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			// A word starts at an uppercase letter following a lowercase letter or digit,
+			// or at the last letter of an acronym followed by a lowercase letter.
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				b.WriteByte('_')
+			}
+		}
+
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// CamelCase converts a field key to camelCase, e.g. "user_id" and "user-id" to
+// "userId" and "HTTPStatus" to "httpStatus". Dots separating the segments of
+// nested keys, as in "panic.value", are kept. It is a preset for WithKeyNormalizer.
+func CamelCase(key string) string {
+	if !strings.ContainsFunc(key, func(r rune) bool { return unicode.IsUpper(r) || r == '_' || r == '-' }) {
+		return key
+	}
+
+	runes := []rune(key)
+
+	var b strings.Builder
+
+	b.Grow(len(key))
+
+	segmentStart, upperNext := true, false
+
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-':
+			upperNext = b.Len() > 0 && !strings.HasSuffix(b.String(), ".")
+			segmentStart = false
+		case r == '.':
+			b.WriteRune(r)
+
+			segmentStart, upperNext = true, false
+		case segmentStart:
+			// The leading uppercase letters of a segment are lowered, except the last one
+			// of an acronym followed by a lowercase letter, as in "HTTPStatus".
+			if unicode.IsUpper(r) && i > 0 && unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+				b.WriteRune(r)
+
+				segmentStart = false
+
+				continue
+			}
+
+			b.WriteRune(unicode.ToLower(r))
+
+			segmentStart = unicode.IsUpper(r)
+		case upperNext:
+			b.WriteRune(unicode.ToUpper(r))
+
+			upperNext = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// normalizeKeys renames the keys of m with normalize. If several keys normalize to
+// the same name, the key already in the normalized form is kept, or else the key
+// that sorts first; the values of the others are dropped.
+func normalizeKeys[V any](m map[string]V, normalize func(string) string) {
+	var renamed []string
+
+	for k := range m {
+		if normalize(k) != k {
+			renamed = append(renamed, k)
+		}
+	}
+
+	if len(renamed) == 0 {
+		return
+	}
+
+	slices.Sort(renamed)
+
+	values := make([]V, len(renamed))
+
+	for i, k := range renamed {
+		values[i] = m[k]
+		delete(m, k)
+	}
+
+	for i, k := range renamed {
+		if nk := normalize(k); nk != "" {
+			if _, ok := m[nk]; !ok {
+				m[nk] = values[i]
+			}
+		}
+	}
+}
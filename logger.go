@@ -4,6 +4,7 @@
 package harelog
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -14,6 +15,8 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -30,6 +33,7 @@ const (
 	LogLevelWarn     LogLevel = "WARN"
 	LogLevelInfo     LogLevel = "INFO"
 	LogLevelDebug    LogLevel = "DEBUG"
+	LogLevelTrace    LogLevel = "TRACE"
 	LogLevelAll      LogLevel = "ALL"
 )
 
@@ -42,6 +46,7 @@ const (
 	logLevelValueWarn
 	logLevelValueInfo
 	logLevelValueDebug
+	logLevelValueTrace // more verbose than Debug; added after it to keep the existing values
 )
 
 const (
@@ -66,6 +71,35 @@ const (
 	SourceLocationModeErrorOrAbove
 )
 
+// SourceFunctionMode defines how the function name of a captured source location is reported.
+type SourceFunctionMode int
+
+const (
+	// SourceFunctionModeFull reports the fully qualified function name, such as
+	// "github.com/you/app/pkg.(*Server).Handle". This is the default behavior.
+	SourceFunctionModeFull SourceFunctionMode = iota
+
+	// SourceFunctionModeShort drops the import path of the package, such as
+	// "pkg.(*Server).Handle" or "pkg.Handle", for readability in the Cloud Logging UI.
+	SourceFunctionModeShort
+)
+
+// LabelValidationMode defines how invalid label keys are handled.
+// A label key is invalid if it is empty or contains a space, '=', or '"'.
+type LabelValidationMode int
+
+const (
+	// LabelValidationStrict prints a warning to os.Stderr and ignores the invalid label.
+	// This is the default behavior.
+	LabelValidationStrict LabelValidationMode = iota
+
+	// LabelValidationWarn prints a warning to os.Stderr but keeps the invalid label.
+	LabelValidationWarn
+
+	// LabelValidationOff accepts any label key without a warning.
+	LabelValidationOff
+)
+
 var (
 	std      = New()
 	stdMutex = &sync.RWMutex{}
@@ -73,6 +107,10 @@ var (
 	// harelogPackage is the import path of this package, determined at runtime.
 	harelogPackage string
 
+	// sourceLocationWarnOnce ensures the warning about an undetermined package path
+	// is printed only once.
+	sourceLocationWarnOnce sync.Once
+
 	osExit = os.Exit
 )
 
@@ -83,6 +121,7 @@ var levelMap = map[LogLevel]logLevelValue{
 	LogLevelWarn:     logLevelValueWarn,
 	LogLevelInfo:     logLevelValueInfo,
 	LogLevelDebug:    logLevelValueDebug,
+	LogLevelTrace:    logLevelValueTrace,
 	LogLevelAll:      logLevelValueAll,
 }
 
@@ -99,14 +138,12 @@ func init() {
 	// Determine the package path of this library at startup.
 	harelogPackage = reflect.TypeOf(Logger{}).PkgPath()
 
-	// Fail Fast: If the package path could not be determined, it's a catastrophic
-	// failure. The findCaller function would not work correctly, so we should
-	// panic immediately to alert the developer.
-	if harelogPackage == "" {
-		panic("harelog: could not determine package path for source location feature")
-	}
+	// If the package path could not be determined (e.g. in some stripped or
+	// obfuscated builds), the source location feature is disabled instead of
+	// failing at import time. findCaller emits a one-time warning in that case.
 
 	setupLogLevelFromEnv()
+	setupFormatterFromEnv()
 }
 
 // setupLogLevelFromEnv reads the HARELOG_LEVEL environment variable and
@@ -159,6 +196,11 @@ type SourceLocation struct {
 	Function string `json:"function,omitempty"`
 }
 
+// caller returns the location as "file:line".
+func (s *SourceLocation) caller() string {
+	return s.File + ":" + strconv.Itoa(s.Line)
+}
+
 // --- Log Entry Structure ---
 
 // LogEntry is the internal data container for a single log entry.
@@ -178,8 +220,73 @@ type LogEntry struct {
 
 	// Any fields you want to output as `jsonPayload` are stored in this map.
 	Payload map[string]interface{} `json:"-"`
+
+	// sourceOverride forces or suppresses source capture for this entry (see the "_source" key).
+	sourceOverride sourceOverride
+
+	// maxFields limits the number of payload fields added via setField (see WithMaxFields).
+	maxFields int
+
+	// misuseErrorKey and missingValuePlaceholder mark an odd number of key-value
+	// arguments (see WithMisuseErrorKey and WithMissingValuePlaceholder).
+	misuseErrorKey          string
+	missingValuePlaceholder string
+
+	// err is the error logged under the "error" key, which the payload holds only as
+	// its message (see WithErrorStackExtraction).
+	err error
+
+	// fields holds the typed fields that are not boxed into the payload (see Field).
+	fields []Field
+
+	// relayedTo lists the loggers that have written the entry and sent it to their
+	// hooks, so that a LoggerHook does not re-dispatch it through one of them again.
+	relayedTo []*Logger
+
+	// nanoTimestamps and timeEncoder are set by the printing logger
+	// (see WithNanoTimestamps and WithTimeEncoder).
+	nanoTimestamps bool
+	timeEncoder    TimeEncoder
+}
+
+// timeLayout returns the layout of the entry's RFC 3339 timestamp.
+func (e *LogEntry) timeLayout() string {
+	if e.nanoTimestamps {
+		return time.RFC3339Nano
+	}
+
+	return time.RFC3339
+}
+
+// appendTime appends the entry's timestamp to dst, with the logger's TimeEncoder
+// if set, or else with enc.
+func (e *LogEntry) appendTime(dst []byte, enc TimeEncoding) []byte {
+	if e.timeEncoder != nil {
+		// The encoder does not get dst, which would make the formatters' stack
+		// scratch buffers escape to the heap even when no encoder is set.
+		return append(dst, e.timeEncoder(nil, e.Time)...)
+	}
+
+	return appendTime(dst, e.Time, enc, e.timeLayout())
 }
 
+// sourceOverride is a per-entry override of the logger's source location mode.
+type sourceOverride int8
+
+const (
+	sourceOverrideNone sourceOverride = iota
+	sourceOverrideForce
+	sourceOverrideSuppress
+)
+
+// sourceOverrideKey is the special key used to force (true) or suppress (false)
+// source capture for a single log call.
+const sourceOverrideKey = "_source"
+
+// timeOverrideKey is the special key used to set the timestamp of a single log call,
+// e.g. when replaying historical events, instead of the current time.
+const timeOverrideKey = "_time"
+
 func (e *LogEntry) Clear() {
 	e.Message = ""
 	e.Severity = ""
@@ -190,6 +297,17 @@ func (e *LogEntry) Clear() {
 	e.SourceLocation = nil
 	e.Time = time.Time{}
 	e.CorrelationID = ""
+	e.sourceOverride = sourceOverrideNone
+	e.maxFields = 0
+	e.misuseErrorKey = ""
+	e.missingValuePlaceholder = ""
+	e.err = nil
+	e.relayedTo = nil
+
+	clear(e.fields)
+	e.fields = e.fields[:0]
+	e.nanoTimestamps = false
+	e.timeEncoder = nil
 
 	if e.Labels != nil {
 		clearOrResetMap(&e.Labels, 16)
@@ -200,21 +318,83 @@ func (e *LogEntry) Clear() {
 	}
 }
 
+// ToMap returns the entry as a flat map using the same field names as the JSON formatter
+// (e.g. "message", "severity", "timestamp", "logging.googleapis.com/trace").
+// Empty special fields are omitted, labels are nested under "labels", and payload
+// fields are placed at the top level. The result can be marshaled directly by hooks
+// that forward entries to JSON-based services.
+func (e *LogEntry) ToMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(e.Payload)+10)
+
+	// Payload first, so that the special fields take precedence on key collisions.
+	for k, v := range e.Payload {
+		m[k] = v
+	}
+
+	m["message"] = e.Message
+
+	if e.Severity != "" {
+		m["severity"] = e.Severity
+	}
+
+	if e.Trace != "" {
+		m["logging.googleapis.com/trace"] = e.Trace
+	}
+
+	if e.SpanID != "" {
+		m["logging.googleapis.com/spanId"] = e.SpanID
+	}
+
+	if e.TraceSampled != nil {
+		m["logging.googleapis.com/trace_sampled"] = *e.TraceSampled
+	}
+
+	if e.HTTPRequest != nil {
+		m["httpRequest"] = e.HTTPRequest
+	}
+
+	if e.SourceLocation != nil {
+		m["logging.googleapis.com/sourceLocation"] = e.SourceLocation
+	}
+
+	if !e.Time.IsZero() {
+		m["timestamp"] = e.Time
+	}
+
+	if len(e.Labels) > 0 {
+		m["labels"] = maps.Clone(e.Labels)
+	}
+
+	if e.CorrelationID != "" {
+		m["correlationId"] = e.CorrelationID
+	}
+
+	return m
+}
+
 // applyKVs applies key-value pairs to a log entry, handling special keys.
+// A MetricValue may appear on its own in place of a key-value pair.
 func (e *LogEntry) applyKVs(kvs ...interface{}) {
-	n := len(kvs)
-	if n%2 != 0 {
-		// confirm whether last key is string or not
-		if key, ok := kvs[n-1].(string); ok {
-			e.Payload[key] = "KEY_WITHOUT_VALUE"
+	for i := 0; i < len(kvs); i += 2 {
+		if m, ok := kvs[i].(MetricValue); ok {
+			e.setField(m.Name, m)
+
+			i-- // a metric occupies a single argument
+
+			continue
 		}
 
-		e.Payload["logging_error"] = "odd number of arguments received"
+		if i == len(kvs)-1 {
+			// confirm whether last key is string or not
+			if key, ok := kvs[i].(string); ok {
+				e.Payload[key] = e.missingValuePlaceholder
+			}
 
-		n--
-	}
+			e.Payload[e.misuseErrorKey] = "odd number of arguments received"
+
+			break
+		}
 
-	for i := 0; i < n; i += 2 {
 		key, ok := kvs[i].(string)
 		if !ok {
 			// For simplicity in this helper, we skip non-string keys.
@@ -222,29 +402,86 @@ func (e *LogEntry) applyKVs(kvs ...interface{}) {
 			continue
 		}
 
-		switch key {
-		case "error":
-			if err, ok := kvs[i+1].(error); ok {
-				e.Payload[key] = err.Error()
+		e.applyKV(key, kvs[i+1])
+	}
+}
+
+// applyKV applies a single key-value pair to a log entry, handling special keys.
+func (e *LogEntry) applyKV(key string, value interface{}) {
+	switch key {
+	case "error":
+		// The error is always kept, regardless of WithMaxFields.
+		if err, ok := value.(error); ok {
+			e.Payload[key] = err.Error()
+			e.err = err
+		} else {
+			e.Payload[key] = value
+			e.err = nil
+		}
+	case "httpRequest":
+		if req, ok := value.(*HTTPRequest); ok {
+			e.HTTPRequest = req
+		} else {
+			e.setField(key, value)
+		}
+	case "sourceLocation":
+		if sl, ok := value.(*SourceLocation); ok {
+			e.SourceLocation = sl
+		} else {
+			e.setField(key, value)
+		}
+	case timeOverrideKey:
+		if t, ok := value.(time.Time); ok {
+			e.Time = t
+		} else {
+			e.setField(key, value)
+		}
+	case sourceOverrideKey:
+		if force, ok := value.(bool); ok {
+			if force {
+				e.sourceOverride = sourceOverrideForce
 			} else {
-				e.Payload[key] = kvs[i+1]
+				e.sourceOverride = sourceOverrideSuppress
 			}
-		case "httpRequest":
-			if req, ok := kvs[i+1].(*HTTPRequest); ok {
-				e.HTTPRequest = req
-			} else {
-				e.Payload[key] = kvs[i+1]
+		} else {
+			e.setField(key, value)
+		}
+	default:
+		e.setField(key, value)
+	}
+}
+
+const (
+	// defaultMisuseErrorKey is the default payload key recording misuse of the logger.
+	defaultMisuseErrorKey = "logging_error"
+
+	// defaultMissingValuePlaceholder is the default value of a trailing key without a value.
+	defaultMissingValuePlaceholder = "KEY_WITHOUT_VALUE"
+)
+
+// fieldsTruncatedKey is the payload key marking an entry whose fields were capped by WithMaxFields.
+const fieldsTruncatedKey = "fields_truncated"
+
+// setField adds a payload field, unless the entry already holds maxFields fields
+// and key is new, in which case the field is dropped and the entry is marked with
+// fields_truncated=true. Existing fields are always updated.
+func (e *LogEntry) setField(key string, value interface{}) {
+	if e.maxFields > 0 {
+		if _, ok := e.Payload[key]; !ok {
+			n := len(e.Payload)
+			if _, ok := e.Payload[fieldsTruncatedKey]; ok {
+				n--
 			}
-		case "sourceLocation":
-			if sl, ok := kvs[i+1].(*SourceLocation); ok {
-				e.SourceLocation = sl
-			} else {
-				e.Payload[key] = kvs[i+1]
+
+			if n >= e.maxFields {
+				e.Payload[fieldsTruncatedKey] = true
+
+				return
 			}
-		default:
-			e.Payload[key] = kvs[i+1]
 		}
 	}
+
+	e.Payload[key] = value
 }
 
 // --- Logger ---
@@ -252,37 +489,100 @@ func (e *LogEntry) applyKVs(kvs ...interface{}) {
 // Logger is a structured logger that provides leveled logging.
 // Instances of Logger are safe for concurrent use.
 type Logger struct {
-	out                io.Writer
-	trace              string
-	spanId             string
-	traceSampled       *bool
-	labels             map[string]string
-	logLevel           atomic.Uint32
-	prefix             string
-	correlationID      string
-	projectID          string
-	sourceLocationMode sourceLocationMode
+	loggerConfig
+
+	logLevel atomic.Uint32
+	outMutex sync.Mutex
+}
+
+// loggerConfig holds the configuration of a Logger.
+// It is kept separate from the Logger's synchronization state so that Clone can
+// copy it as a whole, without enumerating every field.
+type loggerConfig struct {
+	out                   io.Writer
+	closers               []io.Closer
+	closeOnce             *sync.Once // shared with clones that keep the closers, so that they are closed once
+	exitOnFatal           bool
+	trace                 string
+	spanId                string
+	traceSampled          *bool
+	labels                map[string]string
+	prefix                string
+	correlationID         string
+	projectID             string
+	sourceLocationMode    sourceLocationMode
+	sourceFunctionMode    SourceFunctionMode
+	sourceSampling        int
+	sourceSampleCount     *atomic.Uint64 // shared with clones, for WithSourceSampling
+	onceFields            map[string]interface{}
+	onceFieldsDone        *atomic.Bool // shared with clones, for WithOnceFields
+	callerSkip            int
+	packageLevelOverrides bool
+	sourceSnippet         bool
+	errorStackExtraction  bool
+	strictFormat          bool
+	validateOutput        bool
+	syncOnWrite           bool
+	nanoTimestamps        bool
+	timeEncoder           TimeEncoder
+	labelValidation       LabelValidationMode
+
+	stackTraceLevel  logLevelValue
+	stackTraceFilter StackTraceFilter
+	minifyStackTrace bool
 
 	payload map[string]interface{}
 
-	traceContextKey interface{}
+	traceContextKey   interface{}
+	traceParser       TraceParser
+	baseCtx           context.Context
+	contextExtractors []ContextExtractor
+	dynamicFields     []func() []interface{}
+	errorClassifier   ErrorClassifier
+	redactor          func(*LogEntry) *LogEntry
+	keyNormalizer     func(string) string
+
+	formatErrorHandler   func(err error, entry *LogEntry)
+	internalErrorHandler func(entry *LogEntry)
+
+	maxEntrySize        int
+	truncationStrategy  TruncationStrategy
+	maxFields           int
+	maxFieldValueLength int
+	sqlArgMode          SQLArgMode
+
+	misuseErrorKey          string
+	missingValuePlaceholder string
 
 	formatter Formatter
 
+	recordPrefix    []byte
+	recordSeparator []byte
+
+	journaldPriorityPrefix bool
+
+	dedup    *deduper
+	dedupKey DedupKey
+
+	writerShards     int
+	concurrentWriter *concurrentWriter
+
+	writeBufferSize     int
+	writeBufferInterval time.Duration
+	writeBuffer         *writeBuffer
+
 	// for hooks
 	hookBufferSize int
+	hookMinLevel   logLevelValue
 	hooks          []Hook
 	hooksByLevel   map[LogLevel][]Hook
-	hookChan       chan *LogEntry
-	hookWg         sync.WaitGroup
-
-	outMutex sync.Mutex
+	hookWorker     *hookWorker
 }
 
 // New creates a new Logger with default settings.
 // The default log level is LevelInfo and the default output is os.Stderr.
 func New(opts ...Option) *Logger {
-	logger := &Logger{
+	logger := &Logger{loggerConfig: loggerConfig{
 		out:                os.Stderr,
 		trace:              "",
 		spanId:             "",
@@ -295,8 +595,15 @@ func New(opts ...Option) *Logger {
 		traceContextKey:    nil,
 		sourceLocationMode: SourceLocationModeNever,
 		formatter:          JSON.NewFormatter(),
+		recordSeparator:    []byte{'\n'},
 		hookBufferSize:     100,
-	}
+		hookMinLevel:       logLevelValueAll,
+		closeOnce:          new(sync.Once),
+		exitOnFatal:        true,
+
+		misuseErrorKey:          defaultMisuseErrorKey,
+		missingValuePlaceholder: defaultMissingValuePlaceholder,
+	}}
 
 	logger.logLevel.Store(uint32(logLevelValueInfo))
 
@@ -304,10 +611,26 @@ func New(opts ...Option) *Logger {
 		opt(logger)
 	}
 
-	if len(logger.hooks) > 0 {
-		logger.hooksByLevel = make(map[LogLevel][]Hook)
+	logger.startHooks()
+
+	if logger.writerShards > 0 {
+		logger.concurrentWriter = newConcurrentWriter(logger.writerShards)
+	}
+
+	if logger.writeBufferSize > 0 {
+		logger.writeBuffer = newWriteBuffer(logger.writeBufferSize, logger.writeBufferInterval)
+	}
+
+	return logger
+}
+
+// startHooks indexes the hooks of the logger by level and starts a hook worker for
+// them, if there are any.
+func (l *Logger) startHooks() {
+	if len(l.hooks) > 0 {
+		l.hooksByLevel = make(map[LogLevel][]Hook)
 
-		for _, hook := range logger.hooks {
+		for _, hook := range l.hooks {
 			levels := hook.Levels()
 
 			if len(levels) == 0 {
@@ -317,7 +640,7 @@ func New(opts ...Option) *Logger {
 						continue
 					}
 
-					logger.hooksByLevel[level] = append(logger.hooksByLevel[level], hook)
+					l.hooksByLevel[level] = append(l.hooksByLevel[level], hook)
 				}
 			} else {
 				for _, level := range levels {
@@ -325,45 +648,150 @@ func New(opts ...Option) *Logger {
 						continue
 					}
 
-					logger.hooksByLevel[level] = append(logger.hooksByLevel[level], hook)
+					l.hooksByLevel[level] = append(l.hooksByLevel[level], hook)
 				}
 			}
 		}
 
-		logger.hookChan = make(chan *LogEntry, logger.hookBufferSize)
-		logger.hookWg.Add(1)
+		l.hookWorker = newHookWorker(l.hookBufferSize)
 
-		go logger.runHookWorker()
-	}
+		l.initHooks()
 
-	return logger
+		go l.hookWorker.run(l.fireHooks)
+	}
 }
 
 // Close gracefully shuts down the logger's background processes, such as the hook worker.
 // It ensures that all buffered log entries for hooks are processed before returning.
 // It's recommended to call this via defer when the application is shutting down.
+// Writers set via WithOutputs that implement io.Closer are closed as well.
 func (l *Logger) Close() error {
-	// If the hook worker is running, close the channel and wait for it to finish.
-	if l.hookChan != nil {
-		close(l.hookChan)
+	return l.CloseContext(context.Background())
+}
+
+// CloseContext is like Close, but stops waiting for the hook worker when ctx is done.
+// New hook entries are no longer accepted once it is called. If ctx is done before
+// all buffered entries are processed, the remaining entries are discarded and an
+// *UnprocessedHookEntriesError reporting their number is returned.
+// This is useful in serverless environments where shutdown has a hard deadline.
+//
+// Close and CloseContext are idempotent: only the first call, on the logger or on
+// any logger derived from it, shuts it down; later calls wait for it to finish and
+// return nil. A logger derived with WithOutput or WithOutputs owns the writers it
+// was given and is closed on its own, even after the logger it was derived from.
+func (l *Logger) CloseContext(ctx context.Context) error {
+	var err error
+
+	l.closeOnce.Do(func() {
+		err = l.close(ctx)
+	})
+
+	return err
+}
+
+// close implements CloseContext.
+func (l *Logger) close(ctx context.Context) error {
+	var errs []error
+
+	if w := l.hookWorker; w != nil {
+		w.close()
+
+		select {
+		case <-w.done:
+			if err := l.shutdownHooks(); err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			unprocessed := len(w.ch)
+
+			w.abortOnce.Do(func() {
+				close(w.abort)
+			})
+
+			errs = append(errs, &UnprocessedHookEntriesError{Count: unprocessed, Err: ctx.Err()})
+
+			// The hooks may still be firing the current entry, so they are shut down
+			// when the worker exits.
+			go func() {
+				<-w.done
+				l.shutdownHooks()
+			}()
+		}
+	}
+
+	if l.dedup != nil {
+		l.dedup.flush()
+	}
+
+	if l.concurrentWriter != nil {
+		l.concurrentWriter.close()
+	}
+
+	if l.writeBuffer != nil {
+		l.writeBuffer.close()
+	}
+
+	for _, c := range l.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
 
-		l.hookWg.Wait()
+// HookQueueLen returns the number of entries buffered for the hooks and not yet processed.
+// It returns 0 if the logger has no hooks. Together with WithHookBufferSize, it helps
+// to monitor backpressure and size the buffer.
+func (l *Logger) HookQueueLen() int {
+	if l.hookWorker == nil {
+		return 0
 	}
 
-	return nil
+	return len(l.hookWorker.ch)
 }
 
-// runHookWorker is the background goroutine that processes log entries for hooks.
-func (l *Logger) runHookWorker() {
-	defer l.hookWg.Done()
+// closeHooks stops the hook worker, if running, after all buffered entries are processed,
+// and shuts the StatefulHooks down.
+func (l *Logger) closeHooks() {
+	// If the hook worker is running, close the channel and wait for it to finish.
+	if l.hookWorker != nil {
+		l.hookWorker.close()
+
+		<-l.hookWorker.done
+
+		// There is no caller to return the errors to, as the logger is replaced.
+		_ = l.shutdownHooks()
+	}
+}
 
-	for entry := range l.hookChan {
-		if entry != nil {
-			l.fireHooks(entry)
+// initHooks calls Init of the StatefulHooks of a logger whose hook worker is starting.
+func (l *Logger) initHooks() {
+	for _, hook := range l.hooks {
+		if s, ok := hook.(StatefulHook); ok {
+			s.Init(l)
 		}
 	}
 }
 
+// shutdownHooks calls Shutdown of the StatefulHooks once, after the hook worker has
+// exited, and returns their errors.
+func (l *Logger) shutdownHooks() error {
+	var errs []error
+
+	l.hookWorker.shutdownOnce.Do(func() {
+		for _, hook := range l.hooks {
+			if s, ok := hook.(StatefulHook); ok {
+				if err := s.Shutdown(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
 // fireHooks iterates over registered hooks and calls their Fire method if the level matches.
 func (l *Logger) fireHooks(entry *LogEntry) {
 	hooksForLevel, ok := l.hooksByLevel[LogLevel(entry.Severity)]
@@ -377,19 +805,19 @@ func (l *Logger) fireHooks(entry *LogEntry) {
 		func() {
 			defer func() {
 				if r := recover(); r != nil {
-					e := &LogEntry{
-						Severity: LogLevelError,
-						Time:     time.Now(),
-						Message:  "A hook panicked",
-						Payload:  map[string]any{"panic": r},
-					}
+					e := PanicEntry(r)
+					e.Message = "A hook panicked"
 
-					if e.SourceLocation == nil && (l.sourceLocationMode == SourceLocationModeAlways ||
+					if !(l.sourceLocationMode == SourceLocationModeAlways ||
 						(l.sourceLocationMode == SourceLocationModeErrorOrAbove && l.logLevel.Load() <= uint32(logLevelValueError))) {
-						e.SourceLocation = l.findCaller()
+						e.SourceLocation = nil
 					}
 
-					l.print(e)
+					if l.internalErrorHandler != nil {
+						l.internalErrorHandler(e)
+					} else {
+						l.print(e)
+					}
 				}
 			}()
 
@@ -405,24 +833,21 @@ func (l *Logger) defensiveCopy(entry *LogEntry) *LogEntry {
 	entryCopy.Labels = maps.Clone(entry.Labels)
 	entryCopy.Payload = maps.Clone(entry.Payload)
 
+	// The copy does not share the unboxed fields of the entry, which is reused.
+	entryCopy.fields = nil
+
+	for _, f := range entry.fields {
+		entryCopy.setField(f.key, f.Value())
+	}
+
 	return &entryCopy
 }
 
 // Clone creates a new copy of the logger.
+// All configuration is copied; the labels, payload and trace-sampled values are
+// copied deeply so that the clone can be modified independently.
 func (l *Logger) Clone() *Logger {
-	newLogger := &Logger{
-		out:                l.out,
-		trace:              l.trace,
-		spanId:             l.spanId,
-		prefix:             l.prefix,
-		correlationID:      l.correlationID,
-		projectID:          l.projectID,
-		traceContextKey:    l.traceContextKey,
-		sourceLocationMode: l.sourceLocationMode,
-		formatter:          l.formatter,
-		hooks:              l.hooks,
-		hookChan:           l.hookChan,
-	}
+	newLogger := &Logger{loggerConfig: l.loggerConfig}
 
 	newLogger.logLevel.Store(l.logLevel.Load())
 
@@ -439,306 +864,632 @@ func (l *Logger) Clone() *Logger {
 	return newLogger
 }
 
+// TracefCtx logs a formatted message at the Trace level.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) TracefCtx(ctx context.Context, format string, v ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelTrace) {
+		return
+	}
+
+	l.dispatchf(ctx, LogLevelTrace, format, v)
+}
+
 // DebugfCtx logs a formatted message at the Debug level.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
 func (l *Logger) DebugfCtx(ctx context.Context, format string, v ...interface{}) {
-	if !l.IsDebugEnabled() {
+	if !l.isLevelEnabledCtx(ctx, LogLevelDebug) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelDebug, fmt.Sprintf(format, v...))
+	l.dispatchf(ctx, LogLevelDebug, format, v)
 }
 
 // InfofCtx logs a formatted message at the Info level.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
 func (l *Logger) InfofCtx(ctx context.Context, format string, v ...interface{}) {
-	if !l.IsInfoEnabled() {
+	if !l.isLevelEnabledCtx(ctx, LogLevelInfo) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelInfo, fmt.Sprintf(format, v...))
+	l.dispatchf(ctx, LogLevelInfo, format, v)
 }
 
 // WarnfCtx logs a formatted message at the Warn level.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
 func (l *Logger) WarnfCtx(ctx context.Context, format string, v ...interface{}) {
-	if !l.IsWarnEnabled() {
+	if !l.isLevelEnabledCtx(ctx, LogLevelWarn) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelWarn, fmt.Sprintf(format, v...))
+	l.dispatchf(ctx, LogLevelWarn, format, v)
 }
 
 // ErrorfCtx logs a formatted message at the Error level.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
 func (l *Logger) ErrorfCtx(ctx context.Context, format string, v ...interface{}) {
-	if !l.IsErrorEnabled() {
+	if !l.isLevelEnabledCtx(ctx, LogLevelError) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelError, fmt.Sprintf(format, v...))
+	l.dispatchf(ctx, LogLevelError, format, v)
 }
 
 // CriticalfCtx logs a formatted message at the Critical level.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
 func (l *Logger) CriticalfCtx(ctx context.Context, format string, v ...interface{}) {
-	if !l.IsCriticalEnabled() {
+	if !l.isLevelEnabledCtx(ctx, LogLevelCritical) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelCritical, fmt.Sprintf(format, v...))
+	l.dispatchf(ctx, LogLevelCritical, format, v)
 }
 
-// PrintfCtx logs a formatted message at the Info level, like log.Printf.
+// TracelnCtx logs its arguments at the Trace level, like log.Println.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) PrintfCtx(ctx context.Context, format string, v ...interface{}) {
-	l.InfofCtx(ctx, format, v...)
+func (l *Logger) TracelnCtx(ctx context.Context, v ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelTrace) {
+		return
+	}
+
+	l.dispatch(ctx, LogLevelTrace, sprintlnMessage(v...))
 }
 
-// PrintCtx logs its arguments at the Info level, like log.Print.
+// DebuglnCtx logs its arguments at the Debug level, like log.Println.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) PrintCtx(ctx context.Context, v ...interface{}) {
-	if !l.IsInfoEnabled() {
+func (l *Logger) DebuglnCtx(ctx context.Context, v ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelDebug) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelInfo, sprintMessage(v...))
+	l.dispatch(ctx, LogLevelDebug, sprintlnMessage(v...))
 }
 
-// PrintlnCtx logs its arguments at the Info level, like log.Println.
+// InfolnCtx logs its arguments at the Info level, like log.Println.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) PrintlnCtx(ctx context.Context, v ...interface{}) {
-	if !l.IsInfoEnabled() {
+func (l *Logger) InfolnCtx(ctx context.Context, v ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelInfo) {
 		return
 	}
 
 	l.dispatch(ctx, LogLevelInfo, sprintlnMessage(v...))
 }
 
-// FatalCtxf logs a formatted message at the Critical level and then calls os.Exit(1).
+// WarnlnCtx logs its arguments at the Warn level, like log.Println.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) FatalfCtx(ctx context.Context, format string, v ...interface{}) {
-	if l.IsCriticalEnabled() {
-		l.dispatch(ctx, LogLevelCritical, fmt.Sprintf(format, v...))
+func (l *Logger) WarnlnCtx(ctx context.Context, v ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelWarn) {
+		return
 	}
 
-	// FatalfCtx functions always call os.Exit.
-	osExit(1)
+	l.dispatch(ctx, LogLevelWarn, sprintlnMessage(v...))
 }
 
-// FatalCtx logs its arguments at the Critical level and then calls os.Exit(1).
+// ErrorlnCtx logs its arguments at the Error level, like log.Println.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) FatalCtx(ctx context.Context, v ...interface{}) {
-	if l.IsCriticalEnabled() {
-		l.dispatch(ctx, LogLevelCritical, sprintMessage(v...))
+func (l *Logger) ErrorlnCtx(ctx context.Context, v ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelError) {
+		return
 	}
 
-	// FatalCtx functions always call os.Exit.
-	osExit(1)
+	l.dispatch(ctx, LogLevelError, sprintlnMessage(v...))
 }
 
-// FatallnCtx logs its arguments at the Critical level and then calls os.Exit(1).
+// CriticallnCtx logs its arguments at the Critical level, like log.Println.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) FatallnCtx(ctx context.Context, v ...interface{}) {
-	if l.IsCriticalEnabled() {
-		l.dispatch(ctx, LogLevelCritical, sprintlnMessage(v...))
+func (l *Logger) CriticallnCtx(ctx context.Context, v ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelCritical) {
+		return
 	}
 
-	// FatallnCtx functions always call os.Exit.
-	osExit(1)
+	l.dispatch(ctx, LogLevelCritical, sprintlnMessage(v...))
 }
 
-// DebugwCtx logs a formatted message at the Debug level.
+// TraceFuncCtx logs the message returned by fn at the Trace level. fn is called only if
+// the level is enabled, so an expensive message costs nothing when it is disabled.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) DebugwCtx(ctx context.Context, msg string, kvs ...interface{}) {
-	if !l.IsDebugEnabled() {
+func (l *Logger) TraceFuncCtx(ctx context.Context, fn func() string) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelTrace) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelDebug, msg, kvs...)
+	l.dispatch(ctx, LogLevelTrace, fn())
 }
 
-// InfowCtx logs a formatted message at the Info level.
+// DebugFuncCtx logs the message returned by fn at the Debug level. fn is called only if
+// the level is enabled, so an expensive message costs nothing when it is disabled.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) InfowCtx(ctx context.Context, msg string, kvs ...interface{}) {
-	if !l.IsInfoEnabled() {
+func (l *Logger) DebugFuncCtx(ctx context.Context, fn func() string) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelDebug) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelInfo, msg, kvs...)
+	l.dispatch(ctx, LogLevelDebug, fn())
 }
 
-// WarnwCtx logs a formatted message at the Warn level.
+// InfoFuncCtx logs the message returned by fn at the Info level. fn is called only if
+// the level is enabled, so an expensive message costs nothing when it is disabled.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) WarnwCtx(ctx context.Context, msg string, kvs ...interface{}) {
-	if !l.IsWarnEnabled() {
+func (l *Logger) InfoFuncCtx(ctx context.Context, fn func() string) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelInfo) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelWarn, msg, kvs...)
+	l.dispatch(ctx, LogLevelInfo, fn())
 }
 
-// ErrorwCtx logs a formatted message at the Error level.
+// WarnFuncCtx logs the message returned by fn at the Warn level. fn is called only if
+// the level is enabled, so an expensive message costs nothing when it is disabled.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) ErrorwCtx(ctx context.Context, msg string, kvs ...interface{}) {
-	if !l.IsErrorEnabled() {
+func (l *Logger) WarnFuncCtx(ctx context.Context, fn func() string) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelWarn) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelError, msg, kvs...)
+	l.dispatch(ctx, LogLevelWarn, fn())
 }
 
-// CriticalwCtx logs a formatted message at the Critical level.
+// ErrorFuncCtx logs the message returned by fn at the Error level. fn is called only if
+// the level is enabled, so an expensive message costs nothing when it is disabled.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) CriticalwCtx(ctx context.Context, msg string, kvs ...interface{}) {
-	if !l.IsCriticalEnabled() {
+func (l *Logger) ErrorFuncCtx(ctx context.Context, fn func() string) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelError) {
 		return
 	}
 
-	l.dispatch(ctx, LogLevelCritical, msg, kvs...)
+	l.dispatch(ctx, LogLevelError, fn())
 }
 
-// FatalwCtx logs a message with structured key-value pairs at the Critical level
-// and then calls os.Exit(1).
+// CriticalFuncCtx logs the message returned by fn at the Critical level. fn is called only if
+// the level is enabled, so an expensive message costs nothing when it is disabled.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
-func (l *Logger) FatalwCtx(ctx context.Context, msg string, kvs ...interface{}) {
-	if l.IsCriticalEnabled() {
-		l.dispatch(ctx, LogLevelCritical, msg, kvs...)
+func (l *Logger) CriticalFuncCtx(ctx context.Context, fn func() string) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelCritical) {
+		return
 	}
 
-	// FatalwCtx functions always call os.Exit.
-	osExit(1)
-}
-
-// Debugf logs a formatted message at the Debug level.
-func (l *Logger) Debugf(format string, v ...interface{}) {
-	l.DebugfCtx(context.Background(), format, v...)
+	l.dispatch(ctx, LogLevelCritical, fn())
 }
 
-// Infof logs a formatted message at the Info level.
-func (l *Logger) Infof(format string, v ...interface{}) {
-	l.InfofCtx(context.Background(), format, v...)
+// PrintfCtx logs a formatted message at the Info level, like log.Printf.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) PrintfCtx(ctx context.Context, format string, v ...interface{}) {
+	l.InfofCtx(ctx, format, v...)
 }
 
-// Warnf logs a formatted message at the Warn level.
-func (l *Logger) Warnf(format string, v ...interface{}) {
-	l.WarnfCtx(context.Background(), format, v...)
-}
+// PrintCtx logs its arguments at the Info level, like log.Print.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) PrintCtx(ctx context.Context, v ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelInfo) {
+		return
+	}
 
-// Errorf logs a formatted message at the Error level.
-func (l *Logger) Errorf(format string, v ...interface{}) {
-	l.ErrorfCtx(context.Background(), format, v...)
+	l.dispatch(ctx, LogLevelInfo, sprintMessage(v...))
 }
 
-// Criticalf logs a formatted message at the Critical level.
-func (l *Logger) Criticalf(format string, v ...interface{}) {
-	l.CriticalfCtx(context.Background(), format, v...)
-}
+// PrintlnCtx logs its arguments at the Info level, like log.Println.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) PrintlnCtx(ctx context.Context, v ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelInfo) {
+		return
+	}
 
-// Printf logs a formatted message at the Info level, like log.Printf.
-func (l *Logger) Printf(format string, v ...interface{}) {
-	l.PrintfCtx(context.Background(), format, v...)
+	l.dispatch(ctx, LogLevelInfo, sprintlnMessage(v...))
 }
 
-// Print logs its arguments at the Info level, like log.Print.
-func (l *Logger) Print(v ...interface{}) {
-	l.PrintCtx(context.Background(), v...)
-}
+// FatalCtxf logs a formatted message at the Critical level and then calls os.Exit(1).
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) FatalfCtx(ctx context.Context, format string, v ...interface{}) {
+	if l.isLevelEnabledCtx(ctx, LogLevelCritical) {
+		l.undeduped().dispatchf(ctx, LogLevelCritical, format, v)
+	}
 
-// Println logs its arguments at the Info level, like log.Println.
-func (l *Logger) Println(v ...interface{}) {
-	l.PrintlnCtx(context.Background(), v...)
+	// FatalfCtx functions call os.Exit regardless of the level, unless disabled by WithExitOnFatal.
+	l.exit(1)
+}
+
+// FatalCtx logs its arguments at the Critical level and then calls os.Exit(1).
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) FatalCtx(ctx context.Context, v ...interface{}) {
+	if l.isLevelEnabledCtx(ctx, LogLevelCritical) {
+		l.undeduped().dispatch(ctx, LogLevelCritical, sprintMessage(v...))
+	}
+
+	// FatalCtx functions call os.Exit regardless of the level, unless disabled by WithExitOnFatal.
+	l.exit(1)
+}
+
+// FatallnCtx logs its arguments at the Critical level and then calls os.Exit(1).
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) FatallnCtx(ctx context.Context, v ...interface{}) {
+	if l.isLevelEnabledCtx(ctx, LogLevelCritical) {
+		l.undeduped().dispatch(ctx, LogLevelCritical, sprintlnMessage(v...))
+	}
+
+	// FatallnCtx functions call os.Exit regardless of the level, unless disabled by WithExitOnFatal.
+	l.exit(1)
+}
+
+// TracewCtx logs a formatted message at the Trace level.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) TracewCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelTrace) {
+		return
+	}
+
+	l.dispatch(ctx, LogLevelTrace, msg, kvs...)
+}
+
+// DebugwCtx logs a formatted message at the Debug level.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) DebugwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelDebug) {
+		return
+	}
+
+	l.dispatch(ctx, LogLevelDebug, msg, kvs...)
+}
+
+// InfowCtx logs a formatted message at the Info level.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) InfowCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelInfo) {
+		return
+	}
+
+	l.dispatch(ctx, LogLevelInfo, msg, kvs...)
+}
+
+// WarnwCtx logs a formatted message at the Warn level.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) WarnwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelWarn) {
+		return
+	}
+
+	l.dispatch(ctx, LogLevelWarn, msg, kvs...)
+}
+
+// ErrorwCtx logs a formatted message at the Error level.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) ErrorwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelError) {
+		return
+	}
+
+	l.dispatch(ctx, LogLevelError, msg, kvs...)
+}
+
+// CriticalwCtx logs a formatted message at the Critical level.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) CriticalwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	if !l.isLevelEnabledCtx(ctx, LogLevelCritical) {
+		return
+	}
+
+	l.dispatch(ctx, LogLevelCritical, msg, kvs...)
+}
+
+// FatalwCtx logs a message with structured key-value pairs at the Critical level
+// and then calls os.Exit(1).
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func (l *Logger) FatalwCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	if l.isLevelEnabledCtx(ctx, LogLevelCritical) {
+		l.undeduped().dispatch(ctx, LogLevelCritical, msg, kvs...)
+	}
+
+	// FatalwCtx functions call os.Exit regardless of the level, unless disabled by WithExitOnFatal.
+	l.exit(1)
+}
+
+// Tracef logs a formatted message at the Trace level.
+func (l *Logger) Tracef(format string, v ...interface{}) {
+	l.TracefCtx(l.boundContext(), format, v...)
+}
+
+// Debugf logs a formatted message at the Debug level.
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.DebugfCtx(l.boundContext(), format, v...)
+}
+
+// Infof logs a formatted message at the Info level.
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.InfofCtx(l.boundContext(), format, v...)
+}
+
+// Warnf logs a formatted message at the Warn level.
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.WarnfCtx(l.boundContext(), format, v...)
+}
+
+// Errorf logs a formatted message at the Error level.
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.ErrorfCtx(l.boundContext(), format, v...)
+}
+
+// Criticalf logs a formatted message at the Critical level.
+func (l *Logger) Criticalf(format string, v ...interface{}) {
+	l.CriticalfCtx(l.boundContext(), format, v...)
+}
+
+// Traceln logs its arguments at the Trace level, like log.Println.
+func (l *Logger) Traceln(v ...interface{}) {
+	l.TracelnCtx(l.boundContext(), v...)
+}
+
+// Debugln logs its arguments at the Debug level, like log.Println.
+func (l *Logger) Debugln(v ...interface{}) {
+	l.DebuglnCtx(l.boundContext(), v...)
+}
+
+// Infoln logs its arguments at the Info level, like log.Println.
+func (l *Logger) Infoln(v ...interface{}) {
+	l.InfolnCtx(l.boundContext(), v...)
+}
+
+// Warnln logs its arguments at the Warn level, like log.Println.
+func (l *Logger) Warnln(v ...interface{}) {
+	l.WarnlnCtx(l.boundContext(), v...)
+}
+
+// Errorln logs its arguments at the Error level, like log.Println.
+func (l *Logger) Errorln(v ...interface{}) {
+	l.ErrorlnCtx(l.boundContext(), v...)
+}
+
+// Criticalln logs its arguments at the Critical level, like log.Println.
+func (l *Logger) Criticalln(v ...interface{}) {
+	l.CriticallnCtx(l.boundContext(), v...)
+}
+
+// TraceFunc logs the message returned by fn at the Trace level, calling fn only if the level is enabled.
+func (l *Logger) TraceFunc(fn func() string) {
+	l.TraceFuncCtx(l.boundContext(), fn)
+}
+
+// DebugFunc logs the message returned by fn at the Debug level, calling fn only if the level is enabled.
+func (l *Logger) DebugFunc(fn func() string) {
+	l.DebugFuncCtx(l.boundContext(), fn)
+}
+
+// InfoFunc logs the message returned by fn at the Info level, calling fn only if the level is enabled.
+func (l *Logger) InfoFunc(fn func() string) {
+	l.InfoFuncCtx(l.boundContext(), fn)
+}
+
+// WarnFunc logs the message returned by fn at the Warn level, calling fn only if the level is enabled.
+func (l *Logger) WarnFunc(fn func() string) {
+	l.WarnFuncCtx(l.boundContext(), fn)
+}
+
+// ErrorFunc logs the message returned by fn at the Error level, calling fn only if the level is enabled.
+func (l *Logger) ErrorFunc(fn func() string) {
+	l.ErrorFuncCtx(l.boundContext(), fn)
+}
+
+// CriticalFunc logs the message returned by fn at the Critical level, calling fn only if the level is enabled.
+func (l *Logger) CriticalFunc(fn func() string) {
+	l.CriticalFuncCtx(l.boundContext(), fn)
+}
+
+// Printf logs a formatted message at the Info level, like log.Printf.
+func (l *Logger) Printf(format string, v ...interface{}) {
+	l.PrintfCtx(l.boundContext(), format, v...)
+}
+
+// Print logs its arguments at the Info level, like log.Print.
+func (l *Logger) Print(v ...interface{}) {
+	l.PrintCtx(l.boundContext(), v...)
+}
+
+// Println logs its arguments at the Info level, like log.Println.
+func (l *Logger) Println(v ...interface{}) {
+	l.PrintlnCtx(l.boundContext(), v...)
 }
 
 // Fatalf logs a formatted message at the Critical level and then calls os.Exit(1).
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	l.FatalfCtx(context.Background(), format, v...)
+	l.FatalfCtx(l.boundContext(), format, v...)
 }
 
 // Fatal logs its arguments at the Critical level and then calls os.Exit(1).
 func (l *Logger) Fatal(v ...interface{}) {
-	l.FatalCtx(context.Background(), v...)
+	l.FatalCtx(l.boundContext(), v...)
 }
 
 // Fatalln logs its arguments at the Critical level and then calls os.Exit(1).
 func (l *Logger) Fatalln(v ...interface{}) {
-	l.FatallnCtx(context.Background(), v...)
+	l.FatallnCtx(l.boundContext(), v...)
+}
+
+// Tracew logs a message at the Trace level with structured key-value pairs.
+func (l *Logger) Tracew(msg string, kvs ...interface{}) {
+	l.TracewCtx(l.boundContext(), msg, kvs...)
 }
 
 // Debugw logs a message at the Debug level with structured key-value pairs.
 func (l *Logger) Debugw(msg string, kvs ...interface{}) {
-	l.DebugwCtx(context.Background(), msg, kvs...)
+	l.DebugwCtx(l.boundContext(), msg, kvs...)
 }
 
 // Infow logs a message at the Info level with structured key-value pairs.
 func (l *Logger) Infow(msg string, kvs ...interface{}) {
-	l.InfowCtx(context.Background(), msg, kvs...)
+	l.InfowCtx(l.boundContext(), msg, kvs...)
 }
 
 // Warnw logs a message at the Warn level with structured key-value pairs.
 func (l *Logger) Warnw(msg string, kvs ...interface{}) {
-	l.WarnwCtx(context.Background(), msg, kvs...)
+	l.WarnwCtx(l.boundContext(), msg, kvs...)
 }
 
 // Errorw logs a message at the Error level with structured key-value pairs.
 func (l *Logger) Errorw(msg string, kvs ...interface{}) {
-	l.ErrorwCtx(context.Background(), msg, kvs...)
+	l.ErrorwCtx(l.boundContext(), msg, kvs...)
 }
 
 // Criticalw logs a message at the Critical level with structured key-value pairs.
 func (l *Logger) Criticalw(msg string, kvs ...interface{}) {
-	l.CriticalwCtx(context.Background(), msg, kvs...)
+	l.CriticalwCtx(l.boundContext(), msg, kvs...)
 }
 
 // Fatalw logs a message with structured key-value pairs at the Critical level
 // and then calls os.Exit(1).
 func (l *Logger) Fatalw(msg string, kvs ...interface{}) {
-	l.FatalwCtx(context.Background(), msg, kvs...)
+	l.FatalwCtx(l.boundContext(), msg, kvs...)
 }
 
 // dispatch is the single, central method that handles all log entry creation and printing.
 // It is called *after* a level check has been performed by a public method.
 func (l *Logger) dispatch(ctx context.Context, level LogLevel, msg string, kvs ...interface{}) {
-	e := l.createEntry(ctx, level, msg, kvs...)
+	l.dispatchEntry(l.createEntry(ctx, level, msg, kvs...))
+}
 
-	if e.SourceLocation == nil && (l.sourceLocationMode == SourceLocationModeAlways ||
-		(l.sourceLocationMode == SourceLocationModeErrorOrAbove && levelMap[level] <= logLevelValueError)) {
+// dispatchEntry captures the source location and stack trace of a created entry
+// and delivers it.
+func (l *Logger) dispatchEntry(e *LogEntry) {
+	if e.SourceLocation == nil && l.shouldCaptureSource(e) {
 		e.SourceLocation = l.findCaller()
 	}
 
-	if l.hookChan != nil {
+	if l.sourceSnippet && e.SourceLocation != nil {
+		addSourceSnippet(e)
+	}
+
+	if l.errorStackExtraction && e.err != nil {
+		if _, ok := e.Payload[stackTraceKey]; !ok {
+			if stack, ok := l.errorStackTrace(e.err); ok {
+				e.Payload[stackTraceKey] = stack
+			}
+		}
+	}
+
+	if levelMap[e.Severity] <= l.stackTraceLevel {
+		if _, ok := e.Payload[stackTraceKey]; !ok {
+			e.Payload[stackTraceKey] = l.captureStackTrace()
+		}
+	}
+
+	l.deliverEntry(e)
+}
+
+// deliverEntry applies the redactor to an entry, fires the hooks, prints it and
+// returns it to the pool.
+func (l *Logger) deliverEntry(e *LogEntry) {
+	level := e.Severity
+	pooled := e
+
+	if l.keyNormalizer != nil {
+		normalizeKeys(e.Payload, l.keyNormalizer)
+		normalizeKeys(e.Labels, l.keyNormalizer)
+	}
+
+	if l.redactor != nil {
+		if e = l.redactor(pooled); e == nil {
+			pooled.Clear()
+			logEntryPool.Put(pooled)
+
+			return
+		}
+	}
+
+	if l.hookWorker != nil && levelMap[level] <= l.hookMinLevel {
+		// The entry is complete here: createEntry has added the fields derived from the
+		// context, so the copy carries them to the hooks.
 		// Use a non-blocking send to prevent the application from stalling
 		// if the hook channel buffer is full.
-		hookEntry := l.defensiveCopy(e)
-
-		select {
-		case l.hookChan <- hookEntry:
-		default:
-			// The entry is dropped if the channel is full.
-			// This is a trade-off to prioritize application performance over hook reliability under extreme load.
+		// The entry is dropped if the channel is full or the worker is closed.
+		// This is a trade-off to prioritize application performance over hook reliability under extreme load.
+		entryCopy := l.defensiveCopy(e)
+
+		// The copy records that this logger has written the entry, so that a LoggerHook
+		// leading back to it does not write it again.
+		if !slices.Contains(entryCopy.relayedTo, l) {
+			entryCopy.relayedTo = append(slices.Clip(entryCopy.relayedTo), l)
 		}
+
+		l.hookWorker.send(entryCopy)
 	}
 
 	l.print(e)
 
-	logEntryPool.Put(e)
+	if e != pooled {
+		// The redactor returned a new entry; the pooled one may share maps with it,
+		// so it is cleared only after printing.
+		pooled.Clear()
+	}
+
+	logEntryPool.Put(pooled)
+}
+
+// shouldCaptureSource reports whether the source location should be captured for the entry,
+// honoring a per-call "_source" override before the logger's source location mode.
+func (l *Logger) shouldCaptureSource(e *LogEntry) bool {
+	switch e.sourceOverride {
+	case sourceOverrideForce:
+		return true
+	case sourceOverrideSuppress:
+		return false
+	}
+
+	if levelMap[e.Severity] <= logLevelValueError {
+		return l.sourceLocationMode != SourceLocationModeNever
+	}
+
+	if l.sourceLocationMode != SourceLocationModeAlways {
+		return false
+	}
+
+	// With WithSourceSampling, only the first of every n entries below Error gets a location.
+	return l.sourceSampling <= 1 || (l.sourceSampleCount.Add(1)-1)%uint64(l.sourceSampling) == 0
+}
+
+// dispatchf formats the message for the ...f methods and dispatches it.
+// When strict formatting is enabled and the number of formatting verbs does not match
+// the number of arguments, the unformatted string is used as the message and the
+// mismatch is recorded in the misuse error field ("logging_error" by default).
+func (l *Logger) dispatchf(ctx context.Context, level LogLevel, format string, v []interface{}, kvs ...interface{}) {
+	if l.strictFormat {
+		if verbs := countFormatVerbs(format); verbs >= 0 && verbs != len(v) {
+			kvs = append(kvs[:len(kvs):len(kvs)], l.misuseErrorKey,
+				fmt.Sprintf("format verb mismatch: %d verbs, %d arguments", verbs, len(v)))
+
+			l.dispatch(ctx, level, format, kvs...)
+
+			return
+		}
+	}
+
+	l.dispatch(ctx, level, fmt.Sprintf(format, v...), kvs...)
 }
 
 // createEntry is the single, central helper for creating log entries.
@@ -754,37 +1505,61 @@ func (l *Logger) createEntry(ctx context.Context, level LogLevel, msg string, kv
 	e.SpanID = l.spanId
 	e.TraceSampled = l.traceSampled
 	e.CorrelationID = l.correlationID
-	e.Labels = l.labels
 	e.Time = time.Now()
+	e.maxFields = l.maxFields
+	e.misuseErrorKey = l.misuseErrorKey
+	e.missingValuePlaceholder = l.missingValuePlaceholder
+
+	// The labels are copied into the entry's own map, as formatters may mask them
+	// in place and Clear empties the map for reuse.
+	if e.Labels == nil {
+		e.Labels = make(map[string]string, len(l.labels))
+	}
+
+	maps.Copy(e.Labels, l.labels)
 
 	// 2. Apply values from context.Context (lowest precedence).
 	if ctx != nil && l.projectID != "" && l.traceContextKey != nil {
-		if traceHeader, ok := ctx.Value(l.traceContextKey).(string); ok {
-			parts := strings.Split(traceHeader, "/")
-
-			if len(parts) > 0 && e.Trace == "" {
-				e.Trace = "projects/" + l.projectID + "/traces/" + parts[0]
+		if value := ctx.Value(l.traceContextKey); value != nil {
+			parse := l.traceParser
+			if parse == nil {
+				parse = parseTraceHeader
 			}
 
-			if len(parts) > 1 && e.SpanID == "" {
-				spanParts := strings.Split(parts[1], ";")
-				e.SpanID = spanParts[0]
+			if trace, spanID, sampled, ok := parse(value); ok {
+				if trace != "" && e.Trace == "" {
+					e.Trace = "projects/" + l.projectID + "/traces/" + trace
+				}
+
+				if spanID != "" && e.SpanID == "" {
+					e.SpanID = spanID
+				}
+
+				if sampled != nil && e.TraceSampled == nil {
+					e.TraceSampled = sampled
+				}
 			}
 		}
 	}
 
-	// 3. Apply contextual fields from the logger (With method).
-	if len(l.payload) > 0 {
-		contextKVs := make([]interface{}, 0, len(l.payload)*2)
-
-		for k, v := range l.payload {
-			contextKVs = append(contextKVs, k, v)
+	if ctx != nil {
+		for _, extract := range l.contextExtractors {
+			e.applyKVs(extract(ctx)...)
 		}
+	}
+
+	// 3. Apply contextual fields from the logger (With method).
+	// The payload map is applied directly, without building an intermediate slice.
+	for k, v := range l.payload {
+		e.applyKV(k, v)
+	}
 
-		e.applyKVs(contextKVs...)
+	// 4. Apply dynamic fields, evaluated for each entry.
+	for _, fields := range l.dynamicFields {
+		e.applyKVs(fields()...)
 	}
 
-	// 4. Apply key-value pairs from the specific log call (highest precedence).
+	// 5. Apply key-value pairs from the specific log call (highest precedence).
 	if len(kvs) > 0 {
 		e.applyKVs(kvs...)
 	}
@@ -794,91 +1569,380 @@ func (l *Logger) createEntry(ctx context.Context, level LogLevel, msg string, kv
 
 // print writes the log entry to the logger's output.
 func (l *Logger) print(e *LogEntry) {
-	l.outMutex.Lock()
-	defer l.outMutex.Unlock()
+	// The concurrent writer serializes the writes itself, so the entry is formatted
+	// without holding a lock.
+	if l.concurrentWriter == nil {
+		l.outMutex.Lock()
+		defer l.outMutex.Unlock()
+	}
+
+	l.setTimeFormat(e)
+
+	l.applyOnceFields(e)
+
+	if l.sqlArgMode != SQLArgModeRedact {
+		applySQLArgMode(e, l.sqlArgMode)
+	}
+
+	if l.maxFieldValueLength > 0 {
+		truncateFieldValues(e, l.maxFieldValueLength)
+	}
+
+	var dedupKey string
+	if l.dedup != nil {
+		dedupKey = l.dedupKeyOf(e)
+	}
 
 	out, err := l.formatter.Format(e)
 	if err != nil {
-		log.Printf("failed to format log entry: %v", err)
+		l.formatFailed(err, e)
 
 		return
 	}
 
+	if l.maxEntrySize > 0 && len(out) > l.maxEntrySize {
+		out, err = l.truncate(e, out)
+		if err != nil {
+			l.formatFailed(err, e)
+
+			return
+		}
+	}
+
+	level := e.Severity
+
 	e.Clear()
 
-	out = append(out, '\n')
+	if l.validateOutput {
+		if err := validateOutput(l.formatter, out); err != nil {
+			printWarning(l, fmt.Sprintf("harelog: formatter produced invalid output: %v", err))
+		}
+	}
 
-	l.out.Write(out)
-}
+	if l.dedup != nil {
+		l.dedup.write(l, dedupKey, level, l.record(level, out))
 
-func (l *Logger) findCaller() *SourceLocation {
-	pcs := make([]uintptr, 16)
+		return
+	}
 
-	// 0: Callers, 1: findCaller. Start search from the caller of findCaller.
-	n := runtime.Callers(2, pcs)
+	l.writeRecord(level, l.record(level, out))
+}
+
+// setTimeFormat passes the logger's timestamp settings to the formatter through the entry.
+func (l *Logger) setTimeFormat(e *LogEntry) {
+	e.nanoTimestamps = l.nanoTimestamps
+	e.timeEncoder = l.timeEncoder
+}
+
+// formatFailed reports that the entry could not be formatted, to the format error
+// handler, the internal error handler or the standard logger, and clears the entry for reuse.
+func (l *Logger) formatFailed(err error, e *LogEntry) {
+	e.boxFields()
+
+	switch {
+	case l.formatErrorHandler != nil:
+		l.formatErrorHandler(err, e)
+	case l.internalErrorHandler != nil:
+		l.internalErrorHandler(&LogEntry{
+			Time:     time.Now(),
+			Severity: LogLevelError,
+			Message:  "harelog: failed to format log entry",
+			Payload: map[string]interface{}{
+				"error":         err,
+				"entry.message": e.Message,
+			},
+		})
+	default:
+		log.Printf("failed to format log entry: %v", err)
+	}
 
-	frames := runtime.CallersFrames(pcs[:n])
+	e.Clear()
+}
 
-	for {
-		frame, more := frames.Next()
+// writeRecord writes a framed record of the given level to the output, through the
+// concurrent writer if it is enabled.
+func (l *Logger) writeRecord(level LogLevel, record []byte) {
+	out := l.out
+	if w, ok := out.(levelWriter); ok {
+		out = levelOutput{w: w, level: level}
+	}
 
-		// Skip frames that are inside the harelog package.
-		// if !strings.Contains(frame.File, "harelog") {
-		if !strings.HasPrefix(frame.Function, harelogPackage) {
-			return &SourceLocation{
-				File:     frame.File,
-				Line:     frame.Line,
-				Function: frame.Function,
-			}
+	if l.syncOnWrite {
+		if s, ok := out.(syncer); ok {
+			out = syncingWriter{Writer: out, syncer: s}
 		}
+	}
 
-		if !more {
-			break
-		}
+	if l.writeBuffer != nil {
+		out = bufferedOutput{buffer: l.writeBuffer, out: out}
 	}
 
-	return nil
-}
+	if l.concurrentWriter != nil {
+		l.concurrentWriter.write(out, record)
 
-// SetLogLevel dynamically updates the logger's log level.
-// This operation is thread-safe.
-func (l *Logger) SetLogLevel(level LogLevel) {
-	if _, ok := levelMap[level]; !ok {
-		panic(fmt.Sprintf("harelog: invalid log level provided to (*Logger).SetLogLevel: %q", level))
+		return
 	}
 
-	l.logLevel.Store(uint32(levelMap[level]))
+	out.Write(record)
 }
 
-// IsDebugEnabled checks if the Debug level is enabled for the logger.
-func (l *Logger) IsDebugEnabled() bool {
-	return l.logLevel.Load() >= uint32(logLevelValueDebug)
+// levelWriter is implemented by outputs that record the level of each entry themselves,
+// such as the writer returned by NewEventLogWriter.
+type levelWriter interface {
+	writeLevel(level LogLevel, p []byte) (int, error)
 }
 
-// IsInfoEnabled checks if the Info level is enabled for the logger.
-func (l *Logger) IsInfoEnabled() bool {
-	return l.logLevel.Load() >= uint32(logLevelValueInfo)
+// levelOutput writes records of one level to a levelWriter.
+type levelOutput struct {
+	w     levelWriter
+	level LogLevel
 }
 
-// IsWarnEnabled checks if the Warn level is enabled for the logger.
-func (l *Logger) IsWarnEnabled() bool {
-	return l.logLevel.Load() >= uint32(logLevelValueWarn)
+// Write implements io.Writer.
+func (o levelOutput) Write(p []byte) (int, error) {
+	return o.w.writeLevel(o.level, p)
 }
 
-// IsErrorEnabled checks if the Error level is enabled for the logger.
-func (l *Logger) IsErrorEnabled() bool {
-	return l.logLevel.Load() >= uint32(logLevelValueError)
+// syncer is implemented by outputs that can commit written data to stable storage,
+// such as *os.File.
+type syncer interface {
+	Sync() error
 }
 
-// IsCriticalEnabled checks if the Critical level is enabled for the logger.
-func (l *Logger) IsCriticalEnabled() bool {
-	return l.logLevel.Load() >= uint32(logLevelValueCritical)
+// syncingWriter syncs the output after each write (see WithSyncOnWrite).
+type syncingWriter struct {
+	io.Writer
+	syncer syncer
 }
 
-// WithLogLevel returns a new logger instance with the specified log level.
-func (l *Logger) WithLogLevel(level LogLevel) *Logger {
-	if _, ok := levelMap[level]; !ok {
-		panic(fmt.Sprintf("harelog: invalid log level provided to (*Logger).WithLogLevel: %q", level))
+// Write implements io.Writer.
+func (w syncingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	return n, w.syncer.Sync()
+}
+
+// exit writes the records still queued by the concurrent writer or buffered by the
+// write buffer, so that the entry of a Fatal method is not lost, and then calls os.Exit.
+// It does nothing if exiting is disabled by WithExitOnFatal.
+func (l *Logger) exit(code int) {
+	if !l.exitOnFatal {
+		return
+	}
+
+	if l.concurrentWriter != nil {
+		l.concurrentWriter.close()
+	}
+
+	if l.writeBuffer != nil {
+		l.writeBuffer.close()
+	}
+
+	osExit(code)
+}
+
+// record frames formatted output with the journald priority, the record prefix
+// and the record separator.
+func (l *Logger) record(level LogLevel, out []byte) []byte {
+	if len(l.recordPrefix) > 0 || l.journaldPriorityPrefix {
+		var priority []byte
+		if l.journaldPriorityPrefix {
+			priority = journaldPriority(level)
+		}
+
+		record := make([]byte, 0, len(priority)+len(l.recordPrefix)+len(out)+len(l.recordSeparator))
+		record = append(record, priority...)
+		record = append(record, l.recordPrefix...)
+
+		out = append(record, out...)
+	}
+
+	return append(out, l.recordSeparator...)
+}
+
+// findCaller returns the location of the first frame outside this package,
+// skipping a further l.callerSkip frames for wrappers around the logger.
+func (l *Logger) findCaller() *SourceLocation {
+	if harelogPackage == "" {
+		sourceLocationWarnOnce.Do(func() {
+			const msg = "harelog: could not determine package path, source location capturing is disabled"
+
+			if l.internalErrorHandler != nil {
+				l.internalErrorHandler(&LogEntry{Time: time.Now(), Severity: LogLevelWarn, Message: msg})
+			} else {
+				log.Print(msg)
+			}
+		})
+
+		return nil
+	}
+
+	frame, ok := l.callerFrame()
+	if !ok {
+		return nil
+	}
+
+	function := frame.Function
+	if l.sourceFunctionMode == SourceFunctionModeShort {
+		function = shortFunctionName(function)
+	}
+
+	return &SourceLocation{
+		File:     frame.File,
+		Line:     frame.Line,
+		Function: function,
+	}
+}
+
+// callerFrame returns the first frame outside this package, skipping a further
+// l.callerSkip frames for wrappers around the logger.
+func (l *Logger) callerFrame() (runtime.Frame, bool) {
+	if harelogPackage == "" {
+		return runtime.Frame{}, false
+	}
+
+	pcs := make([]uintptr, 16+l.callerSkip)
+
+	// 0: Callers, 1: callerFrame. Start search from the caller of callerFrame.
+	n := runtime.Callers(2, pcs)
+
+	frames := runtime.CallersFrames(pcs[:n])
+	skip := l.callerSkip
+
+	for {
+		frame, more := frames.Next()
+
+		// Skip frames that are inside the harelog package.
+		if !isHarelogFunction(frame.Function) {
+			if skip == 0 {
+				return frame, true
+			}
+
+			skip--
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return runtime.Frame{}, false
+}
+
+// shortFunctionName drops the import path from a fully qualified function name,
+// keeping the package name, e.g. "pkg.(*Server).Handle".
+func shortFunctionName(function string) string {
+	if i := strings.LastIndexByte(function, '/'); i >= 0 {
+		return function[i+1:]
+	}
+
+	return function
+}
+
+// isHarelogFunction reports whether the fully qualified function name belongs
+// to this package or one of its sub-packages.
+func isHarelogFunction(function string) bool {
+	if !strings.HasPrefix(function, harelogPackage) {
+		return false
+	}
+
+	rest := function[len(harelogPackage):]
+
+	return strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "/")
+}
+
+// SetLogLevel dynamically updates the logger's log level.
+// This operation is thread-safe.
+func (l *Logger) SetLogLevel(level LogLevel) {
+	if _, ok := levelMap[level]; !ok {
+		panic(fmt.Sprintf("harelog: invalid log level provided to (*Logger).SetLogLevel: %q", level))
+	}
+
+	l.logLevel.Store(uint32(levelMap[level]))
+}
+
+// IsTraceEnabled checks if the Trace level is enabled for the logger.
+func (l *Logger) IsTraceEnabled() bool {
+	return l.level() >= logLevelValueTrace
+}
+
+// IsDebugEnabled checks if the Debug level is enabled for the logger.
+func (l *Logger) IsDebugEnabled() bool {
+	return l.level() >= logLevelValueDebug
+}
+
+// IsInfoEnabled checks if the Info level is enabled for the logger.
+func (l *Logger) IsInfoEnabled() bool {
+	return l.level() >= logLevelValueInfo
+}
+
+// IsWarnEnabled checks if the Warn level is enabled for the logger.
+func (l *Logger) IsWarnEnabled() bool {
+	return l.level() >= logLevelValueWarn
+}
+
+// IsErrorEnabled checks if the Error level is enabled for the logger.
+func (l *Logger) IsErrorEnabled() bool {
+	return l.level() >= logLevelValueError
+}
+
+// IsCriticalEnabled checks if the Critical level is enabled for the logger.
+func (l *Logger) IsCriticalEnabled() bool {
+	return l.level() >= logLevelValueCritical
+}
+
+// isLevelEnabled checks if the given level is enabled for the logger.
+func (l *Logger) isLevelEnabled(level LogLevel) bool {
+	lv, ok := levelMap[level]
+	if !ok {
+		return false
+	}
+
+	return l.level() >= lv
+}
+
+// isLevelEnabledCtx checks if the given level is enabled for the logger or by a
+// log level attached to the context with ContextWithLogLevel.
+func (l *Logger) isLevelEnabledCtx(ctx context.Context, level LogLevel) bool {
+	if l.isLevelEnabled(level) {
+		return true
+	}
+
+	if ctx == nil {
+		return false
+	}
+
+	ctxLevel, ok := ctx.Value(logLevelContextKey{}).(logLevelValue)
+
+	return ok && ctxLevel >= levelMap[level]
+}
+
+// logLevelContextKey is the context key for the log level set by ContextWithLogLevel.
+type logLevelContextKey struct{}
+
+// ContextWithLogLevel returns a copy of ctx carrying a log level that temporarily
+// raises the verbosity of the ...Ctx methods called with it.
+// This is useful for logging a single request flagged for debugging at Debug level
+// while other requests stay at the logger's level.
+// The context level can only enable more levels; it never suppresses entries
+// that the logger's own level allows.
+// It panics if the level is not a valid log level.
+func ContextWithLogLevel(ctx context.Context, level LogLevel) context.Context {
+	lv, ok := levelMap[level]
+	if !ok {
+		panic(fmt.Sprintf("harelog: invalid log level provided to ContextWithLogLevel: %q", level))
+	}
+
+	return context.WithValue(ctx, logLevelContextKey{}, lv)
+}
+
+// WithLogLevel returns a new logger instance with the specified log level.
+func (l *Logger) WithLogLevel(level LogLevel) *Logger {
+	if _, ok := levelMap[level]; !ok {
+		panic(fmt.Sprintf("harelog: invalid log level provided to (*Logger).WithLogLevel: %q", level))
 	}
 
 	newLogger := l.Clone()
@@ -888,16 +1952,42 @@ func (l *Logger) WithLogLevel(level LogLevel) *Logger {
 }
 
 // WithOutput returns a new logger instance that writes to the provided io.Writer.
+// See the WithOutput option for details.
 func (l *Logger) WithOutput(w io.Writer) *Logger {
 	newLogger := l.Clone()
 
 	if w != nil {
-		newLogger.out = w
+		newLogger.setOutput(w)
 	}
 
 	return newLogger
 }
 
+// setOutput sets a single output writer. The logger owns writers created by
+// NewGzipWriter and NewEventLogWriter, so they are registered to be closed by Close,
+// under a sync.Once of their own, as a clone no longer closes the writers of its parent.
+func (l *Logger) setOutput(w io.Writer) {
+	l.out = w
+
+	if c, ok := w.(io.Closer); ok {
+		switch w.(type) {
+		case *gzipWriter, levelWriter:
+			l.closers = []io.Closer{c}
+			l.closeOnce = new(sync.Once)
+		}
+	}
+}
+
+// WithOutputs returns a new logger instance that writes to all of the provided writers.
+// See the WithOutputs option for details.
+func (l *Logger) WithOutputs(writers ...io.Writer) *Logger {
+	newLogger := l.Clone()
+
+	WithOutputs(writers...)(newLogger)
+
+	return newLogger
+}
+
 // WithFormatter returns a new logger instance with the specified formatter.
 func (l *Logger) WithFormatter(f Formatter) *Logger {
 	newLogger := l.Clone()
@@ -909,6 +1999,66 @@ func (l *Logger) WithFormatter(f Formatter) *Logger {
 	return newLogger
 }
 
+// WithRecordSeparator returns a new logger instance that terminates each record with sep.
+func (l *Logger) WithRecordSeparator(sep []byte) *Logger {
+	newLogger := l.Clone()
+
+	if len(sep) > 0 {
+		newLogger.recordSeparator = bytes.Clone(sep)
+	}
+
+	return newLogger
+}
+
+// WithRecordPrefix returns a new logger instance that starts each record with prefix.
+func (l *Logger) WithRecordPrefix(prefix []byte) *Logger {
+	newLogger := l.Clone()
+	newLogger.recordPrefix = bytes.Clone(prefix)
+
+	return newLogger
+}
+
+// WithBuildInfo returns a new logger instance that adds the version and VCS revision
+// of the binary to every entry. See the WithBuildInfo option for details.
+func (l *Logger) WithBuildInfo(enabled bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.applyBuildInfo(enabled)
+
+	return newLogger
+}
+
+// WithServiceContext returns a new logger instance that adds the service name, version
+// and environment to every entry. See the WithServiceContext option for details.
+func (l *Logger) WithServiceContext(service, version, env string) *Logger {
+	newLogger := l.Clone()
+	newLogger.applyServiceContext(service, version, env)
+
+	return newLogger
+}
+
+// WithJournaldPriorityPrefix returns a new logger instance that starts each record
+// with its syslog priority. See the WithJournaldPriorityPrefix option for details.
+func (l *Logger) WithJournaldPriorityPrefix(enabled bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.journaldPriorityPrefix = enabled
+
+	return newLogger
+}
+
+// WithSourceSampling returns a new logger that captures the source location of only
+// every nth entry below the Error level. See the WithSourceSampling option for details.
+func (l *Logger) WithSourceSampling(n int) *Logger {
+	if n < 0 {
+		panic(fmt.Sprintf("harelog: negative rate provided to (*Logger).WithSourceSampling: %d", n))
+	}
+
+	newLogger := l.Clone()
+	newLogger.sourceSampling = n
+	newLogger.sourceSampleCount = new(atomic.Uint64)
+
+	return newLogger
+}
+
 // WithAutoSource returns a new logger with a different source location mode.
 func (l *Logger) WithAutoSource(mode sourceLocationMode) *Logger {
 	if mode < SourceLocationModeNever || mode > SourceLocationModeErrorOrAbove {
@@ -922,22 +2072,368 @@ func (l *Logger) WithAutoSource(mode sourceLocationMode) *Logger {
 	return newLogger
 }
 
+// WithSourceFunctionMode returns a new logger instance that reports the function name
+// of captured source locations in the given mode.
+// See the WithSourceFunctionMode option for details.
+func (l *Logger) WithSourceFunctionMode(mode SourceFunctionMode) *Logger {
+	validateSourceFunctionMode(mode)
+
+	newLogger := l.Clone()
+	newLogger.sourceFunctionMode = mode
+
+	return newLogger
+}
+
+// WithErrorStackExtraction returns a new logger that logs the stack trace recorded by
+// the logged error or not. See the WithErrorStackExtraction option for details.
+func (l *Logger) WithErrorStackExtraction(enabled bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.errorStackExtraction = enabled
+
+	return newLogger
+}
+
+// WithSourceSnippet returns a new logger that adds the source line of the captured
+// source location to its entries or not. See the WithSourceSnippet option for details.
+func (l *Logger) WithSourceSnippet(enabled bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.sourceSnippet = enabled
+
+	return newLogger
+}
+
+// WithPackageLevelOverrides returns a new logger that applies the levels set with
+// SetPackageLevel to the calls from their packages or not.
+// See the WithPackageLevelOverrides option for details.
+func (l *Logger) WithPackageLevelOverrides(enabled bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.packageLevelOverrides = enabled
+
+	return newLogger
+}
+
+// WithCallerSkip returns a new logger that skips the given number of additional
+// caller frames when capturing the source location.
+func (l *Logger) WithCallerSkip(skip int) *Logger {
+	if skip < 0 {
+		panic(fmt.Sprintf("harelog: negative skip provided to (*Logger).WithCallerSkip: %d", skip))
+	}
+
+	newLogger := l.Clone()
+	newLogger.callerSkip = skip
+
+	return newLogger
+}
+
+// WithStrictFormat returns a new logger with strict format checking enabled or disabled.
+func (l *Logger) WithStrictFormat(enabled bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.strictFormat = enabled
+
+	return newLogger
+}
+
+// WithValidateOutput returns a new logger with output validation enabled or disabled.
+func (l *Logger) WithValidateOutput(enabled bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.validateOutput = enabled
+
+	return newLogger
+}
+
+// WithNanoTimestamps returns a new logger with nanosecond timestamps enabled or disabled.
+func (l *Logger) WithNanoTimestamps(enabled bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.nanoTimestamps = enabled
+
+	return newLogger
+}
+
+// WithTimeEncoder returns a new logger with the given timestamp encoder.
+// See the WithTimeEncoder option for details.
+func (l *Logger) WithTimeEncoder(encoder TimeEncoder) *Logger {
+	newLogger := l.Clone()
+	newLogger.timeEncoder = encoder
+
+	return newLogger
+}
+
+// WithSyncOnWrite returns a new logger that syncs its output after each write or not.
+func (l *Logger) WithSyncOnWrite(enabled bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.syncOnWrite = enabled
+
+	return newLogger
+}
+
 // WithProjectID returns a new logger with a different Project ID.
 func (l *Logger) WithProjectID(projectID string) *Logger {
 	newLogger := l.Clone()
-	newLogger.projectID = projectID
+	newLogger.projectID = projectID
+
+	return newLogger
+}
+
+// WithTraceContextKey returns a new logger with a different trace context key.
+func (l *Logger) WithTraceContextKey(key interface{}) *Logger {
+	if key == nil {
+		panic("harelog: nil key provided to WithTraceContextKey; context keys must be non-nil")
+	}
+
+	newLogger := l.Clone()
+	newLogger.traceContextKey = key
+
+	return newLogger
+}
+
+// WithTraceParser returns a new logger with a different trace parser.
+// See the WithTraceParser option for details.
+func (l *Logger) WithTraceParser(parser TraceParser) *Logger {
+	if parser == nil {
+		panic("harelog: nil parser provided to (*Logger).WithTraceParser")
+	}
+
+	newLogger := l.Clone()
+	newLogger.traceParser = parser
+
+	return newLogger
+}
+
+// WithContext returns a new logger instance bound to ctx. Its non-Ctx methods (e.g. Infof)
+// then behave as if ctx were passed to the corresponding ...Ctx method, so trace
+// information, context extractors and ContextWithLogLevel apply to them. This lets
+// middleware bind the request context once, while handlers use the plain methods.
+//
+// A context passed explicitly to a ...Ctx method takes precedence and replaces the
+// bound context; the two are not merged. As the logger keeps ctx, bind only contexts
+// that live as long as the logger, such as a request context for a request-scoped logger.
+// It panics if ctx is nil.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		panic("harelog: nil context provided to (*Logger).WithContext")
+	}
+
+	newLogger := l.Clone()
+	newLogger.baseCtx = ctx
+
+	return newLogger
+}
+
+// boundContext returns the context bound with WithContext, or context.Background.
+func (l *Logger) boundContext() context.Context {
+	if l.baseCtx != nil {
+		return l.baseCtx
+	}
+
+	return context.Background()
+}
+
+// WithContextExtractors returns a new logger instance with the given context extractors added.
+// It panics if an extractor is nil.
+func (l *Logger) WithContextExtractors(extractors ...ContextExtractor) *Logger {
+	for _, extractor := range extractors {
+		if extractor == nil {
+			panic("harelog: nil extractor provided to (*Logger).WithContextExtractors")
+		}
+	}
+
+	newLogger := l.Clone()
+	newLogger.contextExtractors = append(slices.Clip(l.contextExtractors), extractors...)
+
+	return newLogger
+}
+
+// WithDynamicFields returns a new logger instance with a function returning fields
+// to be evaluated for every entry. See the WithDynamicFields option for details.
+// It panics if fn is nil.
+func (l *Logger) WithDynamicFields(fn func() []interface{}) *Logger {
+	if fn == nil {
+		panic("harelog: nil function provided to (*Logger).WithDynamicFields")
+	}
+
+	newLogger := l.Clone()
+	newLogger.dynamicFields = append(slices.Clip(l.dynamicFields), fn)
+
+	return newLogger
+}
+
+// WithExitOnFatal returns a new logger instance whose Fatal methods call os.Exit only
+// if exit is true. See the WithExitOnFatal option for details.
+func (l *Logger) WithExitOnFatal(exit bool) *Logger {
+	newLogger := l.Clone()
+	newLogger.exitOnFatal = exit
+
+	return newLogger
+}
+
+// WithKeyNormalizer returns a new logger instance that renames the payload and label keys
+// of its entries with normalizer. See the WithKeyNormalizer option for details.
+func (l *Logger) WithKeyNormalizer(normalizer func(key string) string) *Logger {
+	if normalizer == nil {
+		panic("harelog: nil normalizer provided to (*Logger).WithKeyNormalizer")
+	}
+
+	newLogger := l.Clone()
+	newLogger.keyNormalizer = normalizer
+
+	return newLogger
+}
+
+// WithRedactor returns a new logger instance that passes each entry through redactor.
+// See the WithRedactor option for details.
+func (l *Logger) WithRedactor(redactor func(*LogEntry) *LogEntry) *Logger {
+	if redactor == nil {
+		panic("harelog: nil redactor provided to (*Logger).WithRedactor")
+	}
+
+	newLogger := l.Clone()
+	newLogger.redactor = redactor
+
+	return newLogger
+}
+
+// WithFormatErrorHandler returns a new logger instance that reports entries its
+// formatter fails to format to handler. See the WithFormatErrorHandler option for details.
+func (l *Logger) WithFormatErrorHandler(handler func(err error, entry *LogEntry)) *Logger {
+	if handler == nil {
+		panic("harelog: nil handler provided to (*Logger).WithFormatErrorHandler")
+	}
+
+	newLogger := l.Clone()
+	newLogger.formatErrorHandler = handler
+
+	return newLogger
+}
+
+// WithInternalErrorHandler returns a new logger instance that passes its own diagnostics
+// to handler. See the WithInternalErrorHandler option for details.
+func (l *Logger) WithInternalErrorHandler(handler func(entry *LogEntry)) *Logger {
+	if handler == nil {
+		panic("harelog: nil handler provided to (*Logger).WithInternalErrorHandler")
+	}
+
+	newLogger := l.Clone()
+	newLogger.internalErrorHandler = handler
+
+	return newLogger
+}
+
+// WithDedup returns a new logger instance that collapses identical consecutive entries.
+// See the WithDedup option for details.
+func (l *Logger) WithDedup(window time.Duration) *Logger {
+	if window <= 0 {
+		panic(fmt.Sprintf("harelog: non-positive window provided to (*Logger).WithDedup: %v", window))
+	}
+
+	newLogger := l.Clone()
+	newLogger.dedup = newDeduper(window)
+
+	return newLogger
+}
+
+// WithDedupKey returns a new logger instance that compares entries for WithDedup by key.
+// See the WithDedupKey option for details.
+func (l *Logger) WithDedupKey(key DedupKey) *Logger {
+	validateDedupKey(key)
+
+	newLogger := l.Clone()
+	newLogger.dedupKey = key
+
+	return newLogger
+}
+
+// WithErrorClassifier returns a new logger instance with the specified error classifier.
+func (l *Logger) WithErrorClassifier(classifier ErrorClassifier) *Logger {
+	newLogger := l.Clone()
+	newLogger.errorClassifier = classifier
+
+	return newLogger
+}
+
+// WithMaxEntrySize returns a new logger instance that limits the size of formatted entries.
+// See the WithMaxEntrySize option for details.
+func (l *Logger) WithMaxEntrySize(size int, strategy TruncationStrategy) *Logger {
+	if size < 0 {
+		panic(fmt.Sprintf("harelog: negative size provided to (*Logger).WithMaxEntrySize: %d", size))
+	}
+
+	validateTruncationStrategy(strategy)
+
+	newLogger := l.Clone()
+	newLogger.maxEntrySize = size
+	newLogger.truncationStrategy = strategy
+
+	return newLogger
+}
+
+// WithMaxFieldValueLength returns a new logger instance that limits the length of
+// payload field values. See the WithMaxFieldValueLength option for details.
+func (l *Logger) WithMaxFieldValueLength(n int) *Logger {
+	if n < 0 {
+		panic(fmt.Sprintf("harelog: negative length provided to (*Logger).WithMaxFieldValueLength: %d", n))
+	}
+
+	newLogger := l.Clone()
+	newLogger.maxFieldValueLength = n
+
+	return newLogger
+}
+
+// WithOnceFields returns a new logger instance that adds the given key-value pairs
+// to its first formatted entry only. See the WithOnceFields option for details.
+func (l *Logger) WithOnceFields(kvs ...interface{}) *Logger {
+	if len(kvs)%2 != 0 {
+		panic("harelog: odd number of arguments provided to (*Logger).WithOnceFields")
+	}
+
+	newLogger := l.Clone()
+	newLogger.setOnceFields(kvs)
+
+	return newLogger
+}
+
+// WithSQLArgMode returns a new logger instance that writes the arguments of SQLQuery
+// values according to mode. See the WithSQLArgMode option for details.
+func (l *Logger) WithSQLArgMode(mode SQLArgMode) *Logger {
+	validateSQLArgMode(mode)
+
+	newLogger := l.Clone()
+	newLogger.sqlArgMode = mode
+
+	return newLogger
+}
+
+// WithMaxFields returns a new logger instance that limits the number of payload fields
+// per entry. See the WithMaxFields option for details.
+func (l *Logger) WithMaxFields(n int) *Logger {
+	if n < 0 {
+		panic(fmt.Sprintf("harelog: negative limit provided to (*Logger).WithMaxFields: %d", n))
+	}
+
+	newLogger := l.Clone()
+	newLogger.maxFields = n
 
 	return newLogger
 }
 
-// WithTraceContextKey returns a new logger with a different trace context key.
-func (l *Logger) WithTraceContextKey(key interface{}) *Logger {
-	if key == nil {
-		panic("harelog: nil key provided to WithTraceContextKey; context keys must be non-nil")
+// WithMisuseErrorKey returns a new logger instance that records misuse errors under key.
+// See the WithMisuseErrorKey option for details.
+func (l *Logger) WithMisuseErrorKey(key string) *Logger {
+	if key == "" {
+		panic("harelog: empty key provided to (*Logger).WithMisuseErrorKey")
 	}
 
 	newLogger := l.Clone()
-	newLogger.traceContextKey = key
+	newLogger.misuseErrorKey = key
+
+	return newLogger
+}
+
+// WithMissingValuePlaceholder returns a new logger instance that logs value for a key
+// without a value. See the WithMissingValuePlaceholder option for details.
+func (l *Logger) WithMissingValuePlaceholder(value string) *Logger {
+	newLogger := l.Clone()
+	newLogger.missingValuePlaceholder = value
 
 	return newLogger
 }
@@ -955,7 +2451,7 @@ func (l *Logger) WithLabels(labels map[string]string) *Logger {
 	newLogger := l.Clone()
 
 	for k, v := range labels {
-		if handleInvalidKey(l, k, "label") {
+		if !acceptLabelKey(l, k) {
 			continue
 		}
 
@@ -965,6 +2461,16 @@ func (l *Logger) WithLabels(labels map[string]string) *Logger {
 	return newLogger
 }
 
+// WithLabelValidation returns a new logger with a different label validation mode.
+func (l *Logger) WithLabelValidation(mode LabelValidationMode) *Logger {
+	validateLabelValidationMode(mode)
+
+	newLogger := l.Clone()
+	newLogger.labelValidation = mode
+
+	return newLogger
+}
+
 // WithoutLabels returns a new logger instance with the provided labels removed.
 func (l *Logger) WithoutLabels(keys ...string) *Logger {
 	newLogger := l.Clone()
@@ -977,17 +2483,26 @@ func (l *Logger) WithoutLabels(keys ...string) *Logger {
 }
 
 // With returns a new logger instance with the provided key-value pairs added to its context.
+// A MetricValue may be passed on its own in place of a key-value pair.
 // It panics if the number of arguments is odd or if a key is not a string.
 func (l *Logger) With(kvs ...interface{}) *Logger {
-	n := len(kvs)
+	newLogger := l.Clone()
 
-	if n%2 != 0 {
-		panic("log.With: odd number of arguments received")
-	}
+	for i := 0; i < len(kvs); i += 2 {
+		if m, ok := kvs[i].(MetricValue); ok {
+			if !handleInvalidKey(l, m.Name, "field") {
+				newLogger.payload[m.Name] = m
+			}
 
-	newLogger := l.Clone()
+			i-- // a metric occupies a single argument
+
+			continue
+		}
+
+		if i == len(kvs)-1 {
+			panic("log.With: odd number of arguments received")
+		}
 
-	for i := 0; i < n; i += 2 {
 		key, ok := kvs[i].(string)
 		if !ok {
 			panic(fmt.Sprintf("log.With: non-string key at argument position %d", i))
@@ -1077,6 +2592,50 @@ func SetDefaultAutoSource(mode sourceLocationMode) {
 	std = std.WithAutoSource(mode)
 }
 
+// Default returns the default logger used by the package-level functions.
+func Default() *Logger {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	return std
+}
+
+// SetDefault atomically replaces the default logger with l, which is useful for
+// installing a fully configured logger instead of calling several SetDefault... functions.
+// The previous default logger's hook worker and concurrent writer are closed after
+// their buffered entries are processed, unless l shares them (e.g. l was derived from
+// Default() via With).
+// Its outputs are not closed, as they may be shared with l.
+// It panics if l is nil.
+func SetDefault(l *Logger) {
+	if l == nil {
+		panic("harelog: nil logger provided to SetDefault")
+	}
+
+	stdMutex.Lock()
+	defer stdMutex.Unlock()
+
+	std.retire(l)
+	std = l
+}
+
+// retire closes the hook worker, the concurrent writer and the write buffer of l,
+// which is replaced by next, after their buffered entries are processed, unless next
+// shares them.
+func (l *Logger) retire(next *Logger) {
+	if l.hookWorker != next.hookWorker {
+		l.closeHooks()
+	}
+
+	if l.concurrentWriter != nil && l.concurrentWriter != next.concurrentWriter {
+		l.concurrentWriter.close()
+	}
+
+	if l.writeBuffer != nil && l.writeBuffer != next.writeBuffer {
+		l.writeBuffer.close()
+	}
+}
+
 // SetDefaultHooks sets hooks for the default logger.
 // This function is safe for concurrent use.
 // It replaces the existing default logger with a new one containing the specified hooks.
@@ -1085,50 +2644,86 @@ func SetDefaultHooks(hooks ...Hook) {
 	defer stdMutex.Unlock()
 
 	// Gracefully close the old logger's worker if it exists.
-	if std.hookChan != nil {
-		_ = std.Close()
-	}
+	// The outputs are carried over to the new logger, so they are not closed.
+	std.closeHooks()
+
+	closers, dedup, concurrentWriter, writeBuffer := std.closers, std.dedup, std.concurrentWriter, std.writeBuffer
+
+	std = std.rebuild(hooks...)
+	std.closers = closers
+	std.dedup = dedup
+	std.concurrentWriter = concurrentWriter
+	std.writeBuffer = writeBuffer
+}
+
+// NewFromDefault creates a new logger with the configuration of the default logger,
+// such as its level, formatter, output, labels, fields, prefix, trace context key and
+// project ID. This function is safe for concurrent use.
+//
+// Unlike a Clone of the default logger, the new logger is independent: its hooks run
+// on a hook worker of its own, and it has its own dedup window (see WithDedup), so
+// closing it affects neither the default logger nor the loggers derived from it.
+// Closing it does not close the outputs of the default logger either. This lets
+// libraries derive a logger from whatever the application configured as the default.
+func NewFromDefault() *Logger {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
 
-	// --- Preserve existing settings ---
-	// Find the current LogLevel string from the internal logLevelValue.
-	var currentLevel LogLevel = LogLevelInfo // Default fallback
-	stdLogLevel := std.logLevel.Load()
+	l := std.rebuild(std.hooks...)
 
-	for l, v := range levelMap {
-		if uint32(v) == stdLogLevel {
-			currentLevel = l
-			break
-		}
+	if std.dedup != nil {
+		l.dedup = newDeduper(std.dedup.window)
 	}
 
-	// Convert payload map to a slice for WithFields.
-	payloadKVs := make([]interface{}, 0, len(std.payload)*2)
-
-	for k, v := range std.payload {
-		payloadKVs = append(payloadKVs, k, v)
+	if l.writerShards > 0 {
+		l.concurrentWriter = newConcurrentWriter(l.writerShards)
 	}
 
-	opts := []Option{
-		WithOutput(std.out),
-		WithLogLevel(currentLevel),
-		WithFormatter(std.formatter),
-		WithAutoSource(std.sourceLocationMode),
-		WithProjectID(std.projectID),
-		WithPrefix(std.prefix),
-		WithLabels(std.labels),
-		WithFields(payloadKVs...),
-		WithHookBufferSize(std.hookBufferSize),
-		WithHooks(hooks...),
+	if l.writeBufferSize > 0 {
+		l.writeBuffer = newWriteBuffer(l.writeBufferSize, l.writeBufferInterval)
 	}
 
-	// WithTraceContextKey panics on nil, so only add it if it exists.
-	if std.traceContextKey != nil {
-		opts = append(opts, WithTraceContextKey(std.traceContextKey))
-	}
-	// --- End of preserving settings ---
+	return l
+}
+
+// rebuild creates a new logger with l's configuration and the given hooks, which get
+// a hook worker of their own. The configuration is copied as a whole, like Clone does;
+// only the resources of the logger instance are reset: the closers, the dedup window,
+// the concurrent writer and the write buffer are not carried over, and the new logger
+// is closed independently of l.
+func (l *Logger) rebuild(hooks ...Hook) *Logger {
+	newLogger := l.Clone()
+
+	newLogger.closers = nil
+	newLogger.closeOnce = new(sync.Once)
+	newLogger.dedup = nil
+	newLogger.concurrentWriter = nil
+	newLogger.writeBuffer = nil
+
+	newLogger.hooks = slices.Clone(hooks)
+	newLogger.hooksByLevel = nil
+	newLogger.hookWorker = nil
+	newLogger.startHooks()
+
+	return newLogger
+}
+
+// SetDefaultBuildInfo adds the build information of the binary to the entries of the
+// default logger, or removes it if enabled is false. See WithBuildInfo for details.
+func SetDefaultBuildInfo(enabled bool) {
+	stdMutex.Lock()
+	defer stdMutex.Unlock()
+
+	std = std.WithBuildInfo(enabled)
+}
 
-	// Create a new logger with the new hooks, preserving all other settings.
-	std = New(opts...)
+// SetDefaultServiceContext adds the service name, version and environment to the entries
+// of the default logger. See WithServiceContext for details.
+func SetDefaultServiceContext(service, version, env string) {
+	stdMutex.Lock()
+	defer stdMutex.Unlock()
+
+	std = std.WithServiceContext(service, version, env)
 }
 
 // WithProjectID sets the initial Google Cloud Project ID.
@@ -1172,6 +2767,11 @@ func RemoveDefaultLabels(keys ...string) {
 	std = std.WithoutLabels(keys...)
 }
 
+// IsTraceEnabled checks if the Trace level is enabled for the default logger.
+func IsTraceEnabled() bool {
+	return std.IsTraceEnabled()
+}
+
 // IsDebugEnabled checks if the Debug level is enabled for the default logger.
 func IsDebugEnabled() bool {
 	return std.IsDebugEnabled()
@@ -1197,6 +2797,16 @@ func IsCriticalEnabled() bool {
 	return std.IsCriticalEnabled()
 }
 
+// TracefCtx logs a formatted message at the Trace level using the default logger.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func TracefCtx(ctx context.Context, format string, v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.TracefCtx(ctx, format, v...)
+}
+
 // DebugfCtx logs a formatted message at the Debug level using the default logger.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
@@ -1247,6 +2857,132 @@ func CriticalfCtx(ctx context.Context, format string, v ...interface{}) {
 	std.CriticalfCtx(ctx, format, v...)
 }
 
+// TracelnCtx logs its arguments at the Trace level using the default logger, like log.Println.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func TracelnCtx(ctx context.Context, v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.TracelnCtx(ctx, v...)
+}
+
+// DebuglnCtx logs its arguments at the Debug level using the default logger, like log.Println.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func DebuglnCtx(ctx context.Context, v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.DebuglnCtx(ctx, v...)
+}
+
+// InfolnCtx logs its arguments at the Info level using the default logger, like log.Println.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func InfolnCtx(ctx context.Context, v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.InfolnCtx(ctx, v...)
+}
+
+// WarnlnCtx logs its arguments at the Warn level using the default logger, like log.Println.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func WarnlnCtx(ctx context.Context, v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.WarnlnCtx(ctx, v...)
+}
+
+// ErrorlnCtx logs its arguments at the Error level using the default logger, like log.Println.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func ErrorlnCtx(ctx context.Context, v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.ErrorlnCtx(ctx, v...)
+}
+
+// CriticallnCtx logs its arguments at the Critical level using the default logger, like log.Println.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func CriticallnCtx(ctx context.Context, v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.CriticallnCtx(ctx, v...)
+}
+
+// TraceFuncCtx logs the message returned by fn at the Trace level using the default logger,
+// calling fn only if the level is enabled.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func TraceFuncCtx(ctx context.Context, fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.TraceFuncCtx(ctx, fn)
+}
+
+// DebugFuncCtx logs the message returned by fn at the Debug level using the default logger,
+// calling fn only if the level is enabled.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func DebugFuncCtx(ctx context.Context, fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.DebugFuncCtx(ctx, fn)
+}
+
+// InfoFuncCtx logs the message returned by fn at the Info level using the default logger,
+// calling fn only if the level is enabled.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func InfoFuncCtx(ctx context.Context, fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.InfoFuncCtx(ctx, fn)
+}
+
+// WarnFuncCtx logs the message returned by fn at the Warn level using the default logger,
+// calling fn only if the level is enabled.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func WarnFuncCtx(ctx context.Context, fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.WarnFuncCtx(ctx, fn)
+}
+
+// ErrorFuncCtx logs the message returned by fn at the Error level using the default logger,
+// calling fn only if the level is enabled.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func ErrorFuncCtx(ctx context.Context, fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.ErrorFuncCtx(ctx, fn)
+}
+
+// CriticalFuncCtx logs the message returned by fn at the Critical level using the default logger,
+// calling fn only if the level is enabled.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func CriticalFuncCtx(ctx context.Context, fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.CriticalFuncCtx(ctx, fn)
+}
+
 // PrintfCtx logs a formatted message at the Info level using the default logger.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
@@ -1307,6 +3043,16 @@ func FatallnCtx(ctx context.Context, v ...interface{}) {
 	std.FatallnCtx(ctx, v...)
 }
 
+// TracewCtx logs a message at the Trace level using the default logger.
+// It extracts values from the provided context, such as Google Cloud Trace identifiers,
+// and includes them in the log entry.
+func TracewCtx(ctx context.Context, msg string, kvs ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.TracewCtx(ctx, msg, kvs...)
+}
+
 // DebugwCtx logs a message at the Debug level using the default logger.
 // It extracts values from the provided context, such as Google Cloud Trace identifiers,
 // and includes them in the log entry.
@@ -1368,6 +3114,14 @@ func FatalwCtx(ctx context.Context, msg string, kvs ...interface{}) {
 	std.FatalwCtx(ctx, msg, kvs...)
 }
 
+// Tracef logs a formatted message at the Trace level using the default logger.
+func Tracef(format string, v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Tracef(format, v...)
+}
+
 // Debugf logs a formatted message at the Debug level using the default logger.
 func Debugf(format string, v ...interface{}) {
 	stdMutex.RLock()
@@ -1408,6 +3162,108 @@ func Criticalf(format string, v ...interface{}) {
 	std.Criticalf(format, v...)
 }
 
+// Traceln logs its arguments at the Trace level using the default logger, like log.Println.
+func Traceln(v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Traceln(v...)
+}
+
+// Debugln logs its arguments at the Debug level using the default logger, like log.Println.
+func Debugln(v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Debugln(v...)
+}
+
+// Infoln logs its arguments at the Info level using the default logger, like log.Println.
+func Infoln(v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Infoln(v...)
+}
+
+// Warnln logs its arguments at the Warn level using the default logger, like log.Println.
+func Warnln(v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Warnln(v...)
+}
+
+// Errorln logs its arguments at the Error level using the default logger, like log.Println.
+func Errorln(v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Errorln(v...)
+}
+
+// Criticalln logs its arguments at the Critical level using the default logger, like log.Println.
+func Criticalln(v ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Criticalln(v...)
+}
+
+// TraceFunc logs the message returned by fn at the Trace level using the default logger,
+// calling fn only if the level is enabled.
+func TraceFunc(fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.TraceFunc(fn)
+}
+
+// DebugFunc logs the message returned by fn at the Debug level using the default logger,
+// calling fn only if the level is enabled.
+func DebugFunc(fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.DebugFunc(fn)
+}
+
+// InfoFunc logs the message returned by fn at the Info level using the default logger,
+// calling fn only if the level is enabled.
+func InfoFunc(fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.InfoFunc(fn)
+}
+
+// WarnFunc logs the message returned by fn at the Warn level using the default logger,
+// calling fn only if the level is enabled.
+func WarnFunc(fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.WarnFunc(fn)
+}
+
+// ErrorFunc logs the message returned by fn at the Error level using the default logger,
+// calling fn only if the level is enabled.
+func ErrorFunc(fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.ErrorFunc(fn)
+}
+
+// CriticalFunc logs the message returned by fn at the Critical level using the default logger,
+// calling fn only if the level is enabled.
+func CriticalFunc(fn func() string) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.CriticalFunc(fn)
+}
+
 // Printf logs a formatted message at the Info level using the default logger.
 func Printf(format string, v ...interface{}) {
 	stdMutex.RLock()
@@ -1456,6 +3312,14 @@ func Fatalln(v ...interface{}) {
 	std.Fatalln(v...)
 }
 
+// Tracew logs a message at the Trace level using the default logger.
+func Tracew(msg string, kvs ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.Tracew(msg, kvs...)
+}
+
 // Debugw logs a message at the Debug level using the default logger.
 func Debugw(msg string, kvs ...interface{}) {
 	stdMutex.RLock()
@@ -1505,14 +3369,31 @@ func Fatalw(msg string, kvs ...interface{}) {
 	std.Fatalw(msg, kvs...)
 }
 
-// Close gracefully shuts down the default logger's background processes,
-// such as the hook worker. It's recommended to call this via defer in the main function
-// to ensure all buffered logs for hooks are processed before the application exits.
-func Close() error {
+// Close gracefully shuts down the default logger's background processes,
+// such as the hook worker. It's recommended to call this via defer in the main function
+// to ensure all buffered logs for hooks are processed before the application exits.
+func Close() error {
+	stdMutex.Lock()
+	defer stdMutex.Unlock()
+
+	return std.Close()
+}
+
+// HookQueueLen returns the number of entries buffered for the default logger's hooks.
+func HookQueueLen() int {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	return std.HookQueueLen()
+}
+
+// CloseContext is like Close for the default logger, but stops waiting for
+// the hook worker when ctx is done. See (*Logger).CloseContext for details.
+func CloseContext(ctx context.Context) error {
 	stdMutex.Lock()
 	defer stdMutex.Unlock()
 
-	return std.Close()
+	return std.CloseContext(ctx)
 }
 
 // sprintMessage builds a string from a slice of interfaces, separated by spaces.
@@ -1550,11 +3431,48 @@ func WithLogLevel(level LogLevel) Option {
 }
 
 // WithOutput sets the writer for the logger.
+// A writer created by NewGzipWriter is closed by Close, which finalizes the gzip stream,
+// as is a writer created by NewEventLogWriter.
 func WithOutput(w io.Writer) Option {
 	return func(l *Logger) {
 		if w != nil {
-			l.out = w
+			l.setOutput(w)
+		}
+	}
+}
+
+// WithOutputs sets multiple writers for the logger, e.g. both os.Stdout and a file.
+// Every writer receives the same formatted bytes; writes are serialized by the logger,
+// so the writers do not need to be safe for concurrent use. Nil writers are ignored.
+// Writers implementing io.Closer (except os.Stdout and os.Stderr) are closed by Close.
+func WithOutputs(writers ...io.Writer) Option {
+	return func(l *Logger) {
+		outs := make([]io.Writer, 0, len(writers))
+		closers := make([]io.Closer, 0, len(writers))
+
+		for _, w := range writers {
+			if w == nil {
+				continue
+			}
+
+			outs = append(outs, w)
+
+			if c, ok := w.(io.Closer); ok && w != os.Stdout && w != os.Stderr {
+				closers = append(closers, c)
+			}
 		}
+
+		switch len(outs) {
+		case 0:
+			return
+		case 1:
+			l.out = outs[0]
+		default:
+			l.out = io.MultiWriter(outs...)
+		}
+
+		l.closers = closers
+		l.closeOnce = new(sync.Once)
 	}
 }
 
@@ -1567,6 +3485,77 @@ func WithFormatter(f Formatter) Option {
 	}
 }
 
+// WithRecordSeparator sets the byte sequence written after each formatted record.
+// The default is a single newline ("\n"). A nil or empty separator is ignored.
+func WithRecordSeparator(sep []byte) Option {
+	return func(l *Logger) {
+		if len(sep) > 0 {
+			l.recordSeparator = bytes.Clone(sep)
+		}
+	}
+}
+
+// WithRecordPrefix sets the byte sequence written before each formatted record.
+// For example, use WithRecordPrefix([]byte{0x1e}) together with the JSON formatter
+// to emit RFC 7464 JSON text sequences. The default is no prefix.
+func WithRecordPrefix(prefix []byte) Option {
+	return func(l *Logger) {
+		l.recordPrefix = bytes.Clone(prefix)
+	}
+}
+
+// WithBuildInfo is a functional option that adds the build information of the binary
+// to every entry, for correlating logs with releases: the main module version as
+// "version" and the VCS revision as "revision". They are read with
+// runtime/debug.ReadBuildInfo once and added as fields, like WithFields. Values that
+// are not embedded in the binary, such as the revision of a build outside of a VCS
+// checkout, are omitted. Setting it to false removes the fields again.
+func WithBuildInfo(enabled bool) Option {
+	return func(l *Logger) {
+		l.applyBuildInfo(enabled)
+	}
+}
+
+// WithServiceContext is a functional option that adds the identity of the service to
+// every entry as the fields "service", "version" and "env", for filtering the logs of
+// many services in one backend. They are added like WithFields, so they are inherited
+// by derived loggers and can be overridden by With and by the fields of a log call.
+// Empty values are not added.
+// The "version" field is the same as that of WithBuildInfo; the last option applied wins.
+func WithServiceContext(service, version, env string) Option {
+	return func(l *Logger) {
+		l.applyServiceContext(service, version, env)
+	}
+}
+
+// WithJournaldPriorityPrefix enables a syslog priority prefix such as "<3>" at the start
+// of each record, before any record prefix. systemd-journald reads it (as described in
+// sd-daemon(3)) to set the PRIORITY of lines written to stdout or stderr, so entries
+// are categorized by severity without a dedicated formatter. See journaldPriority for
+// the mapping from LogLevel to priority. The default is false.
+func WithJournaldPriorityPrefix(enabled bool) Option {
+	return func(l *Logger) {
+		l.journaldPriorityPrefix = enabled
+	}
+}
+
+// WithSourceSampling is a functional option that reduces the cost of SourceLocationModeAlways
+// under load by capturing the source location of only the first of every n entries below
+// the Error level. Entries at the Error level and above, and entries with a "_source"
+// override, always get their location as configured. The count is shared by the loggers
+// derived from this one.
+// A value of 0 or 1 captures every entry, which is the default. It panics if n is negative.
+func WithSourceSampling(n int) Option {
+	if n < 0 {
+		panic(fmt.Sprintf("harelog: negative rate provided to WithSourceSampling: %d", n))
+	}
+
+	return func(l *Logger) {
+		l.sourceSampling = n
+		l.sourceSampleCount = new(atomic.Uint64)
+	}
+}
+
 // WithAutoSource is a functional option that configures the logger's behavior for
 // automatically capturing the source code location (file, line, function name).
 // Note: Enabling this feature, especially with SourceLocationModeAlways, has a
@@ -1582,6 +3571,181 @@ func WithAutoSource(mode sourceLocationMode) Option {
 	}
 }
 
+// WithSourceFunctionMode is a functional option that sets how the function name of
+// a source location captured by WithAutoSource is reported. Cloud Logging displays
+// the fully qualified name verbatim, which is verbose; SourceFunctionModeShort keeps
+// only the package name and the function. The default is SourceFunctionModeFull.
+// It panics if the mode is unknown.
+func WithSourceFunctionMode(mode SourceFunctionMode) Option {
+	validateSourceFunctionMode(mode)
+
+	return func(l *Logger) {
+		l.sourceFunctionMode = mode
+	}
+}
+
+// WithSourceSnippet is a functional option that adds the source line that logged an
+// entry, read from the file of its source location, to the entry as the "sourceSnippet"
+// field, e.g. `logger.Infow("user created", "id", id)`. It applies only to entries
+// with a source location (see WithAutoSource), and the field is left out if the file
+// cannot be read, as when the binary runs away from its source tree.
+//
+// The file is read for every such entry, so it is meant for local debugging only.
+// It is off by default.
+func WithSourceSnippet(enabled bool) Option {
+	return func(l *Logger) {
+		l.sourceSnippet = enabled
+	}
+}
+
+// validateSourceFunctionMode panics if the mode is unknown.
+func validateSourceFunctionMode(mode SourceFunctionMode) {
+	switch mode {
+	case SourceFunctionModeFull, SourceFunctionModeShort:
+	default:
+		panic(fmt.Sprintf("harelog: invalid SourceFunctionMode provided: %d", mode))
+	}
+}
+
+// WithCallerSkip is a functional option that sets the number of additional caller
+// frames to skip when capturing the source location automatically.
+// Frames inside harelog itself are always skipped, regardless of which logging method
+// was called, so skip only needs to count the frames of your own wrapper functions.
+// For example, a library exposing its own Info function that calls (*Logger).Infow
+// should use WithCallerSkip(1) to report the call site of its Info function.
+func WithCallerSkip(skip int) Option {
+	if skip < 0 {
+		panic(fmt.Sprintf("harelog: negative skip provided to WithCallerSkip: %d", skip))
+	}
+
+	return func(l *Logger) {
+		l.callerSkip = skip
+	}
+}
+
+// WithPackageLevelOverrides is a functional option that applies the levels set with
+// SetPackageLevel: a log call, or a level check such as IsDebugEnabled, made from one
+// of those packages uses the level of its package instead of the logger's level.
+// The package is that of the caller as reported in the source location, so
+// WithCallerSkip applies to it as well. A ContextWithLogLevel level still enables
+// more verbose levels.
+//
+// Finding the caller's package costs a stack walk, a few microseconds, on every level
+// check, including the checks of disabled levels, as long as a package level is set.
+// It is off by default.
+func WithPackageLevelOverrides(enabled bool) Option {
+	return func(l *Logger) {
+		l.packageLevelOverrides = enabled
+	}
+}
+
+// WithStackTrace is a functional option that captures the stack trace of the
+// calling goroutine for entries at the given level or more severe, and logs it
+// under the "stack_trace" key. Frames inside harelog are always omitted.
+// Use LogLevelOff to disable it, which is the default.
+// Note: Capturing a stack trace has a significant performance cost.
+func WithStackTrace(level LogLevel) Option {
+	lv, ok := levelMap[level]
+	if !ok || level == LogLevelAll {
+		panic(fmt.Sprintf("harelog: invalid log level provided to WithStackTrace: %q", level))
+	}
+
+	return func(l *Logger) {
+		l.stackTraceLevel = lv
+	}
+}
+
+// WithErrorStackExtraction is a functional option that logs the stack trace recorded by
+// the error under the "error" key, such as an error of github.com/pkg/errors, under the
+// "stack_trace" key. The error, or one it wraps, must have a StackTrace method returning
+// a slice of program counters, like pkg/errors' StackTrace() errors.StackTrace; if several
+// wrapped errors have one, the innermost is used. Errors joined by errors.Join, or wrapped
+// by fmt.Errorf with several %w verbs, are searched too, the first of them first. The stack is formatted like those
+// of WithStackTrace, with WithMinifiedStacktrace and WithStackTraceFilter applied, and
+// takes precedence over a stack captured by WithStackTrace.
+// It is off by default.
+func WithErrorStackExtraction(enabled bool) Option {
+	return func(l *Logger) {
+		l.errorStackExtraction = enabled
+	}
+}
+
+// WithMinifiedStacktrace is a functional option that trims the runtime frames
+// above main.main (or above a goroutine's function) from captured stack traces.
+func WithMinifiedStacktrace() Option {
+	return func(l *Logger) {
+		l.minifyStackTrace = true
+	}
+}
+
+// WithStackTraceFilter is a functional option that sets a filter for the frames of
+// captured stack traces, e.g. to drop vendor or runtime frames.
+// Frames for which filter returns false are omitted.
+func WithStackTraceFilter(filter StackTraceFilter) Option {
+	return func(l *Logger) {
+		l.stackTraceFilter = filter
+	}
+}
+
+// WithStrictFormat is a functional option that enables verb/argument checking in the
+// ...f methods. When a mismatch is detected (e.g. Printf("%d") with no arguments),
+// the format string is logged as-is and a logging_error field (see WithMisuseErrorKey)
+// describes the mismatch, instead of embedding fmt's "%!d(MISSING)" noise in the message.
+// Note: Enabling this adds a small cost for parsing the format string on every call.
+func WithStrictFormat(enabled bool) Option {
+	return func(l *Logger) {
+		l.strictFormat = enabled
+	}
+}
+
+// WithValidateOutput is a debug option that verifies every formatted record before
+// it is written. If the record is malformed, a warning is printed to os.Stderr;
+// the record itself is still written.
+// Validation is supported for the JSON formatter (valid JSON) and the Logfmt formatter
+// (well-formed key=value pairs on a single line); it is a no-op for other formatters.
+// It is off by default, as it roughly doubles the formatting cost, and is intended
+// for tests and staging environments.
+func WithValidateOutput(enabled bool) Option {
+	return func(l *Logger) {
+		l.validateOutput = enabled
+	}
+}
+
+// WithNanoTimestamps makes all formatters write RFC 3339 timestamps with nanosecond
+// precision (time.RFC3339Nano), including in FormatMessageOnly, so that the order of
+// entries within a second stays visible. The default is second precision (time.RFC3339);
+// the JSON formatter keeps nanosecond precision in its "timestamp" field either way.
+// Epoch encodings set with WithTimeEncoding are not affected.
+func WithNanoTimestamps(enabled bool) Option {
+	return func(l *Logger) {
+		l.nanoTimestamps = enabled
+	}
+}
+
+// WithTimeEncoder sets a function that encodes the timestamps written by all formatters,
+// including in FormatMessageOnly, for full control over their bytes, e.g. an epoch with
+// a specific fractional precision. It takes precedence over WithNanoTimestamps and the
+// WithTimeEncoding options of the formatters. The JSON formatter writes the encoding as
+// a number if it is a valid JSON number, and as a string otherwise.
+// A nil encoder restores the default encoding of each formatter, which is the default.
+func WithTimeEncoder(encoder TimeEncoder) Option {
+	return func(l *Logger) {
+		l.timeEncoder = encoder
+	}
+}
+
+// WithSyncOnWrite makes the logger call Sync on its output after each record is written,
+// if the output implements Sync() error like *os.File does. Records are then on stable
+// storage when a hard crash or power loss follows, instead of in the OS buffers.
+// It is off by default: a sync waits for the storage device and typically costs
+// milliseconds per record, reducing throughput by orders of magnitude. Consider it
+// only for low-volume, crash-critical logs; it has no effect on other outputs.
+func WithSyncOnWrite(enabled bool) Option {
+	return func(l *Logger) {
+		l.syncOnWrite = enabled
+	}
+}
+
 // WithProjectID sets the Google Cloud Project ID to be used for formatting trace identifiers.
 func WithProjectID(id string) Option {
 	return func(l *Logger) {
@@ -1600,6 +3764,286 @@ func WithTraceContextKey(key interface{}) Option {
 	}
 }
 
+// WithTraceParser sets the function that extracts trace data from the value stored
+// under the trace context key. By default, the value must be a string in the format
+// of the X-Cloud-Trace-Context header ("TRACE_ID/SPAN_ID;o=OPTIONS"); a parser lets
+// frameworks that store a structured trace object work as well. It panics if parser is nil.
+func WithTraceParser(parser TraceParser) Option {
+	if parser == nil {
+		panic("harelog: nil parser provided to WithTraceParser")
+	}
+
+	return func(l *Logger) {
+		l.traceParser = parser
+	}
+}
+
+// WithContextExtractors is a functional option that adds extractors deriving
+// log fields from the context.Context passed to the ...Ctx methods.
+// It panics if an extractor is nil.
+func WithContextExtractors(extractors ...ContextExtractor) Option {
+	for _, extractor := range extractors {
+		if extractor == nil {
+			panic("harelog: nil extractor provided to WithContextExtractors")
+		}
+	}
+
+	return func(l *Logger) {
+		l.contextExtractors = append(slices.Clip(l.contextExtractors), extractors...)
+	}
+}
+
+// WithDynamicFields is a functional option that adds a function returning fields
+// to be evaluated for every entry, such as the current number of in-flight requests.
+// Unlike WithFields, whose values are fixed, fn is called each time an entry is logged,
+// so it must be cheap and safe for concurrent use.
+//
+// Dynamic fields take precedence over the fields extracted from the context and
+// the fields added via WithFields or With, but are overridden by the fields of the log call.
+// It panics if fn is nil.
+func WithDynamicFields(fn func() []interface{}) Option {
+	if fn == nil {
+		panic("harelog: nil function provided to WithDynamicFields")
+	}
+
+	return func(l *Logger) {
+		l.dynamicFields = append(slices.Clip(l.dynamicFields), fn)
+	}
+}
+
+// WithExitOnFatal is a functional option that sets whether the Fatal methods call
+// os.Exit(1) after logging, which is the default. With false, they only log the entry at
+// the Critical level and return, for tests and for libraries that must not end the host
+// process. This changes the long-established semantics of Fatal, which callers may rely
+// on to stop, so it is opt-in; code after a Fatal call runs when it is disabled.
+func WithExitOnFatal(exit bool) Option {
+	return func(l *Logger) {
+		l.exitOnFatal = exit
+	}
+}
+
+// WithKeyNormalizer is a functional option that renames the payload and label keys of
+// each entry with normalizer, to enforce a naming convention such as the SnakeCase or
+// CamelCase presets without changing the call sites:
+//
+//	logger := harelog.New(harelog.WithKeyNormalizer(harelog.SnakeCase))
+//	logger.Infow("login", "userID", id) // "user_id":...
+//
+// The keys are renamed before the redactor and the hooks see the entry, including the
+// keys harelog adds, such as "stack_trace", and the keys read by formatters, such as the
+// "referer" of AccessLogFormatter. If several keys are renamed to the same key, the key
+// that already has the normalized name is kept, or else the key that sorts first; the
+// others are dropped. A key normalized to "" is dropped. It panics if normalizer is nil.
+func WithKeyNormalizer(normalizer func(key string) string) Option {
+	if normalizer == nil {
+		panic("harelog: nil normalizer provided to WithKeyNormalizer")
+	}
+
+	return func(l *Logger) {
+		l.keyNormalizer = normalizer
+	}
+}
+
+// WithRedactor is a functional option that sets a policy function applied to every
+// entry after it is created and before it is passed to the hooks and formatted.
+// Unlike the key-based masking of the formatters, it sees the whole entry, so it can
+// implement rules across fields, such as redacting "address" only when "region" is "EU".
+//
+// The redactor may modify the entry in place and return it, or return a new entry.
+// Returning nil drops the entry. The entry is reused after the call, so the redactor
+// must not retain references to it or to its maps. It panics if redactor is nil.
+func WithRedactor(redactor func(*LogEntry) *LogEntry) Option {
+	if redactor == nil {
+		panic("harelog: nil redactor provided to WithRedactor")
+	}
+
+	return func(l *Logger) {
+		l.redactor = redactor
+	}
+}
+
+// WithFormatErrorHandler is a functional option that sets the function called when
+// the formatter returns an error for an entry. The entry is dropped either way. By default, the error is
+// printed with the standard library's log package.
+//
+// The handler is called synchronously while the output is locked, so it must not log
+// with the same logger. The entry is reused after the call, so the handler must not
+// retain references to it or to its maps. It panics if handler is nil.
+func WithFormatErrorHandler(handler func(err error, entry *LogEntry)) Option {
+	if handler == nil {
+		panic("harelog: nil handler provided to WithFormatErrorHandler")
+	}
+
+	return func(l *Logger) {
+		l.formatErrorHandler = handler
+	}
+}
+
+// WithInternalErrorHandler is a functional option that sets the function receiving the
+// diagnostics harelog generates about itself, as entries, instead of writing them to
+// os.Stderr or the standard library's log package: warnings about invalid keys and
+// invalid formatter output (WARN), hooks that panicked (ERROR, see PanicEntry) and, unless
+// WithFormatErrorHandler is set, entries the formatter failed to format (ERROR, with the
+// "error" and "entry.message" fields). By default, the diagnostics are printed as before.
+//
+// The handler may be called synchronously while the output is locked, or from the hook
+// worker, so it must not log with the same logger. The entries are not reused and may be
+// retained. It panics if handler is nil.
+func WithInternalErrorHandler(handler func(entry *LogEntry)) Option {
+	if handler == nil {
+		panic("harelog: nil handler provided to WithInternalErrorHandler")
+	}
+
+	return func(l *Logger) {
+		l.internalErrorHandler = handler
+	}
+}
+
+// WithDedup is a functional option that collapses identical consecutive entries,
+// like the "message repeated N times" of syslog, to reduce the noise of tight retry loops.
+// The first entry is written and starts a window of the given duration; identical
+// entries within the window are suppressed. When a different entry arrives or the
+// window closes, an entry "last message repeated N times" with a "repeated" field
+// is written at the same level, if any entry was suppressed.
+//
+// Only the output is deduplicated; hooks still receive every entry. Entries of the
+// Fatal methods are never suppressed, and Close writes the pending summary.
+// The logger and the loggers derived from it share the window.
+// It panics if window is not positive.
+func WithDedup(window time.Duration) Option {
+	if window <= 0 {
+		panic(fmt.Sprintf("harelog: non-positive window provided to WithDedup: %v", window))
+	}
+
+	return func(l *Logger) {
+		l.dedup = newDeduper(window)
+	}
+}
+
+// WithDedupKey is a functional option that sets what WithDedup compares to detect
+// identical entries. The default, DedupKeyOutput, compares the formatted output
+// without the timestamp. It panics if the key is unknown.
+func WithDedupKey(key DedupKey) Option {
+	validateDedupKey(key)
+
+	return func(l *Logger) {
+		l.dedupKey = key
+	}
+}
+
+// WithErrorClassifier is a functional option that sets the function choosing the
+// level at which LogError logs an error. By default, errors implementing
+// SeverityError are logged at their own level and all others at LogLevelError.
+func WithErrorClassifier(classifier ErrorClassifier) Option {
+	return func(l *Logger) {
+		l.errorClassifier = classifier
+	}
+}
+
+// WithMaxEntrySize is a functional option that limits the size in bytes of a
+// formatted entry, excluding the record prefix and separator. An entry exceeding it
+// is shrunk according to strategy, re-formatted, and marked with truncated=true.
+// This prevents backends such as Cloud Logging (about 256KB per entry) from silently
+// dropping the entry. A size of 0 disables the limit, which is the default.
+// It panics if size is negative or the strategy is unknown.
+func WithMaxEntrySize(size int, strategy TruncationStrategy) Option {
+	if size < 0 {
+		panic(fmt.Sprintf("harelog: negative size provided to WithMaxEntrySize: %d", size))
+	}
+
+	validateTruncationStrategy(strategy)
+
+	return func(l *Logger) {
+		l.maxEntrySize = size
+		l.truncationStrategy = strategy
+	}
+}
+
+// WithMaxFieldValueLength is a functional option that limits the length in bytes of
+// payload field values, for any formatter. A string value longer than n is cut to n
+// bytes, at a UTF-8 character boundary, and "...(truncated)" is appended. Other values,
+// except booleans and numbers, are replaced by their fmt.Sprint form, truncated the
+// same way, if that form is longer than n. Unlike WithMaxEntrySize, the other fields of the entry are kept as they are.
+// A length of 0 disables the limit, which is the default. It panics if n is negative.
+func WithMaxFieldValueLength(n int) Option {
+	if n < 0 {
+		panic(fmt.Sprintf("harelog: negative length provided to WithMaxFieldValueLength: %d", n))
+	}
+
+	return func(l *Logger) {
+		l.maxFieldValueLength = n
+	}
+}
+
+// WithOnceFields is a functional option that adds the given key-value pairs, such as a
+// dump of the configuration, to the first entry written by the logger only, instead of
+// to every entry like WithFields. The fields are added when the first entry is formatted,
+// after the level, the redactor and the hooks, and fields of the same keys in the entry
+// take precedence. The logger and the loggers derived from it share the first entry:
+// when they log concurrently, exactly one of the entries gets the fields. If that entry
+// fails to be formatted, the fields are not written again.
+// It panics if the number of arguments is odd or if a key is not a string.
+func WithOnceFields(kvs ...interface{}) Option {
+	if len(kvs)%2 != 0 {
+		panic("harelog: odd number of arguments provided to WithOnceFields")
+	}
+
+	return func(l *Logger) {
+		l.setOnceFields(kvs)
+	}
+}
+
+// WithSQLArgMode is a functional option that sets how the arguments of SQLQuery
+// payload values are written: redacted as "[MASKED]" (SQLArgModeRedact, the default),
+// as their Go types (SQLArgModeTypes), or as they are (SQLArgModeValues).
+// The query itself is always written. It panics if mode is unknown.
+func WithSQLArgMode(mode SQLArgMode) Option {
+	validateSQLArgMode(mode)
+
+	return func(l *Logger) {
+		l.sqlArgMode = mode
+	}
+}
+
+// WithMaxFields is a functional option that limits the number of payload fields
+// per entry, protecting the backend and memory from call sites that add fields in a loop.
+// Fields are kept in the order they are applied (fields from the context, from
+// WithFields and With, dynamic fields, then the fields of the log call); once the
+// limit is reached, further new fields are dropped and fields_truncated=true is added.
+// The "error" field and the special fields such as "httpRequest" are always kept.
+// A limit of 0 disables it, which is the default. It panics if n is negative.
+func WithMaxFields(n int) Option {
+	if n < 0 {
+		panic(fmt.Sprintf("harelog: negative limit provided to WithMaxFields: %d", n))
+	}
+
+	return func(l *Logger) {
+		l.maxFields = n
+	}
+}
+
+// WithMisuseErrorKey sets the payload key under which misuse of the logger is recorded,
+// such as an odd number of key-value arguments or a format verb mismatch
+// (see WithStrictFormat). The default is "logging_error"; change it if that key
+// collides with a field of your schema. It panics if key is empty.
+func WithMisuseErrorKey(key string) Option {
+	if key == "" {
+		panic("harelog: empty key provided to WithMisuseErrorKey")
+	}
+
+	return func(l *Logger) {
+		l.misuseErrorKey = key
+	}
+}
+
+// WithMissingValuePlaceholder sets the value logged for a trailing key without a value.
+// The default is "KEY_WITHOUT_VALUE".
+func WithMissingValuePlaceholder(value string) Option {
+	return func(l *Logger) {
+		l.missingValuePlaceholder = value
+	}
+}
+
 // WithPrefix sets the initial message prefix.
 func WithPrefix(prefix string) Option {
 	return func(l *Logger) {
@@ -1611,7 +4055,7 @@ func WithPrefix(prefix string) Option {
 func WithLabels(labels map[string]string) Option {
 	return func(l *Logger) {
 		for k, v := range labels {
-			if handleInvalidKey(l, k, "label") {
+			if !acceptLabelKey(l, k) {
 				continue
 			}
 
@@ -1620,6 +4064,19 @@ func WithLabels(labels map[string]string) Option {
 	}
 }
 
+// WithLabelValidation sets how invalid label keys are handled by WithLabels and
+// SetDefaultLabels. The default is LabelValidationStrict, which ignores invalid keys
+// with a warning, as Cloud Logging rejects such labels. Use LabelValidationWarn or
+// LabelValidationOff to keep the previous lax behavior.
+// Options are applied in order, so this option must precede WithLabels.
+func WithLabelValidation(mode LabelValidationMode) Option {
+	validateLabelValidationMode(mode)
+
+	return func(l *Logger) {
+		l.labelValidation = mode
+	}
+}
+
 // WithFields sets the initial set of contextual key-value fields (payload).
 func WithFields(kvs ...interface{}) Option {
 	n := len(kvs)
@@ -1645,6 +4102,53 @@ func WithFields(kvs ...interface{}) Option {
 	}
 }
 
+// WithConcurrentWriter is a functional option that moves the writes to the output
+// to a single background goroutine, for throughput under heavy concurrency. By default,
+// entries are formatted and written while holding a lock of the logger, so concurrent
+// log calls wait for each other's formatting and I/O. With it, log calls format their
+// entry without the lock and enqueue it to one of shards buffers, blocking only when
+// that buffer is full.
+//
+// Entries are written in the order their log calls enqueued them, so the entries of
+// one goroutine keep their order, but an entry may be written after the log call
+// returns. Close writes the queued entries, as do the Fatal methods before exiting.
+// Custom formatters must not reuse the returned bytes. It is off by default; it
+// panics if shards is not positive.
+func WithConcurrentWriter(shards int) Option {
+	if shards <= 0 {
+		panic(fmt.Sprintf("harelog: non-positive shards provided to WithConcurrentWriter: %d", shards))
+	}
+
+	return func(l *Logger) {
+		l.writerShards = shards
+	}
+}
+
+// WithWriteBuffer is a functional option that coalesces the records into fewer writes
+// to the output, to reduce the syscall overhead of logging to a file or socket. Records
+// are appended to a buffer of size bytes, which is written to the output when it is
+// full and every flushInterval by a background goroutine, so an entry may reach the
+// output up to flushInterval after the log call returns.
+//
+// Records are buffered in the order they are written, so the order is kept. Close
+// writes the buffered records, as do the Fatal methods before exiting. With
+// WithSyncOnWrite, the output is synced after each buffered write rather than after
+// each record. It is off by default; it panics if size or flushInterval is not positive.
+func WithWriteBuffer(size int, flushInterval time.Duration) Option {
+	if size <= 0 {
+		panic(fmt.Sprintf("harelog: non-positive size provided to WithWriteBuffer: %d", size))
+	}
+
+	if flushInterval <= 0 {
+		panic(fmt.Sprintf("harelog: non-positive flushInterval provided to WithWriteBuffer: %v", flushInterval))
+	}
+
+	return func(l *Logger) {
+		l.writeBufferSize = size
+		l.writeBufferInterval = flushInterval
+	}
+}
+
 // WithHookBufferSize sets the buffer size for the hook channel.
 // The default is 100. A larger buffer can handle higher log volumes without
 // dropping hook events, but consumes more memory.
@@ -1656,13 +4160,29 @@ func WithHookBufferSize(size int) Option {
 	}
 }
 
+// WithHookMinLevel is a functional option that sets the minimum level of entries
+// sent to any hook. It applies in addition to each hook's Levels: an entry fires a
+// hook only if it passes both. This guards against hooks being flooded, e.g. by a hook
+// whose Levels returns an empty slice (all levels). By default, all levels are sent.
+// It panics if the level is not a valid log level.
+func WithHookMinLevel(level LogLevel) Option {
+	lv, ok := levelMap[level]
+	if !ok {
+		panic(fmt.Sprintf("harelog: invalid log level provided to WithHookMinLevel: %q", level))
+	}
+
+	return func(l *Logger) {
+		l.hookMinLevel = lv
+	}
+}
+
 // WithHooks is a functional option that registers hooks with the logger.
 // Hooks are triggered asynchronously when a log entry is created at a level
 // specified in the hook's Levels() method.
+// Repeated WithHooks options accumulate: New(WithHooks(a), WithHooks(b)) registers
+// both a and b, fired in that order.
 func WithHooks(hooks ...Hook) Option {
 	return func(l *Logger) {
-		l.hooks = make([]Hook, 0, len(hooks))
-
 		l.hooks = append(l.hooks, hooks...)
 	}
 }
@@ -1674,22 +4194,62 @@ func handleInvalidKey(l *Logger, key string, fieldType string) bool {
 		return false
 	}
 
+	printWarning(l, fmt.Sprintf("harelog: invalid key %q contains space, =, or \", %s ignored", key, fieldType))
+
+	return true
+}
+
+// acceptLabelKey reports whether the label key should be added, according to
+// the logger's label validation mode. It prints a warning for invalid keys unless
+// validation is turned off.
+func acceptLabelKey(l *Logger, key string) bool {
+	switch l.labelValidation {
+	case LabelValidationOff:
+		return true
+	case LabelValidationWarn:
+		if !isValidKey(key) {
+			printWarning(l, fmt.Sprintf("harelog: invalid key %q contains space, =, or \", label kept", key))
+		}
+
+		return true
+	default:
+		return !handleInvalidKey(l, key, "label")
+	}
+}
+
+// validateLabelValidationMode panics if mode is not a known LabelValidationMode.
+func validateLabelValidationMode(mode LabelValidationMode) {
+	if mode < LabelValidationStrict || mode > LabelValidationOff {
+		panic(fmt.Sprintf("harelog: invalid LabelValidationMode provided: %d", mode))
+	}
+}
+
+// printWarning passes an internal warning message to the internal error handler, or
+// prints it to os.Stderr using the logger's FormatMessageOnly, falling back to a
+// plain text line.
+func printWarning(l *Logger, msg string) {
 	entry := &LogEntry{
 		Time:     time.Now(),
 		Severity: LogLevelWarn,
-		Message:  fmt.Sprintf("harelog: invalid key %q contains space, =, or \", %s ignored", key, fieldType),
+		Message:  msg,
+	}
+
+	if l.internalErrorHandler != nil {
+		l.internalErrorHandler(entry)
+
+		return
 	}
 
+	l.setTimeFormat(entry)
+
 	b, err := l.formatter.FormatMessageOnly(entry)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s [%s] %s\n",
-			time.Now().Format(time.RFC3339),
+			time.Now().Format(entry.timeLayout()),
 			entry.Severity,
 			entry.Message,
 		)
 	} else {
 		fmt.Fprintln(os.Stderr, string(b))
 	}
-
-	return true
 }
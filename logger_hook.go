@@ -0,0 +1,66 @@
+package harelog
+
+import (
+	"errors"
+	"slices"
+)
+
+// errLoggerHookLoop is returned by the Fire method of a LoggerHook for an entry
+// that its target has already written.
+var errLoggerHookLoop = errors.New("harelog: LoggerHook loop detected, entry dropped")
+
+// loggerHook is the Hook returned by LoggerHook.
+type loggerHook struct {
+	target *Logger
+}
+
+// LoggerHook returns a Hook that re-dispatches entries through target, for fan-out to
+// a differently configured logger, e.g. writing errors additionally as JSON to a file
+// while the main output is the console:
+//
+//	errorLog := harelog.New(harelog.WithOutput(file), harelog.WithLogLevel(harelog.LogLevelError))
+//	logger := harelog.New(harelog.WithFormatter(harelog.Console.NewFormatter()),
+//		harelog.WithHooks(harelog.LoggerHook(errorLog)))
+//
+// The hook fires for all levels, and target writes the entries enabled by its own level.
+// The entry is written as it is, without the prefix, labels and fields of target, and
+// its source location and stack trace are those of the original log call. Otherwise
+// the configuration of target applies: its redactor, hooks, formatter and output.
+//
+// Like all hooks, it runs asynchronously on the hook worker, so the entries reach target
+// after the log calls return; Close the source logger before target to write them all.
+// An entry is never re-dispatched through a logger that has already written it, so a
+// hook whose target is its own logger, or a cycle of LoggerHooks, writes each entry once
+// per logger. It panics if target is nil.
+func LoggerHook(target *Logger) Hook {
+	if target == nil {
+		panic("harelog: nil target provided to LoggerHook")
+	}
+
+	return &loggerHook{target: target}
+}
+
+// Levels implements the Hook interface. It returns an empty slice, so the hook
+// fires for all levels.
+func (h *loggerHook) Levels() []LogLevel {
+	return []LogLevel{}
+}
+
+// Fire implements the Hook interface.
+func (h *loggerHook) Fire(entry *LogEntry) error {
+	if slices.Contains(entry.relayedTo, h.target) {
+		return errLoggerHookLoop
+	}
+
+	if !h.target.isLevelEnabled(entry.Severity) {
+		return nil
+	}
+
+	// The entry is a copy owned by this hook, so target takes it over; target records
+	// itself in relayedTo if it sends the entry to its own hooks.
+	// The source location and stack trace of the hook worker are meaningless,
+	// so the entry is delivered without capturing them.
+	h.target.deliverEntry(entry)
+
+	return nil
+}
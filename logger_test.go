@@ -1,15 +1,27 @@
 package harelog
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"os/signal"
+	"reflect"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -541,6 +553,45 @@ func TestDefaultLogger(t *testing.T) {
 		}
 	})
 
+	t.Run("Ln functions", func(t *testing.T) {
+		buf := setup()
+		SetDefaultLogLevel(LogLevelTrace)
+
+		Warnln("disk", "usage", 91)
+		ErrorlnCtx(context.Background(), "request", "failed")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %q", buf.String())
+		}
+		if !strings.HasPrefix(lines[0], `{"message":"disk usage 91\n","severity":"WARN"`) {
+			t.Errorf("unexpected Warnln output: %s", lines[0])
+		}
+		if !strings.HasPrefix(lines[1], `{"message":"request failed\n","severity":"ERROR"`) {
+			t.Errorf("unexpected ErrorlnCtx output: %s", lines[1])
+		}
+	})
+
+	t.Run("Func functions", func(t *testing.T) {
+		buf := setup()
+		SetDefaultLogLevel(LogLevelInfo)
+
+		DebugFunc(func() string { t.Error("expected fn not to be called"); return "" })
+		WarnFunc(func() string { return "lazy" })
+		ErrorFuncCtx(context.Background(), func() string { return "lazy ctx" })
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %q", buf.String())
+		}
+		if !strings.HasPrefix(lines[0], `{"message":"lazy","severity":"WARN"`) {
+			t.Errorf("unexpected WarnFunc output: %s", lines[0])
+		}
+		if !strings.HasPrefix(lines[1], `{"message":"lazy ctx","severity":"ERROR"`) {
+			t.Errorf("unexpected ErrorFuncCtx output: %s", lines[1])
+		}
+	})
+
 	t.Run("Concurrency", func(t *testing.T) {
 		// Set up a clean logger with a discard writer to avoid noisy output
 		// This setup must also lock std
@@ -596,6 +647,103 @@ func TestPrintMethods(t *testing.T) {
 	}
 }
 
+// TestLnMethods verifies the Println-style methods of each level.
+func TestLnMethods(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	l := New(WithLogLevel(LogLevelTrace)).WithOutput(&buf)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		logFunc  func()
+		expected string
+	}{
+		{"Traceln", func() { l.Traceln("hello", "world", 1) }, `{"message":"hello world 1\n","severity":"TRACE"`},
+		{"Debugln", func() { l.Debugln("hello", "world", 2) }, `{"message":"hello world 2\n","severity":"DEBUG"`},
+		{"Infoln", func() { l.Infoln("hello", "world", 3) }, `{"message":"hello world 3\n","severity":"INFO"`},
+		{"Warnln", func() { l.Warnln("hello", "world", 4) }, `{"message":"hello world 4\n","severity":"WARN"`},
+		{"Errorln", func() { l.Errorln("hello", "world", 5) }, `{"message":"hello world 5\n","severity":"ERROR"`},
+		{"Criticalln", func() { l.Criticalln("hello", "world", 6) }, `{"message":"hello world 6\n","severity":"CRITICAL"`},
+		{"TracelnCtx", func() { l.TracelnCtx(ctx, "hello", "ctx") }, `{"message":"hello ctx\n","severity":"TRACE"`},
+		{"DebuglnCtx", func() { l.DebuglnCtx(ctx, "hello", "ctx") }, `{"message":"hello ctx\n","severity":"DEBUG"`},
+		{"InfolnCtx", func() { l.InfolnCtx(ctx, "hello", "ctx") }, `{"message":"hello ctx\n","severity":"INFO"`},
+		{"WarnlnCtx", func() { l.WarnlnCtx(ctx, "hello", "ctx") }, `{"message":"hello ctx\n","severity":"WARN"`},
+		{"ErrorlnCtx", func() { l.ErrorlnCtx(ctx, "hello", "ctx") }, `{"message":"hello ctx\n","severity":"ERROR"`},
+		{"CriticallnCtx", func() { l.CriticallnCtx(ctx, "hello", "ctx") }, `{"message":"hello ctx\n","severity":"CRITICAL"`},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			buf.Reset()
+			tc.logFunc()
+			if !strings.HasPrefix(buf.String(), tc.expected) {
+				t.Errorf("unexpected log output for %s:\ngot:  %s\nwant prefix: %s", tc.name, buf.String(), tc.expected)
+			}
+		})
+	}
+
+	buf.Reset()
+	l.WithLogLevel(LogLevelWarn).Infoln("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("expected no output below the log level, got %s", buf.String())
+	}
+}
+
+// TestFuncMethods verifies that the ...Func methods log the message returned by fn
+// and call fn only if the level is enabled.
+func TestFuncMethods(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	l := New(WithLogLevel(LogLevelTrace)).WithOutput(&buf)
+	ctx := context.Background()
+	msg := func() string { return "lazy" }
+
+	tests := []struct {
+		name     string
+		logFunc  func()
+		expected string
+	}{
+		{"TraceFunc", func() { l.TraceFunc(msg) }, `{"message":"lazy","severity":"TRACE"`},
+		{"DebugFunc", func() { l.DebugFunc(msg) }, `{"message":"lazy","severity":"DEBUG"`},
+		{"InfoFunc", func() { l.InfoFunc(msg) }, `{"message":"lazy","severity":"INFO"`},
+		{"WarnFunc", func() { l.WarnFunc(msg) }, `{"message":"lazy","severity":"WARN"`},
+		{"ErrorFunc", func() { l.ErrorFunc(msg) }, `{"message":"lazy","severity":"ERROR"`},
+		{"CriticalFunc", func() { l.CriticalFunc(msg) }, `{"message":"lazy","severity":"CRITICAL"`},
+		{"DebugFuncCtx", func() { l.DebugFuncCtx(ctx, msg) }, `{"message":"lazy","severity":"DEBUG"`},
+		{"ErrorFuncCtx", func() { l.ErrorFuncCtx(ctx, msg) }, `{"message":"lazy","severity":"ERROR"`},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			buf.Reset()
+			tc.logFunc()
+			if !strings.HasPrefix(buf.String(), tc.expected) {
+				t.Errorf("unexpected log output for %s:\ngot:  %s\nwant prefix: %s", tc.name, buf.String(), tc.expected)
+			}
+		})
+	}
+
+	buf.Reset()
+
+	called := false
+	l.WithLogLevel(LogLevelInfo).DebugFunc(func() string {
+		called = true
+
+		return "hidden"
+	})
+
+	if called || buf.Len() != 0 {
+		t.Errorf("expected fn not to be called below the log level, got called=%v output=%s", called, buf.String())
+	}
+}
+
 // TestFatalMethods verifies the Fatal, Fatalf, and Fatalln methods.
 func TestFatalMethods(t *testing.T) {
 	t.Parallel()
@@ -632,6 +780,36 @@ func TestFatalMethods(t *testing.T) {
 	}
 }
 
+// TestExitOnFatal verifies that the Fatal methods log but do not exit when
+// WithExitOnFatal(false) is set.
+func TestExitOnFatal(t *testing.T) {
+	t.Parallel()
+
+	// osExit is mocked only to detect an unexpected exit.
+	getExitCode := mockOsExit(t)
+
+	var buf bytes.Buffer
+
+	l := New(WithOutput(&buf), WithExitOnFatal(false))
+
+	l.Fatalf("fatal %s", "error")
+	l.Fatalw("fatal", "key", "value")
+	l.With("k", "v").Fatal("derived")
+
+	if getExitCode() != 0 {
+		t.Error("expected no exit with WithExitOnFatal(false)")
+	}
+	if n := strings.Count(buf.String(), `"severity":"CRITICAL"`); n != 3 {
+		t.Errorf("expected 3 CRITICAL entries, got %d: %s", n, buf.String())
+	}
+
+	l.WithExitOnFatal(true).Fatal("exits")
+
+	if getExitCode() != 1 {
+		t.Error("expected an exit with WithExitOnFatal(true)")
+	}
+}
+
 // TestFatalwMethod verifies the Fatalw method.
 func TestFatalwMethod(t *testing.T) {
 	var buf bytes.Buffer
@@ -1071,6 +1249,34 @@ func TestSetupLogLevelFromEnv(t *testing.T) {
 	})
 }
 
+// TestSetupFormatterFromEnv verifies the HARELOG_FORMAT environment variable.
+func TestSetupFormatterFromEnv(t *testing.T) {
+	originalStd := std
+	defer func() {
+		std = originalStd
+	}()
+
+	t.Run("Valid format set", func(t *testing.T) {
+		std = New()
+		t.Setenv("HARELOG_FORMAT", "Logfmt")
+		setupFormatterFromEnv()
+
+		if _, ok := std.formatter.(*logfmtFormatter); !ok {
+			t.Errorf("expected a logfmt formatter, got %T", std.formatter)
+		}
+	})
+
+	t.Run("Invalid format set", func(t *testing.T) {
+		std = New()
+		t.Setenv("HARELOG_FORMAT", "INVALID_VALUE")
+		setupFormatterFromEnv()
+
+		if _, ok := std.formatter.(*jsonFormatter); !ok {
+			t.Errorf("expected the default JSON formatter, got %T", std.formatter)
+		}
+	})
+}
+
 // TestNew_WithOptions verifies that all functional options passed to New() are correctly applied.
 // TestWithMethods_API verifies the immutability and correctness of all With... methods.
 func TestWithMethods_API(t *testing.T) {
@@ -1385,6 +1591,31 @@ func TestLogger_Hooks_BasicFiring(t *testing.T) {
 	}
 }
 
+func TestLogger_Hooks_RepeatedOptionsAccumulate(t *testing.T) {
+	t.Parallel()
+
+	first := newMockHook()
+	first.wg = nil
+	second := newMockHook()
+	second.wg = nil
+
+	logger := New(WithOutput(io.Discard), WithHooks(first), WithHooks(second))
+
+	logger.Infof("hooked")
+
+	// Close drains the hook worker.
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	if n := len(first.FiredEntries()); n != 1 {
+		t.Errorf("expected the first hook to fire once, got %d", n)
+	}
+	if n := len(second.FiredEntries()); n != 1 {
+		t.Errorf("expected the second hook to fire once, got %d", n)
+	}
+}
+
 // safeBuffer is a thread-safe buffer for concurrent testing.
 // It embeds a bytes.Buffer and protects its methods with a mutex.
 type safeBuffer struct {
@@ -1433,9 +1664,12 @@ func TestLogger_Hooks_PanicRecovery(t *testing.T) {
 	if !strings.Contains(output, "A hook panicked") {
 		t.Errorf("expected output to contain panic recovery message, but it didn't. Output:\n%s", output)
 	}
-	if !strings.Contains(output, `panic="test panic in hook"`) {
+	if !strings.Contains(output, `panic.value="test panic in hook"`) {
 		t.Errorf("expected output to contain the panic message, but it didn't. Output:\n%s", output)
 	}
+	if !strings.Contains(output, "panic.type=string") {
+		t.Errorf("expected output to contain the panic type, but it didn't. Output:\n%s", output)
+	}
 }
 
 func TestLogger_Hooks_GracefulShutdown(t *testing.T) {
@@ -1569,6 +1803,104 @@ func TestLogger_Hooks_AllLevels(t *testing.T) {
 	}
 }
 
+// TestSourceSampling verifies that WithSourceSampling captures the source location of
+// every nth entry below the Error level, and of every error.
+func TestSourceSampling(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := New(
+		WithOutput(&buf),
+		WithAutoSource(SourceLocationModeAlways),
+		WithSourceSampling(3),
+	)
+
+	for i := 0; i < 3; i++ {
+		logger.Infof("info %d", i)
+		logger.Errorf("error %d", i)
+	}
+	logger.With("k", "v").Infof("derived")
+	logger.Infow("forced", "_source", true)
+	logger.Infof("info 3")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []bool{true, true, false, true, false, true, true, true, false}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d:\n%s", len(want), len(lines), buf.String())
+	}
+
+	for i, line := range lines {
+		if got := strings.Contains(line, "sourceLocation"); got != want[i] {
+			t.Errorf("line %d: expected source location %v, got %s", i, want[i], line)
+		}
+	}
+
+	t.Run("Negative Panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for a negative rate")
+			}
+		}()
+
+		WithSourceSampling(-1)
+	})
+}
+
+// BenchmarkSourceLocation compares capturing the source location of every entry with
+// sampling it with WithSourceSampling.
+func BenchmarkSourceLocation(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		opts []Option
+	}{
+		{"Always", []Option{WithAutoSource(SourceLocationModeAlways)}},
+		{"Always_Sampling100", []Option{WithAutoSource(SourceLocationModeAlways), WithSourceSampling(100)}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			logger := New(append([]Option{WithOutput(io.Discard)}, bm.opts...)...)
+
+			b.ResetTimer()
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				logger.Infof("simple log message for benchmark, value: %d", i)
+			}
+		})
+	}
+}
+
+// BenchmarkDisabledLevel_ExpensiveMessage compares a disabled Debugf, whose arguments
+// are evaluated at the call site, with a disabled DebugFunc, which skips building the message.
+func BenchmarkDisabledLevel_ExpensiveMessage(b *testing.B) {
+	logger := New(WithOutput(io.Discard))
+	items := make([]int, 100)
+
+	expensive := func() string {
+		return fmt.Sprint(items)
+	}
+
+	b.Run("Debugf", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			logger.Debugf("items: %s", expensive())
+		}
+	})
+
+	b.Run("DebugFunc", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			logger.DebugFunc(expensive)
+		}
+	})
+}
+
 // Benchmark for a simple formatted log message without any extra fields.
 func BenchmarkSimpleLog(b *testing.B) {
 	// Setup: Create a logger with options. Discarding output ensures we measure
@@ -1725,3 +2057,3804 @@ func TestLogger_SetLogLevel_Concurrency(t *testing.T) {
 	wg.Wait()
 	// Test passes if `go test -race` reports no data race.
 }
+
+// TestEntryBuilder verifies the fluent EntryBuilder API.
+func TestEntryBuilder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Typed fields are dispatched", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf)).With("service", "api")
+
+		logger.Entry().
+			Level(LogLevelWarn).
+			Str("user", "u-1").
+			Int("count", 3).
+			Bool("ok", true).
+			Err(errors.New("boom")).
+			Msg("done")
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("failed to unmarshal log output: %v", err)
+		}
+
+		if entry["message"] != "done" {
+			t.Errorf("unexpected message: %v", entry["message"])
+		}
+		if entry["severity"] != "WARN" {
+			t.Errorf("unexpected severity: %v", entry["severity"])
+		}
+		if entry["user"] != "u-1" || entry["count"] != float64(3) || entry["ok"] != true {
+			t.Errorf("unexpected typed fields: %v", entry)
+		}
+		if entry["error"] != "boom" {
+			t.Errorf("unexpected error field: %v", entry["error"])
+		}
+		if entry["service"] != "api" {
+			t.Errorf("expected logger context field to be inherited, got %v", entry["service"])
+		}
+	})
+
+	t.Run("Disabled level is not dispatched", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithLogLevel(LogLevelInfo))
+		logger.Entry().Level(LogLevelDebug).Str("k", "v").Msgf("value %d", 1)
+
+		if buf.Len() != 0 {
+			t.Errorf("expected no output, got %s", buf.String())
+		}
+	})
+
+	t.Run("Context trace is extracted", func(t *testing.T) {
+		t.Parallel()
+
+		type contextKey string
+		const traceKey = contextKey("trace-key")
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithProjectID("p"), WithTraceContextKey(traceKey))
+		ctx := context.WithValue(context.Background(), traceKey, "abc/123")
+
+		logger.Entry().Ctx(ctx).Msgf("hello %s", "world")
+
+		output := buf.String()
+		if !strings.Contains(output, `"logging.googleapis.com/trace":"projects/p/traces/abc"`) {
+			t.Errorf("expected trace in output, got %s", output)
+		}
+		if !strings.Contains(output, `"message":"hello world"`) {
+			t.Errorf("expected formatted message, got %s", output)
+		}
+	})
+}
+
+// TestStrictFormat verifies the verb/argument mismatch detection of WithStrictFormat.
+func TestStrictFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		strict      bool
+		format      string
+		args        []interface{}
+		wantMessage string
+		wantError   string
+	}{
+		{"Disabled keeps fmt behavior", false, "value %d", nil, "value %!d(MISSING)", ""},
+		{"Missing argument", true, "value %d", nil, "value %d", "format verb mismatch: 1 verbs, 0 arguments"},
+		{"Extra argument", true, "value", []interface{}{1}, "value", "format verb mismatch: 0 verbs, 1 arguments"},
+		{"Matching arguments", true, "%s=%d 100%%", []interface{}{"a", 1}, "a=1 100%", ""},
+		{"Star width consumes argument", true, "%*d", []interface{}{5, 1}, "    1", ""},
+		{"Explicit index is not checked", true, "%[1]d %[1]d", []interface{}{7}, "7 7", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			logger := New(WithOutput(&buf), WithStrictFormat(tt.strict))
+			logger.Infof(tt.format, tt.args...)
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("failed to unmarshal log output: %v", err)
+			}
+
+			if entry["message"] != tt.wantMessage {
+				t.Errorf("unexpected message: got %q, want %q", entry["message"], tt.wantMessage)
+			}
+
+			gotError, _ := entry["logging_error"].(string)
+			if gotError != tt.wantError {
+				t.Errorf("unexpected logging_error: got %q, want %q", gotError, tt.wantError)
+			}
+		})
+	}
+}
+
+// TestRecordSeparator verifies the WithRecordSeparator and WithRecordPrefix options.
+func TestRecordSeparator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Default separator is newline", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithFormatter(Logfmt.NewFormatter()))
+		logger.Infof("first")
+
+		if !strings.HasSuffix(buf.String(), "message=first\n") {
+			t.Errorf("expected record to end with newline, got %q", buf.String())
+		}
+	})
+
+	t.Run("JSON text sequence", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(
+			WithOutput(&buf),
+			WithRecordPrefix([]byte{0x1e}),
+			WithRecordSeparator([]byte("\n")),
+		)
+		logger.Infof("first")
+		logger.Infof("second")
+
+		records := strings.Split(buf.String(), "\x1e")
+		if len(records) != 3 || records[0] != "" {
+			t.Fatalf("expected 2 records prefixed with RS, got %q", buf.String())
+		}
+
+		for _, r := range records[1:] {
+			if !strings.HasSuffix(r, "}\n") {
+				t.Errorf("expected record to end with newline, got %q", r)
+			}
+			if !json.Valid([]byte(r)) {
+				t.Errorf("expected valid JSON record, got %q", r)
+			}
+		}
+	})
+
+	t.Run("Custom separator via With method", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithFormatter(Logfmt.NewFormatter())).WithRecordSeparator([]byte("\r\n"))
+		logger.Infof("first")
+
+		if !strings.HasSuffix(buf.String(), "message=first\r\n") {
+			t.Errorf("expected record to end with CRLF, got %q", buf.String())
+		}
+	})
+}
+
+// TestValidateOutput verifies that WithValidateOutput warns about malformed records.
+func TestValidateOutput(t *testing.T) {
+	t.Run("Valid JSON output does not warn", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithValidateOutput(true))
+
+		stopCapture := captureStderr(t)
+		logger.Infow("valid", "key", "value")
+		stderrOutput := stopCapture()
+
+		if stderrOutput != "" {
+			t.Errorf("expected no warning, got: %s", stderrOutput)
+		}
+	})
+
+	t.Run("Malformed logfmt output warns", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		logger := New(
+			WithOutput(&buf),
+			WithFormatter(Logfmt.NewFormatter()),
+			WithValidateOutput(true),
+		)
+
+		stopCapture := captureStderr(t)
+		// Values with control characters are quoted, but keys are written as-is.
+		logger.Infow("multi-line", "key1\nkey2", "value")
+		stderrOutput := stopCapture()
+
+		if !strings.Contains(stderrOutput, "harelog: formatter produced invalid output") {
+			t.Errorf("expected validation warning, got: %s", stderrOutput)
+		}
+		if !strings.Contains(buf.String(), "multi-line") {
+			t.Errorf("expected record to be written regardless, got: %s", buf.String())
+		}
+	})
+}
+
+// TestValidateLogfmt verifies the logfmt validator used by WithValidateOutput.
+func TestValidateLogfmt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		expectErr bool
+	}{
+		{"Simple pairs", `a=1 b=two`, false},
+		{"Quoted value", `msg="hello world" n=1`, false},
+		{"Escaped quote", `msg="say \"hi\""`, false},
+		{"Newline in bare value", "a=x\ny", true},
+		{"Unterminated quote", `msg="oops`, true},
+		{"Missing value separator", `novalue`, true},
+		{"Trailing space", `a=1 `, true},
+		{"Space in key", `a b=1`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLogfmt([]byte(tt.input))
+			if (err != nil) != tt.expectErr {
+				t.Errorf("validateLogfmt(%q) error = %v, expectErr %v", tt.input, err, tt.expectErr)
+			}
+		})
+	}
+}
+
+// closeTrackingBuffer is a bytes.Buffer that records whether Close was called.
+type closeTrackingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeTrackingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+// closeCountingWriter is an io.WriteCloser that fails when it is closed twice.
+type closeCountingWriter struct {
+	closes atomic.Int32
+}
+
+func (w *closeCountingWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w *closeCountingWriter) Close() error {
+	if w.closes.Add(1) > 1 {
+		return errors.New("already closed")
+	}
+
+	return nil
+}
+
+// TestLogger_CloseTwice verifies that repeated calls to Close are no-ops returning nil,
+// also when made through a derived logger.
+func TestLogger_CloseTwice(t *testing.T) {
+	t.Parallel()
+
+	hook := newMockHook(LogLevelInfo)
+	hook.wg = nil
+
+	w := &closeCountingWriter{}
+
+	logger := New(WithOutputs(w), WithHooks(hook))
+	child := logger.With("k", "v")
+
+	logger.Infof("entry")
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("first Close returned an error: %v", err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Errorf("second Close returned an error: %v", err)
+	}
+	if err := child.CloseContext(context.Background()); err != nil {
+		t.Errorf("Close of a derived logger returned an error: %v", err)
+	}
+
+	if n := w.closes.Load(); n != 1 {
+		t.Errorf("expected the output to be closed once, got %d", n)
+	}
+	if len(hook.FiredEntries()) != 1 {
+		t.Errorf("expected the entry to be processed, got %d", len(hook.FiredEntries()))
+	}
+}
+
+// TestWithOutputs verifies that WithOutputs writes to all writers and closes them on Close.
+func TestWithOutputs(t *testing.T) {
+	t.Parallel()
+
+	var plain bytes.Buffer
+	closable := &closeTrackingBuffer{}
+
+	logger := New(WithOutputs(&plain, nil, closable))
+	logger.Infof("to both")
+
+	if !strings.Contains(plain.String(), "to both") {
+		t.Errorf("expected first writer to receive output, got %q", plain.String())
+	}
+	if plain.String() != closable.String() {
+		t.Errorf("expected all writers to receive the same bytes, got %q and %q", plain.String(), closable.String())
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+	if !closable.closed {
+		t.Error("expected writer implementing io.Closer to be closed")
+	}
+}
+
+// TestLogEntry_ToMap verifies the canonical field map produced for hooks.
+func TestLogEntry_ToMap(t *testing.T) {
+	t.Parallel()
+
+	sampled := true
+	ts := time.Date(2025, 9, 25, 12, 0, 0, 0, time.UTC)
+
+	e := &LogEntry{
+		Message:      "hello",
+		Severity:     LogLevelInfo,
+		Trace:        "projects/p/traces/t",
+		TraceSampled: &sampled,
+		Time:         ts,
+		Labels:       map[string]string{"env": "test"},
+		Payload:      map[string]interface{}{"user": "gopher", "message": "shadowed"},
+	}
+
+	m := e.ToMap()
+
+	if m["message"] != "hello" {
+		t.Errorf("special fields should take precedence over payload, got %v", m["message"])
+	}
+	if m["severity"] != LogLevelInfo || m["timestamp"] != ts || m["user"] != "gopher" {
+		t.Errorf("unexpected map: %v", m)
+	}
+	if m["logging.googleapis.com/trace"] != "projects/p/traces/t" || m["logging.googleapis.com/trace_sampled"] != true {
+		t.Errorf("expected GCP trace field names, got %v", m)
+	}
+	if _, ok := m["logging.googleapis.com/spanId"]; ok {
+		t.Error("empty spanId should be omitted")
+	}
+
+	labels, _ := m["labels"].(map[string]string)
+	if labels["env"] != "test" {
+		t.Errorf("expected labels to be nested, got %v", m["labels"])
+	}
+
+	labels["env"] = "modified"
+	if e.Labels["env"] != "test" {
+		t.Error("modifying the map's labels must not affect the entry")
+	}
+}
+
+// TestFindCaller_UnknownPackage verifies that source location capturing degrades
+// gracefully when the package path could not be determined.
+func TestFindCaller_UnknownPackage(t *testing.T) {
+	originalPackage := harelogPackage
+	harelogPackage = ""
+	defer func() {
+		harelogPackage = originalPackage
+	}()
+
+	var buf bytes.Buffer
+
+	logger := New(WithOutput(&buf), WithAutoSource(SourceLocationModeAlways))
+	logger.Infof("no source")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to unmarshal log output: %v", err)
+	}
+	if _, ok := entry["logging.googleapis.com/sourceLocation"]; ok {
+		t.Error("expected sourceLocation to be omitted when the package path is unknown")
+	}
+	if entry["message"] != "no source" {
+		t.Errorf("expected the entry to be logged, got %v", entry)
+	}
+}
+
+// TestLogger_CloseContext verifies that CloseContext stops draining when the context is done.
+func TestLogger_CloseContext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Canceled context reports unprocessed entries", func(t *testing.T) {
+		t.Parallel()
+
+		hook := newMockHook(LogLevelInfo)
+		hook.delay = 50 * time.Millisecond
+		hook.wg = nil
+
+		logger := New(WithOutput(io.Discard), WithHooks(hook))
+
+		for i := 0; i < 5; i++ {
+			logger.Infof("entry %d", i)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		start := time.Now()
+		err := logger.CloseContext(ctx)
+
+		if time.Since(start) >= hook.delay {
+			t.Errorf("CloseContext should return promptly for a canceled context, took %v", time.Since(start))
+		}
+
+		var unprocessed *UnprocessedHookEntriesError
+		if !errors.As(err, &unprocessed) {
+			t.Fatalf("expected UnprocessedHookEntriesError, got %v", err)
+		}
+		if unprocessed.Count == 0 {
+			t.Error("expected some entries to be reported as unprocessed")
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected error to wrap context.Canceled, got %v", err)
+		}
+
+		// Logging after close must not panic and must not reach the hook.
+		logger.Infof("after close")
+	})
+
+	t.Run("Completed drain returns nil", func(t *testing.T) {
+		t.Parallel()
+
+		hook := newMockHook(LogLevelInfo)
+		hook.wg.Add(1)
+
+		logger := New(WithOutput(io.Discard), WithHooks(hook))
+		logger.Infof("entry")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := logger.CloseContext(ctx); err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		if len(hook.FiredEntries()) != 1 {
+			t.Errorf("expected the entry to be processed, got %d", len(hook.FiredEntries()))
+		}
+	})
+}
+
+// TestLabelValidation verifies the configurable handling of invalid label keys.
+func TestLabelValidation(t *testing.T) {
+	labels := map[string]string{
+		"valid_key":   "value1",
+		"invalid key": "value2",
+	}
+
+	tests := []struct {
+		name        string
+		mode        LabelValidationMode
+		wantInvalid bool
+		wantWarning string
+	}{
+		{"Strict ignores and warns", LabelValidationStrict, false, `invalid key "invalid key" contains space, =, or ", label ignored`},
+		{"Warn keeps and warns", LabelValidationWarn, true, `invalid key "invalid key" contains space, =, or ", label kept`},
+		{"Off keeps silently", LabelValidationOff, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stopCapture := captureStderr(t)
+
+			l1 := New(
+				WithOutput(io.Discard),
+				WithFormatter(Text.NewFormatter()),
+				WithLabelValidation(tt.mode),
+				WithLabels(labels),
+			)
+			l2 := New(WithOutput(io.Discard), WithFormatter(Text.NewFormatter())).WithLabelValidation(tt.mode).WithLabels(labels)
+
+			stderrOutput := stopCapture()
+
+			for _, l := range []*Logger{l1, l2} {
+				if _, ok := l.labels["invalid key"]; ok != tt.wantInvalid {
+					t.Errorf("invalid key presence = %v, want %v", ok, tt.wantInvalid)
+				}
+				if l.labels["valid_key"] != "value1" {
+					t.Error("valid key was not added")
+				}
+			}
+
+			if tt.wantWarning == "" {
+				if stderrOutput != "" {
+					t.Errorf("expected no warning, got: %s", stderrOutput)
+				}
+			} else if strings.Count(stderrOutput, tt.wantWarning) != 2 {
+				t.Errorf("expected warning %q twice, got: %s", tt.wantWarning, stderrOutput)
+			}
+		})
+	}
+}
+
+// TestSourceOverride verifies that the "_source" key forces or suppresses source capture per call.
+func TestSourceOverride(t *testing.T) {
+	t.Parallel()
+
+	hasSource := func(t *testing.T, buf *bytes.Buffer) bool {
+		t.Helper()
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("failed to unmarshal log output: %v", err)
+		}
+		if _, ok := entry["_source"]; ok {
+			t.Errorf("_source key must be stripped from output, got %s", buf.String())
+		}
+
+		_, ok := entry["logging.googleapis.com/sourceLocation"]
+		return ok
+	}
+
+	t.Run("Force with ModeNever", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithAutoSource(SourceLocationModeNever))
+		logger.Criticalw("critical", "_source", true)
+
+		if !hasSource(t, &buf) {
+			t.Error("expected source to be forced")
+		}
+	})
+
+	t.Run("Suppress with ModeAlways", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithAutoSource(SourceLocationModeAlways))
+		logger.Infow("hot path", "_source", false)
+
+		if hasSource(t, &buf) {
+			t.Error("expected source to be suppressed")
+		}
+	})
+
+	t.Run("Non-bool value is a regular field", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf))
+		logger.Infow("regular", "_source", "yes")
+
+		if !strings.Contains(buf.String(), `"_source":"yes"`) {
+			t.Errorf("expected non-bool _source to be logged as a field, got %s", buf.String())
+		}
+	})
+}
+
+// TestLogger_Clone verifies that Clone copies every configuration field and that
+// the copied maps and pointers are independent of the parent.
+func TestLogger_Clone(t *testing.T) {
+	t.Parallel()
+
+	type contextKey string
+
+	var buf bytes.Buffer
+
+	sampled := true
+
+	parent := New(
+		WithOutput(&buf),
+		WithLogLevel(LogLevelDebug),
+		WithFormatter(Text.NewFormatter()),
+		WithRecordPrefix([]byte(">")),
+		WithRecordSeparator([]byte("\r\n")),
+		WithAutoSource(SourceLocationModeAlways),
+		WithCallerSkip(2),
+		WithStrictFormat(true),
+		WithValidateOutput(true),
+		WithProjectID("my-project"),
+		WithPrefix("[app] "),
+		WithLabelValidation(LabelValidationWarn),
+		WithLabels(map[string]string{"env": "test"}),
+		WithFields("service", "api"),
+		WithTraceContextKey(contextKey("trace")),
+		WithStackTrace(LogLevelError),
+		WithMinifiedStacktrace(),
+		WithHookBufferSize(7),
+		WithHooks(newMockHook(LogLevelError)),
+	).WithTrace("trace-1").WithSpanId("span-1").WithTraceSampled(&sampled).WithCorrelationID("corr-1")
+	defer parent.Close()
+
+	clone := parent.Clone()
+
+	if !reflect.DeepEqual(parent.loggerConfig, clone.loggerConfig) {
+		t.Errorf("expected clone config to equal parent config\nparent: %+v\nclone:  %+v", parent.loggerConfig, clone.loggerConfig)
+	}
+	if clone.logLevel.Load() != parent.logLevel.Load() {
+		t.Errorf("expected log level %d, got %d", parent.logLevel.Load(), clone.logLevel.Load())
+	}
+	if clone.hookBufferSize != 7 {
+		t.Errorf("expected hookBufferSize 7, got %d", clone.hookBufferSize)
+	}
+
+	clone.labels["env"] = "changed"
+	clone.payload["service"] = "changed"
+	*clone.traceSampled = false
+
+	if parent.labels["env"] != "test" || parent.payload["service"] != "api" || !*parent.traceSampled {
+		t.Error("expected modifying the clone not to affect the parent")
+	}
+}
+
+// TestContextWithLogLevel verifies that a log level attached to the context
+// raises the verbosity of the ...Ctx methods only for that context.
+func TestContextWithLogLevel(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := New(WithOutput(&buf), WithFormatter(Text.NewFormatter()), WithLogLevel(LogLevelInfo))
+
+	debugCtx := ContextWithLogLevel(context.Background(), LogLevelDebug)
+
+	logger.DebugwCtx(context.Background(), "not flagged")
+	logger.DebugwCtx(debugCtx, "flagged")
+	logger.Entry().Ctx(debugCtx).Level(LogLevelDebug).Msg("flagged builder")
+
+	out := buf.String()
+	if strings.Contains(out, "not flagged") {
+		t.Errorf("expected debug log without the context level to be suppressed, got %q", out)
+	}
+	if !strings.Contains(out, "flagged") || !strings.Contains(out, "flagged builder") {
+		t.Errorf("expected debug logs with the context level to be written, got %q", out)
+	}
+
+	buf.Reset()
+
+	// A lower context level never suppresses entries allowed by the logger.
+	logger.InfowCtx(ContextWithLogLevel(context.Background(), LogLevelError), "info")
+
+	if !strings.Contains(buf.String(), "info") {
+		t.Errorf("expected info log to be written, got %q", buf.String())
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected a panic for an invalid log level")
+		}
+	}()
+
+	ContextWithLogLevel(context.Background(), LogLevel("INVALID"))
+}
+
+// BenchmarkLogger_WithFields benchmarks logging with a logger that carries
+// several contextual fields added via With.
+func BenchmarkLogger_WithFields(b *testing.B) {
+	logger := New(WithOutput(io.Discard)).With(
+		"service", "api",
+		"version", "1.2.3",
+		"region", "asia-northeast1",
+		"instance", "i-0123",
+		"userID", "user-123",
+	)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infow("request handled", "status", 200)
+	}
+}
+
+// TestContextExtractors verifies that fields extracted from the context are
+// logged with the lowest precedence.
+func TestContextExtractors(t *testing.T) {
+	t.Parallel()
+
+	type contextKey string
+
+	const baggageKey contextKey = "baggage"
+
+	extractor := BaggageExtractor("baggage.", func(ctx context.Context) map[string]string {
+		m, _ := ctx.Value(baggageKey).(map[string]string)
+
+		return m
+	})
+
+	var buf bytes.Buffer
+
+	logger := New(WithOutput(&buf), WithFormatter(Logfmt.NewFormatter()), WithContextExtractors(extractor)).
+		With("baggage.tenant", "from-with")
+
+	ctx := context.WithValue(context.Background(), baggageKey, map[string]string{
+		"tenant":  "from-baggage",
+		"user.id": "u-1",
+		"flag":    "on",
+	})
+
+	logger.InfowCtx(ctx, "hello", "baggage.flag", "from-call")
+
+	out := buf.String()
+	for _, want := range []string{"baggage.user.id=u-1", "baggage.tenant=from-with", "baggage.flag=from-call"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q does not contain %q", out, want)
+		}
+	}
+
+	buf.Reset()
+
+	logger.Infow("no context")
+
+	if strings.Contains(buf.String(), "u-1") {
+		t.Errorf("expected no baggage fields without the context, got %q", buf.String())
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected a panic for a nil extractor")
+		}
+	}()
+
+	logger.WithContextExtractors(nil)
+}
+
+// lifecycleHook is a StatefulHook that records the calls to its methods.
+type lifecycleHook struct {
+	mu     sync.Mutex
+	events []string
+	owner  *Logger
+	err    error
+}
+
+func (h *lifecycleHook) record(event string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.events = append(h.events, event)
+}
+
+func (h *lifecycleHook) Levels() []LogLevel { return nil }
+
+func (h *lifecycleHook) Fire(entry *LogEntry) error {
+	h.record("fire " + entry.Message)
+
+	return nil
+}
+
+func (h *lifecycleHook) Init(logger *Logger) {
+	h.owner = logger
+	h.record("init")
+}
+
+func (h *lifecycleHook) Shutdown() error {
+	h.record("shutdown")
+
+	return h.err
+}
+
+// Events returns the recorded calls.
+func (h *lifecycleHook) Events() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return slices.Clone(h.events)
+}
+
+// TestStatefulHook verifies that New initializes a StatefulHook before its first Fire
+// and Close shuts it down after its last Fire, once for the logger and its clones.
+func TestStatefulHook(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Init And Shutdown Order", func(t *testing.T) {
+		t.Parallel()
+
+		hook := &lifecycleHook{err: errors.New("flush failed")}
+		logger := New(WithOutput(io.Discard), WithHooks(hook))
+
+		if hook.owner != logger {
+			t.Error("expected Init to receive the new logger")
+		}
+
+		clone := logger.With("request", "r1")
+		logger.Infof("first")
+		clone.Infof("second")
+
+		if err := clone.Close(); err == nil || !strings.Contains(err.Error(), "flush failed") {
+			t.Errorf("expected Close to return the Shutdown error, got %v", err)
+		}
+
+		logger.Close()
+
+		want := []string{"init", "fire first", "fire second", "shutdown"}
+		if got := hook.Events(); !slices.Equal(got, want) {
+			t.Errorf("expected events %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Each Owner", func(t *testing.T) {
+		t.Parallel()
+
+		hook := &lifecycleHook{}
+		logger := New(WithOutput(io.Discard), WithHooks(hook))
+
+		// A rebuilt logger owns a hook worker of its own.
+		derived := logger.rebuild(logger.hooks...)
+
+		logger.Close()
+		derived.Close()
+
+		want := []string{"init", "init", "shutdown", "shutdown"}
+		if got := hook.Events(); !slices.Equal(got, want) {
+			t.Errorf("expected events %v, got %v", want, got)
+		}
+	})
+}
+
+// TestContextExtractors_Hooks verifies that the fields extracted from the context are
+// part of the entry the hooks receive, along with the trace fields.
+func TestContextExtractors_Hooks(t *testing.T) {
+	t.Parallel()
+
+	type contextKey string
+
+	const requestKey contextKey = "request"
+
+	hook := newMockHook(LogLevelError)
+	hook.wg = nil
+
+	logger := New(
+		WithOutput(io.Discard),
+		WithProjectID("test-project"),
+		WithTraceContextKey("trace"),
+		WithContextExtractors(func(ctx context.Context) []interface{} {
+			if id, ok := ctx.Value(requestKey).(string); ok {
+				return []interface{}{"request.id", id}
+			}
+
+			return nil
+		}),
+		WithHooks(hook),
+	)
+
+	ctx := context.WithValue(context.Background(), requestKey, "r-1")
+	ctx = context.WithValue(ctx, "trace", "trace-1/span-1;o=1")
+
+	logger.ErrorwCtx(ctx, "failed", "op", "fetch")
+	logger.Entry().Ctx(ctx).Level(LogLevelError).Msg("failed again")
+	logger.Close()
+
+	entries := hook.FiredEntries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 hook entries, got %d", len(entries))
+	}
+
+	for _, e := range entries {
+		if e.Payload["request.id"] != "r-1" {
+			t.Errorf("expected the extracted field in the hook entry, got %v", e.Payload)
+		}
+		if e.Trace != "projects/test-project/traces/trace-1" || e.SpanID != "span-1" {
+			t.Errorf("expected the trace fields in the hook entry, got %q %q", e.Trace, e.SpanID)
+		}
+	}
+}
+
+// retryableError is a test error that reports its own severity.
+type retryableError struct{}
+
+func (retryableError) Error() string      { return "temporarily unavailable" }
+func (retryableError) Severity() LogLevel { return LogLevelWarn }
+
+// TestLogger_LogError verifies that LogError chooses the level via the classifier.
+func TestLogger_LogError(t *testing.T) {
+	t.Parallel()
+
+	logAndDecode := func(t *testing.T, logger *Logger, err error) map[string]interface{} {
+		t.Helper()
+
+		var buf bytes.Buffer
+
+		logger.WithOutput(&buf).LogError(context.Background(), err, "operation failed", "op", "fetch")
+
+		if buf.Len() == 0 {
+			return nil
+		}
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("failed to unmarshal log output: %v", err)
+		}
+
+		return got
+	}
+
+	t.Run("Default classifier", func(t *testing.T) {
+		t.Parallel()
+
+		logger := New()
+
+		got := logAndDecode(t, logger, errors.New("boom"))
+		if got["severity"] != "ERROR" || got["error"] != "boom" || got["op"] != "fetch" {
+			t.Errorf("unexpected entry: %v", got)
+		}
+
+		got = logAndDecode(t, logger, fmt.Errorf("wrapped: %w", retryableError{}))
+		if got["severity"] != "WARN" {
+			t.Errorf("expected WARN for a SeverityError, got %v", got["severity"])
+		}
+	})
+
+	t.Run("Custom classifier", func(t *testing.T) {
+		t.Parallel()
+
+		logger := New(WithErrorClassifier(func(err error) LogLevel {
+			if errors.Is(err, context.Canceled) {
+				return LogLevelDebug
+			}
+
+			return LogLevel("INVALID")
+		}))
+
+		if got := logAndDecode(t, logger, context.Canceled); got != nil {
+			t.Errorf("expected debug entry to be suppressed at Info level, got %v", got)
+		}
+
+		got := logAndDecode(t, logger, errors.New("boom"))
+		if got["severity"] != "ERROR" {
+			t.Errorf("expected ERROR for an invalid classified level, got %v", got["severity"])
+		}
+	})
+}
+
+// TestMaxEntrySize verifies that oversized entries are shrunk and marked as truncated.
+func TestMaxEntrySize(t *testing.T) {
+	t.Parallel()
+
+	decode := func(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+		t.Helper()
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("failed to unmarshal log output %q: %v", buf.String(), err)
+		}
+
+		return got
+	}
+
+	t.Run("Drop largest field", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithMaxEntrySize(300, TruncateLargestField))
+
+		logger.Infow("upload", "body", strings.Repeat("x", 1000), "user", "u-1")
+
+		if n := buf.Len() - 1; n > 300 {
+			t.Errorf("expected output of at most 300 bytes, got %d", n)
+		}
+
+		got := decode(t, &buf)
+		if _, ok := got["body"]; ok {
+			t.Error("expected the oversized field to be dropped")
+		}
+		if got["user"] != "u-1" || got["message"] != "upload" || got["truncated"] != true {
+			t.Errorf("unexpected entry: %v", got)
+		}
+	})
+
+	t.Run("Truncate message", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithMaxEntrySize(300, TruncateMessage))
+
+		logger.Infow(strings.Repeat("あ", 200), "user", "u-1")
+
+		if n := buf.Len() - 1; n > 300 {
+			t.Errorf("expected output of at most 300 bytes, got %d", n)
+		}
+
+		got := decode(t, &buf)
+		msg, _ := got["message"].(string)
+		if !strings.HasSuffix(msg, truncatedMarker) || got["user"] != "u-1" || got["truncated"] != true {
+			t.Errorf("unexpected entry: %v", got)
+		}
+	})
+
+	t.Run("Fitting entry is unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithMaxEntrySize(300, TruncateLargestField))
+
+		logger.Infow("small", "user", "u-1")
+
+		if _, ok := decode(t, &buf)["truncated"]; ok {
+			t.Error("expected no truncated field")
+		}
+	})
+}
+
+func TestNanoTimestamps(t *testing.T) {
+	t.Parallel()
+
+	t.Run("All formatters", func(t *testing.T) {
+		t.Parallel()
+
+		entry := func() *LogEntry {
+			return &LogEntry{
+				Message:        "hello",
+				Severity:       LogLevelInfo,
+				Time:           time.Date(2025, 10, 14, 13, 30, 0, 123456789, time.UTC),
+				nanoTimestamps: true,
+			}
+		}
+
+		formatters := map[string]Formatter{
+			"json":    JSON.NewFormatter(),
+			"text":    Text.NewFormatter(),
+			"console": Console.NewFormatter(),
+			"logfmt":  Logfmt.NewFormatter(),
+		}
+
+		for name, f := range formatters {
+			out, err := f.Format(entry())
+			if err != nil {
+				t.Fatalf("%s: Format returned an error: %v", name, err)
+			}
+			if !strings.Contains(string(out), "2025-10-14T13:30:00.123456789Z") {
+				t.Errorf("%s: expected a nanosecond timestamp, got %q", name, out)
+			}
+
+			out, err = f.FormatMessageOnly(entry())
+			if err != nil {
+				t.Fatalf("%s: FormatMessageOnly returned an error: %v", name, err)
+			}
+			if !strings.Contains(string(out), "2025-10-14T13:30:00.123456789Z") {
+				t.Errorf("%s: expected a nanosecond timestamp in FormatMessageOnly, got %q", name, out)
+			}
+		}
+	})
+
+	t.Run("Logger option", func(t *testing.T) {
+		t.Parallel()
+
+		timestamp := regexp.MustCompile(`^timestamp=\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?Z `)
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithFormatter(Logfmt.NewFormatter()))
+
+		logger.Infof("seconds")
+		logger.WithNanoTimestamps(true).Infof("nanos")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %q", buf.String())
+		}
+
+		if m := timestamp.FindStringSubmatch(lines[0]); m == nil || m[1] != "" {
+			t.Errorf("expected a second-precision timestamp by default, got %q", lines[0])
+		}
+		if m := timestamp.FindStringSubmatch(lines[1]); m == nil || m[1] == "" {
+			t.Errorf("expected a nanosecond timestamp, got %q", lines[1])
+		}
+	})
+}
+
+func TestTimeEncoder(t *testing.T) {
+	t.Parallel()
+
+	epochMicros := func(dst []byte, t time.Time) []byte {
+		return strconv.AppendInt(dst, t.UnixMicro(), 10)
+	}
+	dateOnly := func(dst []byte, t time.Time) []byte {
+		return t.AppendFormat(dst, time.DateOnly)
+	}
+
+	entry := func(encoder TimeEncoder) *LogEntry {
+		return &LogEntry{
+			Message:     "hello",
+			Severity:    LogLevelInfo,
+			Time:        time.Date(2025, 10, 14, 13, 30, 0, 123456789, time.UTC),
+			timeEncoder: encoder,
+		}
+	}
+
+	t.Run("All formatters", func(t *testing.T) {
+		t.Parallel()
+
+		formatters := map[string]Formatter{
+			"json":      JSON.NewFormatter(),
+			"json-zap":  JSON.NewFormatter(JSON.ZapFieldNames()),
+			"text":      Text.NewFormatter(),
+			"console":   Console.NewFormatter(),
+			"logfmt":    Logfmt.NewFormatter(),
+			"accesslog": AccessLog.NewFormatter(),
+		}
+
+		for name, f := range formatters {
+			out, err := f.Format(entry(epochMicros))
+			if err != nil {
+				t.Fatalf("%s: Format returned an error: %v", name, err)
+			}
+			if !strings.Contains(string(out), "1760448600123456") || strings.Contains(string(out), "2025-10-14") {
+				t.Errorf("%s: expected the encoder's timestamp, got %q", name, out)
+			}
+
+			out, err = f.FormatMessageOnly(entry(epochMicros))
+			if err != nil {
+				t.Fatalf("%s: FormatMessageOnly returned an error: %v", name, err)
+			}
+			if !strings.Contains(string(out), "1760448600123456") {
+				t.Errorf("%s: expected the encoder's timestamp in FormatMessageOnly, got %q", name, out)
+			}
+		}
+	})
+
+	t.Run("JSON value types", func(t *testing.T) {
+		t.Parallel()
+
+		f := JSON.NewFormatter()
+
+		out, err := f.Format(entry(epochMicros))
+		if err != nil {
+			t.Fatalf("Format returned an error: %v", err)
+		}
+		if !strings.Contains(string(out), `"timestamp":1760448600123456`) {
+			t.Errorf("expected a numeric timestamp, got %q", out)
+		}
+
+		out, err = f.Format(entry(dateOnly))
+		if err != nil {
+			t.Fatalf("Format returned an error: %v", err)
+		}
+		if !strings.Contains(string(out), `"timestamp":"2025-10-14"`) {
+			t.Errorf("expected a string timestamp, got %q", out)
+		}
+	})
+
+	t.Run("Logger option", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithFormatter(Logfmt.NewFormatter()), WithTimeEncoder(dateOnly))
+
+		logger.Infof("custom")
+		logger.WithTimeEncoder(nil).Infof("default")
+		logger.WithLogLevel(LogLevelDebug).Infof("rebuilt")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines, got %q", buf.String())
+		}
+
+		for _, i := range []int{0, 2} {
+			if !regexp.MustCompile(`^timestamp=\d{4}-\d{2}-\d{2} `).MatchString(lines[i]) {
+				t.Errorf("expected the encoder's timestamp, got %q", lines[i])
+			}
+		}
+		if !strings.Contains(lines[1], "T") {
+			t.Errorf("expected the default timestamp, got %q", lines[1])
+		}
+	})
+}
+
+// failingFormatter is a JSON formatter that fails on entries with a "fail" field.
+type failingFormatter struct {
+	Formatter
+}
+
+func (f failingFormatter) Format(e *LogEntry) ([]byte, error) {
+	if _, ok := e.Payload["fail"]; ok {
+		return nil, errors.New("cannot format")
+	}
+
+	return f.Formatter.Format(e)
+}
+
+func TestFormatErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	var errs []error
+	var messages []string
+
+	logger := New(
+		WithOutput(&buf),
+		WithFormatter(failingFormatter{JSON.NewFormatter()}),
+		WithFormatErrorHandler(func(err error, entry *LogEntry) {
+			errs = append(errs, err)
+			messages = append(messages, entry.Message)
+		}),
+	)
+
+	logger.Infow("unformattable", "fail", true)
+
+	if len(errs) != 1 || errs[0] == nil || messages[0] != "unformattable" {
+		t.Fatalf("expected the handler to be called once for the entry, got %v %q", errs, messages)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected the entry to be dropped, got %q", buf.String())
+	}
+
+	// The failed entry must not leak its fields into the next one.
+	logger.Infof("next")
+
+	if strings.Contains(buf.String(), `"fail"`) || !strings.Contains(buf.String(), "next") {
+		t.Errorf("unexpected output after a format error: %q", buf.String())
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected no further format errors, got %v", errs)
+	}
+}
+
+func TestInternalErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Invalid key warnings are passed to the handler", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		var entries []*LogEntry
+
+		logger := New(
+			WithOutput(&buf),
+			WithInternalErrorHandler(func(entry *LogEntry) {
+				entries = append(entries, entry)
+			}),
+		)
+
+		logger.With("bad key", 1).Infof("hello")
+
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 internal entry, got %d", len(entries))
+		}
+		if entries[0].Severity != LogLevelWarn || !strings.Contains(entries[0].Message, `invalid key "bad key"`) {
+			t.Errorf("unexpected internal entry: %+v", entries[0])
+		}
+		if strings.Contains(buf.String(), "invalid key") || !strings.Contains(buf.String(), "hello") {
+			t.Errorf("unexpected output: %q", buf.String())
+		}
+	})
+
+	t.Run("Format failures are passed to the handler", func(t *testing.T) {
+		t.Parallel()
+
+		var entries []*LogEntry
+
+		logger := New(
+			WithOutput(io.Discard),
+			WithFormatter(failingFormatter{JSON.NewFormatter()}),
+			WithInternalErrorHandler(func(entry *LogEntry) {
+				entries = append(entries, entry)
+			}),
+		)
+
+		logger.Infow("unformattable", "fail", true)
+
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 internal entry, got %d", len(entries))
+		}
+		if e := entries[0]; e.Severity != LogLevelError || e.Payload["error"] == nil || e.Payload["entry.message"] != "unformattable" {
+			t.Errorf("unexpected internal entry: %+v", e)
+		}
+	})
+
+	t.Run("Hook panics are passed to the handler", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		var entries []*LogEntry
+
+		logger := New(
+			WithOutput(&buf),
+			WithHooks(&panicHook{}),
+			WithInternalErrorHandler(func(entry *LogEntry) {
+				entries = append(entries, entry)
+			}),
+		)
+
+		logger.Errorf("hello")
+		logger.Close()
+
+		if len(entries) != 1 || entries[0].Message != "A hook panicked" {
+			t.Fatalf("expected the hook panic entry, got %+v", entries)
+		}
+		if strings.Contains(buf.String(), "A hook panicked") {
+			t.Errorf("expected the panic not to be written to the output, got %q", buf.String())
+		}
+	})
+
+	t.Run("nil handler panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for a nil handler")
+			}
+		}()
+
+		WithInternalErrorHandler(nil)
+	})
+}
+
+func TestMaxFieldValueLength(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Long values are cut", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithMaxFieldValueLength(10))
+
+		logger.Infow("upload",
+			"body", strings.Repeat("x", 1000),
+			"name", strings.Repeat("あ", 5), // 15 bytes
+			"ids", []int{1, 2, 3, 4, 5, 6, 7, 8},
+			"user", "u-1",
+			"size", 1234567890123,
+		)
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("failed to unmarshal log output %q: %v", buf.String(), err)
+		}
+
+		want := map[string]interface{}{
+			"body": "xxxxxxxxxx...(truncated)",
+			"name": "あああ...(truncated)",
+			"ids":  "[1 2 3 4 5...(truncated)",
+			"user": "u-1",
+			"size": float64(1234567890123),
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s = %v, want %v", k, got[k], v)
+			}
+		}
+	})
+
+	t.Run("Applies to text formatters", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithFormatter(Logfmt.NewFormatter())).WithMaxFieldValueLength(4)
+
+		logger.Infow("upload", "body", "abcdefgh")
+
+		if !strings.Contains(buf.String(), `body=abcd...(truncated)`) {
+			t.Errorf("expected the truncated value, got %q", buf.String())
+		}
+	})
+
+	t.Run("Negative length panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for a negative length")
+			}
+		}()
+
+		WithMaxFieldValueLength(-1)
+	})
+}
+
+// TestCloseOnSignal verifies that the default logger is closed, flushing its hooks,
+// when a signal is received.
+func TestCloseOnSignal(t *testing.T) {
+	// Restore default logger after test
+	originalStd := std
+	defer func() {
+		stdMutex.Lock()
+		std = originalStd
+		stdMutex.Unlock()
+	}()
+
+	// Receive the signals ourselves, as an application does, so that they do not
+	// terminate the test process once CloseOnSignal stops listening.
+	received := make(chan os.Signal, 2)
+	signal.Notify(received, syscall.SIGHUP)
+	defer signal.Stop(received)
+
+	waitSignal := func(t *testing.T) {
+		t.Helper()
+
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for the signal")
+		}
+	}
+
+	t.Run("Stopped", func(t *testing.T) {
+		hook := newMockHook(LogLevelError)
+		hook.wg = nil
+
+		stdMutex.Lock()
+		std = New(WithOutput(io.Discard), WithHooks(hook))
+		stdMutex.Unlock()
+		defer Close()
+
+		stop := CloseOnSignal(syscall.SIGHUP)
+		stop()
+		stop() // must be safe to call twice
+
+		p, _ := os.FindProcess(os.Getpid())
+		if err := p.Signal(syscall.SIGHUP); err != nil {
+			t.Skipf("cannot send signals on this platform: %v", err)
+		}
+
+		waitSignal(t)
+
+		if !std.hookWorker.send(&LogEntry{Severity: LogLevelError}) {
+			t.Error("expected the logger to stay open after stop")
+		}
+	})
+
+	t.Run("Closes on signal", func(t *testing.T) {
+		hook := newMockHook(LogLevelError)
+		hook.wg = nil
+		hook.delay = 50 * time.Millisecond
+
+		stdMutex.Lock()
+		std = New(WithOutput(io.Discard), WithHooks(hook))
+		stdMutex.Unlock()
+
+		stop := CloseOnSignal(syscall.SIGHUP)
+		defer stop()
+
+		Errorf("flush me")
+
+		p, _ := os.FindProcess(os.Getpid())
+		if err := p.Signal(syscall.SIGHUP); err != nil {
+			t.Skipf("cannot send signals on this platform: %v", err)
+		}
+
+		waitSignal(t)
+
+		select {
+		case <-std.hookWorker.done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for the logger to be closed")
+		}
+
+		if fired := hook.FiredEntries(); len(fired) != 1 {
+			t.Errorf("expected the hook to be flushed, got %d entries", len(fired))
+		}
+
+		select {
+		case <-received:
+			t.Error("expected the signal not to be raised again")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+// TestLogger_HookQueueLen verifies that HookQueueLen reports the buffered hook entries.
+func TestLogger_HookQueueLen(t *testing.T) {
+	t.Parallel()
+
+	if n := New(WithOutput(io.Discard)).HookQueueLen(); n != 0 {
+		t.Errorf("expected 0 without hooks, got %d", n)
+	}
+
+	hook := newMockHook(LogLevelError)
+	hook.wg = nil
+	hook.delay = 100 * time.Millisecond
+
+	logger := New(WithOutput(io.Discard), WithHooks(hook), WithHookBufferSize(10))
+	defer logger.Close()
+
+	for i := 0; i < 4; i++ {
+		logger.Errorf("entry %d", i)
+	}
+
+	// The first entry may already have been taken by the worker.
+	if n := logger.HookQueueLen(); n < 3 || n > 4 {
+		t.Errorf("expected 3 or 4 buffered entries, got %d", n)
+	}
+	if n := logger.With("k", "v").HookQueueLen(); n < 3 {
+		t.Errorf("expected derived loggers to share the queue, got %d", n)
+	}
+}
+
+// TestDynamicFields verifies that dynamic fields are evaluated for every entry
+// at the documented precedence.
+func TestDynamicFields(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	var counter atomic.Int64
+
+	logger := New(
+		WithOutput(&buf),
+		WithFormatter(Logfmt.NewFormatter()),
+		WithFields("source", "static"),
+		WithDynamicFields(func() []interface{} {
+			return []interface{}{"inflight", counter.Add(1), "source", "dynamic", "override", "dynamic"}
+		}),
+	)
+
+	logger.Infow("first", "override", "call")
+	logger.Infow("second")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+
+	for _, want := range []string{"inflight=1", "source=dynamic", "override=call"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("first line %q does not contain %q", lines[0], want)
+		}
+	}
+	if !strings.Contains(lines[1], "inflight=2") {
+		t.Errorf("expected the function to be evaluated again, got %q", lines[1])
+	}
+}
+
+// TestLogger_HookMinLevel verifies that WithHookMinLevel gates hooks in addition to their Levels.
+func TestLogger_HookMinLevel(t *testing.T) {
+	t.Parallel()
+
+	allLevels := newMockHook() // empty Levels() fires for all levels
+	errorOnly := newMockHook(LogLevelError)
+
+	allLevels.wg = nil
+	errorOnly.wg = nil
+
+	logger := New(
+		WithOutput(io.Discard),
+		WithLogLevel(LogLevelDebug),
+		WithHooks(allLevels, errorOnly),
+		WithHookMinLevel(LogLevelWarn),
+	)
+
+	logger.Debugf("debug")
+	logger.Infof("info")
+	logger.Warnf("warn")
+	logger.Errorf("error")
+	logger.Close()
+
+	var got []LogLevel
+	for _, e := range allLevels.FiredEntries() {
+		got = append(got, e.Severity)
+	}
+
+	if !reflect.DeepEqual(got, []LogLevel{LogLevelWarn, LogLevelError}) {
+		t.Errorf("expected the all-levels hook to fire for WARN and ERROR only, got %v", got)
+	}
+	if n := len(errorOnly.FiredEntries()); n != 1 {
+		t.Errorf("expected the error hook to fire once, got %d", n)
+	}
+}
+
+// TestMaxFields verifies that WithMaxFields caps the payload fields and marks the entry.
+func TestMaxFields(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := New(WithOutput(&buf), WithMaxFields(10)).With("service", "api")
+
+	kvs := make([]interface{}, 0, 2000)
+	for i := 0; i < 1000; i++ {
+		kvs = append(kvs, fmt.Sprintf("key%04d", i), i)
+	}
+	kvs = append(kvs, "error", errors.New("boom"), "service", "overridden")
+
+	logger.Infow("many fields", kvs...)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal log output: %v", err)
+	}
+
+	n := 0
+	for k := range got {
+		if strings.HasPrefix(k, "key") {
+			n++
+		}
+	}
+
+	// service + key0000..key0008 fill the limit; error is always kept.
+	if n != 9 {
+		t.Errorf("expected 9 call-site fields to be kept, got %d", n)
+	}
+	if _, ok := got["key0000"]; !ok {
+		t.Error("expected the first fields to be kept")
+	}
+	if got["fields_truncated"] != true {
+		t.Error("expected fields_truncated=true")
+	}
+	if got["error"] != "boom" || got["service"] != "overridden" {
+		t.Errorf("expected the error and existing fields to be kept and updated, got error=%v service=%v", got["error"], got["service"])
+	}
+
+	buf.Reset()
+
+	logger.Infow("few fields", "a", 1)
+
+	if strings.Contains(buf.String(), "fields_truncated") {
+		t.Errorf("expected no marker within the limit, got %s", buf.String())
+	}
+}
+
+// TestSetDefault verifies that SetDefault installs a logger and closes the previous
+// default's hook worker.
+func TestSetDefault(t *testing.T) {
+	// Restore default logger after test
+	originalStd := std
+	defer func() {
+		stdMutex.Lock()
+		std = originalStd
+		stdMutex.Unlock()
+	}()
+
+	oldHook := newMockHook(LogLevelError)
+	oldHook.wg = nil
+	oldHook.delay = 20 * time.Millisecond
+
+	SetDefault(New(WithOutput(io.Discard), WithHooks(oldHook)))
+	Errorf("to the old hook")
+
+	var buf bytes.Buffer
+
+	newLogger := New(WithOutput(&buf), WithFormatter(Text.NewFormatter()))
+	SetDefault(newLogger)
+
+	if n := len(oldHook.FiredEntries()); n != 1 {
+		t.Errorf("expected the previous hook worker to be drained on replacement, got %d entries", n)
+	}
+	if Default() != newLogger {
+		t.Error("expected Default to return the installed logger")
+	}
+
+	Infof("to the new logger")
+
+	if !strings.Contains(buf.String(), "to the new logger") {
+		t.Errorf("expected package-level functions to use the new logger, got %q", buf.String())
+	}
+
+	// Replacing with a derived logger keeps the shared hook worker running.
+	hook := newMockHook(LogLevelError)
+	hook.wg = nil
+
+	SetDefault(New(WithOutput(io.Discard), WithHooks(hook)))
+	SetDefault(Default().With("k", "v"))
+	defer Close()
+
+	if !Default().hookWorker.send(&LogEntry{Severity: LogLevelError}) {
+		t.Error("expected the shared hook worker to stay open")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected a panic for a nil logger")
+		}
+	}()
+
+	SetDefault(nil)
+}
+
+// TestLogger_Fields verifies that typed fields are logged like key-value pairs,
+// including their precedence over contextual fields and the special keys.
+func TestLogger_Fields(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := New(WithOutput(&buf), WithLogLevel(LogLevelDebug)).With("user", "from-with", "region", "asia")
+
+	req := &HTTPRequest{RequestMethod: "GET"}
+
+	logger.Infofields("fields",
+		String("user", "u-1"),
+		Int("count", 3),
+		Int64("big", 1<<40),
+		Float64("ratio", 0.5),
+		Bool("ok", true),
+		Duration("elapsed", 1500*time.Millisecond),
+		Err(errors.New("boom")),
+		Any("httpRequest", req),
+	)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"user":    "u-1",
+		"region":  "asia",
+		"count":   float64(3),
+		"big":     float64(1 << 40),
+		"ratio":   0.5,
+		"ok":      true,
+		"elapsed": float64(1500 * time.Millisecond),
+		"error":   "boom",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+
+	if hr, ok := got["httpRequest"].(map[string]interface{}); !ok || hr["requestMethod"] != "GET" {
+		t.Errorf("expected httpRequest special field, got %v", got["httpRequest"])
+	}
+
+	t.Run("Value", func(t *testing.T) {
+		tests := []struct {
+			field Field
+			want  interface{}
+		}{
+			{String("k", "v"), "v"},
+			{Int("k", -1), -1},
+			{Int64("k", 2), int64(2)},
+			{Float64("k", 1.25), 1.25},
+			{Bool("k", false), false},
+			{Duration("k", time.Second), time.Second},
+			{Any("k", []int{1}), []int{1}},
+		}
+
+		for _, tt := range tests {
+			if got := tt.field.Value(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, got)
+			}
+			if tt.field.Key() != "k" {
+				t.Errorf("expected key k, got %s", tt.field.Key())
+			}
+		}
+	})
+
+	t.Run("level filtering", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		New(WithOutput(&buf), WithLogLevel(LogLevelWarn)).Infofields("dropped", String("k", "v"))
+
+		if buf.Len() != 0 {
+			t.Errorf("expected no output, got %q", buf.String())
+		}
+	})
+}
+
+// TestFieldsAllocs verifies that the ...fields methods allocate less than the ...w
+// methods for the same values, as the typed values are not boxed.
+func TestFieldsAllocs(t *testing.T) {
+	logger := New(WithOutput(io.Discard))
+	service := strings.Repeat("s", 8)
+	userID := 1000 // incremented, so that the compiler cannot box a constant
+
+	kvs := testing.AllocsPerRun(100, func() {
+		userID++
+		logger.Infow("request handled", "service", service, "user_id", userID, "is_member", true)
+	})
+
+	fields := testing.AllocsPerRun(100, func() {
+		userID++
+		logger.Infofields("request handled", String("service", service), Int("user_id", userID), Bool("is_member", true))
+	})
+
+	if fields >= kvs {
+		t.Errorf("expected Infofields to allocate less than Infow (%v), got %v", kvs, fields)
+	}
+}
+
+// TestFieldsUnboxedOutput verifies that typed fields written without boxing produce
+// the same output as the same values logged as key-value pairs.
+func TestFieldsUnboxedOutput(t *testing.T) {
+	t.Parallel()
+
+	timestamp := regexp.MustCompile(`"timestamp":"[^"]*"`)
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		kvs    []interface{}
+		fields []Field
+	}{
+		{
+			name:   "Values",
+			kvs:    []interface{}{"s", "v", "i", -3, "i64", int64(1 << 40), "f", 0.25, "b", false, "d", time.Second},
+			fields: []Field{String("s", "v"), Int("i", -3), Int64("i64", 1<<40), Float64("f", 0.25), Bool("b", false), Duration("d", time.Second)},
+		},
+		{
+			name:   "Escaped strings",
+			kvs:    []interface{}{"a\"b", "<x>\n\x00", "u", "caf\u00e9\u2028"},
+			fields: []Field{String("a\"b", "<x>\n\x00"), String("u", "caf\u00e9\u2028")},
+		},
+		{
+			name:   "Floats in exponent form",
+			kvs:    []interface{}{"small", 1e-9, "large", 1e22, "nan", math.NaN()},
+			fields: []Field{Float64("small", 1e-9), Float64("large", 1e22), Float64("nan", math.NaN())},
+		},
+		{
+			name:   "Duplicate keys",
+			kvs:    []interface{}{"k", 1, "k", "last"},
+			fields: []Field{Int("k", 1), String("k", "last")},
+		},
+		{
+			name:   "Typed after Any",
+			kvs:    []interface{}{"k", "any", "k", 2},
+			fields: []Field{Any("k", "any"), Int("k", 2)},
+		},
+		{
+			name:   "Any after typed",
+			kvs:    []interface{}{"k", 1, "k", "any"},
+			fields: []Field{Int("k", 1), Any("k", "any")},
+		},
+		{
+			name:   "Masked",
+			opts:   []Option{WithFormatter(JSON.NewFormatter(JSON.WithMaskingKeys("password")))},
+			kvs:    []interface{}{"password", "secret", "user", "u-1"},
+			fields: []Field{String("password", "secret"), String("user", "u-1")},
+		},
+		{
+			name:   "With other payload fields",
+			opts:   []Option{WithFields("region", "asia", "user", "from-with")},
+			kvs:    []interface{}{"user", "u-1", "zone", 3},
+			fields: []Field{String("user", "u-1"), Int("zone", 3)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var kvBuf, fieldBuf bytes.Buffer
+
+			New(append([]Option{WithOutput(&kvBuf)}, tt.opts...)...).Infow("msg", tt.kvs...)
+			New(append([]Option{WithOutput(&fieldBuf)}, tt.opts...)...).Infofields("msg", tt.fields...)
+
+			want := timestamp.ReplaceAllString(kvBuf.String(), "")
+			if got := timestamp.ReplaceAllString(fieldBuf.String(), ""); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		})
+	}
+
+	t.Run("Hooks receive the boxed fields", func(t *testing.T) {
+		t.Parallel()
+
+		hook := newMockHook(LogLevelInfo)
+		hook.wg = nil
+
+		logger := New(WithOutput(io.Discard), WithHooks(hook))
+		logger.Infofields("msg", String("user", "u-1"), Int("count", 2))
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		fired := hook.FiredEntries()
+		if len(fired) != 1 || fired[0].Payload["user"] != "u-1" || fired[0].Payload["count"] != 2 {
+			t.Errorf("expected the hook to receive the fields in the payload, got %v", fired)
+		}
+	})
+}
+
+// BenchmarkInfow benchmarks logging a mix of value types as key-value pairs.
+func BenchmarkInfow(b *testing.B) {
+	logger := New(WithOutput(io.Discard))
+	service := strings.Repeat("s", 8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infow("request handled",
+			"service", service,
+			"user_id", i,
+			"is_member", true,
+			"elapsed", time.Duration(i),
+		)
+	}
+}
+
+// BenchmarkInfofields benchmarks logging the same values as BenchmarkInfow as typed fields.
+func BenchmarkInfofields(b *testing.B) {
+	logger := New(WithOutput(io.Discard))
+	service := strings.Repeat("s", 8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infofields("request handled",
+			String("service", service),
+			Int("user_id", i),
+			Bool("is_member", true),
+			Duration("elapsed", time.Duration(i)),
+		)
+	}
+}
+
+// TestMisuseMarkers verifies that the keys and values marking an odd number of
+// arguments can be changed so that they do not collide with real fields.
+func TestMisuseMarkers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		New(WithOutput(&buf)).Infow("odd", "user", "u-1", "dangling")
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+
+		if got["logging_error"] != "odd number of arguments received" || got["dangling"] != "KEY_WITHOUT_VALUE" {
+			t.Errorf("unexpected markers: %v", got)
+		}
+	})
+
+	t.Run("Conflicting field name", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithStrictFormat(true),
+			WithMisuseErrorKey("harelog_error"), WithMissingValuePlaceholder("<missing>"))
+
+		// "logging_error" is a real field in this schema.
+		logger.Infow("odd", "logging_error", "E1001", "dangling")
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+
+		if got["logging_error"] != "E1001" {
+			t.Errorf("expected the real field to be kept, got %v", got["logging_error"])
+		}
+		if got["harelog_error"] != "odd number of arguments received" {
+			t.Errorf("expected the misuse error under the custom key, got %v", got["harelog_error"])
+		}
+		if got["dangling"] != "<missing>" {
+			t.Errorf("expected the custom placeholder, got %v", got["dangling"])
+		}
+
+		buf.Reset()
+		logger.With("logging_error", "E1002").Infof("value %d")
+
+		if !strings.Contains(buf.String(), `"harelog_error":"format verb mismatch`) ||
+			!strings.Contains(buf.String(), `"logging_error":"E1002"`) {
+			t.Errorf("expected the format mismatch under the custom key, got %s", buf.String())
+		}
+	})
+
+	t.Run("Panics on empty key", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+
+		WithMisuseErrorKey("")
+	})
+}
+
+// TestRingBufferHook verifies that the hook keeps only the most recent entries
+// and dumps them oldest first.
+func TestRingBufferHook(t *testing.T) {
+	t.Parallel()
+
+	hook := NewRingBufferHook(3)
+
+	logger := New(WithOutput(io.Discard), WithLogLevel(LogLevelDebug), WithHooks(hook))
+
+	var buf bytes.Buffer
+
+	if err := hook.Dump(&buf); err != nil || buf.Len() != 0 {
+		t.Fatalf("expected an empty dump, got %q (err: %v)", buf.String(), err)
+	}
+
+	logger.Debugw("entry 1", "password", "secret")
+	logger.Infow("entry 2")
+
+	// Close drains the hook queue, so that all entries are captured.
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := hook.Dump(&buf); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 ||
+		!strings.Contains(lines[0], `"message":"entry 1"`) || !strings.Contains(lines[1], `"message":"entry 2"`) {
+		t.Errorf("unexpected dump before wraparound: %s", buf.String())
+	}
+
+	for i := 3; i <= 7; i++ {
+		_ = hook.Fire(&LogEntry{Message: fmt.Sprintf("entry %d", i), Severity: LogLevelInfo})
+	}
+
+	buf.Reset()
+
+	if err := hook.DumpWith(&buf, Logfmt.NewFormatter()); err != nil {
+		t.Fatalf("DumpWith failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 entries after wraparound, got %d: %s", len(lines), buf.String())
+	}
+
+	for i, want := range []string{`message="entry 5"`, `message="entry 6"`, `message="entry 7"`} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d: expected %s, got %s", i, want, lines[i])
+		}
+	}
+
+	t.Run("Dump formats a copy", func(t *testing.T) {
+		hook := NewRingBufferHook(1)
+		_ = hook.Fire(&LogEntry{Message: "m", Payload: map[string]interface{}{"password": "secret"}})
+
+		var buf bytes.Buffer
+
+		_ = hook.DumpWith(&buf, JSON.NewFormatter(JSON.WithMaskingKeys("password")))
+		buf.Reset()
+		_ = hook.Dump(&buf)
+
+		if !strings.Contains(buf.String(), `"password":"secret"`) {
+			t.Errorf("expected the stored entry to be unchanged, got %s", buf.String())
+		}
+	})
+
+	t.Run("Panics on non-positive size", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+
+		NewRingBufferHook(0)
+	})
+}
+
+// TestJournaldPriorityPrefix verifies that each record starts with the syslog
+// priority of its level when enabled.
+func TestJournaldPriorityPrefix(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := New(WithOutput(&buf), WithLogLevel(LogLevelDebug), WithFormatter(Logfmt.NewFormatter()),
+		WithJournaldPriorityPrefix(true), WithRecordPrefix([]byte{0x1e}))
+
+	logger.Debugf("d")
+	logger.Infof("i")
+	logger.Warnf("w")
+	logger.Errorf("e")
+	logger.Criticalf("c")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %d: %q", len(lines), buf.String())
+	}
+
+	for i, want := range []string{"<7>\x1e", "<6>\x1e", "<4>\x1e", "<3>\x1e", "<2>\x1e"} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("line %d: expected prefix %q, got %q", i, want, lines[i])
+		}
+	}
+
+	buf.Reset()
+	logger.WithJournaldPriorityPrefix(false).Infof("plain")
+
+	if !strings.HasPrefix(buf.String(), "\x1e") {
+		t.Errorf("expected no priority prefix, got %q", buf.String())
+	}
+}
+
+// TestTimeOverride verifies that the "_time" key sets the timestamp of the entry
+// and is not written as a field.
+func TestTimeOverride(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		log  func(l *Logger)
+		want string
+	}{
+		{"Key-value pair", func(l *Logger) { l.Infow("replayed", "_time", ts) }, `"timestamp":"2020-02-03T04:05:06Z"`},
+		{"Typed field", func(l *Logger) { l.Infofields("replayed", Any("_time", ts)) }, `"timestamp":"2020-02-03T04:05:06Z"`},
+		{"Not a time.Time", func(l *Logger) { l.Infow("replayed", "_time", "yesterday") }, `"_time":"yesterday"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			tt.log(New(WithOutput(&buf)))
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("output %s does not contain %s", buf.String(), tt.want)
+			}
+		})
+	}
+
+	t.Run("Not written as a field", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		New(WithOutput(&buf), WithFormatter(Logfmt.NewFormatter())).Infow("replayed", "_time", ts)
+
+		if strings.Contains(buf.String(), "_time") || !strings.Contains(buf.String(), "timestamp=2020-02-03T04:05:06Z") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+}
+
+// TestLogger_WithContext verifies that the non-Ctx methods use the bound context,
+// and that an explicitly passed context replaces it.
+func TestLogger_WithContext(t *testing.T) {
+	t.Parallel()
+
+	type contextKey string
+
+	const traceKey contextKey = "trace"
+
+	var buf bytes.Buffer
+
+	base := New(WithOutput(&buf), WithProjectID("p"), WithTraceContextKey(traceKey))
+
+	bound := context.WithValue(ContextWithLogLevel(context.Background(), LogLevelDebug), traceKey, "bound-trace/1")
+	logger := base.WithContext(bound)
+
+	tests := []struct {
+		name string
+		log  func()
+		want string
+	}{
+		{"Infof", func() { logger.Infof("m") }, `"logging.googleapis.com/trace":"projects/p/traces/bound-trace"`},
+		{"Infow", func() { logger.Infow("m", "k", "v") }, `"logging.googleapis.com/trace":"projects/p/traces/bound-trace"`},
+		{"Infofields", func() { logger.Infofields("m") }, `"logging.googleapis.com/trace":"projects/p/traces/bound-trace"`},
+		{"Entry", func() { logger.Entry().Msg("m") }, `"logging.googleapis.com/trace":"projects/p/traces/bound-trace"`},
+		{"Debugf via the bound log level", func() { logger.Debugf("m") }, `"severity":"DEBUG"`},
+		{
+			"Explicit context takes precedence",
+			func() { logger.InfofCtx(context.WithValue(context.Background(), traceKey, "explicit-trace/2"), "m") },
+			`"logging.googleapis.com/trace":"projects/p/traces/explicit-trace"`,
+		},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		tt.log()
+
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("%s: output %s does not contain %s", tt.name, buf.String(), tt.want)
+		}
+	}
+
+	buf.Reset()
+	base.Debugf("not bound")
+	base.Infof("not bound")
+
+	if strings.Contains(buf.String(), "DEBUG") || strings.Contains(buf.String(), "bound-trace") {
+		t.Errorf("expected the base logger to be unaffected, got %s", buf.String())
+	}
+
+	t.Run("Panics on nil context", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+
+		base.WithContext(nil)
+	})
+}
+
+// TestTraceParser verifies that trace data is extracted from a non-string context
+// value with a custom parser, and that the default parser ignores such values.
+func TestTraceParser(t *testing.T) {
+	t.Parallel()
+
+	type contextKey string
+
+	type traceContext struct {
+		TraceID string
+		SpanID  string
+		Sampled bool
+	}
+
+	const traceKey contextKey = "trace"
+
+	parser := func(value interface{}) (string, string, *bool, bool) {
+		tc, ok := value.(*traceContext)
+		if !ok {
+			return "", "", nil, false
+		}
+
+		return tc.TraceID, tc.SpanID, &tc.Sampled, true
+	}
+
+	ctx := context.WithValue(context.Background(), traceKey, &traceContext{TraceID: "abc", SpanID: "123", Sampled: true})
+
+	t.Run("Custom parser", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithProjectID("p"), WithTraceContextKey(traceKey), WithTraceParser(parser))
+		logger.InfofCtx(ctx, "m")
+
+		for _, want := range []string{
+			`"logging.googleapis.com/trace":"projects/p/traces/abc"`,
+			`"logging.googleapis.com/spanId":"123"`,
+			`"logging.googleapis.com/trace_sampled":true`,
+		} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("output %s does not contain %s", buf.String(), want)
+			}
+		}
+	})
+
+	t.Run("Default parser ignores non-string values", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithProjectID("p"), WithTraceContextKey(traceKey))
+		logger.InfofCtx(ctx, "m")
+
+		if strings.Contains(buf.String(), "logging.googleapis.com/trace") {
+			t.Errorf("expected no trace, got %s", buf.String())
+		}
+
+		buf.Reset()
+		logger.InfofCtx(context.WithValue(context.Background(), traceKey, "def/456;o=1"), "m")
+
+		if !strings.Contains(buf.String(), `"logging.googleapis.com/trace":"projects/p/traces/def","logging.googleapis.com/spanId":"456"`) {
+			t.Errorf("expected the header to be parsed, got %s", buf.String())
+		}
+	})
+
+	t.Run("Panics on nil parser", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+
+		WithTraceParser(nil)
+	})
+}
+
+// TestRedactor verifies that the redactor is applied to the output and the hooks,
+// and that it can replace or drop entries.
+func TestRedactor(t *testing.T) {
+	t.Parallel()
+
+	euAddress := func(e *LogEntry) *LogEntry {
+		if e.Payload["region"] == "EU" {
+			if _, ok := e.Payload["address"]; ok {
+				e.Payload["address"] = "[REDACTED]"
+			}
+		}
+
+		return e
+	}
+
+	t.Run("Cross-field rule", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		hook := newMockHook(LogLevelInfo)
+		hook.wg = nil
+
+		logger := New(WithOutput(&buf), WithRedactor(euAddress), WithHooks(hook))
+
+		logger.Infow("eu", "region", "EU", "address", "1 Rue de Rivoli")
+		logger.Infow("us", "region", "US", "address", "1 Main St")
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 || !strings.Contains(lines[0], `"address":"[REDACTED]"`) || !strings.Contains(lines[1], `"address":"1 Main St"`) {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+
+		if fired := hook.FiredEntries(); len(fired) == 0 || fired[0].Payload["address"] != "[REDACTED]" {
+			t.Errorf("expected the hook to receive the redacted entry, got %v", fired)
+		}
+	})
+
+	t.Run("New entry and drop", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf)).WithRedactor(func(e *LogEntry) *LogEntry {
+			if e.Payload["drop"] == true {
+				return nil
+			}
+
+			return &LogEntry{Message: "replaced", Severity: e.Severity, Time: e.Time}
+		})
+
+		logger.Infow("dropped", "drop", true)
+		logger.Infow("original", "secret", "s3cr3t")
+
+		if got := strings.TrimSpace(buf.String()); strings.Count(got, "\n") != 0 ||
+			!strings.Contains(got, `"message":"replaced"`) || strings.Contains(got, "s3cr3t") {
+			t.Errorf("unexpected output: %s", got)
+		}
+
+		buf.Reset()
+		logger.Infow("reused entry", "k", "v")
+
+		if !strings.Contains(buf.String(), `"message":"replaced"`) {
+			t.Errorf("unexpected output after reuse: %s", buf.String())
+		}
+	})
+}
+
+// syncCloseBuffer is a closeTrackingBuffer that is safe for concurrent use.
+type syncCloseBuffer struct {
+	mu  sync.Mutex
+	buf closeTrackingBuffer
+}
+
+func (b *syncCloseBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncCloseBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Close()
+}
+
+func (b *syncCloseBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return bytes.Clone(b.buf.Bytes())
+}
+
+// syncCountingFile is an *os.File that counts the calls to Sync.
+type syncCountingFile struct {
+	*os.File
+	syncs atomic.Int32
+}
+
+func (f *syncCountingFile) Sync() error {
+	f.syncs.Add(1)
+
+	return f.File.Sync()
+}
+
+// TestWithSyncOnWrite verifies that WithSyncOnWrite syncs the output after each record.
+func TestWithSyncOnWrite(t *testing.T) {
+	t.Parallel()
+
+	newFile := func(t *testing.T) *syncCountingFile {
+		file, err := os.CreateTemp(t.TempDir(), "harelog-*.log")
+		if err != nil {
+			t.Fatalf("failed to create a temp file: %v", err)
+		}
+		t.Cleanup(func() { file.Close() })
+
+		return &syncCountingFile{File: file}
+	}
+
+	t.Run("Syncs After Each Write", func(t *testing.T) {
+		t.Parallel()
+
+		file := newFile(t)
+		logger := New(WithOutput(file), WithFormatter(Bare.NewFormatter()), WithSyncOnWrite(true))
+
+		logger.Infof("first")
+		logger.Infof("second")
+
+		if got := file.syncs.Load(); got != 2 {
+			t.Errorf("expected 2 syncs, got %d", got)
+		}
+
+		data, err := os.ReadFile(file.Name())
+		if err != nil {
+			t.Fatalf("failed to read the log file: %v", err)
+		}
+		if string(data) != "first\nsecond\n" {
+			t.Errorf("unexpected file content: %q", data)
+		}
+	})
+
+	t.Run("Off By Default", func(t *testing.T) {
+		t.Parallel()
+
+		file := newFile(t)
+		logger := New(WithOutput(file), WithFormatter(Bare.NewFormatter()))
+
+		logger.Infof("not synced")
+
+		if got := file.syncs.Load(); got != 0 {
+			t.Errorf("expected no syncs, got %d", got)
+		}
+
+		if got := logger.WithSyncOnWrite(true); !got.syncOnWrite || logger.syncOnWrite {
+			t.Error("WithSyncOnWrite should enable sync only on the new logger")
+		}
+	})
+
+	t.Run("With Concurrent Writer", func(t *testing.T) {
+		t.Parallel()
+
+		file := newFile(t)
+		logger := New(WithOutput(file), WithFormatter(Bare.NewFormatter()), WithSyncOnWrite(true), WithConcurrentWriter(2))
+
+		logger.Infof("queued")
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		if got := file.syncs.Load(); got != 1 {
+			t.Errorf("expected 1 sync, got %d", got)
+		}
+	})
+}
+
+// TestGzipWriter verifies that NewGzipWriter compresses logger output, flushes it
+// periodically and finishes the stream on Close.
+func TestGzipWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Round Trip", func(t *testing.T) {
+		t.Parallel()
+
+		out := &syncCloseBuffer{}
+		logger := New(WithOutput(NewGzipWriter(out, gzip.BestSpeed)), WithFormatter(Bare.NewFormatter()))
+
+		logger.Infof("first")
+		logger.Infof("second")
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		if !out.buf.closed {
+			t.Error("expected the underlying writer to be closed")
+		}
+
+		r, err := gzip.NewReader(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatalf("failed to open gzip stream: %v", err)
+		}
+
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("failed to read gzip stream: %v", err)
+		}
+
+		if string(got) != "first\nsecond\n" {
+			t.Errorf("unexpected decompressed output: %q", got)
+		}
+	})
+
+	t.Run("Clone Closed After Parent", func(t *testing.T) {
+		t.Parallel()
+
+		parentOut := &syncCloseBuffer{}
+		parent := New(WithOutput(NewGzipWriter(parentOut, gzip.BestSpeed)), WithFormatter(Bare.NewFormatter()))
+
+		out := &syncCloseBuffer{}
+		child := parent.WithOutput(NewGzipWriter(out, gzip.BestSpeed))
+
+		child.Infof("child")
+
+		if err := parent.Close(); err != nil {
+			t.Fatalf("parent Close returned an error: %v", err)
+		}
+		if err := child.Close(); err != nil {
+			t.Fatalf("child Close returned an error: %v", err)
+		}
+
+		if !parentOut.buf.closed || !out.buf.closed {
+			t.Fatal("expected the writers of both loggers to be closed")
+		}
+
+		r, err := gzip.NewReader(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatalf("failed to open gzip stream: %v", err)
+		}
+
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("failed to read gzip stream: %v", err)
+		}
+
+		if string(got) != "child\n" {
+			t.Errorf("unexpected decompressed output: %q", got)
+		}
+	})
+
+	t.Run("Periodic Flush", func(t *testing.T) {
+		t.Parallel()
+
+		out := &syncCloseBuffer{}
+		w := newGzipWriter(out, gzip.DefaultCompression, 10*time.Millisecond)
+		defer w.Close()
+
+		if _, err := w.Write([]byte("flushed\n")); err != nil {
+			t.Fatalf("Write returned an error: %v", err)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			// The stream has no footer yet, so read only up to the first line.
+			r, err := gzip.NewReader(bytes.NewReader(out.Bytes()))
+			if err == nil {
+				if line, err := bufio.NewReader(r).ReadString('\n'); err == nil {
+					if line != "flushed\n" {
+						t.Errorf("unexpected flushed line: %q", line)
+					}
+
+					break
+				}
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatal("data was not flushed before the deadline")
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	t.Run("Write After Close", func(t *testing.T) {
+		t.Parallel()
+
+		w := NewGzipWriter(&syncCloseBuffer{}, gzip.DefaultCompression)
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("second Close returned an error: %v", err)
+		}
+		if _, err := w.Write([]byte("late")); err == nil {
+			t.Error("expected an error when writing after Close")
+		}
+	})
+
+	t.Run("Invalid Level Panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for an invalid compression level")
+			}
+		}()
+
+		NewGzipWriter(&syncCloseBuffer{}, 42)
+	})
+}
+
+// TestDedup verifies that WithDedup collapses identical consecutive entries.
+func TestDedup(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Collapses Rapid Identical Entries", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		logger := New(WithOutput(&buf), WithDedup(time.Hour))
+
+		for i := 0; i < 5; i++ {
+			logger.Warnw("retrying", "attempt", 1)
+		}
+		logger.Infof("connected")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines, got %d: %q", len(lines), buf.String())
+		}
+
+		var summary map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[1]), &summary); err != nil {
+			t.Fatalf("failed to unmarshal summary: %v", err)
+		}
+		if summary["message"] != "last message repeated 4 times" || summary["repeated"] != float64(4) || summary["severity"] != "WARN" {
+			t.Errorf("unexpected summary: %s", lines[1])
+		}
+		if !strings.Contains(lines[2], `"message":"connected"`) {
+			t.Errorf("expected the different entry last, got %s", lines[2])
+		}
+	})
+
+	t.Run("Summary When Window Closes", func(t *testing.T) {
+		t.Parallel()
+
+		out := &syncCloseBuffer{}
+		logger := New(WithOutput(out), WithFormatter(Bare.NewFormatter()), WithDedup(10*time.Millisecond))
+
+		for i := 0; i < 3; i++ {
+			logger.Infof("tick")
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for !strings.Contains(string(out.Bytes()), "last message repeated 2 times") {
+			if time.Now().After(deadline) {
+				t.Fatalf("summary was not written before the deadline, got %q", out.Bytes())
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		// The window is closed, so the same entry is written again.
+		logger.Infof("tick")
+
+		if got := string(out.Bytes()); got != "tick\nlast message repeated 2 times { repeated=2 }\ntick\n" {
+			t.Errorf("unexpected output: %q", got)
+		}
+	})
+
+	t.Run("Close Writes Pending Summary", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		logger := New(WithOutput(&buf), WithFormatter(Bare.NewFormatter()), WithDedup(time.Hour))
+
+		logger.Infof("tick")
+		logger.Infof("tick")
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		if buf.String() != "tick\nlast message repeated 1 times { repeated=1 }\n" {
+			t.Errorf("unexpected output: %q", buf.String())
+		}
+	})
+
+	t.Run("Comparison Key", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name string
+			key  DedupKey
+			want int
+		}{
+			{"Output Differs By Label", DedupKeyOutput, 2},
+			{"Message Ignores Labels", DedupKeyMessage, 1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var buf bytes.Buffer
+				logger := New(WithOutput(&buf), WithDedup(time.Hour), WithDedupKey(tt.key))
+
+				logger.WithLabels(map[string]string{"pod": "a"}).Infow("same", "n", 1)
+				logger.WithLabels(map[string]string{"pod": "b"}).Infow("same", "n", 1)
+
+				if got := strings.Count(buf.String(), `"message":"same"`); got != tt.want {
+					t.Errorf("expected %d written entries, got %d: %q", tt.want, got, buf.String())
+				}
+
+				// The payload is part of both keys.
+				logger.Infow("same", "n", 2)
+				if !strings.Contains(buf.String(), `"n":2`) {
+					t.Errorf("expected an entry with different fields to be written, got %q", buf.String())
+				}
+			})
+		}
+	})
+
+	t.Run("Fatal Is Never Deduplicated", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		logger := New(WithOutput(&buf), WithFormatter(Bare.NewFormatter()), WithDedup(time.Hour))
+
+		getExitCode := mockOsExit(t)
+
+		logger.Criticalf("fatal")
+		logger.Criticalf("fatal")
+		logger.Fatalf("fatal")
+
+		if getExitCode() != 1 {
+			t.Errorf("expected os.Exit(1) to be called, but exit code was %d", getExitCode())
+		}
+		if buf.String() != "fatal\nlast message repeated 1 times { repeated=1 }\nfatal\n" {
+			t.Errorf("unexpected output: %q", buf.String())
+		}
+	})
+}
+
+// TestNewFromDefault verifies that NewFromDefault replicates the default logger's
+// configuration with a hook worker of its own.
+func TestNewFromDefault(t *testing.T) {
+	// This test modifies the global `std` logger, so it must not run in parallel.
+	originalStd := std
+	t.Cleanup(func() {
+		stdMutex.Lock()
+		std = originalStd
+		stdMutex.Unlock()
+	})
+
+	var buf bytes.Buffer
+	hook := newMockHook(LogLevelInfo)
+	hook.wg = nil
+
+	stdMutex.Lock()
+	std = New(
+		WithOutput(&buf),
+		WithLogLevel(LogLevelDebug),
+		WithFormatter(Text.NewFormatter()),
+		WithPrefix("[app] "),
+		WithLabels(map[string]string{"service": "api"}),
+		WithHooks(hook),
+	).WithCorrelationID("req-1")
+	defaultLogger := std
+	stdMutex.Unlock()
+
+	logger := NewFromDefault()
+
+	if logger == defaultLogger || logger.hookWorker == nil || logger.hookWorker == defaultLogger.hookWorker {
+		t.Fatal("expected an independent logger with a hook worker of its own")
+	}
+
+	logger.Debugw("from library", "k", "v")
+
+	got := buf.String()
+	for _, want := range []string{"DEBUG", "[app] from library", "service=api", "k=v", "correlationId=req-1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got %q", want, got)
+		}
+	}
+
+	logger.Infof("to hook")
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	// Closing the derived logger must not stop the default logger's hooks.
+	Infof("from app")
+
+	if err := defaultLogger.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	fired := hook.FiredEntries()
+	if len(fired) != 2 || fired[0].Message != "[app] to hook" || fired[1].Message != "[app] from app" {
+		t.Errorf("unexpected hook entries: %d", len(fired))
+	}
+}
+
+// TestTraceLevel verifies the Trace level below Debug.
+func TestTraceLevel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ParseLogLevel", func(t *testing.T) {
+		t.Parallel()
+
+		level, err := ParseLogLevel("trace")
+		if err != nil || level != LogLevelTrace {
+			t.Errorf("expected LogLevelTrace, got %q (err: %v)", level, err)
+		}
+	})
+
+	t.Run("Level Boundaries", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			level       LogLevel
+			wantTrace   bool
+			wantDebug   bool
+			wantWritten bool
+		}{
+			{LogLevelAll, true, true, true},
+			{LogLevelTrace, true, true, true},
+			{LogLevelDebug, false, true, false},
+			{LogLevelOff, false, false, false},
+		}
+
+		for _, tt := range tests {
+			var buf bytes.Buffer
+			logger := New(WithOutput(&buf), WithLogLevel(tt.level))
+
+			if logger.IsTraceEnabled() != tt.wantTrace || logger.IsDebugEnabled() != tt.wantDebug {
+				t.Errorf("level %s: unexpected IsTraceEnabled=%v IsDebugEnabled=%v", tt.level, logger.IsTraceEnabled(), logger.IsDebugEnabled())
+			}
+
+			logger.Tracef("trace %d", 1)
+			logger.Tracew("trace", "n", 2)
+			logger.Tracefields("trace", Int("n", 3))
+
+			want := 0
+			if tt.wantWritten {
+				want = 3
+			}
+
+			if got := strings.Count(buf.String(), `"severity":"TRACE"`); got != want {
+				t.Errorf("level %s: unexpected output %q", tt.level, buf.String())
+			}
+		}
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		t.Parallel()
+
+		allLevels := newMockHook()
+		debugOnly := newMockHook(LogLevelDebug)
+		allLevels.wg = nil
+		debugOnly.wg = nil
+
+		logger := New(WithOutput(io.Discard), WithLogLevel(LogLevelTrace), WithHooks(allLevels, debugOnly))
+		logger.Tracef("trace")
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		if fired := allLevels.FiredEntries(); len(fired) != 1 || fired[0].Severity != LogLevelTrace {
+			t.Errorf("expected the all-levels hook to fire for Trace, got %d entries", len(fired))
+		}
+		if fired := debugOnly.FiredEntries(); len(fired) != 0 {
+			t.Errorf("expected the Debug hook not to fire for Trace, got %d entries", len(fired))
+		}
+	})
+}
+
+// TestBuildInfo verifies the fields added by WithBuildInfo.
+func TestBuildInfo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Fields", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name string
+			info *debug.BuildInfo
+			ok   bool
+			want map[string]string
+		}{
+			{
+				name: "Version And Revision",
+				info: &debug.BuildInfo{
+					Main:     debug.Module{Path: "example.com/app", Version: "v1.2.3"},
+					Settings: []debug.BuildSetting{{Key: "vcs", Value: "git"}, {Key: "vcs.revision", Value: "abc123"}},
+				},
+				ok:   true,
+				want: map[string]string{"version": "v1.2.3", "revision": "abc123"},
+			},
+			{
+				name: "Devel Build Without VCS",
+				info: &debug.BuildInfo{Main: debug.Module{Path: "example.com/app", Version: "(devel)"}},
+				ok:   true,
+				want: map[string]string{},
+			},
+			{
+				name: "Not Embedded",
+				ok:   false,
+				want: map[string]string{},
+			},
+		}
+
+		for _, tt := range tests {
+			if got := buildInfoFields(tt.info, tt.ok); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			}
+		}
+	})
+
+	t.Run("Option", func(t *testing.T) {
+		t.Parallel()
+
+		logger := New(WithOutput(io.Discard), WithBuildInfo(true))
+
+		for k, v := range buildInfo() {
+			if logger.payload[k] != v {
+				t.Errorf("expected field %s=%s, got %v", k, v, logger.payload[k])
+			}
+		}
+
+		if without := logger.WithBuildInfo(false); len(without.payload) != 0 {
+			t.Errorf("expected WithBuildInfo(false) to remove the fields, got %v", without.payload)
+		}
+	})
+}
+
+// TestServiceContext verifies that the service identity fields are inherited and can be overridden.
+func TestServiceContext(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := New(WithOutput(&buf), WithServiceContext("checkout", "v1.2.0", "prod"))
+
+	logger.Infof("base")
+	logger.With("env", "canary").Infow("overridden", "service", "checkout-worker")
+	logger.WithServiceContext("", "v1.3.0", "").Infof("partial")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", buf.String())
+	}
+
+	wants := []string{
+		`"env":"prod","service":"checkout","version":"v1.2.0"`,
+		`"env":"canary","service":"checkout-worker","version":"v1.2.0"`,
+		`"env":"prod","service":"checkout","version":"v1.3.0"`,
+	}
+
+	for i, want := range wants {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d: expected %s, got %s", i, want, lines[i])
+		}
+	}
+
+	if fields := New(WithServiceContext("api", "", "")).payload; len(fields) != 1 || fields["service"] != "api" {
+		t.Errorf("expected only the non-empty fields, got %v", fields)
+	}
+}
+
+// TestConcurrentWriter verifies that WithConcurrentWriter writes every entry and keeps
+// the order of the entries of each goroutine.
+func TestConcurrentWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Order And Completeness", func(t *testing.T) {
+		t.Parallel()
+
+		const goroutines, perGoroutine = 8, 500
+
+		var buf bytes.Buffer
+		logger := New(WithOutput(&buf), WithFormatter(Bare.NewFormatter()), WithConcurrentWriter(4))
+
+		var wg sync.WaitGroup
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for i := 0; i < perGoroutine; i++ {
+					logger.Infof("%d %d", g, i)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		next := make([]int, goroutines)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+		for _, line := range lines {
+			var g, i int
+			if _, err := fmt.Sscanf(line, "%d %d", &g, &i); err != nil {
+				t.Fatalf("unexpected line %q: %v", line, err)
+			}
+
+			if i != next[g] {
+				t.Fatalf("goroutine %d: expected entry %d, got %d", g, next[g], i)
+			}
+			next[g]++
+		}
+
+		if len(lines) != goroutines*perGoroutine {
+			t.Errorf("expected %d lines, got %d", goroutines*perGoroutine, len(lines))
+		}
+	})
+
+	t.Run("Fatal Writes Queued Entries", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		logger := New(WithOutput(&buf), WithFormatter(Bare.NewFormatter()), WithConcurrentWriter(2))
+
+		getExitCode := mockOsExit(t)
+
+		logger.Infof("before")
+		logger.Fatalf("fatal")
+
+		if getExitCode() != 1 {
+			t.Errorf("expected os.Exit(1) to be called, but exit code was %d", getExitCode())
+		}
+		if buf.String() != "before\nfatal\n" {
+			t.Errorf("unexpected output: %q", buf.String())
+		}
+	})
+
+	t.Run("Invalid Shards Panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for non-positive shards")
+			}
+		}()
+
+		WithConcurrentWriter(0)
+	})
+}
+
+// benchmarkContended logs from many goroutines at once with the given options.
+func benchmarkContended(b *testing.B, opts ...Option) {
+	logger := New(append([]Option{WithOutput(io.Discard)}, opts...)...)
+	defer logger.Close()
+
+	b.SetParallelism(8)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Infow("request handled", "method", "GET", "status", 200, "latency_ms", 12.5)
+		}
+	})
+}
+
+// BenchmarkContended_Mutex measures the default write path under contention.
+func BenchmarkContended_Mutex(b *testing.B) {
+	benchmarkContended(b)
+}
+
+// BenchmarkContended_ConcurrentWriter measures WithConcurrentWriter under contention.
+func BenchmarkContended_ConcurrentWriter(b *testing.B) {
+	benchmarkContended(b, WithConcurrentWriter(8))
+}
+
+// writeCountingWriter counts the writes to it and keeps the written bytes.
+type writeCountingWriter struct {
+	mu     sync.Mutex
+	writes int
+	buf    bytes.Buffer
+}
+
+func (w *writeCountingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writes++
+
+	return w.buf.Write(p)
+}
+
+// stats returns the number of writes and the written bytes.
+func (w *writeCountingWriter) stats() (int, string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.writes, w.buf.String()
+}
+
+// levelRecordingWriter is a levelWriter that records the level of each write.
+type levelRecordingWriter struct {
+	levels []LogLevel
+}
+
+func (w *levelRecordingWriter) Write(p []byte) (int, error) {
+	return w.writeLevel("", p)
+}
+
+func (w *levelRecordingWriter) writeLevel(level LogLevel, p []byte) (int, error) {
+	w.levels = append(w.levels, level)
+
+	return len(p), nil
+}
+
+// TestEventLogWriter verifies that the logger passes the level of each entry to an
+// output recording levels, as the Event Log writer does, and the mapping of the levels
+// to event types.
+func TestEventLogWriter(t *testing.T) {
+	t.Parallel()
+
+	w := &levelRecordingWriter{}
+	logger := New(WithOutput(w), WithLogLevel(LogLevelDebug))
+
+	logger.Debugf("debug")
+	logger.Warnf("warn")
+	logger.Criticalf("critical")
+
+	if want := []LogLevel{LogLevelDebug, LogLevelWarn, LogLevelCritical}; !slices.Equal(w.levels, want) {
+		t.Errorf("expected levels %v, got %v", want, w.levels)
+	}
+
+	for level, want := range map[LogLevel]eventLogType{
+		LogLevelCritical: eventLogError,
+		LogLevelError:    eventLogError,
+		LogLevelWarn:     eventLogWarning,
+		LogLevelInfo:     eventLogInformation,
+		LogLevelTrace:    eventLogInformation,
+		"":               eventLogInformation,
+	} {
+		if got := eventLogTypeOf(level); got != want {
+			t.Errorf("eventLogTypeOf(%q) = %d, want %d", level, got, want)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		if _, err := NewEventLogWriter("harelog"); err == nil {
+			t.Error("expected an error on a platform without the Event Log")
+		}
+	}
+}
+
+// TestSourceLine verifies that sourceLine reports unreadable files and missing lines.
+func TestSourceLine(t *testing.T) {
+	t.Parallel()
+
+	if line, ok := sourceLine("source_snippet.go", 1); !ok || line != "package harelog" {
+		t.Errorf("sourceLine() = %q, %v", line, ok)
+	}
+
+	for _, tt := range []struct {
+		file string
+		line int
+	}{
+		{"does_not_exist.go", 1},
+		{"source_snippet.go", 100000},
+		{"source_snippet.go", 0},
+	} {
+		if line, ok := sourceLine(tt.file, tt.line); ok {
+			t.Errorf("sourceLine(%q, %d) = %q, expected no line", tt.file, tt.line, line)
+		}
+	}
+}
+
+// TestPackageLevel verifies that a package level applies to the package and its
+// sub-packages, the most specific level winning.
+func TestPackageLevel(t *testing.T) {
+	t.Cleanup(ResetPackageLevels)
+
+	if got := functionPackage("github.com/you/app/db.(*Repo).Find"); got != "github.com/you/app/db" {
+		t.Errorf("functionPackage() = %q", got)
+	}
+	if got := functionPackage("main.main.func1"); got != "main" {
+		t.Errorf("functionPackage() = %q", got)
+	}
+
+	SetPackageLevel("github.com/you/app", LogLevelWarn)
+	SetPackageLevel("github.com/you/app/db/", LogLevelDebug)
+
+	tests := []struct {
+		pkg  string
+		want logLevelValue
+		ok   bool
+	}{
+		{"github.com/you/app", logLevelValueWarn, true},
+		{"github.com/you/app/api", logLevelValueWarn, true},
+		{"github.com/you/app/db", logLevelValueDebug, true},
+		{"github.com/you/app/db/migrations", logLevelValueDebug, true},
+		{"github.com/you/application", 0, false},
+		{"github.com/other", 0, false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := packageLevel(tt.pkg); got != tt.want || ok != tt.ok {
+			t.Errorf("packageLevel(%q) = %v, %v, want %v, %v", tt.pkg, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an empty package")
+		}
+	}()
+
+	SetPackageLevel("", LogLevelDebug)
+}
+
+// TestWriteBuffer verifies that WithWriteBuffer coalesces records into fewer writes,
+// flushing when the buffer is full, when the interval elapses and on Close.
+func TestWriteBuffer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Close Flushes", func(t *testing.T) {
+		t.Parallel()
+
+		w := &writeCountingWriter{}
+		logger := New(WithOutput(w), WithFormatter(Bare.NewFormatter()), WithWriteBuffer(1024, time.Hour))
+
+		logger.Infof("first")
+		logger.Infof("second")
+
+		if writes, _ := w.stats(); writes != 0 {
+			t.Fatalf("expected the records to be buffered, got %d writes", writes)
+		}
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		if writes, out := w.stats(); writes != 1 || out != "first\nsecond\n" {
+			t.Errorf("expected one write of both records, got %d writes of %q", writes, out)
+		}
+	})
+
+	t.Run("Full Buffer Flushes", func(t *testing.T) {
+		t.Parallel()
+
+		w := &writeCountingWriter{}
+		logger := New(WithOutput(w), WithFormatter(Bare.NewFormatter()), WithWriteBuffer(10, time.Hour))
+		defer logger.Close()
+
+		logger.Infof("12345")
+		logger.Infof("67890")
+		logger.Infof("after")
+
+		if writes, out := w.stats(); writes != 1 || out != "12345\n67890\n" {
+			t.Errorf("expected one write of the first two records, got %d writes of %q", writes, out)
+		}
+	})
+
+	t.Run("Interval Flushes", func(t *testing.T) {
+		t.Parallel()
+
+		w := &writeCountingWriter{}
+		logger := New(WithOutput(w), WithFormatter(Bare.NewFormatter()), WithWriteBuffer(1024, 10*time.Millisecond))
+		defer logger.Close()
+
+		logger.Infof("tick")
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			if _, out := w.stats(); out == "tick\n" {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatal("expected the record to be flushed by the interval")
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	t.Run("Other Output Flushes", func(t *testing.T) {
+		t.Parallel()
+
+		w1, w2 := &writeCountingWriter{}, &writeCountingWriter{}
+		logger := New(WithOutput(w1), WithFormatter(Bare.NewFormatter()), WithWriteBuffer(1024, time.Hour))
+
+		logger.Infof("one")
+		logger.WithOutput(w2).Infof("two")
+
+		if _, out := w1.stats(); out != "one\n" {
+			t.Errorf("expected the first output to be flushed, got %q", out)
+		}
+
+		logger.Close()
+
+		if _, out := w2.stats(); out != "two\n" {
+			t.Errorf("expected the second output to get its record on Close, got %q", out)
+		}
+	})
+
+	t.Run("Fatal Flushes", func(t *testing.T) {
+		t.Parallel()
+
+		w := &writeCountingWriter{}
+		logger := New(WithOutput(w), WithFormatter(Bare.NewFormatter()), WithWriteBuffer(1024, time.Hour))
+
+		getExitCode := mockOsExit(t)
+
+		logger.Infof("before")
+		logger.Fatalf("fatal")
+
+		if getExitCode() != 1 {
+			t.Errorf("expected os.Exit(1) to be called, but exit code was %d", getExitCode())
+		}
+		if _, out := w.stats(); out != "before\nfatal\n" {
+			t.Errorf("unexpected output: %q", out)
+		}
+	})
+
+	t.Run("Invalid Arguments Panic", func(t *testing.T) {
+		t.Parallel()
+
+		for _, args := range []struct {
+			size     int
+			interval time.Duration
+		}{{0, time.Second}, {1024, 0}} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("expected a panic for size %d and interval %v", args.size, args.interval)
+					}
+				}()
+
+				WithWriteBuffer(args.size, args.interval)
+			}()
+		}
+	})
+}
+
+// discardCountingWriter counts the writes to it, discarding the written bytes.
+type discardCountingWriter struct {
+	writes atomic.Int64
+}
+
+func (w *discardCountingWriter) Write(p []byte) (int, error) {
+	w.writes.Add(1)
+
+	return io.Discard.Write(p)
+}
+
+// benchmarkWriteCount logs to a discardCountingWriter with the given options and
+// reports the number of writes to it per log call.
+func benchmarkWriteCount(b *testing.B, opts ...Option) {
+	w := &discardCountingWriter{}
+	logger := New(append([]Option{WithOutput(w)}, opts...)...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		logger.Infow("request handled", "method", "GET", "status", 200, "latency_ms", 12.5)
+	}
+
+	logger.Close()
+	b.StopTimer()
+
+	b.ReportMetric(float64(w.writes.Load())/float64(b.N), "writes/op")
+}
+
+// BenchmarkWriteBuffer_Unbuffered measures the writes of the default write path.
+func BenchmarkWriteBuffer_Unbuffered(b *testing.B) {
+	benchmarkWriteCount(b)
+}
+
+// BenchmarkWriteBuffer_Buffered measures the writes with WithWriteBuffer.
+func BenchmarkWriteBuffer_Buffered(b *testing.B) {
+	benchmarkWriteCount(b, WithWriteBuffer(32*1024, 100*time.Millisecond))
+}
+
+// TestLabelsPersistAcrossEntries verifies that writing an entry does not clear the
+// labels of the logger.
+func TestLabelsPersistAcrossEntries(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := New(WithOutput(&buf), WithLabels(map[string]string{"service": "api"}))
+
+	logger.Infof("first")
+	logger.Infof("second")
+
+	if got := strings.Count(buf.String(), `"labels":{"service":"api"}`); got != 2 {
+		t.Errorf("expected both entries to have the labels, got %q", buf.String())
+	}
+}
+
+// TestOTelRecordHook verifies that OTelRecordHook converts entries to the OpenTelemetry
+// log data model.
+func TestOTelRecordHook(t *testing.T) {
+	t.Parallel()
+
+	// The emit function plays the OpenTelemetry logger, capturing the records.
+	var records []OTelRecord
+
+	sampled := true
+	logger := New(
+		WithOutput(io.Discard),
+		WithLogLevel(LogLevelTrace),
+		WithLabels(map[string]string{"service": "api"}),
+		WithHooks(OTelRecordHook(func(record OTelRecord) {
+			records = append(records, record)
+		})),
+	).WithTrace("projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736").
+		WithSpanId("00f067aa0ba902b7").
+		WithTraceSampled(&sampled)
+
+	logger.Warnw("disk almost full", "usage", 0.93)
+	logger.Tracef("tick")
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	r := records[0]
+	if r.SeverityNumber != 13 || r.SeverityText != "WARN" || r.Body != "disk almost full" || r.Timestamp.IsZero() {
+		t.Errorf("unexpected record: %+v", r)
+	}
+	if r.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || r.SpanID != "00f067aa0ba902b7" || r.TraceFlags != 1 {
+		t.Errorf("unexpected trace context: %q %q %d", r.TraceID, r.SpanID, r.TraceFlags)
+	}
+	if r.Attributes["usage"] != 0.93 || r.Attributes["label.service"] != "api" {
+		t.Errorf("unexpected attributes: %v", r.Attributes)
+	}
+
+	if records[1].SeverityNumber != 1 {
+		t.Errorf("expected TRACE to map to severity number 1, got %d", records[1].SeverityNumber)
+	}
+
+	for level, want := range map[LogLevel]int{LogLevelCritical: 21, LogLevelError: 17, LogLevelInfo: 9, LogLevelDebug: 5} {
+		if got := toOTelRecord(&LogEntry{Severity: level}).SeverityNumber; got != want {
+			t.Errorf("severity number of %s = %d, want %d", level, got, want)
+		}
+	}
+}
+
+// TestLoggerHook verifies that LoggerHook re-dispatches entries through the target logger.
+func TestLoggerHook(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Target Receives Error Entries", func(t *testing.T) {
+		t.Parallel()
+
+		var mainBuf, errorBuf bytes.Buffer
+
+		errorLog := New(WithOutput(&errorBuf), WithLogLevel(LogLevelError), WithLabels(map[string]string{"target": "yes"}))
+		logger := New(
+			WithOutput(&mainBuf),
+			WithFormatter(Text.NewFormatter()),
+			WithAutoSource(SourceLocationModeAlways),
+			WithHooks(LoggerHook(errorLog)),
+		)
+
+		logger.Infof("started")
+		logger.Errorw("failed", "code", 42)
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(errorBuf.String()), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("expected only the error entry in the target, got %q", errorBuf.String())
+		}
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+			t.Fatalf("expected the target's JSON format: %v", err)
+		}
+
+		if entry["message"] != "failed" || entry["severity"] != "ERROR" || entry["code"] != float64(42) {
+			t.Errorf("unexpected entry: %v", entry)
+		}
+		if _, ok := entry["labels"]; ok {
+			t.Errorf("expected the target's labels not to be added, got %v", entry["labels"])
+		}
+
+		// Frames of this package, including the tests, are skipped, so the original
+		// call is attributed to the test runner rather than to the hook worker.
+		source, _ := entry["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+		if function, _ := source["function"].(string); function != "testing.tRunner" {
+			t.Errorf("expected the source location of the original call, got %v", source)
+		}
+
+		if strings.Count(mainBuf.String(), "\n") != 2 {
+			t.Errorf("expected both entries in the main output, got %q", mainBuf.String())
+		}
+	})
+
+	t.Run("Cycle Does Not Loop", func(t *testing.T) {
+		t.Parallel()
+
+		var bufA, bufB bytes.Buffer
+
+		hookToB := &loggerHook{}
+		a := New(WithOutput(&bufA), WithFormatter(Bare.NewFormatter()), WithHooks(hookToB))
+		b := New(WithOutput(&bufB), WithFormatter(Bare.NewFormatter()), WithHooks(LoggerHook(a)))
+		hookToB.target = b
+
+		a.Infof("ping")
+
+		if err := a.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+		if err := b.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		// a -> b, then the hook of b stops as a has already written the entry.
+		if bufA.String() != "ping\n" || bufB.String() != "ping\n" {
+			t.Errorf("unexpected outputs: a=%q b=%q", bufA.String(), bufB.String())
+		}
+	})
+
+	t.Run("Hook To Itself Writes Once", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		toSelf := &loggerHook{}
+		logger := New(WithOutput(&buf), WithFormatter(Bare.NewFormatter()), WithHooks(toSelf))
+		toSelf.target = logger
+
+		logger.Infof("ping")
+
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+
+		if buf.String() != "ping\n" {
+			t.Errorf("expected a single write, got %q", buf.String())
+		}
+	})
+}
+
+func TestOnceFields(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Only the first entry gets the fields", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithOnceFields("config", "debug=true", "user", "default"))
+
+		logger.Debugf("filtered")
+		logger.Infow("first", "user", "u-1")
+		logger.With("component", "db").Infof("second")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %q", buf.String())
+		}
+		if !strings.Contains(lines[0], `"config":"debug=true"`) || !strings.Contains(lines[0], `"user":"u-1"`) {
+			t.Errorf("expected the once fields in the first entry, with the entry's fields first, got %s", lines[0])
+		}
+		if strings.Contains(lines[1], "config") {
+			t.Errorf("expected no once fields in the second entry, got %s", lines[1])
+		}
+	})
+
+	t.Run("WithOnceFields rearms the fields", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithOnceFields("config", "a"))
+		logger.Infof("first")
+
+		logger.WithOnceFields("config", "b").Infof("second")
+
+		if !strings.Contains(buf.String(), `"config":"b"`) {
+			t.Errorf("expected the new once fields, got %s", buf.String())
+		}
+	})
+
+	t.Run("Concurrent first entries", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithOnceFields("config", "x"))
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				logger.Infof("concurrent")
+			}()
+		}
+
+		wg.Wait()
+
+		if n := strings.Count(buf.String(), `"config":"x"`); n != 1 {
+			t.Errorf("expected exactly one entry with the once fields, got %d", n)
+		}
+	})
+
+	t.Run("Odd number of arguments panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for an odd number of arguments")
+			}
+		}()
+
+		WithOnceFields("config")
+	})
+}
+
+func TestLogStartup(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := New(WithOutput(&buf))
+
+	logger.LogStartup("service started", "port", 8080)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal output: %v", err)
+	}
+
+	if got["message"] != "service started" || got["severity"] != "INFO" {
+		t.Errorf("unexpected entry: %v", got)
+	}
+	if got["go_version"] != runtime.Version() || got["pid"] != float64(os.Getpid()) || got["port"] != float64(8080) {
+		t.Errorf("expected the process information and the given fields, got %v", got)
+	}
+}
+
+func TestNamedLoggers(t *testing.T) {
+	// The registry is global, so this test does not run in parallel.
+	t.Cleanup(func() { _ = CloseLoggers() })
+
+	if GetLogger("audit") != GetLogger("audit") {
+		t.Error("expected GetLogger to return the same logger for a name")
+	}
+	if GetLogger("audit") == GetLogger("access") {
+		t.Error("expected different loggers for different names")
+	}
+
+	var buf bytes.Buffer
+
+	ConfigureLogger("audit", WithOutput(&buf), WithFormatter(Logfmt.NewFormatter()))
+	GetLogger("audit").Infow("user deleted", "user", "u-1")
+
+	if !strings.Contains(buf.String(), `message="user deleted"`) || !strings.Contains(buf.String(), "user=u-1") {
+		t.Errorf("expected the configured logger to be used, got %q", buf.String())
+	}
+
+	t.Run("Concurrent access", func(t *testing.T) {
+		var wg sync.WaitGroup
+
+		loggers := make([]*Logger, 20)
+
+		for i := range loggers {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				loggers[i] = GetLogger("concurrent")
+			}(i)
+		}
+
+		wg.Wait()
+
+		for _, l := range loggers {
+			if l != loggers[0] {
+				t.Fatal("expected all callers to get the same logger")
+			}
+		}
+	})
+
+	t.Run("CloseLoggers closes all", func(t *testing.T) {
+		out := &closeCountingWriter{}
+
+		ConfigureLogger("closing", WithOutputs(out, io.Discard))
+		closing := GetLogger("closing")
+
+		if err := CloseLoggers(); err != nil {
+			t.Fatalf("CloseLoggers returned an error: %v", err)
+		}
+
+		if n := out.closes.Load(); n != 1 {
+			t.Errorf("expected the output to be closed once, got %d", n)
+		}
+		if GetLogger("closing") == closing {
+			t.Error("expected a new logger after CloseLoggers")
+		}
+	})
+}
+
+func TestKeyNormalizer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Presets", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			key, snake, camel string
+		}{
+			{"userID", "user_id", "userID"},
+			{"user_id", "user_id", "userId"},
+			{"user-name", "user_name", "userName"},
+			{"UserName", "user_name", "userName"},
+			{"HTTPStatus", "http_status", "httpStatus"},
+			{"ID", "id", "id"},
+			{"user2ID", "user2_id", "user2ID"},
+			{"panic.value", "panic.value", "panic.value"},
+			{"request.RemoteAddr", "request.remote_addr", "request.remoteAddr"},
+			{"plain", "plain", "plain"},
+		}
+
+		for _, tt := range tests {
+			if got := SnakeCase(tt.key); got != tt.snake {
+				t.Errorf("SnakeCase(%q) = %q, want %q", tt.key, got, tt.snake)
+			}
+			if got := CamelCase(tt.key); got != tt.camel {
+				t.Errorf("CamelCase(%q) = %q, want %q", tt.key, got, tt.camel)
+			}
+		}
+	})
+
+	t.Run("Payload and label keys are normalized", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(
+			WithOutput(&buf),
+			WithKeyNormalizer(SnakeCase),
+			WithLabels(map[string]string{"teamName": "core"}),
+		).With("requestID", "r-1")
+
+		logger.Infow("login", "userID", "u-1")
+
+		for _, want := range []string{`"request_id":"r-1"`, `"user_id":"u-1"`, `"team_name":"core"`} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("output %s does not contain %s", buf.String(), want)
+			}
+		}
+	})
+
+	t.Run("Collisions", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		logger := New(WithOutput(&buf), WithKeyNormalizer(SnakeCase))
+
+		logger.Infow("collision", "userID", "renamed", "user_id", "normalized", "orderID", "first", "order-id", "second")
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("failed to unmarshal output: %v", err)
+		}
+
+		// "order-id" sorts before "orderID".
+		if got["user_id"] != "normalized" || got["order_id"] != "second" {
+			t.Errorf("unexpected collision resolution: %v", got)
+		}
+		if _, ok := got["userID"]; ok {
+			t.Errorf("expected the original key to be removed: %v", got)
+		}
+	})
+
+	t.Run("nil normalizer panics", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for a nil normalizer")
+			}
+		}()
+
+		WithKeyNormalizer(nil)
+	})
+}
@@ -0,0 +1,42 @@
+package harelog
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// MetricValue is a numeric field tagged as a metric, such as a counter or gauge,
+// so that downstream processors can treat it specially.
+// It is a formatting convention layered on the payload, not a metrics backend.
+//
+// The JSON formatter writes it as {"value":12.3,"unit":"ms"}, while the
+// text-based formatters write it as name=12.3ms.
+type MetricValue struct {
+	Name  string
+	Value float64
+	Unit  string
+}
+
+// Metric returns a MetricValue with the given name, value and unit.
+// It is passed on its own in place of a key-value pair, with its name as the key:
+//
+//	logger.Infow("request handled", harelog.Metric("latency", 12.3, "ms"))
+func Metric(name string, value float64, unit string) MetricValue {
+	return MetricValue{Name: name, Value: value, Unit: unit}
+}
+
+// String returns the value followed by its unit, e.g. "12.3ms".
+func (m MetricValue) String() string {
+	return strconv.FormatFloat(m.Value, 'f', -1, 64) + m.Unit
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (m MetricValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Value float64 `json:"value"`
+		Unit  string  `json:"unit,omitempty"`
+	}{
+		Value: m.Value,
+		Unit:  m.Unit,
+	})
+}
@@ -0,0 +1,148 @@
+package harelog
+
+import (
+	"strings"
+	"time"
+)
+
+// otelSeverityNumbers maps each LogLevel to the severity number of the OpenTelemetry
+// log data model, using the first number of each range:
+//
+//	CRITICAL -> 21 (FATAL)
+//	ERROR    -> 17 (ERROR)
+//	WARN     -> 13 (WARN)
+//	INFO     ->  9 (INFO)
+//	DEBUG    ->  5 (DEBUG)
+//	TRACE    ->  1 (TRACE)
+var otelSeverityNumbers = map[LogLevel]int{
+	LogLevelCritical: 21,
+	LogLevelError:    17,
+	LogLevelWarn:     13,
+	LogLevelInfo:     9,
+	LogLevelDebug:    5,
+	LogLevelTrace:    1,
+}
+
+// OTelRecord is a log entry converted to the OpenTelemetry log data model by the
+// hook returned by OTelRecordHook.
+type OTelRecord struct {
+	Timestamp time.Time
+	// SeverityNumber is the OpenTelemetry severity number of the entry's level, or 0
+	// (unspecified) for an unknown level. SeverityText is the level itself.
+	SeverityNumber int
+	SeverityText   string
+	Body           string
+	// Attributes holds the payload fields as they are, the labels prefixed with
+	// "label.", and the source location, correlation ID and HTTP request under their
+	// OpenTelemetry semantic convention names (e.g. "code.function", "http.request.method").
+	Attributes map[string]any
+	// TraceID and SpanID are the hex IDs of the entry's trace, or empty.
+	// The "projects/PROJECT_ID/traces/" prefix of Cloud Trace is removed from TraceID.
+	TraceID string
+	SpanID  string
+	// TraceFlags is 1 if the trace is sampled, 0 otherwise.
+	TraceFlags byte
+}
+
+// otelRecordHook is the Hook returned by OTelRecordHook.
+type otelRecordHook struct {
+	emit func(record OTelRecord)
+}
+
+// OTelRecordHook returns a Hook that converts each entry into an OTelRecord and passes
+// it to emit. It is the base of the hook of the harelogotel module, which emits the
+// records to a Logger of the OpenTelemetry Logs API, for programs that do not use it:
+// emit builds the log.Record and emits it:
+//
+//	harelog.OTelRecordHook(func(r harelog.OTelRecord) {
+//		var rec log.Record
+//		rec.SetTimestamp(r.Timestamp)
+//		rec.SetSeverity(log.Severity(r.SeverityNumber))
+//		rec.SetSeverityText(r.SeverityText)
+//		rec.SetBody(log.StringValue(r.Body))
+//		// ... add r.Attributes with rec.AddAttributes
+//		otelLogger.Emit(ctx, rec)
+//	})
+//
+// The hook fires for all levels. It panics if emit is nil.
+func OTelRecordHook(emit func(record OTelRecord)) Hook {
+	if emit == nil {
+		panic("harelog: nil emit function provided to OTelRecordHook")
+	}
+
+	return &otelRecordHook{emit: emit}
+}
+
+// Levels implements the Hook interface. It returns an empty slice, so the hook
+// fires for all levels.
+func (h *otelRecordHook) Levels() []LogLevel {
+	return []LogLevel{}
+}
+
+// Fire implements the Hook interface.
+func (h *otelRecordHook) Fire(entry *LogEntry) error {
+	h.emit(toOTelRecord(entry))
+
+	return nil
+}
+
+// toOTelRecord converts an entry to the OpenTelemetry log data model.
+func toOTelRecord(e *LogEntry) OTelRecord {
+	r := OTelRecord{
+		Timestamp:      e.Time,
+		SeverityNumber: otelSeverityNumbers[e.Severity],
+		SeverityText:   string(e.Severity),
+		Body:           e.Message,
+		Attributes:     make(map[string]any, len(e.Payload)+len(e.Labels)),
+		SpanID:         e.SpanID,
+	}
+
+	if e.Trace != "" {
+		r.TraceID = e.Trace
+		if i := strings.LastIndex(e.Trace, "/traces/"); i >= 0 {
+			r.TraceID = e.Trace[i+len("/traces/"):]
+		}
+	}
+
+	if e.TraceSampled != nil && *e.TraceSampled {
+		r.TraceFlags = 1
+	}
+
+	for k, v := range e.Payload {
+		r.Attributes[k] = v
+	}
+
+	for k, v := range e.Labels {
+		r.Attributes["label."+k] = v
+	}
+
+	if e.SourceLocation != nil {
+		r.Attributes["code.filepath"] = e.SourceLocation.File
+		r.Attributes["code.lineno"] = e.SourceLocation.Line
+		r.Attributes["code.function"] = e.SourceLocation.Function
+	}
+
+	if e.CorrelationID != "" {
+		r.Attributes["correlationId"] = e.CorrelationID
+	}
+
+	if req := e.HTTPRequest; req != nil {
+		setOTelAttribute(r.Attributes, "http.request.method", req.RequestMethod)
+		setOTelAttribute(r.Attributes, "url.full", req.RequestURL)
+		setOTelAttribute(r.Attributes, "user_agent.original", req.UserAgent)
+		setOTelAttribute(r.Attributes, "client.address", req.RemoteIP)
+
+		if req.Status != 0 {
+			r.Attributes["http.response.status_code"] = req.Status
+		}
+	}
+
+	return r
+}
+
+// setOTelAttribute sets the attribute if value is not empty.
+func setOTelAttribute(attrs map[string]any, key, value string) {
+	if value != "" {
+		attrs[key] = value
+	}
+}
@@ -0,0 +1,105 @@
+package harelog
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// packageLevels holds the levels set with SetPackageLevel, keyed by package path.
+// The map is replaced, never modified, so that it is read without a lock.
+var packageLevels atomic.Pointer[map[string]logLevelValue]
+
+// packageLevelsMu serializes the updates of packageLevels.
+var packageLevelsMu sync.Mutex
+
+// SetPackageLevel sets the log level of the log calls made from the package with the
+// import path pkg, e.g. "github.com/you/app/db", and from its sub-packages, unless
+// they have a level of their own. It replaces the level of the logger for those calls,
+// so it can both enable Debug for one package and silence another.
+//
+// The levels apply only to loggers created with WithPackageLevelOverrides(true), as
+// finding the package of the caller costs a stack walk on every level check.
+// This function is safe for concurrent use. It panics if pkg is empty or the level
+// is invalid.
+func SetPackageLevel(pkg string, level LogLevel) {
+	if pkg == "" {
+		panic("harelog: empty package provided to SetPackageLevel")
+	}
+
+	lv, ok := levelMap[level]
+	if !ok {
+		panic(fmt.Sprintf("harelog: invalid log level provided to SetPackageLevel: %q", level))
+	}
+
+	packageLevelsMu.Lock()
+	defer packageLevelsMu.Unlock()
+
+	levels := make(map[string]logLevelValue)
+	if old := packageLevels.Load(); old != nil {
+		maps.Copy(levels, *old)
+	}
+
+	levels[strings.TrimSuffix(pkg, "/")] = lv
+
+	packageLevels.Store(&levels)
+}
+
+// ResetPackageLevels removes the levels set with SetPackageLevel.
+// This function is safe for concurrent use.
+func ResetPackageLevels() {
+	packageLevelsMu.Lock()
+	defer packageLevelsMu.Unlock()
+
+	packageLevels.Store(nil)
+}
+
+// packageLevel returns the level set for the package pkg or for the closest package
+// it is a sub-package of.
+func packageLevel(pkg string) (logLevelValue, bool) {
+	levels := packageLevels.Load()
+	if levels == nil || pkg == "" {
+		return 0, false
+	}
+
+	for {
+		if lv, ok := (*levels)[pkg]; ok {
+			return lv, true
+		}
+
+		i := strings.LastIndexByte(pkg, '/')
+		if i < 0 {
+			return 0, false
+		}
+
+		pkg = pkg[:i]
+	}
+}
+
+// level returns the level in effect for the caller: the level set with SetPackageLevel
+// for its package if WithPackageLevelOverrides is enabled, or else the logger's level.
+func (l *Logger) level() logLevelValue {
+	if l.packageLevelOverrides && packageLevels.Load() != nil {
+		if frame, ok := l.callerFrame(); ok {
+			if lv, ok := packageLevel(functionPackage(frame.Function)); ok {
+				return lv
+			}
+		}
+	}
+
+	return logLevelValue(l.logLevel.Load())
+}
+
+// functionPackage returns the import path of the package of a fully qualified
+// function name, e.g. "github.com/you/app/db" for "github.com/you/app/db.(*Repo).Find".
+func functionPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+
+	return function
+}
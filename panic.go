@@ -0,0 +1,139 @@
+package harelog
+
+import (
+	"fmt"
+	"maps"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Payload keys of the entries built by PanicEntry.
+const (
+	panicValueKey = "panic.value"
+	panicTypeKey  = "panic.type"
+)
+
+// PanicEntry builds an Error entry describing a value recovered from a panic.
+// The payload holds the value rendered as a string under "panic.value" (the message
+// of an error, the fields of a struct), its Go type under "panic.type" and the stack
+// of the panicking goroutine under "stack_trace". SourceLocation is set to the
+// function that panicked.
+//
+// It must be called while the goroutine is still panicking, i.e. from the deferred
+// function that recovered r, so that the stack includes the panicking frames.
+func PanicEntry(r any) *LogEntry {
+	return &LogEntry{
+		Severity:       LogLevelError,
+		Message:        "recovered from panic",
+		Time:           time.Now(),
+		SourceLocation: panicSite(),
+		Payload: map[string]interface{}{
+			panicValueKey: panicValue(r),
+			panicTypeKey:  fmt.Sprintf("%T", r),
+			stackTraceKey: stackTrace(false, nil),
+		},
+	}
+}
+
+// panicValue renders a recovered value for the "panic.value" field.
+func panicValue(r any) string {
+	switch v := r.(type) {
+	case error:
+		return v.Error()
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%+v", v)
+	}
+}
+
+// panicSite returns the location of the function that panicked: the first frame
+// below runtime.gopanic that is outside harelog and the runtime. If the goroutine
+// is not panicking, it is the first frame outside harelog.
+func panicSite() *SourceLocation {
+	pcs := make([]uintptr, 64)
+
+	// 0: Callers, 1: panicSite.
+	n := runtime.Callers(2, pcs)
+
+	var site *runtime.Frame
+
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+
+		switch {
+		case frame.Function == "runtime.gopanic":
+			// The frames found so far belong to the deferred call.
+			site = nil
+		case site == nil && !isHarelogFunction(frame.Function) && !strings.HasPrefix(frame.Function, "runtime."):
+			site = &frame
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	if site == nil {
+		return nil
+	}
+
+	return &SourceLocation{
+		File:     site.File,
+		Line:     site.Line,
+		Function: site.Function,
+	}
+}
+
+// RecoverAndLog recovers from a panic of the calling goroutine and logs it at the
+// Error level, in the structured form of PanicEntry. The goroutine then continues
+// as if the deferring function had returned normally.
+// It must be deferred directly, as recover only works in a deferred call:
+//
+//	defer logger.RecoverAndLog()
+func (l *Logger) RecoverAndLog() {
+	if r := recover(); r != nil {
+		l.logPanic(r)
+	}
+}
+
+// logPanic logs a recovered value at the Error level.
+func (l *Logger) logPanic(r any) {
+	ctx := l.boundContext()
+
+	if !l.isLevelEnabledCtx(ctx, LogLevelError) {
+		return
+	}
+
+	p := PanicEntry(r)
+
+	e := l.createEntry(ctx, LogLevelError, p.Message)
+	maps.Copy(e.Payload, p.Payload)
+
+	// The panic site is more useful than the caller of RecoverAndLog, which is the runtime.
+	if l.shouldCaptureSource(e) && p.SourceLocation != nil {
+		e.SourceLocation = p.SourceLocation
+
+		if l.sourceFunctionMode == SourceFunctionModeShort {
+			e.SourceLocation.Function = shortFunctionName(e.SourceLocation.Function)
+		}
+	}
+
+	l.dispatchEntry(e)
+}
+
+// RecoverAndLog recovers from a panic of the calling goroutine and logs it using
+// the default logger. It must be deferred directly:
+//
+//	defer harelog.RecoverAndLog()
+func RecoverAndLog() {
+	if r := recover(); r != nil {
+		stdMutex.RLock()
+		defer stdMutex.RUnlock()
+
+		std.logPanic(r)
+	}
+}
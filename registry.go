@@ -0,0 +1,86 @@
+package harelog
+
+import (
+	"errors"
+	"sync"
+)
+
+// The named loggers of GetLogger and ConfigureLogger.
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]*Logger)
+)
+
+// GetLogger returns the logger registered under name, such as "audit" for an audit
+// log kept apart from the operational logs. If no logger is registered under the name,
+// one is created with the settings of New and registered.
+//
+// A named logger lives until ConfigureLogger replaces it or CloseLoggers closes it;
+// callers that keep the returned logger keep using it after that, so call
+// ConfigureLogger at startup, before the logger is used.
+// This function is safe for concurrent use.
+func GetLogger(name string) *Logger {
+	registryMutex.RLock()
+	l, ok := registry[name]
+	registryMutex.RUnlock()
+
+	if ok {
+		return l
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if l, ok := registry[name]; ok {
+		return l
+	}
+
+	l = New()
+	registry[name] = l
+
+	return l
+}
+
+// ConfigureLogger creates a logger with opts and registers it under name, for
+// GetLogger to return it:
+//
+//	harelog.ConfigureLogger("audit", harelog.WithOutput(auditFile), harelog.WithFormatter(harelog.Logfmt.NewFormatter()))
+//	harelog.GetLogger("audit").Infow("user deleted", "user", id)
+//
+// The logger previously registered under the name, if any, is replaced like the
+// default logger by SetDefault: its hook worker and concurrent writer are closed after
+// their buffered entries are processed, and its outputs are left open.
+// This function is safe for concurrent use.
+func ConfigureLogger(name string, opts ...Option) {
+	l := New(opts...)
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if old, ok := registry[name]; ok {
+		old.retire(l)
+	}
+
+	registry[name] = l
+}
+
+// CloseLoggers closes all the named loggers, like Close, and unregisters them.
+// A later GetLogger creates a new logger. The default logger is not closed.
+// It returns the errors of all the loggers that failed to close.
+// This function is safe for concurrent use.
+func CloseLoggers() error {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	var errs []error
+
+	for name, l := range registry {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+
+		delete(registry, name)
+	}
+
+	return errors.Join(errs...)
+}
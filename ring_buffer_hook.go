@@ -0,0 +1,94 @@
+package harelog
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+)
+
+// RingBufferHook is a Hook that keeps the most recent entries in memory, so that
+// they can be written out for post-mortem debugging, e.g. from a crash handler.
+// It fires for all levels; register it with WithHooks, or WithHookMinLevel to
+// narrow it down.
+//
+// Like all hooks, it receives entries asynchronously, so the entries logged just
+// before Dump may still be in the hook queue. Call Close or CloseContext on the
+// logger first to drain the queue when the last entries matter.
+type RingBufferHook struct {
+	mu      sync.Mutex
+	entries []*LogEntry
+	next    int // index of the slot for the next entry
+	full    bool
+}
+
+// NewRingBufferHook creates a RingBufferHook that keeps the last size entries.
+// It panics if size is not positive.
+func NewRingBufferHook(size int) *RingBufferHook {
+	if size <= 0 {
+		panic(fmt.Sprintf("harelog: non-positive size provided to NewRingBufferHook: %d", size))
+	}
+
+	return &RingBufferHook{entries: make([]*LogEntry, size)}
+}
+
+// Levels implements the Hook interface. It returns an empty slice, so the hook
+// fires for all levels.
+func (h *RingBufferHook) Levels() []LogLevel {
+	return []LogLevel{}
+}
+
+// Fire implements the Hook interface. It stores the entry, overwriting the oldest
+// one when the buffer is full.
+func (h *RingBufferHook) Fire(entry *LogEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+
+	if h.next == 0 {
+		h.full = true
+	}
+
+	return nil
+}
+
+// Dump writes the retained entries to w, oldest first, in the JSON format, one per line.
+// The entries are kept, so Dump can be called again.
+func (h *RingBufferHook) Dump(w io.Writer) error {
+	return h.DumpWith(w, JSON.NewFormatter())
+}
+
+// DumpWith writes the retained entries to w, oldest first, formatted by f, one per line.
+// The entries are kept, so DumpWith can be called again.
+func (h *RingBufferHook) DumpWith(w io.Writer, f Formatter) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	start := 0
+	n := h.next
+
+	if h.full {
+		start = h.next
+		n = len(h.entries)
+	}
+
+	for i := 0; i < n; i++ {
+		// Formatters may mask fields in place, so each dump formats its own copy.
+		entry := *h.entries[(start+i)%len(h.entries)]
+		entry.Labels = maps.Clone(entry.Labels)
+		entry.Payload = maps.Clone(entry.Payload)
+
+		out, err := f.Format(&entry)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(append(out, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package harelog
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// CloseOnSignal starts a goroutine that closes the default logger, flushing its
+// hooks, when one of the given signals is received, and then stops listening.
+// With no signals, it listens for SIGINT and SIGTERM. It returns a function that
+// stops listening.
+//
+// It does not terminate the process, nor raise the signal again: the application
+// keeps its own shutdown handling, such as a context from signal.NotifyContext,
+// which receives the signal as well. Call it once at startup:
+//
+//	func main() {
+//		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+//		defer cancel()
+//
+//		stop := harelog.CloseOnSignal()
+//		defer stop()
+//		// ...
+//	}
+func CloseOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		select {
+		case sig := <-ch:
+			signal.Stop(ch)
+
+			if err := Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "harelog: failed to close the default logger on %v: %v\n", sig, err)
+			}
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			// Stop delivery synchronously, so no signal is handled after stop returns.
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package harelog
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// sourceSnippetKey is the payload key of the source line added by WithSourceSnippet.
+const sourceSnippetKey = "sourceSnippet"
+
+// addSourceSnippet adds the source line of the entry's location to its payload,
+// unless the payload already has the key or the line cannot be read.
+func addSourceSnippet(e *LogEntry) {
+	if _, ok := e.Payload[sourceSnippetKey]; ok {
+		return
+	}
+
+	if line, ok := sourceLine(e.SourceLocation.File, e.SourceLocation.Line); ok {
+		e.Payload[sourceSnippetKey] = line
+	}
+}
+
+// sourceLine returns the line n of the file, with its leading and trailing white space
+// removed. It reports false if the file cannot be read or has fewer lines.
+func sourceLine(file string, n int) (string, bool) {
+	if n <= 0 {
+		return "", false
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for i := 1; scanner.Scan(); i++ {
+		if i == n {
+			return strings.TrimSpace(scanner.Text()), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,125 @@
+package harelog
+
+import (
+	"fmt"
+	"strings"
+
+	json "github.com/goccy/go-json"
+)
+
+// SQLArgMode defines how WithSQLArgMode writes the arguments of a SQLQuery.
+type SQLArgMode int
+
+const (
+	// SQLArgModeRedact replaces each argument with "[MASKED]". This is the default.
+	SQLArgModeRedact SQLArgMode = iota
+	// SQLArgModeTypes writes the Go type of each argument, e.g. "int64", instead of its value.
+	SQLArgModeTypes
+	// SQLArgModeValues writes the arguments as they are. Use it only where the
+	// arguments are known not to contain personal data, e.g. in development.
+	SQLArgModeValues
+)
+
+// validateSQLArgMode panics if the mode is unknown.
+func validateSQLArgMode(mode SQLArgMode) {
+	switch mode {
+	case SQLArgModeRedact, SQLArgModeTypes, SQLArgModeValues:
+	default:
+		panic(fmt.Sprintf("harelog: invalid SQLArgMode provided: %d", mode))
+	}
+}
+
+// SQLQuery is a database query with its arguments. The query is written as it
+// is, with its "?" or "$1" placeholders, while the arguments are written according
+// to the logger's SQLArgMode, redacted by default, so that personal data passed as
+// query parameters does not leak into the logs:
+//
+//	logger.Debugw("query", "sql", harelog.SQLQuery{Query: q, Args: args})
+//
+// The JSON formatter writes it as an object, e.g.
+// {"query":"SELECT * FROM users WHERE id = $1","args":["[MASKED]"]}, while the
+// text-based formatters write a compact form, e.g.
+// "SELECT * FROM users WHERE id = $1 [args: [MASKED]]".
+//
+// Outside of the logger's output, e.g. in hooks, the arguments are always redacted.
+type SQLQuery struct {
+	Query string
+	Args  []any
+
+	argMode SQLArgMode
+}
+
+// args returns the arguments as written under the query's mode.
+func (q SQLQuery) args() []any {
+	args := make([]any, len(q.Args))
+
+	for i, arg := range q.Args {
+		switch q.argMode {
+		case SQLArgModeTypes:
+			args[i] = sqlArgType(arg)
+		case SQLArgModeValues:
+			args[i] = arg
+		default:
+			args[i] = maskedValueString
+		}
+	}
+
+	return args
+}
+
+// String returns the compact form, e.g. "SELECT * FROM users WHERE id = $1 [args: [MASKED]]".
+func (q SQLQuery) String() string {
+	if len(q.Args) == 0 {
+		return q.Query
+	}
+
+	var b strings.Builder
+
+	b.WriteString(q.Query)
+	b.WriteString(" [args: ")
+
+	for i, arg := range q.args() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		fmt.Fprint(&b, arg)
+	}
+
+	b.WriteByte(']')
+
+	return b.String()
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (q SQLQuery) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Query string `json:"query"`
+		Args  []any  `json:"args,omitempty"`
+	}{Query: q.Query}
+
+	if len(q.Args) > 0 {
+		v.Args = q.args()
+	}
+
+	return json.Marshal(v)
+}
+
+// sqlArgType returns the name of the type of arg, or "nil".
+func sqlArgType(arg any) string {
+	if arg == nil {
+		return "nil"
+	}
+
+	return fmt.Sprintf("%T", arg)
+}
+
+// applySQLArgMode sets the logger's SQLArgMode on the SQLQuery values of the payload.
+func applySQLArgMode(e *LogEntry, mode SQLArgMode) {
+	for k, v := range e.Payload {
+		if q, ok := v.(SQLQuery); ok {
+			q.argMode = mode
+			e.Payload[k] = q
+		}
+	}
+}
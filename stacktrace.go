@@ -0,0 +1,147 @@
+package harelog
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// stackTraceKey is the payload key under which captured stack traces are logged.
+// It is recognized by Google Cloud Error Reporting.
+const stackTraceKey = "stack_trace"
+
+// StackTraceFilter decides whether a frame is included in a captured stack trace.
+// It returns true to keep the frame.
+type StackTraceFilter func(frame runtime.Frame) bool
+
+// captureStackTrace returns the stack of the calling goroutine, one frame per
+// "function\n\tfile:line" pair. Frames inside harelog are always omitted.
+func (l *Logger) captureStackTrace() string {
+	return stackTrace(l.minifyStackTrace, l.stackTraceFilter)
+}
+
+// stackTrace is captureStackTrace with the given minification and filter.
+func stackTrace(minify bool, filter StackTraceFilter) string {
+	pcs := make([]uintptr, 64)
+
+	// 0: Callers, 1: stackTrace. The remaining harelog frames are omitted below.
+	n := runtime.Callers(2, pcs)
+
+	return formatStackTrace(pcs[:n], minify, filter)
+}
+
+// formatStackTrace formats the frames of the program counters pcs, as returned by
+// runtime.Callers, like captureStackTrace.
+func formatStackTrace(pcs []uintptr, minify bool, filter StackTraceFilter) string {
+	frames := runtime.CallersFrames(pcs)
+
+	var b strings.Builder
+
+	for {
+		frame, more := frames.Next()
+
+		if !isHarelogFunction(frame.Function) &&
+			!(minify && isRuntimeEntryFunction(frame.Function)) &&
+			(filter == nil || filter(frame)) {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+
+			b.WriteString(frame.Function)
+			b.WriteString("()\n\t")
+			b.WriteString(frame.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+		}
+
+		// The frames above main.main belong to the runtime.
+		if !more || (minify && frame.Function == "main.main") {
+			break
+		}
+	}
+
+	return b.String()
+}
+
+// isRuntimeEntryFunction reports whether the function is one of the runtime's
+// goroutine entry points, which appear above main.main or a goroutine's function.
+func isRuntimeEntryFunction(function string) bool {
+	return function == "runtime.main" || function == "runtime.goexit"
+}
+
+// errorStackTrace returns the stack trace recorded by err, formatted like
+// captureStackTrace. Errors of github.com/pkg/errors record one where they are created
+// or wrapped; the innermost one among the wrapped errors, which is closest to the
+// origin of the error, is used. It reports false if no wrapped error has one.
+func (l *Logger) errorStackTrace(err error) (string, bool) {
+	pcs, _ := innermostErrorStack(err, 0)
+	if len(pcs) == 0 {
+		return "", false
+	}
+
+	return formatStackTrace(pcs, l.minifyStackTrace, l.stackTraceFilter), true
+}
+
+// innermostErrorStack returns the program counters of the most deeply wrapped error
+// with a stack trace in the tree of err, and its depth, or -1 if there is none.
+// The tree is walked depth-first, as errors.As does, following both Unwrap() error
+// and Unwrap() []error, as returned by errors.Join and fmt.Errorf with several %w
+// verbs; of several errors at the same depth, the first one is used.
+func innermostErrorStack(err error, depth int) ([]uintptr, int) {
+	if err == nil {
+		return nil, -1
+	}
+
+	pcs, at := []uintptr(nil), -1
+	if p, ok := errorStackPCs(err); ok {
+		pcs, at = p, depth
+	}
+
+	var wrapped []error
+
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		wrapped = []error{u.Unwrap()}
+	case interface{ Unwrap() []error }:
+		wrapped = u.Unwrap()
+	}
+
+	for _, w := range wrapped {
+		if p, d := innermostErrorStack(w, depth+1); d > at {
+			pcs, at = p, d
+		}
+	}
+
+	return pcs, at
+}
+
+// errorStackPCs returns the program counters of the stack trace of err, if it has a
+// StackTrace method returning a slice of uintptr-based frames, like github.com/pkg/errors'
+// StackTrace() errors.StackTrace. The method is found by reflection, as an interface
+// would have to name the errors.StackTrace type and so depend on the package.
+func errorStackPCs(err error) ([]uintptr, bool) {
+	rv := reflect.ValueOf(err)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, false
+	}
+
+	m := rv.MethodByName("StackTrace")
+	if !m.IsValid() {
+		return nil, false
+	}
+
+	if t := m.Type(); t.NumIn() != 0 || t.NumOut() != 1 ||
+		t.Out(0).Kind() != reflect.Slice || t.Out(0).Elem().Kind() != reflect.Uintptr {
+		return nil, false
+	}
+
+	frames := m.Call(nil)[0]
+
+	pcs := make([]uintptr, frames.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(frames.Index(i).Uint())
+	}
+
+	return pcs, true
+}
@@ -0,0 +1,82 @@
+package harelog
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync/atomic"
+)
+
+// Payload keys of the process information added by LogStartup,
+// in addition to the build information.
+const (
+	startupGoVersionKey = "go_version"
+	startupPIDKey       = "pid"
+)
+
+// startupFields returns the key-value pairs LogStartup adds to its entry.
+func startupFields() []interface{} {
+	info := buildInfo()
+
+	kvs := make([]interface{}, 0, 2*len(info)+4)
+	for k, v := range info {
+		kvs = append(kvs, k, v)
+	}
+
+	return append(kvs, startupGoVersionKey, runtime.Version(), startupPIDKey, os.Getpid())
+}
+
+// LogStartup logs a message at the Info level with the build information of the binary
+// (see WithBuildInfo), the Go version ("go_version") and the process ID ("pid"),
+// followed by the given key-value pairs, such as the configuration of the service.
+// It is meant to be called once, when the service starts.
+func (l *Logger) LogStartup(msg string, kvs ...interface{}) {
+	l.Infow(msg, append(startupFields(), kvs...)...)
+}
+
+// LogStartup logs a startup message using the default logger.
+// See (*Logger).LogStartup for details.
+func LogStartup(msg string, kvs ...interface{}) {
+	stdMutex.RLock()
+	defer stdMutex.RUnlock()
+
+	std.LogStartup(msg, kvs...)
+}
+
+// setOnceFields sets the fields of WithOnceFields, which the number of arguments has
+// been checked for, and rearms them so that the next formatted entry gets them.
+// It panics if a key is not a string.
+func (l *Logger) setOnceFields(kvs []interface{}) {
+	fields := make(map[string]interface{}, len(kvs)/2)
+
+	for i := 0; i < len(kvs); i += 2 {
+		key, ok := kvs[i].(string)
+		if !ok {
+			panic(fmt.Sprintf("harelog: non-string key at argument position %d provided to WithOnceFields", i))
+		}
+
+		if handleInvalidKey(l, key, "field") {
+			continue
+		}
+
+		fields[key] = kvs[i+1]
+	}
+
+	l.onceFields = fields
+	l.onceFieldsDone = new(atomic.Bool)
+}
+
+// applyOnceFields adds the fields of WithOnceFields to the entry if it is the first
+// one formatted by the logger or the loggers derived from it. Fields already in the
+// entry are kept.
+func (l *Logger) applyOnceFields(e *LogEntry) {
+	if len(l.onceFields) == 0 || !l.onceFieldsDone.CompareAndSwap(false, true) {
+		return
+	}
+
+	for k, v := range l.onceFields {
+		if _, ok := e.Payload[k]; !ok {
+			e.Payload[k] = v
+		}
+	}
+}
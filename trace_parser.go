@@ -0,0 +1,25 @@
+package harelog
+
+import "strings"
+
+// TraceParser extracts trace data from the value stored in a context.Context under
+// the trace context key (see WithTraceContextKey and WithTraceParser).
+// trace is the trace ID, which is logged as "projects/<projectID>/traces/<trace>".
+// Empty results and a nil sampled are left unset. ok reports whether value was recognized.
+type TraceParser func(value interface{}) (trace, spanID string, sampled *bool, ok bool)
+
+// parseTraceHeader is the default TraceParser. It parses a string in the format of
+// the X-Cloud-Trace-Context header, "TRACE_ID/SPAN_ID;o=OPTIONS".
+func parseTraceHeader(value interface{}) (trace, spanID string, sampled *bool, ok bool) {
+	header, ok := value.(string)
+	if !ok {
+		return "", "", nil, false
+	}
+
+	trace, rest, found := strings.Cut(header, "/")
+	if found {
+		spanID, _, _ = strings.Cut(rest, ";")
+	}
+
+	return trace, spanID, nil, true
+}
@@ -0,0 +1,149 @@
+package harelog
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// TruncationStrategy defines how an entry exceeding the size set by
+// WithMaxEntrySize is shrunk.
+type TruncationStrategy int
+
+const (
+	// TruncateLargestField drops the largest payload fields, one at a time, until
+	// the entry fits. If no payload field is left, the message is truncated.
+	TruncateLargestField TruncationStrategy = iota
+	// TruncateMessage shortens the message until the entry fits.
+	TruncateMessage
+)
+
+// truncatedKey is the payload key marking an entry that was shrunk to fit WithMaxEntrySize.
+const truncatedKey = "truncated"
+
+// truncatedMarker is appended to a truncated message.
+const truncatedMarker = "...[TRUNCATED]"
+
+// fieldValueTruncatedMarker is appended to a field value cut by WithMaxFieldValueLength.
+const fieldValueTruncatedMarker = "...(truncated)"
+
+// validateTruncationStrategy panics if the strategy is unknown.
+func validateTruncationStrategy(strategy TruncationStrategy) {
+	switch strategy {
+	case TruncateLargestField, TruncateMessage:
+	default:
+		panic(fmt.Sprintf("harelog: invalid TruncationStrategy provided: %d", strategy))
+	}
+}
+
+// truncate shrinks the entry according to the logger's strategy and re-formats it
+// until the output fits l.maxEntrySize or the entry cannot be shrunk any further.
+// The entry is marked with truncated=true.
+func (l *Logger) truncate(e *LogEntry, out []byte) ([]byte, error) {
+	for len(out) > l.maxEntrySize {
+		excess := len(out) - l.maxEntrySize
+
+		shrunk := false
+		if l.truncationStrategy == TruncateLargestField {
+			shrunk = dropLargestField(e)
+		}
+		if !shrunk {
+			shrunk = truncateMessage(e, excess)
+		}
+		if !shrunk {
+			break
+		}
+
+		e.Payload[truncatedKey] = true
+
+		var err error
+
+		out, err = l.formatter.Format(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// dropLargestField removes the payload field with the largest printed value.
+// It reports whether a field was removed.
+func dropLargestField(e *LogEntry) bool {
+	largestKey := ""
+	largestSize := -1
+
+	for k, v := range e.Payload {
+		if k == truncatedKey {
+			continue
+		}
+
+		if size := len(k) + len(fmt.Sprint(v)); size > largestSize {
+			largestKey = k
+			largestSize = size
+		}
+	}
+
+	if largestSize < 0 {
+		return false
+	}
+
+	delete(e.Payload, largestKey)
+
+	return true
+}
+
+// truncateMessage shortens the message by at least excess bytes, appending
+// truncatedMarker. It reports whether the message was shortened.
+func truncateMessage(e *LogEntry, excess int) bool {
+	msg := e.Message
+	if len(msg) > len(truncatedMarker) && msg[len(msg)-len(truncatedMarker):] == truncatedMarker {
+		msg = msg[:len(msg)-len(truncatedMarker)]
+	}
+
+	if msg == "" {
+		return false
+	}
+
+	cut := len(msg) - excess - len(truncatedMarker)
+	if cut < 0 {
+		cut = 0
+	}
+
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+
+	e.Message = msg[:cut] + truncatedMarker
+
+	return true
+}
+
+// truncateFieldValues cuts the payload values of the entry longer than max bytes.
+// Non-string values are measured and cut in their fmt.Sprint form.
+func truncateFieldValues(e *LogEntry, max int) {
+	for k, v := range e.Payload {
+		switch val := v.(type) {
+		case string:
+			if len(val) > max {
+				e.Payload[k] = cutFieldValue(val, max)
+			}
+		case bool, int, int32, int64, float32, float64:
+			// Never long enough to be cut; skip rendering them.
+		default:
+			if s := fmt.Sprint(val); len(s) > max {
+				e.Payload[k] = cutFieldValue(s, max)
+			}
+		}
+	}
+}
+
+// cutFieldValue cuts s to at most max bytes, without splitting a UTF-8 character,
+// and appends fieldValueTruncatedMarker.
+func cutFieldValue(s string, max int) string {
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return s[:cut] + fieldValueTruncatedMarker
+}
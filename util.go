@@ -1,6 +1,11 @@
 package harelog
 
-import "strings"
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
 // charsRequiringQuoting defines the set of characters that generally require
 // quoting when used in unquoted keys or values in simple key=value formats (like logfmt).
@@ -46,3 +51,114 @@ func needsQuoting(value string) bool {
 
 	return strings.ContainsAny(value, charsRequiringQuoting)
 }
+
+// isControlChar reports whether r is a C0 or C1 control character or DEL.
+// Such characters can inject line breaks or terminal escape sequences into the output.
+func isControlChar(r rune) bool {
+	return r < 0x20 || r == 0x7f || (r >= 0x80 && r <= 0x9f)
+}
+
+// containsControlChar reports whether s contains a control character or
+// bytes that are not valid UTF-8.
+func containsControlChar(s string) bool {
+	for _, r := range s {
+		if isControlChar(r) || r == utf8.RuneError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendEscapedControlChars writes s to b, escaping control characters and invalid
+// UTF-8 bytes as in Go string literals (e.g. \n, \x1b) so that s cannot span lines
+// or emit escape sequences.
+// Other characters, including backslashes, are written as-is.
+func appendEscapedControlChars(b *bytes.Buffer, s string) {
+	if !containsControlChar(s) {
+		b.WriteString(s)
+
+		return
+	}
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(b, `\x%02x`, s[i])
+
+			i++
+
+			continue
+		}
+
+		i += size
+
+		if !isControlChar(r) {
+			b.WriteRune(r)
+
+			continue
+		}
+
+		switch r {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x80 {
+				fmt.Fprintf(b, `\x%02x`, r)
+			} else {
+				fmt.Fprintf(b, `\u%04x`, r)
+			}
+		}
+	}
+}
+
+// countFormatVerbs counts the number of arguments consumed by the verbs in a
+// fmt-style format string, including '*' widths and precisions.
+// It returns -1 if the format uses explicit argument indexes (e.g. %[1]d),
+// since the number of consumed arguments cannot be determined by counting.
+func countFormatVerbs(format string) int {
+	n := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+
+		i++
+
+		// flags
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+
+		// width and precision
+		for i < len(format) && (format[i] == '*' || format[i] == '.' || (format[i] >= '0' && format[i] <= '9')) {
+			if format[i] == '*' {
+				n++
+			}
+
+			i++
+		}
+
+		if i >= len(format) {
+			// A trailing '%' without a verb.
+			return n
+		}
+
+		switch format[i] {
+		case '%':
+			// Literal percent sign, no argument consumed.
+		case '[':
+			return -1
+		default:
+			n++
+		}
+	}
+
+	return n
+}
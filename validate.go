@@ -0,0 +1,94 @@
+package harelog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	json "github.com/goccy/go-json"
+)
+
+// validateOutput checks that out is well-formed for the formatter that produced it.
+// Only the JSON and Logfmt formatters are supported; for other formatters, nil is returned.
+func validateOutput(f Formatter, out []byte) error {
+	switch f.(type) {
+	case *jsonFormatter:
+		if !json.Valid(out) {
+			return errors.New("output is not valid JSON")
+		}
+	case *logfmtFormatter:
+		return validateLogfmt(out)
+	}
+
+	return nil
+}
+
+// validateLogfmt checks that b is a single line of space-separated key=value pairs,
+// where values are either bare (without spaces, '=', '"' or control characters)
+// or valid Go-quoted strings.
+func validateLogfmt(b []byte) error {
+	i := 0
+
+	for i < len(b) {
+		// Key
+		start := i
+
+		for i < len(b) && b[i] != '=' {
+			if b[i] <= ' ' || b[i] == '"' {
+				return fmt.Errorf("invalid character %q in key at offset %d", b[i], i)
+			}
+
+			i++
+		}
+
+		if i == start || i >= len(b) {
+			return fmt.Errorf("missing key or '=' at offset %d", start)
+		}
+
+		// Skip '='
+		i++
+
+		// Value
+		if i < len(b) && b[i] == '"' {
+			start = i
+			i++
+
+			for i < len(b) && b[i] != '"' {
+				if b[i] == '\\' {
+					i++
+				}
+
+				i++
+			}
+
+			if i >= len(b) {
+				return fmt.Errorf("unterminated quoted value at offset %d", start)
+			}
+
+			i++
+
+			if _, err := strconv.Unquote(string(b[start:i])); err != nil {
+				return fmt.Errorf("invalid quoted value at offset %d: %w", start, err)
+			}
+		} else {
+			for i < len(b) && b[i] != ' ' {
+				if b[i] < ' ' || b[i] == '"' || b[i] == '=' {
+					return fmt.Errorf("invalid character %q in value at offset %d", b[i], i)
+				}
+
+				i++
+			}
+		}
+
+		// Separator
+		if i < len(b) {
+			if b[i] != ' ' || i+1 >= len(b) {
+				return fmt.Errorf("invalid separator at offset %d", i)
+			}
+
+			i++
+		}
+	}
+
+	return nil
+}
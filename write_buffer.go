@@ -0,0 +1,137 @@
+package harelog
+
+import (
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// writeBuffer coalesces the records of a logger into fewer writes to its output
+// (see WithWriteBuffer). Records are appended to the buffer in the order they are
+// written, and the buffer is written to the output when it reaches its size, when
+// the flush interval elapses, and on close.
+type writeBuffer struct {
+	mu     sync.Mutex
+	buf    []byte
+	out    io.Writer // output of the buffered records
+	size   int
+	closed bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newWriteBuffer creates a writeBuffer of the given size and starts its flusher.
+func newWriteBuffer(size int, interval time.Duration) *writeBuffer {
+	b := &writeBuffer{
+		buf:  make([]byte, 0, size),
+		size: size,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go b.flushLoop(interval)
+
+	return b
+}
+
+// flushLoop periodically writes the buffered records until close is called.
+func (b *writeBuffer) flushLoop(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.mu.Lock()
+			b.flushLocked()
+			b.mu.Unlock()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// write appends a record to be written to out. The buffered records are written first
+// if out is not the output they were buffered for, as when a derived logger writes to
+// another output. After close, the record is written synchronously.
+func (b *writeBuffer) write(out io.Writer, record []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		out.Write(record)
+
+		return
+	}
+
+	if !sameWriter(out, b.out) {
+		b.flushLocked()
+		b.out = out
+	}
+
+	b.buf = append(b.buf, record...)
+
+	if len(b.buf) >= b.size {
+		b.flushLocked()
+	}
+}
+
+// flushLocked writes the buffered records to their output. Write errors are ignored,
+// as they are for unbuffered records.
+func (b *writeBuffer) flushLocked() {
+	if len(b.buf) == 0 {
+		return
+	}
+
+	b.out.Write(b.buf)
+	b.buf = b.buf[:0]
+}
+
+// close stops the flusher and writes the buffered records. Calling it more than once
+// is a no-op.
+func (b *writeBuffer) close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+
+		return
+	}
+	b.closed = true
+	b.flushLocked()
+	b.mu.Unlock()
+
+	close(b.stop)
+	<-b.done
+}
+
+// bufferedOutput is the output of a logger with a write buffer: it appends the records
+// written to it to the buffer for out.
+type bufferedOutput struct {
+	buffer *writeBuffer
+	out    io.Writer
+}
+
+// Write implements io.Writer.
+func (o bufferedOutput) Write(p []byte) (int, error) {
+	o.buffer.write(o.out, p)
+
+	return len(p), nil
+}
+
+// sameWriter reports whether a and b are the same writer. Writers of an uncomparable
+// type are never the same, so that comparing them does not panic.
+func sameWriter(a, b io.Writer) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if t := reflect.TypeOf(a); t != reflect.TypeOf(b) || !t.Comparable() {
+		return false
+	}
+
+	return a == b
+}